@@ -5,5 +5,7 @@ go 1.21.3
 require (
 	github.com/go-zookeeper/zk v1.0.2
 	github.com/lib/pq v1.4.0
+	golang.org/x/crypto v0.17.0
 )
 
+require golang.org/x/sys v0.15.0 // indirect
@@ -16,6 +16,7 @@ import (
 	"github.com/Dobryvechir/microcore/pkg/dvcontext"
 	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
 	"github.com/Dobryvechir/microcore/pkg/dvlog"
+	"github.com/Dobryvechir/microcore/pkg/dvmodules"
 	"github.com/Dobryvechir/microcore/pkg/dvparser"
 	"github.com/Dobryvechir/microcore/pkg/dvsecurity"
 )
@@ -46,7 +47,9 @@ func fireActionByName(ctx *dvcontext.RequestContext, name string,
 		ctx.HandleCommunication()
 		return true
 	}
+	session := startDebugSession(ctx, prefix)
 	res := ExecuteSequence(prefix, ctx, definitions)
+	session.finish()
 	if !omitResults {
 		ActionProcessResult(ctx, res)
 	}
@@ -188,6 +191,51 @@ func securityEndPointHandler(ctx *dvcontext.RequestContext) bool {
 	return res
 }
 
+func securityRevokeEndPointHandler(ctx *dvcontext.RequestContext) bool {
+	res := dvsecurity.RevokeByRequestEndPointHandler(ctx)
+	if res {
+		ActionContextResult(ctx)
+	}
+	return res
+}
+
+func apiKeyCreateEndPointHandler(ctx *dvcontext.RequestContext) bool {
+	res := dvsecurity.ApiKeyCreateByRequestEndPointHandler(ctx)
+	if res {
+		ActionContextResult(ctx)
+	}
+	return res
+}
+
+func apiKeyListEndPointHandler(ctx *dvcontext.RequestContext) bool {
+	res := dvsecurity.ApiKeyListByRequestEndPointHandler(ctx)
+	if res {
+		ActionContextResult(ctx)
+	}
+	return res
+}
+
+func apiKeyRevokeEndPointHandler(ctx *dvcontext.RequestContext) bool {
+	res := dvsecurity.ApiKeyRevokeByRequestEndPointHandler(ctx)
+	if res {
+		ActionContextResult(ctx)
+	}
+	return res
+}
+
+// userEndPointHandler executes the user request and then the steps of the action if they are defined,
+// e.g. to send the password reset token by email
+func userEndPointHandler(handler dvmodules.ActionEndPointHandler) dvmodules.ActionEndPointHandler {
+	return func(ctx *dvcontext.RequestContext) bool {
+		if handler(ctx) && ctx.Action != nil &&
+			ctx.PrimaryContextEnvironment.GetString(ActionPrefix+ctx.Action.Name+"_1") != "" {
+			return fireActionByName(ctx, ctx.Action.Name, ctx.Action.Definitions, false)
+		}
+		ActionContextResult(ctx)
+		return true
+	}
+}
+
 func GetEnvironment(ctx *dvcontext.RequestContext) *dvevaluation.DvObject {
 	return ctx.GetEnvironment()
 }
@@ -0,0 +1,497 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvaction
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
+	"github.com/Dobryvechir/microcore/pkg/dvlog"
+)
+
+const (
+	DebugModeContinue = iota
+	DebugModeStepOver
+	DebugModeStepInto
+)
+
+const (
+	DebugCommandContinue = "continue"
+	DebugCommandStepOver = "step"
+	DebugCommandStepInto = "stepin"
+	DebugCommandEnv      = "env"
+	DebugCommandEvaluate = "evaluate"
+)
+
+const (
+	DefaultDebugListen       = "127.0.0.1:9339"
+	DefaultDebugHeader       = "X-Debug"
+	DefaultDebugPauseTimeout = 600
+)
+
+// DebuggerConfig starts the debugger of the action sequences and scripts; the breakpoints are
+// either the step names like ACTION_name_3 or the script lines like file.js:12;
+// PauseTimeout is the number of seconds, after which the paused request is continued without debugging
+type DebuggerConfig struct {
+	Listen       string   `json:"listen"`
+	Header       string   `json:"header"`
+	Breakpoints  []string `json:"breakpoints"`
+	PauseTimeout int      `json:"pause_timeout"`
+}
+
+// DebugLocation is the step of the action sequence or the script statement, where the request is paused
+type DebugLocation struct {
+	Kind    string `json:"kind"`
+	Step    string `json:"step,omitempty"`
+	Command string `json:"command,omitempty"`
+	Place   string `json:"place,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Level   int    `json:"level"`
+	depth   int
+}
+
+type DebugSessionInfo struct {
+	Id       int64          `json:"id"`
+	Action   string         `json:"action"`
+	Url      string         `json:"url"`
+	Started  time.Time      `json:"started"`
+	Paused   bool           `json:"paused"`
+	Location *DebugLocation `json:"location,omitempty"`
+}
+
+type debugCommand struct {
+	kind       string
+	expression string
+	reply      chan interface{}
+}
+
+// DebugSession is the debugged request; it is kept by the environment of the request,
+// so the scripts evaluated for the request stop at the breakpoints as well
+type DebugSession struct {
+	Id       int64
+	Action   string
+	Url      string
+	Started  time.Time
+	ctx      *dvcontext.RequestContext
+	debugger *debugger
+	mu       sync.Mutex
+	pauseMu  sync.Mutex
+	mode     int
+	depth    int
+	level    int
+	detached bool
+	location *DebugLocation
+	scope    dvgrammar.ScopeInterface
+	commands chan *debugCommand
+}
+
+type scriptBreakpoint struct {
+	file string
+	line int
+}
+
+type debugger struct {
+	config      DebuggerConfig
+	mu          sync.Mutex
+	sessions    map[int64]*DebugSession
+	breakpoints map[string]bool
+	scriptLines []scriptBreakpoint
+}
+
+var activeDebugger *debugger
+var debugSessionId int64
+
+func newDebugger(config *DebuggerConfig) *debugger {
+	d := &debugger{
+		config:      *config,
+		sessions:    make(map[int64]*DebugSession),
+		breakpoints: make(map[string]bool),
+	}
+	if d.config.Listen == "" {
+		d.config.Listen = DefaultDebugListen
+	}
+	if d.config.Header == "" {
+		d.config.Header = DefaultDebugHeader
+	}
+	if d.config.PauseTimeout <= 0 {
+		d.config.PauseTimeout = DefaultDebugPauseTimeout
+	}
+	d.addBreakpoints(config.Breakpoints)
+	return d
+}
+
+func parseScriptBreakpoint(breakpoint string) (scriptBreakpoint, bool) {
+	pos := strings.LastIndex(breakpoint, ":")
+	if pos <= 0 {
+		return scriptBreakpoint{}, false
+	}
+	line, err := strconv.Atoi(breakpoint[pos+1:])
+	if err != nil || line <= 0 {
+		return scriptBreakpoint{}, false
+	}
+	return scriptBreakpoint{file: filepath.ToSlash(breakpoint[:pos]), line: line}, true
+}
+
+func (d *debugger) addBreakpoints(breakpoints []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, breakpoint := range breakpoints {
+		breakpoint = strings.TrimSpace(breakpoint)
+		if breakpoint != "" {
+			d.breakpoints[breakpoint] = true
+		}
+	}
+	d.updateScriptLines()
+}
+
+// removeBreakpoints removes the listed breakpoints or all of them if the list is empty
+func (d *debugger) removeBreakpoints(breakpoints []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(breakpoints) == 0 {
+		d.breakpoints = make(map[string]bool)
+	}
+	for _, breakpoint := range breakpoints {
+		delete(d.breakpoints, strings.TrimSpace(breakpoint))
+	}
+	d.updateScriptLines()
+}
+
+func (d *debugger) updateScriptLines() {
+	d.scriptLines = nil
+	for breakpoint := range d.breakpoints {
+		if line, ok := parseScriptBreakpoint(breakpoint); ok {
+			d.scriptLines = append(d.scriptLines, line)
+		}
+	}
+}
+
+func (d *debugger) getBreakpoints() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res := make([]string, 0, len(d.breakpoints))
+	for breakpoint := range d.breakpoints {
+		res = append(res, breakpoint)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (d *debugger) isStepBreakpoint(step string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.breakpoints[step]
+}
+
+// isScriptBreakpoint matches the place of the script by its full name or by the ending of its path
+func (d *debugger) isScriptBreakpoint(place string, line int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	place = filepath.ToSlash(place)
+	for _, breakpoint := range d.scriptLines {
+		if breakpoint.line == line && (place == breakpoint.file || strings.HasSuffix(place, "/"+breakpoint.file)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *debugger) getSession(id int64) *DebugSession {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.sessions[id]
+}
+
+func (d *debugger) getSessions() []*DebugSession {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res := make([]*DebugSession, 0, len(d.sessions))
+	for _, session := range d.sessions {
+		res = append(res, session)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Id < res[j].Id })
+	return res
+}
+
+// isDebugRequested checks whether the action or the request header asks for the debugging
+func (d *debugger) isDebugRequested(ctx *dvcontext.RequestContext) bool {
+	if ctx.Action != nil && ctx.Action.Debug {
+		return true
+	}
+	if ctx.Reader == nil {
+		return false
+	}
+	v := strings.ToLower(strings.TrimSpace(ctx.Reader.Header.Get(d.config.Header)))
+	return v != "" && v != "0" && v != "false" && v != "off"
+}
+
+// startDebugSession returns nil unless the debugger is started and the debugging is requested
+func startDebugSession(ctx *dvcontext.RequestContext, name string) *DebugSession {
+	d := activeDebugger
+	if d == nil || ctx == nil || ctx.PrimaryContextEnvironment == nil || ctx.PrimaryContextEnvironment.Debug != nil || !d.isDebugRequested(ctx) {
+		return nil
+	}
+	session := &DebugSession{
+		Id:       atomic.AddInt64(&debugSessionId, 1),
+		Action:   name,
+		Url:      ctx.Url,
+		Started:  time.Now(),
+		ctx:      ctx,
+		debugger: d,
+		commands: make(chan *debugCommand),
+	}
+	d.mu.Lock()
+	d.sessions[session.Id] = session
+	d.mu.Unlock()
+	ctx.PrimaryContextEnvironment.Debug = session
+	if Log >= dvlog.LogInfo {
+		dvlog.PrintfFullOnly("Debug session %d is started for %s", session.Id, name)
+	}
+	return session
+}
+
+func (session *DebugSession) finish() {
+	if session == nil {
+		return
+	}
+	d := session.debugger
+	d.mu.Lock()
+	delete(d.sessions, session.Id)
+	d.mu.Unlock()
+	session.ctx.PrimaryContextEnvironment.Debug = nil
+}
+
+func getDebugSession(ctx *dvcontext.RequestContext) *DebugSession {
+	if ctx == nil || ctx.PrimaryContextEnvironment == nil {
+		return nil
+	}
+	session, _ := ctx.PrimaryContextEnvironment.Debug.(*DebugSession)
+	return session
+}
+
+func (session *DebugSession) Info() *DebugSessionInfo {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return &DebugSessionInfo{
+		Id:       session.Id,
+		Action:   session.Action,
+		Url:      session.Url,
+		Started:  session.Started,
+		Paused:   session.location != nil,
+		Location: session.location,
+	}
+}
+
+// shouldPause returns whether the step or the statement at the depth stops the execution;
+// the depth is the level of the sequence for the steps and is deeper by one plus the call depth for the scripts
+func (session *DebugSession) shouldPause(depth int, breakpoint func() bool) bool {
+	session.mu.Lock()
+	mode, modeDepth, detached := session.mode, session.depth, session.detached
+	session.mu.Unlock()
+	if detached {
+		return false
+	}
+	switch mode {
+	case DebugModeStepInto:
+		return true
+	case DebugModeStepOver:
+		if depth <= modeDepth {
+			return true
+		}
+	}
+	return breakpoint()
+}
+
+// beforeStep is called before the step of the action sequence is executed
+func (session *DebugSession) beforeStep(step string, command string, level int) {
+	session.mu.Lock()
+	session.level = level
+	session.mu.Unlock()
+	if session.shouldPause(level, func() bool { return session.debugger.isStepBreakpoint(step) }) {
+		session.pause(&DebugLocation{Kind: "action", Step: step, Command: command, Level: level, depth: level}, nil)
+	}
+}
+
+// BeforeStatement is called before each statement of the scripts evaluated for the request
+func (session *DebugSession) BeforeStatement(node *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) error {
+	session.mu.Lock()
+	level := session.level
+	session.mu.Unlock()
+	depth := level + 1 + context.Depth
+	location := &DebugLocation{Kind: "script", Level: level, depth: depth}
+	token := node.FirstToken()
+	if token != nil {
+		location.Place = token.Place
+		location.Line = token.Row
+		location.Column = token.Column
+	}
+	if location.Place == "" && context.Reference != nil {
+		location.Place = context.Reference.Place
+	}
+	if session.shouldPause(depth, func() bool { return session.debugger.isScriptBreakpoint(location.Place, location.Line) }) {
+		session.pause(location, context.Scope)
+	}
+	return nil
+}
+
+// pause blocks the execution until the debugger continues it; the other goroutines of the request
+// wait for the end of the pause, if they reach a breakpoint
+func (session *DebugSession) pause(location *DebugLocation, scope dvgrammar.ScopeInterface) {
+	session.pauseMu.Lock()
+	defer session.pauseMu.Unlock()
+	session.mu.Lock()
+	if session.detached {
+		session.mu.Unlock()
+		return
+	}
+	session.location = location
+	session.scope = scope
+	session.mu.Unlock()
+	timer := time.NewTimer(time.Duration(session.debugger.config.PauseTimeout) * time.Second)
+	defer timer.Stop()
+	var done <-chan struct{}
+	if session.ctx.Reader != nil {
+		done = session.ctx.Reader.Context().Done()
+	}
+	for {
+		select {
+		case command := <-session.commands:
+			switch command.kind {
+			case DebugCommandEnv:
+				command.reply <- session.environment()
+				continue
+			case DebugCommandEvaluate:
+				command.reply <- session.evaluate(command.expression)
+				continue
+			}
+			session.resume(command.kind, location.depth)
+			command.reply <- session.Info()
+			return
+		case <-timer.C:
+			dvlog.PrintfError("Debug session %d was paused longer than %d seconds, it is continued without debugging", session.Id, session.debugger.config.PauseTimeout)
+			session.detach()
+			return
+		case <-done:
+			session.detach()
+			return
+		}
+	}
+}
+
+func (session *DebugSession) resume(kind string, depth int) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	switch kind {
+	case DebugCommandStepOver:
+		session.mode = DebugModeStepOver
+	case DebugCommandStepInto:
+		session.mode = DebugModeStepInto
+	default:
+		session.mode = DebugModeContinue
+	}
+	session.depth = depth
+	session.location = nil
+	session.scope = nil
+}
+
+func (session *DebugSession) detach() {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.detached = true
+	session.location = nil
+	session.scope = nil
+}
+
+// execute passes the command to the paused request and returns its reply;
+// false is returned if the request is not paused
+func (session *DebugSession) execute(kind string, expression string) (interface{}, bool) {
+	command := &debugCommand{kind: kind, expression: expression, reply: make(chan interface{}, 1)}
+	session.mu.Lock()
+	paused := session.location != nil
+	session.mu.Unlock()
+	if !paused {
+		return nil, false
+	}
+	select {
+	case session.commands <- command:
+		return <-command.reply, true
+	case <-time.After(time.Second):
+		return nil, false
+	}
+}
+
+func debugValue(v interface{}) json.RawMessage {
+	if v == nil {
+		return json.RawMessage("null")
+	}
+	if variable := dvevaluation.AnyToDvVariable(v); variable != nil {
+		if data := variable.JsonStringify(); json.Valid(data) {
+			return data
+		}
+	}
+	data, _ := json.Marshal(dvevaluation.AnyToString(v))
+	return data
+}
+
+func debugProperties(levels []*dvevaluation.DvObject) map[string]json.RawMessage {
+	res := make(map[string]json.RawMessage)
+	for _, level := range levels {
+		for k, v := range level.Properties {
+			if _, ok := res[k]; !ok {
+				res[k] = debugValue(v)
+			}
+		}
+	}
+	return res
+}
+
+// environment returns the variables of the script scope (if paused in a script),
+// of the sub-sequences and of the request
+func (session *DebugSession) environment() interface{} {
+	ctx := session.ctx
+	res := make(map[string]interface{})
+	if stack, ok := session.scope.(*dvevaluation.ObjectStack); ok {
+		var levels []*dvevaluation.DvObject
+		for level := stack.CurrentLevel; level != nil && level != stack.BaseLevel; level = level.Prototype {
+			levels = append(levels, level)
+		}
+		res["scope"] = debugProperties(levels)
+	}
+	var levels []*dvevaluation.DvObject
+	for level := ctx.LocalContextEnvironment; level != nil && level != ctx.PrimaryContextEnvironment; level = level.Prototype {
+		levels = append(levels, level)
+	}
+	res["local"] = debugProperties(levels)
+	res["request"] = debugProperties([]*dvevaluation.DvObject{ctx.PrimaryContextEnvironment})
+	return res
+}
+
+// evaluate calculates the expression in the scope of the paused script or step, the evaluation is not debugged
+func (session *DebugSession) evaluate(expression string) interface{} {
+	scope := session.scope
+	if scope == nil {
+		scope = dvevaluation.NewObjectStack(GetEnvironment(session.ctx))
+	}
+	value, err := dvevaluation.CalculatorEvaluator([]byte(expression), scope, &dvgrammar.SourceReference{Place: "debug"}, 0)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	var v interface{}
+	if value != nil {
+		v = value.Value
+	}
+	return map[string]interface{}{"result": debugValue(v)}
+}
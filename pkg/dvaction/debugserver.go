@@ -0,0 +1,172 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvaction
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Dobryvechir/microcore/pkg/dvlog"
+)
+
+// DebugBreakpointsRequest is the body of POST and DELETE /breakpoints
+type DebugBreakpointsRequest struct {
+	Breakpoints []string `json:"breakpoints"`
+}
+
+// DebugEvaluateRequest is the body of POST /sessions/{id}/evaluate
+type DebugEvaluateRequest struct {
+	Expression string `json:"expression"`
+}
+
+// StartDebugger starts the local http endpoint of the debugger:
+//   GET /sessions, GET /sessions/{id} list the debugged requests and where they are paused
+//   GET /sessions/{id}/env shows the variables of the paused request
+//   POST /sessions/{id}/evaluate calculates the expression in the scope of the paused request
+//   POST /sessions/{id}/continue, /step, /stepin continue, step over or step into the call: sub-sequences and functions
+//   GET, POST, DELETE /breakpoints list, add or remove the breakpoints
+func StartDebugger(config *DebuggerConfig) error {
+	d := newDebugger(config)
+	listener, err := net.Listen("tcp", d.config.Listen)
+	if err != nil {
+		return err
+	}
+	d.config.Listen = listener.Addr().String()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sessions", d.handleSessions)
+	mux.HandleFunc("/sessions/", d.handleSession)
+	mux.HandleFunc("/breakpoints", d.handleBreakpoints)
+	activeDebugger = d
+	dvlog.PrintfError("Debugger is listening at %s, debug mode is requested by %s header", d.config.Listen, d.config.Header)
+	go func() {
+		err := http.Serve(listener, mux)
+		dvlog.PrintfError("Debugger is stopped: %v", err)
+	}()
+	return nil
+}
+
+// GetDebuggerAddress returns the address of the started debugger or empty string
+func GetDebuggerAddress() string {
+	if activeDebugger == nil {
+		return ""
+	}
+	return activeDebugger.config.Listen
+}
+
+func writeDebugResponse(w http.ResponseWriter, status int, data interface{}) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		status = http.StatusInternalServerError
+		body, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func writeDebugError(w http.ResponseWriter, status int, message string) {
+	writeDebugResponse(w, status, map[string]string{"error": message})
+}
+
+func readDebugRequest(r *http.Request, data interface{}) error {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, data)
+}
+
+func (d *debugger) handleSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeDebugError(w, http.StatusMethodNotAllowed, "Method "+r.Method+" is not allowed")
+		return
+	}
+	sessions := d.getSessions()
+	res := make([]*DebugSessionInfo, len(sessions))
+	for i, session := range sessions {
+		res[i] = session.Info()
+	}
+	writeDebugResponse(w, http.StatusOK, res)
+}
+
+func (d *debugger) handleSession(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/sessions/"), "/")
+	command := ""
+	if pos := strings.Index(path, "/"); pos >= 0 {
+		command = path[pos+1:]
+		path = path[:pos]
+	}
+	id, err := strconv.ParseInt(path, 10, 64)
+	session := d.getSession(id)
+	if err != nil || session == nil {
+		writeDebugError(w, http.StatusNotFound, "Debug session "+path+" is not found")
+		return
+	}
+	expectedMethod := http.MethodPost
+	if command == "" || command == DebugCommandEnv {
+		expectedMethod = http.MethodGet
+	}
+	if r.Method != expectedMethod {
+		writeDebugError(w, http.StatusMethodNotAllowed, "Method "+r.Method+" is not allowed")
+		return
+	}
+	expression := ""
+	switch command {
+	case "":
+		writeDebugResponse(w, http.StatusOK, session.Info())
+		return
+	case DebugCommandEvaluate:
+		request := &DebugEvaluateRequest{}
+		err = readDebugRequest(r, request)
+		if err == nil && request.Expression == "" {
+			err = errors.New("expression is not specified")
+		}
+		if err != nil {
+			writeDebugError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		expression = request.Expression
+	case DebugCommandEnv, DebugCommandContinue, DebugCommandStepOver, DebugCommandStepInto:
+	default:
+		writeDebugError(w, http.StatusNotFound, "Unknown debug command "+command)
+		return
+	}
+	res, ok := session.execute(command, expression)
+	if !ok {
+		writeDebugError(w, http.StatusConflict, "Debug session "+path+" is not paused")
+		return
+	}
+	writeDebugResponse(w, http.StatusOK, res)
+}
+
+func (d *debugger) handleBreakpoints(w http.ResponseWriter, r *http.Request) {
+	request := &DebugBreakpointsRequest{}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodDelete:
+		if err := readDebugRequest(r, request); err != nil {
+			writeDebugError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if r.Method == http.MethodPost {
+			d.addBreakpoints(request.Breakpoints)
+		} else {
+			d.removeBreakpoints(request.Breakpoints)
+		}
+	default:
+		writeDebugError(w, http.StatusMethodNotAllowed, "Method "+r.Method+" is not allowed")
+		return
+	}
+	writeDebugResponse(w, http.StatusOK, &DebugBreakpointsRequest{Breakpoints: d.getBreakpoints()})
+}
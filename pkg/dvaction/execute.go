@@ -13,6 +13,7 @@ import (
 	"github.com/Dobryvechir/microcore/pkg/dvmodules"
 	"github.com/Dobryvechir/microcore/pkg/dvparser"
 	"github.com/Dobryvechir/microcore/pkg/dvtextutils"
+	"github.com/Dobryvechir/microcore/pkg/dvuser"
 	"strconv"
 	"strings"
 	"sync"
@@ -50,6 +51,7 @@ const (
 	CommandRecordScan    = "recordscan"
 	CommandRecordUpdate  = "recordupdate"
 	CommandReturn        = "return"
+	CommandScriptCache   = "scriptcache"
 	CommandStore         = "store"
 	CommandSql           = "sql"
 	CommandSwitch        = "switch"
@@ -77,6 +79,7 @@ var processFunctions = map[string]ProcessFunction{
 	CommandRecordReadOne: {Init: recordReadOneInit, Run: recordReadOneRun},
 	CommandRecordScan:    {Init: recordScanInit, Run: recordScanRun},
 	CommandRecordUpdate:  {Init: recordUpdateInit, Run: recordUpdateRun},
+	CommandScriptCache:   {Init: scriptCacheInit, Run: scriptCacheRun},
 	CommandSql:           {Init: dvdbdata.SqlInit, Run: dvdbdata.SqlRun},
 	CommandStore:         {Init: storeInit, Run: storeRun},
 	CommandValidate:      {Init: validationInit, Run: validationRun},
@@ -281,6 +284,7 @@ func ExecuteSequence(startActionName string, ctx *dvcontext.RequestContext, init
 			Id:                        dvcontext.GetUniqueId(),
 			PrimaryContextEnvironment: dvparser.GetGlobalPropertiesAsDvObject(),
 		}
+		defer ctx.RollbackTransactions("the sequence " + startActionName + " is finished without commit")
 	}
 	debug, ok := ctx.PrimaryContextEnvironment.Get(startActionName + "_LOG")
 	if ok {
@@ -298,7 +302,11 @@ func ExecuteSequence(startActionName string, ctx *dvcontext.RequestContext, init
 		dvlog.Printf(startActionName, "ExecuteSequence %s\n", startActionName)
 	}
 	pushSubsequence(ctx, startActionName, ExSeqReturnAllDefaultNames, initialParams, 0)
-	return ExecuteSequenceCycle(ctx, 0)
+	res := ExecuteSequenceCycle(ctx, 0)
+	if !res {
+		ctx.RollbackTransactions("the sequence " + startActionName + " failed")
+	}
+	return res
 }
 
 func ExecuteSequenceCycle(ctx *dvcontext.RequestContext, cycleLevel int) bool {
@@ -319,6 +327,7 @@ func ExecuteSequenceCycle(ctx *dvcontext.RequestContext, cycleLevel int) bool {
 			}
 		}
 	}
+	session := getDebugSession(ctx)
 	for true {
 		level := ctx.PrimaryContextEnvironment.GetInt(ExSeqLevel)
 		if level < cycleLevel {
@@ -339,6 +348,9 @@ func ExecuteSequenceCycle(ctx *dvcontext.RequestContext, cycleLevel int) bool {
 			ExecuteReturnSubsequence(ctx, ExSeqReturnSingleDefault)
 			continue
 		}
+		if session != nil {
+			session.beforeStep(p, waitCommandRaw, level)
+		}
 		debugList := ""
 		if debugMode >= 0 {
 			dvlog.Printf(p, "Executing %s %s", p, waitCommandRaw)
@@ -477,6 +489,16 @@ func RegisterOC() bool {
 	dvmodules.RegisterActionProcessor("static", fireStaticAction, false)
 	dvmodules.RegisterActionProcessor("switch", fireSwitchAction, false)
 	dvmodules.RegisterActionProcessor("security", securityEndPointHandler, false)
+	dvmodules.RegisterActionProcessor("security_revoke", securityRevokeEndPointHandler, false)
+	dvmodules.RegisterActionProcessor("apikey_create", apiKeyCreateEndPointHandler, false)
+	dvmodules.RegisterActionProcessor("apikey_list", apiKeyListEndPointHandler, false)
+	dvmodules.RegisterActionProcessor("apikey_revoke", apiKeyRevokeEndPointHandler, false)
+	dvmodules.RegisterActionProcessor("user_register", userEndPointHandler(dvuser.RegisterByRequestEndPointHandler), false)
+	dvmodules.RegisterActionProcessor("user_password", userEndPointHandler(dvuser.PasswordByRequestEndPointHandler), false)
+	dvmodules.RegisterActionProcessor("user_reset_request", userEndPointHandler(dvuser.ResetRequestByRequestEndPointHandler), false)
+	dvmodules.RegisterActionProcessor("user_reset", userEndPointHandler(dvuser.ResetByRequestEndPointHandler), false)
+	dvmodules.RegisterActionProcessor("user_login", userEndPointHandler(dvuser.LoginByRequestEndPointHandler), false)
+	dvmodules.RegisterActionProcessor("user_logout", userEndPointHandler(dvuser.LogoutByRequestEndPointHandler), false)
 	dvmodules.RegisterActionProcessor("sse", fireSseAction, false)
 	return dvmodules.SubscribeForEvents(ocExecutorRegistrationConfig, false)
 }
@@ -7,7 +7,6 @@ package dvaction
 
 import (
 	"github.com/Dobryvechir/microcore/pkg/dvcontext"
-	"io/ioutil"
 	"log"
 )
 
@@ -42,12 +41,7 @@ func jsRun(data []interface{}) bool {
 }
 
 func JsRunByConfig(config *JsConfig, ctx *dvcontext.RequestContext) bool {
-	data, err := ioutil.ReadFile(config.File)
-	if err != nil {
-		message := "Error in reading " + config.File + ": " + err.Error()
-		ActionInternalException(500, message, message, ctx)
-	}
-	v, err := GetEnvironment(ctx).EvaluateAnyTypeExpression(string(data))
+	v, err := GetEnvironment(ctx).EvaluateScriptFile(config.File)
 	if err != nil {
 		message := "Error in reading " + config.File + ": " + err.Error()
 		ActionInternalException(500, message, message, ctx)
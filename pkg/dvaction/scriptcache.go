@@ -0,0 +1,56 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvaction
+
+import (
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
+	"log"
+)
+
+// ScriptCacheConfig saves the statistics of the compiled script cache (hits, misses, evictions, size, capacity)
+// to the result; the cache is cleared when clear is true
+type ScriptCacheConfig struct {
+	Result string `json:"result"`
+	Clear  bool   `json:"clear"`
+}
+
+func scriptCacheInit(command string, ctx *dvcontext.RequestContext) ([]interface{}, bool) {
+	config := &ScriptCacheConfig{}
+	r, s := DefaultOrSimpleInitWithObject(command, config, GetEnvironment(ctx))
+	if !r {
+		return nil, false
+	}
+	if s != "" {
+		config.Result = s
+	}
+	if config.Result == "" && !config.Clear {
+		log.Printf("scriptcache.result must be specified in %s", command)
+		return nil, false
+	}
+	return []interface{}{config, ctx}, true
+}
+
+func scriptCacheRun(data []interface{}) bool {
+	config := data[0].(*ScriptCacheConfig)
+	var ctx *dvcontext.RequestContext = nil
+	if data[1] != nil {
+		ctx = data[1].(*dvcontext.RequestContext)
+	}
+	if config.Result != "" {
+		stats, err := dvevaluation.AnyStructToDvVariable(dvgrammar.CompiledScripts.Stats())
+		if err != nil {
+			ActionInternalException(500, err.Error(), err.Error(), ctx)
+			return true
+		}
+		SaveActionResult(config.Result, stats, ctx)
+	}
+	if config.Clear {
+		dvgrammar.CompiledScripts.Clear()
+	}
+	return true
+}
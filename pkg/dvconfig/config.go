@@ -15,8 +15,10 @@ import (
 
 	"github.com/Dobryvechir/microcore/pkg/dvtextutils"
 
+	"github.com/Dobryvechir/microcore/pkg/dvaction"
 	"github.com/Dobryvechir/microcore/pkg/dvcom"
 	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
 	"github.com/Dobryvechir/microcore/pkg/dvjsmaster"
 	"github.com/Dobryvechir/microcore/pkg/dvjson"
 	"github.com/Dobryvechir/microcore/pkg/dvlog"
@@ -123,6 +125,9 @@ type DvConfig struct {
 	Providers          map[string]map[string]string  `json:"providers"`
 	ParallelProcessing *dvcontext.ParallelProcessing `json:"parallel_processing"`
 	Dbs                []*dvcontext.DatabaseConfig   `json:"dbs"`
+	ScriptLimits       *dvgrammar.ExecutionLimits    `json:"script_limits"`
+	ScriptCacheSize    int                           `json:"script_cache_size"`
+	Debugger           *dvaction.DebuggerConfig      `json:"debugger"`
 }
 
 // CurrentDir is a current folder where the application started
@@ -6,9 +6,13 @@ Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@
 package dvconfig
 
 import (
+	"bufio"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -18,12 +22,15 @@ import (
 
 	"github.com/Dobryvechir/microcore/pkg/dvcom"
 	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvdbdata"
 	"github.com/Dobryvechir/microcore/pkg/dvdbmanager"
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
 	"github.com/Dobryvechir/microcore/pkg/dvlog"
 	"github.com/Dobryvechir/microcore/pkg/dvmodules"
 	"github.com/Dobryvechir/microcore/pkg/dvparser"
 	"github.com/Dobryvechir/microcore/pkg/dvprocessors"
 	"github.com/Dobryvechir/microcore/pkg/dvproviders"
+	"github.com/Dobryvechir/microcore/pkg/dvsecurity"
 )
 
 // ServerStart starts http server, the config and properties are read from the current folder or by other options
@@ -45,7 +52,13 @@ func ServerStart() {
 	case "finish":
 		dvcom.ProcessHosts(cf.Hosts, true)
 		dvcom.ResolveAdministrativeTasks()
+	case "hashpassword":
+		executeHashPassword(osargs2)
 	case "execute":
+		if strings.ToLower(osargs2) == "migrate" {
+			executeMigrate(args[2:])
+			return
+		}
 		if osargs2 == "" {
 			osargs2 = "SERVER"
 		} else {
@@ -71,6 +84,46 @@ func ServerStart() {
 	}
 }
 
+// executeMigrate runs execute migrate status|up|down|unlock [count]
+func executeMigrate(args []string) {
+	command := ""
+	count := 0
+	if len(args) > 0 {
+		command = args[0]
+	}
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			log.Printf("Migration count must be a number, not %s", args[1])
+			return
+		}
+		count = n
+	}
+	err := dvdbdata.Migrate(dvparser.GlobalProperties, command, count)
+	if err != nil {
+		log.Printf("Migration failed: %v", err)
+	}
+}
+
+// executeHashPassword prints the hash of the password (read from the standard input if it is not specified)
+// for the users and the clients of the oauth store
+func executeHashPassword(password string) {
+	if password == "" {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			log.Printf("Password expected: %v", err)
+			return
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	hash, err := dvsecurity.HashPassword(password)
+	if err != nil {
+		log.Printf("Password cannot be hashed: %v", err)
+		return
+	}
+	fmt.Println(hash)
+}
+
 // ProvideServerCommand registers the http server as server for command execution purposes
 func ProvideServerCommand() {
 	dvaction.AddProcessFunction("server", dvaction.ProcessFunction{
@@ -115,6 +168,18 @@ func serverStartByConfigDirect(cf *DvConfig) {
 	dvlog.StartingLogFile()
 	dvcom.ProcessHosts(cf.Hosts, false)
 	dvssews.SetParallelProcessingParameters(cf.ParallelProcessing)
+	if cf.ScriptLimits != nil {
+		dvgrammar.DefaultExecutionLimits = dvgrammar.DefaultExecutionLimits.Merge(cf.ScriptLimits)
+	}
+	if cf.ScriptCacheSize != 0 {
+		dvgrammar.CompiledScripts.SetCapacity(cf.ScriptCacheSize)
+	}
+	if cf.Debugger != nil {
+		err := dvaction.StartDebugger(cf.Debugger)
+		if err != nil {
+			log.Printf("Cannot start debugger: %v", err)
+		}
+	}
 	dvmodules.MakeModuleGlobalInitialization(cf.Modules)
 	dvmodules.MakeHookGlobalInitialization(cf.Hooks)
 	dvprocessors.MakeProcessorGlobalInitialization(cf.Processors)
@@ -4,6 +4,10 @@ MicroCore Copyright 2020 - 2024 by Danyil Dobryvechir (dobrivecher@yahoo.com ddo
 
 package dvcontext
 
+import (
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
+)
+
 type DvAction struct {
 	Name        string                `json:"name"`
 	Typ         string                `json:"type"`
@@ -22,7 +26,12 @@ type DvAction struct {
 	Session     *SessionActionRequest `json:"session"`
 	Roles       string                `json:"roles"`
 	Auth        string                `json:"auth"`
+	Policy      string                `json:"policy"`
 	SseWs       *SSEWSControl         `json:"sse_ws"`
+	// Limits override the global limits of the scripts evaluated by the action
+	Limits *dvgrammar.ExecutionLimits `json:"limits"`
+	// Debug makes the action stop at the breakpoints of the debugger, if it is started
+	Debug bool `json:"debug"`
 }
 
 type Stage struct {
@@ -68,5 +77,8 @@ func (action *DvAction) CloneFrom(other *DvAction) {
 	action.Session = other.Session
 	action.Roles = other.Roles
 	action.Auth = other.Auth
+	action.Policy = other.Policy
 	action.SseWs = other.SseWs
+	action.Limits = other.Limits
+	action.Debug = other.Debug
 }
@@ -8,6 +8,7 @@ package dvcontext
 import (
 	"errors"
 	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
 	"github.com/Dobryvechir/microcore/pkg/dvparser"
 	"log"
 )
@@ -82,6 +83,20 @@ func (ctx *RequestContext) GetEnvironment() *dvevaluation.DvObject {
 	return ctx.PrimaryContextEnvironment
 }
 
+// ApplyExecutionLimits restricts the scripts evaluated for the request by the limits of the action
+// and stops them, when the request is cancelled
+func (ctx *RequestContext) ApplyExecutionLimits(action *DvAction) {
+	if ctx.PrimaryContextEnvironment == nil {
+		return
+	}
+	if action != nil && action.Limits != nil {
+		ctx.PrimaryContextEnvironment.Limits = dvgrammar.DefaultExecutionLimits.Merge(action.Limits)
+	}
+	if ctx.Reader != nil {
+		ctx.PrimaryContextEnvironment.Done = ctx.Reader.Context()
+	}
+}
+
 func (ctx *RequestContext) StoreStoringSession() {
 	if ctx.Session != nil && ctx.PrimaryContextEnvironment != nil {
 		ctx.PrimaryContextEnvironment.Set(ServerSessionStoringKey, ctx.Session)
@@ -45,6 +45,30 @@ func (request *RequestContext) HandleCommunication() {
 	if request.LogLevel == LogHandled || request.Reader == nil {
 		return
 	}
+	request.writeResponseHeaders()
+	if request.Error != nil {
+		if len(request.Output) == 0 {
+			request.Output = []byte(request.Error.Error())
+		}
+	}
+	Send(request.Writer, request.Reader, request.Output)
+	request.markHandled()
+}
+
+// StartStreaming sends the status and the headers of the response with the content type,
+// the body is written by the caller directly to the Writer; the response is marked as handled,
+// so the result of the action is not sent again. It returns false if the response cannot be streamed.
+func (request *RequestContext) StartStreaming(dataType string) bool {
+	if request.LogLevel == LogHandled || request.Reader == nil || request.Writer == nil {
+		return false
+	}
+	request.DataType = dataType
+	request.writeResponseHeaders()
+	request.markHandled()
+	return true
+}
+
+func (request *RequestContext) writeResponseHeaders() {
 	if request.DataType == "" {
 		request.DataType = "application/json"
 	}
@@ -67,12 +91,9 @@ func (request *RequestContext) HandleCommunication() {
 	if request.StatusCode > 0 {
 		request.Writer.WriteHeader(request.StatusCode)
 	}
-	if request.Error != nil {
-		if len(request.Output) == 0 {
-			request.Output = []byte(request.Error.Error())
-		}
-	}
-	Send(request.Writer, request.Reader, request.Output)
+}
+
+func (request *RequestContext) markHandled() {
 	if request.PlaceInfo == "" {
 		s := strconv.Itoa(request.StatusCode)
 		if request.Action != nil && len(request.Action.LogPolicy) != 0 && request.Server != nil && request.Server.ActionPolicies != nil {
@@ -333,4 +354,5 @@ func (ctx *RequestContext) CleanUpRequest() {
 	for i := 0; i < n; i++ {
 		os.Remove(ctx.TempFiles[i])
 	}
+	ctx.RollbackTransactions("the request " + ctx.Url + " is finished without commit")
 }
@@ -51,9 +51,65 @@ type RewriteMapItem struct {
 	Src    string
 }
 
+type JwtKeyInfo struct {
+	Kid        string `json:"kid"`
+	Alg        string `json:"alg"`
+	Secret     string `json:"secret"`
+	PublicKey  string `json:"public_key"`
+	PrivateKey string `json:"private_key"`
+}
+
+type JwtVerificationInfo struct {
+	Keys           []*JwtKeyInfo `json:"keys"`
+	Jwks           string        `json:"jwks"`
+	JwksMinRefresh int           `json:"jwks_min_refresh"`
+	JwksCacheTime  int           `json:"jwks_cache_time"`
+	Issuers        []string      `json:"issuers"`
+	Audiences      []string      `json:"audiences"`
+	ClockSkew      int           `json:"clock_skew"`
+}
+
+type OAuthServerInfo struct {
+	Issuer           string      `json:"issuer"`
+	Audience         string      `json:"audience"`
+	SigningKey       *JwtKeyInfo `json:"signing_key"`
+	AccessTokenTime  int         `json:"access_token_time"`
+	RefreshTokenTime int         `json:"refresh_token_time"`
+	Connection       string      `json:"connection"`
+	Users            string      `json:"users"`
+	Clients          string      `json:"clients"`
+	RefreshTokens    string      `json:"refresh_tokens"`
+}
+
+type UserManagementInfo struct {
+	Store             string   `json:"store"`
+	Connection        string   `json:"connection"`
+	Users             string   `json:"users"`
+	ResetTokens       string   `json:"reset_tokens"`
+	ResetTokenTime    int      `json:"reset_token_time"`
+	MinPasswordLength int      `json:"min_password_length"`
+	DefaultRoles      []string `json:"default_roles"`
+	DefaultTenant     string   `json:"default_tenant"`
+}
+
+type ApiKeyInfo struct {
+	Store      string `json:"store"`
+	File       string `json:"file"`
+	Connection string `json:"connection"`
+	Table      string `json:"table"`
+	Header     string `json:"header"`
+	Query      string `json:"query"`
+	RateLimit  int    `json:"rate_limit"`
+	RatePeriod int    `json:"rate_period"`
+}
+
 type SecurityServerInfo struct {
-	RolePrefix      string   `json:"role_prefix"`
-	SuperAdminRoles []string `json:"super_admin_roles"`
+	RolePrefix      string               `json:"role_prefix"`
+	SuperAdminRoles []string             `json:"super_admin_roles"`
+	Jwt             *JwtVerificationInfo `json:"jwt"`
+	OAuth           *OAuthServerInfo     `json:"oauth"`
+	Users           *UserManagementInfo  `json:"users"`
+	ApiKeys         *ApiKeyInfo          `json:"api_keys"`
 }
 
 type RewriteMap map[string][]*RewriteMapItem
@@ -77,9 +133,25 @@ type ParallelExecutionControl struct {
 	Value   interface{}
 }
 
+// ActionPolicy authorizes the actions with this "policy" by Rules, a matching deny rule wins over allow rules;
+// Default is "allow" or "deny" (by default) for requests without matching rules, DryRun only logs the decisions
 type ActionPolicy struct {
-	LogFirstTimes int `json:"log_first_times"`
-	LogNextTime   int `json:"log_next_time"`
+	LogFirstTimes int           `json:"log_first_times"`
+	LogNextTime   int           `json:"log_next_time"`
+	Rules         []*PolicyRule `json:"rules"`
+	Default       string        `json:"default"`
+	DryRun        bool          `json:"dry_run"`
+}
+
+// PolicyRule matches the request if all its specified conditions match
+type PolicyRule struct {
+	Effect    string            `json:"effect"`
+	Roles     []string          `json:"roles"`
+	Claims    map[string]string `json:"claims"`
+	Tenants   []string          `json:"tenants"`
+	Methods   []string          `json:"methods"`
+	Params    map[string]string `json:"params"`
+	Condition string            `json:"condition"`
 }
 
 type DatabaseTable struct {
@@ -159,19 +231,25 @@ type RequestContext struct {
 	Reader                    *http.Request
 	Server                    *MicroCoreInfo
 	Session                   RequestSession
-	Input                     []byte
-	InputStr                  string
-	InputJson                 interface{}
-	Output                    []byte
-	Headers                   map[string][]string
-	Error                     error
-	Action                    *DvAction
-	StatusCode                int
-	ParallelExecution         *ParallelExecutionControl
-	ExecutorFn                InterfaceExecutor
-	LogLevel                  int
-	PlaceInfo                 string
-	TempFiles                 []string
+	// User is the identity of the validated token or the session, it is also USER in the environment
+	User              *UserInfo
+	Input             []byte
+	InputStr          string
+	InputJson         interface{}
+	Output            []byte
+	Headers           map[string][]string
+	Error             error
+	Action            *DvAction
+	StatusCode        int
+	ParallelExecution *ParallelExecutionControl
+	ExecutorFn        InterfaceExecutor
+	LogLevel          int
+	PlaceInfo         string
+	TempFiles         []string
+	// Transactions are the database transactions opened by the request steps, they are
+	// rolled back if the request ends without commit
+	Transactions     map[string]RequestTransaction
+	transactionMutex sync.Mutex
 }
 
 type HandlerFunc func(request *RequestContext) bool
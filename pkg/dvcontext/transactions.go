@@ -0,0 +1,90 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2024 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvcontext
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/Dobryvechir/microcore/pkg/dvlog"
+)
+
+// RequestTransaction is the database transaction bound to the request under a name
+type RequestTransaction interface {
+	Commit() error
+	Rollback() error
+}
+
+// BeginTransaction binds the transaction to the request, the name must not be used by another open transaction
+func (ctx *RequestContext) BeginTransaction(name string, tx RequestTransaction) error {
+	ctx.transactionMutex.Lock()
+	defer ctx.transactionMutex.Unlock()
+	if ctx.Transactions[name] != nil {
+		return errors.New("Transaction " + name + " is already started")
+	}
+	if ctx.Transactions == nil {
+		ctx.Transactions = make(map[string]RequestTransaction)
+	}
+	ctx.Transactions[name] = tx
+	return nil
+}
+
+func (ctx *RequestContext) GetTransaction(name string) RequestTransaction {
+	ctx.transactionMutex.Lock()
+	defer ctx.transactionMutex.Unlock()
+	return ctx.Transactions[name]
+}
+
+func (ctx *RequestContext) takeTransaction(name string) (RequestTransaction, error) {
+	ctx.transactionMutex.Lock()
+	defer ctx.transactionMutex.Unlock()
+	tx := ctx.Transactions[name]
+	if tx == nil {
+		return nil, errors.New("Transaction " + name + " is not started")
+	}
+	delete(ctx.Transactions, name)
+	return tx, nil
+}
+
+// CommitTransaction commits the transaction and unbinds it from the request
+func (ctx *RequestContext) CommitTransaction(name string) error {
+	tx, err := ctx.takeTransaction(name)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RollbackTransaction rolls back the transaction and unbinds it from the request
+func (ctx *RequestContext) RollbackTransaction(name string) error {
+	tx, err := ctx.takeTransaction(name)
+	if err != nil {
+		return err
+	}
+	return tx.Rollback()
+}
+
+// RollbackTransactions rolls back all the transactions, which are still open, the reason is logged
+func (ctx *RequestContext) RollbackTransactions(reason string) {
+	if ctx == nil {
+		return
+	}
+	ctx.transactionMutex.Lock()
+	names := make([]string, 0, len(ctx.Transactions))
+	for name := range ctx.Transactions {
+		names = append(names, name)
+	}
+	ctx.transactionMutex.Unlock()
+	sort.Strings(names)
+	for _, name := range names {
+		err := ctx.RollbackTransaction(name)
+		if err != nil {
+			dvlog.PrintfError("Failed to roll back transaction %s because %s: %v", name, reason, err)
+		} else {
+			dvlog.PrintfError("Transaction %s is rolled back because %s", name, reason)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvcontext
+
+import (
+	"encoding/json"
+)
+
+const (
+	UserEnvironmentKey = "USER"
+	UserSessionKey     = "USER_INFO"
+
+	UserSourceToken   = "token"
+	UserSourceSession = "session"
+	UserSourceApiKey  = "apikey"
+)
+
+// UserInfo is the identity of the request, Registered is false for anonymous requests
+type UserInfo struct {
+	Registered bool     `json:"registered"`
+	Id         string   `json:"id"`
+	Name       string   `json:"name"`
+	Email      string   `json:"email"`
+	Roles      []string `json:"roles"`
+	TenantId   string   `json:"tenant_id"`
+	Source     string   `json:"source"`
+}
+
+func (user *UserInfo) ToMap() map[string]interface{} {
+	roles := user.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	return map[string]interface{}{
+		"registered": user.Registered,
+		"id":         user.Id,
+		"name":       user.Name,
+		"email":      user.Email,
+		"roles":      roles,
+		"tenant_id":  user.TenantId,
+		"source":     user.Source,
+	}
+}
+
+// SetUser sets the identity of the request and USER in the environment
+func (ctx *RequestContext) SetUser(user *UserInfo) {
+	if user == nil {
+		user = &UserInfo{}
+	}
+	ctx.User = user
+	if ctx.PrimaryContextEnvironment != nil {
+		ctx.PrimaryContextEnvironment.Set(UserEnvironmentKey, user.ToMap())
+	}
+}
+
+// ReadSessionUser returns the user stored in the session or nil
+func ReadSessionUser(session RequestSession) *UserInfo {
+	if session == nil {
+		return nil
+	}
+	var data []byte
+	switch v := session.GetItem(UserSessionKey).(type) {
+	case *UserInfo:
+		return v
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return nil
+	}
+	user := &UserInfo{}
+	if err := json.Unmarshal(data, user); err != nil || !user.Registered {
+		return nil
+	}
+	user.Source = UserSourceSession
+	return user
+}
+
+// StoreSessionUser stores the user in the session as json, so that it is kept by any session storage;
+// the nil user is removed
+func StoreSessionUser(session RequestSession, user *UserInfo) {
+	if session == nil {
+		return
+	}
+	if user == nil {
+		session.RemoveItem(UserSessionKey)
+		return
+	}
+	data, _ := json.Marshal(user)
+	session.SetItem(UserSessionKey, string(data))
+}
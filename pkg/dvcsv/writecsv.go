@@ -104,3 +104,21 @@ func placeStringToBufForCsv(b []byte, p []byte) []byte {
 	b = append(b, '"')
 	return b
 }
+
+// AppendCsvRow appends the row with the values quoted the same way as WriteCsvToBytes does
+// and terminated by CR LF, the empty values are not quoted
+func AppendCsvRow(b []byte, row []string, options int) []byte {
+	sep := byte(';')
+	if (options & CsvSeparatorComma) != 0 {
+		sep = ','
+	}
+	for i, t := range row {
+		if i > 0 {
+			b = append(b, sep)
+		}
+		if t != "" {
+			b = placeStringToBufForCsv(b, []byte(t))
+		}
+	}
+	return append(b, 13, 10)
+}
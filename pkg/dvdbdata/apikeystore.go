@@ -0,0 +1,102 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvdbdata
+
+import (
+	"strings"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvsecurity"
+)
+
+const apiKeyColumns = "id,hash,name,roles,tenant_id,expires_at,rate_limit,rate_period,created_at"
+
+// apiKeySqlStore keeps the api keys in the sql table
+type apiKeySqlStore struct {
+	connection string
+	table      string
+}
+
+func newApiKeySqlStore(info *dvcontext.ApiKeyInfo) (dvsecurity.ApiKeyStore, error) {
+	return &apiKeySqlStore{connection: info.Connection, table: dvsecurity.GetApiKeyTableName(info)}, nil
+}
+
+var registeredApiKeySqlStore = dvsecurity.RegisterApiKeySqlStore(newApiKeySqlStore)
+
+func (s *apiKeySqlStore) FindKey(hash string) (*dvsecurity.ApiKey, error) {
+	fields, err := findSqlRow(s.connection, s.table, apiKeyColumns, "hash", hash)
+	if err != nil || fields == nil {
+		return nil, err
+	}
+	return dvsecurity.NewApiKey(fields), nil
+}
+
+func (s *apiKeySqlStore) SaveKey(key *dvsecurity.ApiKey) error {
+	db, err := GetDBConnection(s.connection)
+	if err != nil {
+		return err
+	}
+	defer db.Close(false)
+	values := make([]string, 9)
+	for i := range values {
+		values[i] = GetSqlPlaceholder(db.KindMask, i+1)
+	}
+	_, err = db.Exec("INSERT INTO "+s.table+"("+apiKeyColumns+") VALUES("+strings.Join(values, ",")+")",
+		key.Id, key.Hash, key.Name, strings.Join(key.Roles, ","), key.TenantId, key.ExpiresAt, key.RateLimit, key.RatePeriod, key.CreatedAt)
+	return err
+}
+
+func (s *apiKeySqlStore) ListKeys() ([]*dvsecurity.ApiKey, error) {
+	db, err := GetDBConnection(s.connection)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close(false)
+	rs, err := db.Query("SELECT " + apiKeyColumns + " FROM " + s.table + " ORDER BY created_at,id")
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	names := strings.Split(apiKeyColumns, ",")
+	n := len(names)
+	keys := make([]*dvsecurity.ApiKey, 0, 16)
+	for rs.Next() {
+		row := make([]interface{}, n)
+		cols := make([]interface{}, n)
+		for i := 0; i < n; i++ {
+			cols[i] = &row[i]
+		}
+		if err = rs.Scan(cols...); err != nil {
+			return nil, err
+		}
+		fields := make(map[string]string, n)
+		for i, v := range row {
+			if p, ok := v.([]byte); ok {
+				v = string(p)
+			}
+			if v != nil {
+				fields[names[i]] = dvevaluation.AnyToStringWithOptions(v, dvevaluation.ConversionOptionSimpleLike)
+			}
+		}
+		keys = append(keys, dvsecurity.NewApiKey(fields))
+	}
+	return keys, rs.Err()
+}
+
+func (s *apiKeySqlStore) DeleteKey(id string) (bool, error) {
+	db, err := GetDBConnection(s.connection)
+	if err != nil {
+		return false, err
+	}
+	defer db.Close(false)
+	res, err := db.Exec("DELETE FROM "+s.table+" WHERE id="+GetSqlPlaceholder(db.KindMask, 1), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -19,6 +19,8 @@ const (
 const (
 	SqlOracleLike      = 1
 	SqlPostgresLike    = 2
+	SqlMysqlLike       = 4
+	SqlSqliteLike      = 8
 	SqlTraceError	   = 128
 	CommonMaxBatch     = 1000
 	ComplexIdSeparator = "_._"
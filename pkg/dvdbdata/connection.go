@@ -4,6 +4,7 @@
 package dvdbdata
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"github.com/Dobryvechir/microcore/pkg/dvlog"
@@ -84,15 +85,11 @@ func GetConnectionType(connName string) int {
 }
 
 func GetConnectionKindMask(kind string) int {
-	r := 0
-	switch kind {
-	case "oracle":
-		r = SqlOracleLike
-		break
-	case "postgres":
-		r = SqlPostgresLike
+	dialect := GetSqlDialectByKind(kind)
+	if dialect == nil {
+		return 0
 	}
-	return r
+	return dialect.Mask
 }
 
 func GetDBConnection(connName string) (r *DBConnection, err error) {
@@ -171,6 +168,10 @@ func (db *DBConnection) Query(query string, args ...interface{}) (*sql.Rows, err
 	return db.Db.Query(query, args...)
 }
 
+func (db *DBConnection) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return db.Db.QueryContext(ctx, query, args...)
+}
+
 func (db *DBConnection) Exec(query string, args ...interface{}) (sql.Result, error) {
 	return db.Db.Exec(query, args...)
 }
@@ -60,7 +60,9 @@ func CreateTableByDefinition(db *DBConnection, def string) error {
 	primary := ""
 	query := "CREATE TABLE " + table + "("
 	query1 := query
+	dialect := GetSqlDialect(db.KindMask)
 	for i, col := range colDefs {
+		col = dialect.MapColumnDefinition(col)
 		if i != 0 {
 			query += ","
 			query1 += ","
@@ -0,0 +1,207 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvdbdata
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SqlDialect describes the differences of the sql of the database kind
+type SqlDialect struct {
+	Kind       string
+	Mask       int
+	QuoteOpen  string
+	QuoteClose string
+	// Placeholder is $ or : followed by the number of the parameter or just ?
+	Placeholder      string
+	DateNow          string
+	TimestampLessDay string
+	// MaxBatch is the maximum number of rows inserted by one statement
+	MaxBatch int
+	// BackslashEscape means that backslashes in string literals are escaped as well as quotes
+	BackslashEscape bool
+	// TypeMapping replaces the types of the columns in the table definitions,
+	// VarcharLength is added to varchar without the length
+	TypeMapping   map[string]string
+	VarcharLength int
+}
+
+var genericSqlDialect = &SqlDialect{
+	QuoteOpen:        "\"",
+	QuoteClose:       "\"",
+	Placeholder:      "?",
+	DateNow:          "NOW()",
+	TimestampLessDay: "NOW() - INTERVAL '1 DAY'",
+	MaxBatch:         1,
+	BackslashEscape:  true,
+}
+
+var SqlDialects = map[string]*SqlDialect{
+	"oracle": {
+		Kind:             "oracle",
+		Mask:             SqlOracleLike,
+		QuoteOpen:        "\"",
+		QuoteClose:       "\"",
+		Placeholder:      ":",
+		DateNow:          "CURRENT_TIMESTAMP",
+		TimestampLessDay: "CURRENT_TIMESTAMP - 1",
+		MaxBatch:         CommonMaxBatch,
+		BackslashEscape:  true,
+		TypeMapping:      map[string]string{"text": "clob", "bigint": "number(19)", "boolean": "number(1)", "double": "binary_double"},
+		VarcharLength:    4000,
+	},
+	"postgres": {
+		Kind:             "postgres",
+		Mask:             SqlPostgresLike,
+		QuoteOpen:        "\"",
+		QuoteClose:       "\"",
+		Placeholder:      "$",
+		DateNow:          "NOW()",
+		TimestampLessDay: "NOW() - INTERVAL '1 DAY'",
+		MaxBatch:         5000,
+		TypeMapping:      map[string]string{"clob": "text", "varchar2": "varchar", "number": "numeric", "double": "double precision"},
+	},
+	"mysql": {
+		Kind:             "mysql",
+		Mask:             SqlMysqlLike,
+		QuoteOpen:        "`",
+		QuoteClose:       "`",
+		Placeholder:      "?",
+		DateNow:          "NOW()",
+		TimestampLessDay: "NOW() - INTERVAL 1 DAY",
+		MaxBatch:         CommonMaxBatch,
+		BackslashEscape:  true,
+		TypeMapping:      map[string]string{"clob": "longtext", "varchar2": "varchar", "number": "decimal", "timestamp": "datetime(6)"},
+		VarcharLength:    255,
+	},
+	"sqlite": {
+		Kind:             "sqlite",
+		Mask:             SqlSqliteLike,
+		QuoteOpen:        "\"",
+		QuoteClose:       "\"",
+		Placeholder:      "?",
+		DateNow:          "CURRENT_TIMESTAMP",
+		TimestampLessDay: "DATETIME('now', '-1 day')",
+		MaxBatch:         500,
+		TypeMapping:      map[string]string{"clob": "text", "varchar2": "varchar", "number": "numeric"},
+	},
+}
+
+// sqlDialectAliases are other names of the database kinds in the connection definitions
+var sqlDialectAliases = map[string]string{
+	"mariadb": "mysql",
+	"sqlite3": "sqlite",
+}
+
+// GetSqlDialectByKind returns the dialect by the kind of the connection or nil if it is unknown
+func GetSqlDialectByKind(kind string) *SqlDialect {
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	if alias, ok := sqlDialectAliases[kind]; ok {
+		kind = alias
+	}
+	return SqlDialects[kind]
+}
+
+// GetSqlDialect returns the dialect by the sql type mask, the generic dialect is returned for unknown databases
+func GetSqlDialect(sqlType int) *SqlDialect {
+	for _, dialect := range SqlDialects {
+		if (sqlType & dialect.Mask) != 0 {
+			return dialect
+		}
+	}
+	return genericSqlDialect
+}
+
+// QuoteSqlIdentifier quotes the name of the table or the column in the sql dialect
+func QuoteSqlIdentifier(sqlType int, name string) string {
+	d := GetSqlDialect(sqlType)
+	return d.QuoteOpen + strings.Replace(name, d.QuoteClose, d.QuoteClose+d.QuoteClose, -1) + d.QuoteClose
+}
+
+// MapColumnDefinition replaces the type of the column definition like "name varchar(255) primary"
+// by the type of the dialect
+func (d *SqlDialect) MapColumnDefinition(colDef string) string {
+	p := strings.Index(colDef, " ")
+	if p <= 0 {
+		return colDef
+	}
+	name := colDef[:p]
+	rest := strings.TrimLeft(colDef[p:], " ")
+	e := strings.IndexAny(rest, " (")
+	if e < 0 {
+		e = len(rest)
+	}
+	tp := strings.ToLower(rest[:e])
+	rest = rest[e:]
+	if r, ok := d.TypeMapping[tp]; ok {
+		tp = r
+	}
+	if d.VarcharLength > 0 && (tp == "varchar" || tp == "varchar2") && !strings.HasPrefix(rest, "(") {
+		tp += "(" + strconv.Itoa(d.VarcharLength) + ")"
+	}
+	return name + " " + tp + rest
+}
+
+// GetSqlUpsert returns the statement inserting the row or updating it if the row with the same key columns exists,
+// the values are passed as the parameters in the order of the columns
+func GetSqlUpsert(sqlType int, table string, columns []string, keys []string) string {
+	isKey := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		isKey[key] = true
+	}
+	n := len(columns)
+	values := make([]string, n)
+	for i := 0; i < n; i++ {
+		values[i] = GetSqlPlaceholder(sqlType, i+1)
+	}
+	cols := strings.Join(columns, ",")
+	updates := make([]string, 0, n)
+	switch {
+	case (sqlType & SqlOracleLike) != 0:
+		source := make([]string, n)
+		inserts := make([]string, n)
+		for i, column := range columns {
+			source[i] = values[i] + " " + column
+			inserts[i] = "s." + column
+			if !isKey[column] {
+				updates = append(updates, "t."+column+"=s."+column)
+			}
+		}
+		on := make([]string, len(keys))
+		for i, key := range keys {
+			on[i] = "t." + key + "=s." + key
+		}
+		query := "MERGE INTO " + table + " t USING (SELECT " + strings.Join(source, ",") + " FROM dual) s ON (" +
+			strings.Join(on, " AND ") + ")"
+		if len(updates) != 0 {
+			query += " WHEN MATCHED THEN UPDATE SET " + strings.Join(updates, ",")
+		}
+		return query + " WHEN NOT MATCHED THEN INSERT (" + cols + ") VALUES (" + strings.Join(inserts, ",") + ")"
+	case (sqlType & SqlMysqlLike) != 0:
+		for _, column := range columns {
+			if !isKey[column] {
+				updates = append(updates, column+"=VALUES("+column+")")
+			}
+		}
+		if len(updates) == 0 {
+			return "INSERT IGNORE INTO " + table + "(" + cols + ") VALUES(" + strings.Join(values, ",") + ")"
+		}
+		return "INSERT INTO " + table + "(" + cols + ") VALUES(" + strings.Join(values, ",") + ") ON DUPLICATE KEY UPDATE " +
+			strings.Join(updates, ",")
+	}
+	for _, column := range columns {
+		if !isKey[column] {
+			updates = append(updates, column+"=EXCLUDED."+column)
+		}
+	}
+	query := "INSERT INTO " + table + "(" + cols + ") VALUES(" + strings.Join(values, ",") + ") ON CONFLICT(" +
+		strings.Join(keys, ",") + ")"
+	if len(updates) == 0 {
+		return query + " DO NOTHING"
+	}
+	return query + " DO UPDATE SET " + strings.Join(updates, ",")
+}
@@ -0,0 +1,506 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvdbdata
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvtextutils"
+)
+
+const (
+	propertyMigrationFolder      = "DB_ROOT_MIGRATION_FOLDER"
+	propertyMigrationConnections = "DB_CONNECTIONS_MIGRATE"
+	propertyMigrationTable       = "DB_MIGRATION_TABLE"
+	propertyMigrationLockTable   = "DB_MIGRATION_LOCK_TABLE"
+	propertyMigrationLockTimeout = "DB_MIGRATION_LOCK_TIMEOUT"
+	migrationTableDefault        = "R_MIGRATION_HISTORY(filename varchar(255) primary,checksum varchar(64),applied_at timestamp,duration_ms integer)"
+	migrationLockTableDefault    = "R_MIGRATION_LOCK(id varchar(64) primary,owner varchar(255),locked_at timestamp)"
+	migrationLockId              = "migration"
+	migrationLockTimeoutDefault  = 300
+	migrationDownMarker          = "-- down"
+)
+
+const (
+	MigrationPending = "pending"
+	MigrationApplied = "applied"
+	MigrationChanged = "changed"
+	MigrationMissing = "missing"
+)
+
+// MigrationFile is the sql file of the migration folder, the statements after the line "-- down"
+// revert the statements before it
+type MigrationFile struct {
+	Name     string
+	Checksum string
+	Up       []byte
+	Down     []byte
+}
+
+// MigrationRecord is the row of the migration history table
+type MigrationRecord struct {
+	Name      string
+	Checksum  string
+	AppliedAt string
+	Duration  string
+}
+
+// MigrationStatus shows whether the migration file is pending, applied, changed after it was applied
+// or applied but missing in the folder
+type MigrationStatus struct {
+	Name      string
+	State     string
+	AppliedAt string
+	Duration  string
+	File      *MigrationFile
+}
+
+// Migrator applies and reverts the migrations of the folder for the connection, recording them in the history table
+type Migrator struct {
+	Db          *DBConnection
+	Folder      string
+	props       map[string]string
+	table       string
+	columns     []string
+	lockTable   string
+	lockColumns []string
+	lockTimeout time.Duration
+}
+
+// ParseMigration splits the migration into the up statements and the down statements after the line "-- down",
+// the lines of comments are removed because the statements are joined into one line when they are executed
+func ParseMigration(data []byte) (up []byte, down []byte) {
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	up = make([]byte, 0, len(data))
+	for _, line := range lines {
+		s := strings.TrimSpace(string(line))
+		if down == nil && strings.ToLower(s) == migrationDownMarker {
+			down = make([]byte, 0, len(data))
+		} else if !strings.HasPrefix(s, "--") {
+			if down != nil {
+				down = append(down, line...)
+			} else {
+				up = append(up, line...)
+			}
+		}
+	}
+	return
+}
+
+// GetMigrationChecksum returns the hex sha256 checksum of the content of the migration file
+func GetMigrationChecksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ReadMigrationFiles reads the .sql files of the folder in the alphabetical order,
+// files like name.oracle.sql are read only for the sql of the same kind
+func ReadMigrationFiles(folder string, kind string) ([]*MigrationFile, error) {
+	files, err := ioutil.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*MigrationFile, 0, len(files))
+	for _, file := range files {
+		name := file.Name()
+		s := strings.ToLower(name)
+		if file.IsDir() || !strings.HasSuffix(s, ".sql") {
+			continue
+		}
+		t := s[:len(s)-4]
+		if p := strings.LastIndex(t, "."); p >= 0 {
+			t = t[p+1:]
+			if dvtextutils.IsAlphabeticalLowCase(t) && t != kind {
+				continue
+			}
+		}
+		data, err := ioutil.ReadFile(filepath.Join(folder, name))
+		if err != nil {
+			return nil, err
+		}
+		up, down := ParseMigration(data)
+		res = append(res, &MigrationFile{Name: name, Checksum: GetMigrationChecksum(data), Up: up, Down: down})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+// CompareMigrations returns the status of each migration file and each history record in the order of names
+func CompareMigrations(files []*MigrationFile, history []*MigrationRecord) []*MigrationStatus {
+	applied := make(map[string]*MigrationRecord, len(history))
+	for _, record := range history {
+		applied[record.Name] = record
+	}
+	res := make([]*MigrationStatus, 0, len(files)+len(history))
+	for _, file := range files {
+		status := &MigrationStatus{Name: file.Name, State: MigrationPending, File: file}
+		if record, ok := applied[file.Name]; ok {
+			status.State = MigrationApplied
+			if record.Checksum != file.Checksum {
+				status.State = MigrationChanged
+			}
+			status.AppliedAt = record.AppliedAt
+			status.Duration = record.Duration
+			delete(applied, file.Name)
+		}
+		res = append(res, status)
+	}
+	for _, record := range history {
+		if _, ok := applied[record.Name]; ok {
+			res = append(res, &MigrationStatus{Name: record.Name, State: MigrationMissing, AppliedAt: record.AppliedAt, Duration: record.Duration})
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+func NewMigrator(props map[string]string, db *DBConnection, folder string) (*Migrator, error) {
+	m := &Migrator{Db: db, Folder: folder, props: props, lockTimeout: migrationLockTimeoutDefault * time.Second}
+	var err error
+	m.table, m.columns, _, err = GetTableNameColumnsFromDefinition(m.tableDefinition())
+	if err != nil {
+		return nil, err
+	}
+	if len(m.columns) < 4 {
+		return nil, errors.New("Migration table must have 4 columns: file name, checksum, time and duration")
+	}
+	m.lockTable, m.lockColumns, _, err = GetTableNameColumnsFromDefinition(m.lockTableDefinition())
+	if err != nil {
+		return nil, err
+	}
+	if len(m.lockColumns) < 3 {
+		return nil, errors.New("Migration lock table must have 3 columns: id, owner and time")
+	}
+	if s := strings.TrimSpace(props[propertyMigrationLockTimeout]); s != "" {
+		seconds, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, errors.New(propertyMigrationLockTimeout + " must be the number of seconds")
+		}
+		m.lockTimeout = time.Duration(seconds) * time.Second
+	}
+	return m, nil
+}
+
+func (m *Migrator) tableDefinition() string {
+	if r := m.props[propertyMigrationTable]; r != "" {
+		return r
+	}
+	return migrationTableDefault
+}
+
+func (m *Migrator) lockTableDefinition() string {
+	if r := m.props[propertyMigrationLockTable]; r != "" {
+		return r
+	}
+	return migrationLockTableDefault
+}
+
+func (m *Migrator) placeholders(n int) string {
+	s := make([]string, n)
+	for i := 0; i < n; i++ {
+		s[i] = GetSqlPlaceholder(m.Db.KindMask, i+1)
+	}
+	return strings.Join(s, ",")
+}
+
+// History reads the applied migrations, the history table is created if it does not exist
+func (m *Migrator) History() ([]*MigrationRecord, error) {
+	query := "SELECT " + strings.Join(m.columns[:4], ",") + " FROM " + m.table + " ORDER BY " + m.columns[0]
+	rs, err := m.Db.Query(query)
+	if err != nil {
+		if CreateTableByDefinition(m.Db, m.tableDefinition()) != nil {
+			return nil, err
+		}
+		rs, err = m.Db.Query(query)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer rs.Close()
+	res := make([]*MigrationRecord, 0, 16)
+	for rs.Next() {
+		r := make([]interface{}, 4)
+		if err = rs.Scan(&r[0], &r[1], &r[2], &r[3]); err != nil {
+			return nil, err
+		}
+		s := dvevaluation.ConvertInterfaceListToStringList(r, dvevaluation.ConversionOptionSimpleLike)
+		res = append(res, &MigrationRecord{Name: s[0], Checksum: s[1], AppliedAt: s[2], Duration: s[3]})
+	}
+	return res, rs.Err()
+}
+
+func (m *Migrator) Status() ([]*MigrationStatus, error) {
+	files, err := ReadMigrationFiles(m.Folder, m.Db.Kind)
+	if err != nil {
+		return nil, err
+	}
+	history, err := m.History()
+	if err != nil {
+		return nil, err
+	}
+	return CompareMigrations(files, history), nil
+}
+
+func checkMigrationsUnchanged(statuses []*MigrationStatus) error {
+	for _, status := range statuses {
+		if status.State == MigrationChanged {
+			return errors.New("Migration " + status.Name + " was edited after it had been applied")
+		}
+	}
+	return nil
+}
+
+// runMigration executes the up or down statements of the migration and adds or removes its history record
+// in one transaction, it returns the duration of the statements in milliseconds
+func (m *Migrator) runMigration(file *MigrationFile, up bool) (int64, error) {
+	tx, err := m.Db.Db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	data := file.Down
+	if up {
+		data = file.Up
+	}
+	start := time.Now()
+	err = ExecuteSqlData(tx, data)
+	duration := time.Since(start).Milliseconds()
+	if err == nil {
+		if up {
+			_, err = tx.Exec("INSERT INTO "+m.table+"("+strings.Join(m.columns[:4], ",")+") VALUES("+m.placeholders(4)+")",
+				file.Name, file.Checksum, start, duration)
+		} else {
+			_, err = tx.Exec("DELETE FROM "+m.table+" WHERE "+m.columns[0]+"="+GetSqlPlaceholder(m.Db.KindMask, 1), file.Name)
+		}
+	}
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return duration, tx.Commit()
+}
+
+// Up applies up to count pending migrations (all if count is not positive) and returns the number of the applied ones;
+// nothing is applied if any applied migration was edited
+func (m *Migrator) Up(count int) (int, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+	if err = checkMigrationsUnchanged(statuses); err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, status := range statuses {
+		if status.State != MigrationPending {
+			continue
+		}
+		if count > 0 && n >= count {
+			break
+		}
+		duration, err := m.runMigration(status.File, true)
+		if err != nil {
+			return n, errors.New("Migration " + status.Name + " failed: " + err.Error())
+		}
+		log.Printf("Migration %s is applied to %s in %d ms", status.Name, m.Db.Name, duration)
+		n++
+	}
+	return n, nil
+}
+
+// Down reverts up to count last applied migrations (one if count is not positive) by their down statements
+func (m *Migrator) Down(count int) (int, error) {
+	if count <= 0 {
+		count = 1
+	}
+	statuses, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for i := len(statuses) - 1; i >= 0 && n < count; i-- {
+		status := statuses[i]
+		switch status.State {
+		case MigrationPending:
+			continue
+		case MigrationChanged:
+			return n, errors.New("Migration " + status.Name + " was edited after it had been applied")
+		case MigrationMissing:
+			return n, errors.New("Migration " + status.Name + " is applied but its file is missing")
+		}
+		file := status.File
+		if len(bytes.TrimSpace(file.Down)) == 0 {
+			return n, errors.New("Migration " + file.Name + " has no " + migrationDownMarker + " section")
+		}
+		duration, err := m.runMigration(file, false)
+		if err != nil {
+			return n, errors.New("Migration " + file.Name + " cannot be reverted: " + err.Error())
+		}
+		log.Printf("Migration %s is reverted in %s in %d ms", file.Name, m.Db.Name, duration)
+		n++
+	}
+	return n, nil
+}
+
+func migrationLockOwner() string {
+	host, _ := os.Hostname()
+	return host + ":" + strconv.Itoa(os.Getpid())
+}
+
+func (m *Migrator) migrationLockKey() int64 {
+	h := fnv.New64a()
+	h.Write([]byte(m.table))
+	return int64(h.Sum64())
+}
+
+// Lock waits until no other instance migrates the database and returns the function releasing the lock;
+// postgres and mysql use their advisory locks, other databases use the row of the lock table
+func (m *Migrator) Lock() (func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.lockTimeout)
+	defer cancel()
+	if (m.Db.KindMask & (SqlPostgresLike | SqlMysqlLike)) != 0 {
+		conn, err := m.Db.Db.Conn(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		lock, unlock := "SELECT pg_advisory_lock($1)", "SELECT pg_advisory_unlock($1)"
+		var key interface{} = m.migrationLockKey()
+		if (m.Db.KindMask & SqlMysqlLike) != 0 {
+			lock, unlock = "SELECT GET_LOCK(?, -1)", "SELECT RELEASE_LOCK(?)"
+			key = m.table
+		}
+		if _, err = conn.ExecContext(ctx, lock, key); err != nil {
+			conn.Close()
+			return nil, errors.New("Cannot lock the migrations: " + err.Error())
+		}
+		return func() {
+			if _, err := conn.ExecContext(context.Background(), unlock, key); err != nil {
+				log.Printf("Cannot unlock the migrations: %v", err)
+			}
+			conn.Close()
+		}, nil
+	}
+	owner := migrationLockOwner()
+	query := "INSERT INTO " + m.lockTable + "(" + strings.Join(m.lockColumns[:3], ",") + ") VALUES(" + m.placeholders(3) + ")"
+	created := false
+	for {
+		_, err := m.Db.Exec(query, migrationLockId, owner, time.Now())
+		if err == nil {
+			break
+		}
+		if !created {
+			created = true
+			if CreateTableByDefinition(m.Db, m.lockTableDefinition()) == nil {
+				continue
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.New("Migrations are locked by another instance, run execute migrate unlock if it is stopped")
+		case <-time.After(time.Second):
+		}
+	}
+	return func() {
+		if err := m.Unlock(); err != nil {
+			log.Printf("Cannot unlock the migrations: %v", err)
+		}
+	}, nil
+}
+
+// Unlock removes the row of the lock table left by the stopped instance
+func (m *Migrator) Unlock() error {
+	_, err := m.Db.Exec("DELETE FROM "+m.lockTable+" WHERE "+m.lockColumns[0]+"="+GetSqlPlaceholder(m.Db.KindMask, 1), migrationLockId)
+	return err
+}
+
+func (m *Migrator) runCommand(command string, count int) error {
+	switch command {
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Migrations of %s in %s:\n", m.Db.Name, m.Folder)
+		for _, status := range statuses {
+			if status.State == MigrationPending {
+				fmt.Printf("  %-8s %s\n", status.State, status.Name)
+			} else {
+				fmt.Printf("  %-8s %s at %s in %s ms\n", status.State, status.Name, status.AppliedAt, status.Duration)
+			}
+		}
+		return checkMigrationsUnchanged(statuses)
+	case "unlock":
+		return m.Unlock()
+	}
+	unlock, err := m.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	var n int
+	if command == "up" {
+		n, err = m.Up(count)
+		log.Printf("%d migrations are applied to %s", n, m.Db.Name)
+	} else {
+		n, err = m.Down(count)
+		log.Printf("%d migrations are reverted in %s", n, m.Db.Name)
+	}
+	return err
+}
+
+// Migrate runs the migration command status, up, down or unlock for the connections of DB_CONNECTIONS_MIGRATE
+// (or the default connection) with the sql files of DB_ROOT_MIGRATION_FOLDER/<connection name>;
+// the optional count limits the number of the applied or reverted migrations
+func Migrate(props map[string]string, command string, count int) error {
+	command = strings.ToLower(strings.TrimSpace(command))
+	switch command {
+	case "":
+		command = "status"
+	case "status", "up", "down", "unlock":
+	default:
+		return errors.New("Unknown migration command " + command + ", expected status, up, down or unlock")
+	}
+	folder := strings.TrimSpace(props[propertyMigrationFolder])
+	if folder == "" {
+		return errors.New("Specify " + propertyMigrationFolder + " where the migrations are stored")
+	}
+	connections := dvtextutils.ConvertToNonEmptyList(props[propertyMigrationConnections])
+	if len(connections) == 0 {
+		connections = []string{props[propertyDefaultDb]}
+	}
+	for _, connection := range connections {
+		db, err := GetDBConnection(connection)
+		if err != nil {
+			return err
+		}
+		m, err := NewMigrator(props, db, filepath.Join(folder, db.Name))
+		if err == nil {
+			err = m.runCommand(command, count)
+		}
+		err1 := db.Close(err != nil)
+		if err != nil {
+			return errors.New(db.Name + ": " + err.Error())
+		}
+		if err1 != nil {
+			return err1
+		}
+	}
+	return nil
+}
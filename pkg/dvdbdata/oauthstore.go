@@ -0,0 +1,135 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvdbdata
+
+import (
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvsecurity"
+)
+
+const (
+	oauthUserColumns   = "id,username,password,tenant_id,email,name,given_name,family_name,roles,disabled"
+	oauthClientColumns = "client_id,secret,grants,scope,roles,tenant_id,disabled"
+)
+
+// oauthSqlStore keeps the users, the clients and the refresh tokens of the token endpoint in the sql tables
+type oauthSqlStore struct {
+	connection    string
+	users         string
+	clients       string
+	refreshTokens string
+}
+
+func newOAuthSqlStore(info *dvcontext.OAuthServerInfo) (dvsecurity.OAuthStore, error) {
+	users, clients, refreshTokens := dvsecurity.GetOAuthTableNames(info)
+	return &oauthSqlStore{connection: info.Connection, users: users, clients: clients, refreshTokens: refreshTokens}, nil
+}
+
+var registeredOAuthSqlStore = dvsecurity.RegisterOAuthSqlStore(newOAuthSqlStore)
+
+// findSqlRow returns the first row of the query as the map of the columns or nil if there are no rows
+func findSqlRow(connection string, table string, columns string, key string, value string) (map[string]string, error) {
+	db, err := GetDBConnection(connection)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close(false)
+	rs, err := db.Query("SELECT "+columns+" FROM "+table+" WHERE "+key+"="+GetSqlPlaceholder(db.KindMask, 1), value)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	if !rs.Next() {
+		return nil, rs.Err()
+	}
+	names, err := rs.Columns()
+	if err != nil {
+		return nil, err
+	}
+	n := len(names)
+	row := make([]interface{}, n)
+	cols := make([]interface{}, n)
+	for i := 0; i < n; i++ {
+		cols[i] = &row[i]
+	}
+	if err = rs.Scan(cols...); err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string, n)
+	for i, v := range row {
+		if p, ok := v.([]byte); ok {
+			v = string(p)
+		}
+		if v != nil {
+			fields[names[i]] = dvevaluation.AnyToStringWithOptions(v, dvevaluation.ConversionOptionSimpleLike)
+		}
+	}
+	return fields, nil
+}
+
+func (s *oauthSqlStore) FindUser(username string) (*dvsecurity.OAuthUser, error) {
+	fields, err := findSqlRow(s.connection, s.users, oauthUserColumns, "username", username)
+	if err != nil || fields == nil {
+		return nil, err
+	}
+	return dvsecurity.NewOAuthUser(fields), nil
+}
+
+func (s *oauthSqlStore) FindClient(clientId string) (*dvsecurity.OAuthClient, error) {
+	fields, err := findSqlRow(s.connection, s.clients, oauthClientColumns, "client_id", clientId)
+	if err != nil || fields == nil {
+		return nil, err
+	}
+	return dvsecurity.NewOAuthClient(fields), nil
+}
+
+// deleteRows deletes the refresh tokens with the column value and returns the number of the deleted rows
+func (s *oauthSqlStore) deleteRows(condition string, value interface{}) (int64, error) {
+	db, err := GetDBConnection(s.connection)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close(false)
+	res, err := db.Exec("DELETE FROM "+s.refreshTokens+" WHERE "+condition+GetSqlPlaceholder(db.KindMask, 1), value)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// SaveRefreshToken saves the token and removes the expired tokens
+func (s *oauthSqlStore) SaveRefreshToken(token *dvsecurity.OAuthRefreshToken) error {
+	if _, err := s.deleteRows("expires_at<", dvsecurity.GetCurrentSeconds()); err != nil {
+		return err
+	}
+	db, err := GetDBConnection(s.connection)
+	if err != nil {
+		return err
+	}
+	defer db.Close(false)
+	values := ""
+	for i := 1; i <= 5; i++ {
+		if i > 1 {
+			values += ","
+		}
+		values += GetSqlPlaceholder(db.KindMask, i)
+	}
+	_, err = db.Exec("INSERT INTO "+s.refreshTokens+"(id,family,client_id,username,expires_at) VALUES("+values+")",
+		token.Id, token.Family, token.ClientId, token.Username, token.ExpiresAt)
+	return err
+}
+
+// DeleteRefreshToken deletes the token by one statement, so the token can be rotated only once
+func (s *oauthSqlStore) DeleteRefreshToken(id string) (bool, error) {
+	n, err := s.deleteRows("id=", id)
+	return n > 0, err
+}
+
+func (s *oauthSqlStore) DeleteRefreshTokenFamily(family string) error {
+	_, err := s.deleteRows("family=", family)
+	return err
+}
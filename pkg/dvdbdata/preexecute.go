@@ -65,7 +65,7 @@ func SplitSqlSequences(data []byte) []string {
 	return res
 }
 
-func ExecuteSqlData(db *DBConnection, data []byte) error {
+func ExecuteSqlData(db SqlExecutor, data []byte) error {
 	queries := SplitSqlSequences(data)
 	n := len(queries)
 	if logPreExecuteLevel >= dvlog.LogDetail {
@@ -152,15 +152,7 @@ func ExecuteSqlFromFolder(db *DBConnection, root string) error {
 		return err
 	}
 	sort.Strings(files)
-	csvOptions := 0
-	switch db.Kind {
-	case "oracle":
-		csvOptions |= SqlOracleLike
-		break
-	case "postgres":
-		csvOptions |= SqlPostgresLike
-		break
-	}
+	csvOptions := db.KindMask
 	if logPreExecuteLevel >= dvlog.LogInfo {
 		log.Printf("%d files are found for execution", len(files))
 	}
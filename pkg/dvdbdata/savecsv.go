@@ -110,7 +110,7 @@ func OrderObjectsByHierarchy(objects [][]string, leftObjects map[string]bool,
 }
 
 func appendSlashed(b []byte, v []byte, options int) []byte {
-	if (options & SqlPostgresLike) != 0 {
+	if !GetSqlDialect(options).BackslashEscape {
 		return appendSlashedPostgresLike(b, v)
 	}
 	return appendSlashedOracleLike(b, v)
@@ -206,16 +206,11 @@ func PlaceStringToSqlQuery(v string, tp string, b []byte, options int) ([]byte,
 func savePortionOfItemsInBulk(items [][]string, sqlTable string, conn *DBConnection, left map[string]bool,
 	columnIds []int, options int, types []string) (pos int, err error) {
 	oracleLike := (options & SqlOracleLike) != 0
-	postgresLike := (options & SqlPostgresLike) != 0
 	traceError := (options & SqlTraceError) != 0
 	maxBatch := 1
 	pos = 0
 	if !traceError {
-		if oracleLike {
-			maxBatch = CommonMaxBatch
-		} else if postgresLike {
-			maxBatch = 5000
-		}
+		maxBatch = GetSqlDialect(options).MaxBatch
 	}
 	cols := len(types)
 	sqlStart := "INSERT INTO " + sqlTable + " "
@@ -0,0 +1,188 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvdbdata
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+)
+
+// GetSqlPlaceholder returns the placeholder of the n-th argument (starting from 1) in the sql dialect
+func GetSqlPlaceholder(sqlType int, n int) string {
+	placeholder := GetSqlDialect(sqlType).Placeholder
+	if placeholder == "?" {
+		return placeholder
+	}
+	return placeholder + strconv.Itoa(n)
+}
+
+// SqlParamValue converts the value calculated by the expression to the value accepted by the database drivers,
+// objects and arrays are passed as json
+func SqlParamValue(v interface{}) interface{} {
+	switch v.(type) {
+	case nil, string, int64, float64, bool, []byte, time.Time:
+		return v
+	case int:
+		return int64(v.(int))
+	case *dvevaluation.DvObject:
+		if v == dvevaluation.DvObject_null {
+			return nil
+		}
+	case *dvevaluation.DvVariable:
+		vr := v.(*dvevaluation.DvVariable)
+		if vr == nil {
+			return nil
+		}
+		switch vr.Kind {
+		case dvevaluation.FIELD_UNDEFINED, dvevaluation.FIELD_NULL:
+			return nil
+		case dvevaluation.FIELD_STRING:
+			return string(vr.Value)
+		case dvevaluation.FIELD_BOOLEAN:
+			return string(vr.Value) == "true"
+		case dvevaluation.FIELD_NUMBER:
+			if n, ok := dvevaluation.AnyToNumberInt(string(vr.Value)); ok {
+				return n
+			}
+			return dvevaluation.AnyToNumber(string(vr.Value))
+		case dvevaluation.FIELD_OBJECT, dvevaluation.FIELD_ARRAY:
+			return string(vr.JsonStringify())
+		}
+	}
+	return dvevaluation.AnyToString(v)
+}
+
+func isSqlNameChar(c byte, first bool) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || !first && c >= '0' && c <= '9'
+}
+
+func readSqlNumber(query string, pos int) (int, int) {
+	end := pos
+	for end < len(query) && query[end] >= '0' && query[end] <= '9' {
+		end++
+	}
+	if end == pos {
+		return 0, pos
+	}
+	n, _ := strconv.Atoi(query[pos:end])
+	return n, end
+}
+
+// skipSqlLiteral returns the position after the string literal, the quoted identifier or the comment starting at pos
+// or pos itself if there is nothing to skip
+func skipSqlLiteral(query string, pos int) int {
+	n := len(query)
+	c := query[pos]
+	switch {
+	case c == '\'' || c == '"':
+		for i := pos + 1; i < n; i++ {
+			if query[i] == c {
+				return i + 1
+			}
+		}
+		return n
+	case c == '-' && pos+1 < n && query[pos+1] == '-':
+		for i := pos + 2; i < n; i++ {
+			if query[i] == '\n' {
+				return i
+			}
+		}
+		return n
+	case c == '/' && pos+1 < n && query[pos+1] == '*':
+		for i := pos + 2; i+1 < n; i++ {
+			if query[i] == '*' && query[i+1] == '/' {
+				return i + 2
+			}
+		}
+		return n
+	}
+	return pos
+}
+
+// BindSqlParameters replaces the placeholders of the query with the placeholders of the sql dialect
+// and returns the arguments in the order of the placeholders; ? takes the next positional parameter,
+// $n or :n takes the n-th positional parameter and :name takes the named parameter; ?? is the literal ?
+// (e.g. for the postgres jsonb operators ??, ??| and ??&); the placeholders inside the string literals, quoted identifiers and comments are ignored
+func BindSqlParameters(query string, sqlType int, params []interface{}, named map[string]interface{}) (string, []interface{}, error) {
+	n := len(query)
+	res := make([]byte, 0, n+16)
+	args := make([]interface{}, 0, len(params)+len(named))
+	next := 0
+	for i := 0; i < n; {
+		if end := skipSqlLiteral(query, i); end > i {
+			res = append(res, query[i:end]...)
+			i = end
+			continue
+		}
+		c := query[i]
+		var value interface{}
+		end := i + 1
+		switch {
+		case c == '?' && end < n && query[end] == '?':
+			res = append(res, '?')
+			i += 2
+			continue
+		case c == '?':
+			if next >= len(params) {
+				return query, nil, errors.New("Not enough parameters for the placeholders of " + query)
+			}
+			value = params[next]
+			next++
+		case (c == '$' || c == ':') && end < n && query[end] >= '0' && query[end] <= '9':
+			var k int
+			k, end = readSqlNumber(query, end)
+			if k < 1 || k > len(params) {
+				return query, nil, errors.New("Parameter " + query[i:end] + " is not specified")
+			}
+			value = params[k-1]
+		case c == ':' && end < n && query[end] == ':':
+			res = append(res, "::"...)
+			i += 2
+			continue
+		case c == ':' && end < n && isSqlNameChar(query[end], true) && (i == 0 || !isSqlNameChar(query[i-1], false)):
+			for end < n && isSqlNameChar(query[end], false) {
+				end++
+			}
+			var ok bool
+			value, ok = named[query[i+1:end]]
+			if !ok {
+				return query, nil, errors.New("Parameter " + query[i:end] + " is not specified")
+			}
+		default:
+			res = append(res, c)
+			i++
+			continue
+		}
+		args = append(args, value)
+		res = append(res, GetSqlPlaceholder(sqlType, len(args))...)
+		i = end
+	}
+	return string(res), args, nil
+}
+
+// bindParameters evaluates the parameters against the request environment and binds them to the query
+func (sqlAction *SqlAction) bindParameters(query string, sqlType int, env *dvevaluation.DvObject) (string, []interface{}, error) {
+	params := make([]interface{}, len(sqlAction.Params))
+	for i, expression := range sqlAction.Params {
+		v, err := env.EvaluateAnyTypeExpression(expression)
+		if err != nil {
+			return query, nil, errors.New("Error in parameter " + expression + ": " + err.Error())
+		}
+		params[i] = SqlParamValue(v)
+	}
+	named := make(map[string]interface{}, len(sqlAction.NamedParams))
+	for name, expression := range sqlAction.NamedParams {
+		v, err := env.EvaluateAnyTypeExpression(expression)
+		if err != nil {
+			return query, nil, errors.New("Error in parameter " + name + ": " + err.Error())
+		}
+		named[name] = SqlParamValue(v)
+	}
+	return BindSqlParameters(query, sqlType, params, named)
+}
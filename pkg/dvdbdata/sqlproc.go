@@ -21,6 +21,9 @@ const (
 	SqlKindList      = 4
 	SqlKindRowText   = 5
 	SqlKindTableText = 6
+	SqlKindBegin     = 7
+	SqlKindCommit    = 8
+	SqlKindRollback  = 9
 )
 
 type SqlAction struct {
@@ -28,12 +31,27 @@ type SqlAction struct {
 	Query          string   `json:"query"`
 	QueryOracle    string   `json:"queryOracle"`
 	QueryPostgres  string   `json:"queryPostgres"`
+	QueryMysql     string   `json:"queryMysql"`
+	QuerySqlite    string   `json:"querySqlite"`
 	Result         string   `json:"result"`
 	Kind           string   `json:"kind"`
 	Columns        []string `json:"columns"`
 	EmptyErrorCode int      `json:"emptyErrCode"`
 	Error          string   `json:"error"`
-	KindNo         int
+	// Tx is the name of the transaction begun, committed or rolled back by the step
+	// or used by the query of the step
+	Tx string `json:"tx"`
+	// Params and NamedParams are the expressions calculated in the request environment
+	// and passed to the query as bound arguments
+	Params      []string          `json:"params"`
+	NamedParams map[string]string `json:"named"`
+	// Stream is json, ndjson or csv to write the rows directly to the response without keeping
+	// them in memory; the rows are flushed to the client after every FlushRows rows
+	Stream    string `json:"stream"`
+	FlushRows int    `json:"flushRows"`
+	// Separator is the csv separator, ";" by default or ","
+	Separator string `json:"separator"`
+	KindNo    int
 }
 
 func SqlInit(command string, ctx *dvcontext.RequestContext) ([]interface{}, bool) {
@@ -46,6 +64,10 @@ func SqlInit(command string, ctx *dvcontext.RequestContext) ([]interface{}, bool
 		return nil, false
 	}
 	kind := strings.TrimSpace(strings.ToUpper(sqlAction.Kind))
+	sqlAction.Stream = strings.TrimSpace(strings.ToLower(sqlAction.Stream))
+	if sqlAction.Stream != "" && kind == "" {
+		kind = "TABLE"
+	}
 	switch kind {
 	case "", "UPDATE":
 		sqlAction.KindNo = SqlKindUpdate
@@ -68,23 +90,69 @@ func SqlInit(command string, ctx *dvcontext.RequestContext) ([]interface{}, bool
 	case "TEXT":
 		sqlAction.KindNo = SqlKindTableText
 		break
+	case "BEGIN":
+		sqlAction.KindNo = SqlKindBegin
+		break
+	case "COMMIT":
+		sqlAction.KindNo = SqlKindCommit
+		break
+	case "ROLLBACK":
+		sqlAction.KindNo = SqlKindRollback
+		break
 	default:
 		dvlog.PrintfError("Unknown kind: %s", kind)
 		return nil, false
 	}
+	if sqlAction.Stream != "" {
+		if _, ok := sqlStreamContentTypes[sqlAction.Stream]; !ok {
+			dvlog.PrintfError("Unknown stream %s in %s", sqlAction.Stream, command)
+			return nil, false
+		}
+		if sqlAction.KindNo != SqlKindTable && sqlAction.KindNo != SqlKindTableText {
+			dvlog.PrintfError("Only table or text kind can be streamed in %s", command)
+			return nil, false
+		}
+	}
+	if sqlAction.KindNo >= SqlKindBegin && sqlAction.Tx == "" {
+		dvlog.PrintfError("tx must be specified for %s in %s", kind, command)
+		return nil, false
+	}
 	return []interface{}{sqlAction, ctx}, true
 }
 
 func SqlRun(data []interface{}) bool {
 	sqlAction := data[0].(*SqlAction)
 	ctx := data[1].(*dvcontext.RequestContext)
-	db, err := GetDBConnection(sqlAction.Db)
-	if err != nil {
-		dvlog.PrintfError("Connection to %s failed %v", sqlAction.Db, err)
-		return false
+	if sqlAction.KindNo >= SqlKindBegin {
+		err := sqlAction.runTransactionCommand(ctx)
+		if err != nil {
+			dvlog.PrintfError("Transaction %s failed: %v", sqlAction.Tx, err)
+			return false
+		}
+		return true
 	}
+	var db SqlExecutor
+	var kindMask int
+	if sqlAction.Tx != "" {
+		tx, err := GetSqlTransaction(ctx, sqlAction.Tx)
+		if err != nil {
+			dvlog.PrintfError("%v", err)
+			return false
+		}
+		db = tx
+		kindMask = tx.Db.KindMask
+	} else {
+		conn, err := GetDBConnection(sqlAction.Db)
+		if err != nil {
+			dvlog.PrintfError("Connection to %s failed %v", sqlAction.Db, err)
+			return false
+		}
+		db = conn
+		kindMask = conn.KindMask
+	}
+	var err error
 	query := sqlAction.Query
-	switch db.KindMask {
+	switch kindMask {
 	case SqlOracleLike:
 		if sqlAction.QueryOracle != "" {
 			query = sqlAction.QueryOracle
@@ -93,6 +161,14 @@ func SqlRun(data []interface{}) bool {
 		if sqlAction.QueryPostgres != "" {
 			query = sqlAction.QueryPostgres
 		}
+	case SqlMysqlLike:
+		if sqlAction.QueryMysql != "" {
+			query = sqlAction.QueryMysql
+		}
+	case SqlSqliteLike:
+		if sqlAction.QuerySqlite != "" {
+			query = sqlAction.QuerySqlite
+		}
 	}
 	if strings.HasPrefix(query, "file:") {
 		dat, err := ioutil.ReadFile(query[5:])
@@ -102,14 +178,30 @@ func SqlRun(data []interface{}) bool {
 		}
 		query = string(dat)
 	}
+	var args []interface{}
+	if len(sqlAction.Params) != 0 || len(sqlAction.NamedParams) != 0 {
+		query, args, err = sqlAction.bindParameters(query, kindMask, ctx.GetEnvironment())
+		if err != nil {
+			dvlog.PrintfError("Error %s: %v", query, err)
+			return false
+		}
+	}
 	var res interface{} = nil
 	kind := sqlAction.KindNo
-	if kind == SqlKindUpdate {
-		err = ExecuteSqlData(db, []byte(query))
+	if sqlAction.Stream != "" {
+		var count int
+		count, err = sqlAction.streamRows(db, query, args, ctx)
+		res = count
+	} else if kind == SqlKindUpdate {
+		if args != nil {
+			_, err = db.Exec(query, args...)
+		} else {
+			err = ExecuteSqlData(db, []byte(query))
+		}
 		res = ""
 	} else {
 		var rs *sql.Rows
-		rs, err = db.Query(query)
+		rs, err = db.Query(query, args...)
 		if err == nil {
 			switch kind {
 			case SqlKindSingle:
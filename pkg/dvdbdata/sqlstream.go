@@ -0,0 +1,183 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvdbdata
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvcsv"
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+)
+
+const (
+	SqlStreamJson   = "json"
+	SqlStreamNdJson = "ndjson"
+	SqlStreamCsv    = "csv"
+
+	sqlStreamFlushRows  = 100
+	sqlStreamBufferSize = 32768
+)
+
+var sqlStreamContentTypes = map[string]string{
+	SqlStreamJson:   "application/json",
+	SqlStreamNdJson: "application/x-ndjson",
+	SqlStreamCsv:    "text/csv; charset=utf-8",
+}
+
+// sqlStreamColumns returns the names of the columns of the result set, renamed by the columns of the action
+func (sqlAction *SqlAction) sqlStreamColumns(rs interface{ Columns() ([]string, error) }) ([]string, error) {
+	names, err := rs.Columns()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(names) && i < len(sqlAction.Columns); i++ {
+		if sqlAction.Columns[i] != "" {
+			names[i] = sqlAction.Columns[i]
+		}
+	}
+	return names, nil
+}
+
+func sqlStreamJsonValue(b []byte, v interface{}) []byte {
+	if p, ok := v.([]byte); ok {
+		v = string(p)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(dvevaluation.AnyToStringWithOptions(v, dvevaluation.ConversionOptionSimpleLike))
+	}
+	return append(b, data...)
+}
+
+// appendStreamRow appends the row in the stream format: an object (table kind) or an array (text kind) for json
+// and ndjson, a csv line for csv
+func (sqlAction *SqlAction) appendStreamRow(b []byte, names [][]byte, row []interface{}) []byte {
+	n := len(row)
+	if sqlAction.Stream == SqlStreamCsv {
+		values := make([]string, n)
+		for i, v := range row {
+			if v != nil {
+				values[i] = dvevaluation.AnyToStringWithOptions(v, dvevaluation.ConversionOptionSimpleLike)
+			}
+		}
+		return dvcsv.AppendCsvRow(b, values, sqlAction.csvOptions())
+	}
+	open, end := byte('['), byte(']')
+	if sqlAction.KindNo == SqlKindTable {
+		open, end = '{', '}'
+	}
+	b = append(b, open)
+	for i, v := range row {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		if sqlAction.KindNo == SqlKindTable {
+			b = append(b, names[i]...)
+			b = append(b, ':')
+		}
+		b = sqlStreamJsonValue(b, v)
+	}
+	return append(b, end)
+}
+
+func (sqlAction *SqlAction) csvOptions() int {
+	if sqlAction.Separator == "," {
+		return dvcsv.CsvSeparatorComma
+	}
+	return 0
+}
+
+// streamRows writes the rows of the query directly to the response as they are read from the database,
+// flushing them to the client after every FlushRows rows; the query is cancelled when the client disconnects.
+// It returns the number of the written rows.
+func (sqlAction *SqlAction) streamRows(db SqlExecutor, query string, args []interface{}, ctx *dvcontext.RequestContext) (int, error) {
+	if ctx == nil || ctx.Reader == nil || ctx.Writer == nil {
+		return 0, errors.New("Streaming of " + query + " requires the http request")
+	}
+	rs, err := db.QueryContext(ctx.Reader.Context(), query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rs.Close()
+	columns, err := sqlAction.sqlStreamColumns(rs)
+	if err != nil {
+		return 0, err
+	}
+	n := len(columns)
+	names := make([][]byte, n)
+	for i, name := range columns {
+		names[i], _ = json.Marshal(name)
+	}
+	if !ctx.StartStreaming(sqlStreamContentTypes[sqlAction.Stream]) {
+		return 0, errors.New("Response of " + ctx.Url + " is already sent")
+	}
+	w := bufio.NewWriterSize(ctx.Writer, sqlStreamBufferSize)
+	flusher, _ := ctx.Writer.(http.Flusher)
+	flushRows := sqlAction.FlushRows
+	if flushRows <= 0 {
+		flushRows = sqlStreamFlushRows
+	}
+	b := make([]byte, 0, 1024)
+	switch {
+	case sqlAction.Stream == SqlStreamJson:
+		b = append(b, '[')
+	case sqlAction.Stream == SqlStreamCsv && sqlAction.KindNo == SqlKindTable:
+		b = dvcsv.AppendCsvRow(b, columns, sqlAction.csvOptions())
+	}
+	row := make([]interface{}, n)
+	cols := make([]interface{}, n)
+	for i := 0; i < n; i++ {
+		cols[i] = &row[i]
+	}
+	count := 0
+	for rs.Next() {
+		if err = rs.Scan(cols...); err != nil {
+			break
+		}
+		if count > 0 && sqlAction.Stream == SqlStreamJson {
+			b = append(b, ',')
+		}
+		b = sqlAction.appendStreamRow(b, names, row)
+		if sqlAction.Stream == SqlStreamNdJson {
+			b = append(b, '\n')
+		}
+		if _, err = w.Write(b); err != nil {
+			break
+		}
+		b = b[:0]
+		count++
+		if count%flushRows == 0 {
+			if err = w.Flush(); err != nil {
+				break
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+	if err == nil {
+		err = rs.Err()
+	}
+	if err != nil {
+		// the json array is left unclosed, so that the client does not take the truncated rows for the whole result
+		w.Flush()
+		return count, err
+	}
+	if sqlAction.Stream == SqlStreamJson {
+		b = append(b, ']')
+	}
+	if _, err = w.Write(b); err == nil {
+		err = w.Flush()
+	}
+	if flusher != nil {
+		flusher.Flush()
+	}
+	return count, err
+}
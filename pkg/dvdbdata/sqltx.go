@@ -0,0 +1,99 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvdbdata
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+)
+
+// SqlExecutor runs the queries either by the connection or inside the transaction
+type SqlExecutor interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// SqlTransaction is the transaction bound to the request, the connection is returned to the pool
+// after the transaction is committed or rolled back
+type SqlTransaction struct {
+	Tx *sql.Tx
+	Db *DBConnection
+}
+
+func BeginSqlTransaction(db *DBConnection) (*SqlTransaction, error) {
+	tx, err := db.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &SqlTransaction{Tx: tx, Db: db}, nil
+}
+
+func (tx *SqlTransaction) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.Query(query, args...)
+}
+
+func (tx *SqlTransaction) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.QueryContext(ctx, query, args...)
+}
+
+func (tx *SqlTransaction) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.Exec(query, args...)
+}
+
+func (tx *SqlTransaction) Commit() error {
+	err := tx.Tx.Commit()
+	tx.Db.Close(false)
+	return err
+}
+
+func (tx *SqlTransaction) Rollback() error {
+	err := tx.Tx.Rollback()
+	tx.Db.Close(false)
+	return err
+}
+
+// GetSqlTransaction finds the sql transaction bound to the request under the name
+func GetSqlTransaction(ctx *dvcontext.RequestContext, name string) (*SqlTransaction, error) {
+	if ctx == nil {
+		return nil, errors.New("Transaction " + name + " requires the request context")
+	}
+	tx, ok := ctx.GetTransaction(name).(*SqlTransaction)
+	if !ok {
+		return nil, errors.New("Transaction " + name + " is not started")
+	}
+	return tx, nil
+}
+
+// runTransactionCommand begins, commits or rolls back the transaction named by tx
+func (sqlAction *SqlAction) runTransactionCommand(ctx *dvcontext.RequestContext) error {
+	if ctx == nil {
+		return errors.New("Transaction " + sqlAction.Tx + " requires the request context")
+	}
+	switch sqlAction.KindNo {
+	case SqlKindCommit:
+		return ctx.CommitTransaction(sqlAction.Tx)
+	case SqlKindRollback:
+		return ctx.RollbackTransaction(sqlAction.Tx)
+	}
+	db, err := GetDBConnection(sqlAction.Db)
+	if err != nil {
+		return err
+	}
+	tx, err := BeginSqlTransaction(db)
+	if err != nil {
+		db.Close(false)
+		return err
+	}
+	err = ctx.BeginTransaction(sqlAction.Tx, tx)
+	if err != nil {
+		tx.Rollback()
+	}
+	return err
+}
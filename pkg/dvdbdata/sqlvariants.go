@@ -6,15 +6,9 @@ Copyright 2020 - 2020 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@
 package dvdbdata
 
 func GetDateNowFunction(sqlType int) string {
-	if (sqlType & SqlOracleLike) != 0 {
-		return "CURRENT_TIMESTAMP"
-	}
-	return "NOW()"
+	return GetSqlDialect(sqlType).DateNow
 }
 
 func GetTimestampLessDay(sqlType int) string {
-	if (sqlType & SqlOracleLike) != 0 {
-		return "CURRENT_TIMESTAMP - 1"
-	}
-	return "NOW() - INTERVAL '1 DAY'"
+	return GetSqlDialect(sqlType).TimestampLessDay
 }
@@ -0,0 +1,112 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvdbdata
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvsecurity"
+	"github.com/Dobryvechir/microcore/pkg/dvuser"
+)
+
+// userSqlStore keeps the users and the password reset tokens in the sql tables,
+// the users table is the same as the users table of the oauth token endpoint
+type userSqlStore struct {
+	connection  string
+	users       string
+	resetTokens string
+	mutex       sync.Mutex
+	lastId      int64
+}
+
+func newUserSqlStore(info *dvcontext.UserManagementInfo) (dvuser.UserStore, error) {
+	return &userSqlStore{connection: info.Connection, users: info.Users, resetTokens: info.ResetTokens}, nil
+}
+
+var registeredUserSqlStore = dvuser.RegisterUserStore(dvuser.UserStoreSql, newUserSqlStore)
+
+func (s *userSqlStore) exec(query string, params ...interface{}) (int64, error) {
+	db, err := GetDBConnection(s.connection)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close(false)
+	for i := range params {
+		query = strings.Replace(query, "?", GetSqlPlaceholder(db.KindMask, i+1), 1)
+	}
+	res, err := db.Exec(query, params...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *userSqlStore) FindUser(username string) (*dvuser.UserRecord, error) {
+	fields, err := findSqlRow(s.connection, s.users, oauthUserColumns, "username", username)
+	if err != nil || fields == nil {
+		return nil, err
+	}
+	return dvuser.NewUserRecord(fields), nil
+}
+
+func (s *userSqlStore) CreateUser(user *dvuser.UserRecord) error {
+	s.mutex.Lock()
+	id := time.Now().UnixNano()
+	if id <= s.lastId {
+		id = s.lastId + 1
+	}
+	s.lastId = id
+	s.mutex.Unlock()
+	user.Id = strconv.FormatInt(id, 10)
+	_, err := s.exec("INSERT INTO "+s.users+"("+oauthUserColumns+") VALUES(?,?,?,?,?,?,?,?,?,?)",
+		user.Id, user.Username, user.PasswordHash, user.TenantId, user.Email, user.Name, user.GivenName, user.FamilyName,
+		strings.Join(user.Roles, ","), sqlFlag(user.Disabled))
+	return err
+}
+
+func (s *userSqlStore) UpdateUser(user *dvuser.UserRecord) error {
+	n, err := s.exec("UPDATE "+s.users+" SET password=?,tenant_id=?,email=?,name=?,given_name=?,family_name=?,roles=?,disabled=? WHERE username=?",
+		user.PasswordHash, user.TenantId, user.Email, user.Name, user.GivenName, user.FamilyName,
+		strings.Join(user.Roles, ","), sqlFlag(user.Disabled), user.Username)
+	if err == nil && n == 0 {
+		err = &dvuser.UserError{Status: 404, Message: "user " + user.Username + " is not found"}
+	}
+	return err
+}
+
+// SaveResetToken saves the token and removes the expired tokens
+func (s *userSqlStore) SaveResetToken(token *dvuser.ResetToken) error {
+	if _, err := s.exec("DELETE FROM "+s.resetTokens+" WHERE expires_at<?", dvsecurity.GetCurrentSeconds()); err != nil {
+		return err
+	}
+	_, err := s.exec("INSERT INTO "+s.resetTokens+"(id,username,expires_at) VALUES(?,?,?)", token.Id, token.Username, token.ExpiresAt)
+	return err
+}
+
+// TakeResetToken deletes the token by one statement, so the token can be used only once
+func (s *userSqlStore) TakeResetToken(id string) (*dvuser.ResetToken, error) {
+	fields, err := findSqlRow(s.connection, s.resetTokens, "id,username,expires_at", "id", id)
+	if err != nil || fields == nil {
+		return nil, err
+	}
+	n, err := s.exec("DELETE FROM "+s.resetTokens+" WHERE id=?", id)
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	expiresAt, _ := strconv.ParseInt(fields["expires_at"], 10, 64)
+	return &dvuser.ResetToken{Id: id, Username: fields["username"], ExpiresAt: expiresAt}, nil
+}
+
+func sqlFlag(flag bool) int {
+	if flag {
+		return 1
+	}
+	return 0
+}
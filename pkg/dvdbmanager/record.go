@@ -5,6 +5,7 @@ package dvdbmanager
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
 	"github.com/Dobryvechir/microcore/pkg/dvjson"
@@ -130,3 +131,55 @@ func RecordUpdate(table string, body string) interface{} {
 	}
 	return js
 }
+
+// RecordFindByField returns the records of the table with the field equal to the value
+func RecordFindByField(table string, field string, value string) ([]*dvevaluation.DvVariable, error) {
+	all, err := RecordReadAll(table)
+	if err != nil || all == nil {
+		return nil, err
+	}
+	res := make([]*dvevaluation.DvVariable, 0, 1)
+	for _, record := range all.Fields {
+		if record != nil && record.Kind == dvevaluation.FIELD_OBJECT && record.ReadSimpleChildValue(field) == value {
+			res = append(res, record)
+		}
+	}
+	return res, nil
+}
+
+// RecordToStringMap returns the fields of the record as strings, the arrays are joined by comma
+func RecordToStringMap(record *dvevaluation.DvVariable) map[string]string {
+	fields := make(map[string]string, len(record.Fields))
+	for _, field := range record.Fields {
+		if field == nil {
+			continue
+		}
+		value := ""
+		switch field.Kind {
+		case dvevaluation.FIELD_NULL:
+		case dvevaluation.FIELD_ARRAY:
+			values := make([]string, 0, len(field.Fields))
+			for _, v := range field.Fields {
+				values = append(values, dvtextutils.GetUnquotedString(v.GetStringValue()))
+			}
+			value = strings.Join(values, ",")
+		default:
+			value = dvtextutils.GetUnquotedString(field.GetStringValue())
+		}
+		fields[string(field.Name)] = value
+	}
+	return fields
+}
+
+// RecordResultError returns the error of the result of RecordCreate, RecordUpdate or RecordDelete
+func RecordResultError(res interface{}) error {
+	switch res.(type) {
+	case error:
+		return res.(error)
+	case string:
+		if res.(string) != "" {
+			return errors.New(res.(string))
+		}
+	}
+	return nil
+}
@@ -16,6 +16,7 @@ var ArrayMaster *DvVariable = RegisterMasterVariable("Array", &DvVariable{Kind:
 var StringMaster *DvVariable = RegisterMasterVariable("String", &DvVariable{Kind: FIELD_OBJECT})
 var WindowMaster *DvVariable = RegisterMasterVariable("window", &DvVariable{Kind: FIELD_OBJECT})
 var RegExpMaster *DvVariable = RegisterMasterVariable("RegExp", &DvVariable{Kind: FIELD_OBJECT})
+var PromiseMaster *DvVariable = RegisterMasterVariable("Promise", &DvVariable{Kind: FIELD_OBJECT})
 
 func AssignVariableDirect(parent *DvVariable, val interface{}) error {
 	var value *DvVariable
@@ -423,3 +424,282 @@ func GetIntFromVariableAndIncrementAfter(parent *DvVariable, variableDefinition
 	}
 	return
 }
+
+// SpreadValue marks the value of ...expression, which is expanded by calls and array or object literals
+type SpreadValue struct {
+	Value interface{}
+}
+
+func SpreadOperator(value *dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string, lastVarName string, lastParent *dvgrammar.ExpressionValue) (*dvgrammar.ExpressionValue, error) {
+	var v interface{}
+	if value != nil {
+		v = value.Value
+	}
+	return &dvgrammar.ExpressionValue{Value: &SpreadValue{Value: v}, DataType: dvgrammar.TYPE_OBJECT}, nil
+}
+
+func isSpreadNode(node *dvgrammar.BuildNode) bool {
+	n := len(node.PreAttributes)
+	return n != 0 && node.PreAttributes[n-1] == "..."
+}
+
+func isUndefinedValue(v interface{}) bool {
+	switch v.(type) {
+	case nil:
+		return true
+	case *dvgrammar.ExpressionValue:
+		ev := v.(*dvgrammar.ExpressionValue)
+		if ev == nil || ev.DataType == dvgrammar.TYPE_UNDEFINED {
+			return true
+		}
+		if ev.Value == nil {
+			return ev.DataType != dvgrammar.TYPE_NULL
+		}
+		return isUndefinedValue(ev.Value)
+	case *DvVariable:
+		dv := v.(*DvVariable)
+		return dv == nil || dv.Kind == FIELD_UNDEFINED
+	}
+	return false
+}
+
+func IsNullOrUndefined(v interface{}) bool {
+	if isUndefinedValue(v) {
+		return true
+	}
+	if ev, ok := v.(*dvgrammar.ExpressionValue); ok {
+		if ev.DataType == dvgrammar.TYPE_NULL {
+			return true
+		}
+		v = ev.Value
+	}
+	if v == DvObject_null {
+		return true
+	}
+	dv := AnyToDvVariable(v)
+	return dv != nil && dv.Kind == FIELD_NULL
+}
+
+// SpreadIntoObject copies own enumerable properties of the value into the object the way Object.assign does
+func SpreadIntoObject(dst *DvVariable, v interface{}, excluded []string) error {
+	src := AnyToDvVariable(v)
+	if src == nil {
+		return nil
+	}
+	switch src.Kind {
+	case FIELD_OBJECT, FIELD_FUNCTION:
+		for _, field := range src.Fields {
+			if field == nil || isExcludedKey(string(field.Name), excluded) {
+				continue
+			}
+			err := AssignVariableByKey(dst, string(field.Name), field, false)
+			if err != nil {
+				return err
+			}
+		}
+	case FIELD_ARRAY, FIELD_STRING:
+		items, err := GetIterableElements(nil, src)
+		if err != nil {
+			return err
+		}
+		for i, item := range items {
+			key := strconv.Itoa(i)
+			if isExcludedKey(key, excluded) {
+				continue
+			}
+			err = AssignVariableByKey(dst, key, item, false)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func isExcludedKey(key string, excluded []string) bool {
+	for _, k := range excluded {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// PatternAssigner binds the variable name to the value found by destructuring
+type PatternAssigner func(name string, value interface{}) error
+
+// AssignPattern destructures the value by the target, which is a variable name, a property reference
+// or a pattern like [a, b = 1, ...c] or {a, b: {c}, ...d}; default values are used for undefined parts
+func AssignPattern(target *dvgrammar.BuildNode, value interface{}, context *dvgrammar.ExpressionContext, assigner PatternAssigner) error {
+	if target == nil {
+		return errors.New("Invalid destructuring target")
+	}
+	if target.Operator == "=" && len(target.Children) == 2 {
+		if isUndefinedValue(value) {
+			_, v, err := target.Children[1].ExecuteExpression(context)
+			if err != nil {
+				return err
+			}
+			value = v
+		}
+		target = target.Children[0]
+	}
+	if target.Operator != "" {
+		return errors.New("Invalid destructuring target")
+	}
+	if dvgrammar.IsDestructuringPattern(target) {
+		body := target.Children[0]
+		if body.Operator == "[" {
+			return assignArrayPattern(body.Children, value, context, assigner)
+		}
+		return assignObjectPattern(body.Children, value, context, assigner)
+	}
+	if target.Value == nil || target.Value.DataType != dvgrammar.TYPE_DATA {
+		return errors.New("Invalid destructuring target")
+	}
+	if ev, ok := value.(*dvgrammar.ExpressionValue); ok && ev != nil {
+		value = ev.Value
+	}
+	if len(target.Children) == 0 {
+		return assigner(target.Value.Value, value)
+	}
+	oldVisitorOptions := context.VisitorOptions
+	context.VisitorOptions |= dvgrammar.EVALUATE_OPTION_PARENT | dvgrammar.EVALUATE_OPTION_NAME
+	_, ref, err := target.ExecuteExpression(context)
+	context.VisitorOptions = oldVisitorOptions
+	if err != nil {
+		return err
+	}
+	if ref == nil || ref.Name == "" || ref.Parent == nil {
+		return errors.New("Invalid destructuring target")
+	}
+	parent := AnyToDvVariable(ref.Parent)
+	if parent == nil {
+		return errors.New("Invalid destructuring target")
+	}
+	return AssignPropertyByKey(context, parent, ref.Name, value)
+}
+
+func isPatternHole(node *dvgrammar.BuildNode) bool {
+	return node == nil || node.Operator == "" && node.Value == nil && len(node.Children) == 0
+}
+
+func assignArrayPattern(elements []*dvgrammar.BuildNode, value interface{}, context *dvgrammar.ExpressionContext, assigner PatternAssigner) error {
+	if IsNullOrUndefined(value) {
+		return NewScriptError(ERROR_KIND_TYPE, AnyToString(value)+" is not iterable")
+	}
+	it, err := NewValueIterator(context, value)
+	if err != nil {
+		return err
+	}
+	for _, element := range elements {
+		if isSpreadNode(element) {
+			rest := &DvVariable{Kind: FIELD_ARRAY, Fields: make([]*DvVariable, 0, 7)}
+			for {
+				item, done, err := it.Next()
+				if err != nil {
+					return err
+				}
+				if done {
+					break
+				}
+				rest.Fields = append(rest.Fields, item)
+			}
+			return AssignPattern(element, rest, context, assigner)
+		}
+		item, _, err := it.Next()
+		if err != nil {
+			return err
+		}
+		if isPatternHole(element) {
+			continue
+		}
+		var v interface{}
+		if item != nil {
+			v = item
+		}
+		err = AssignPattern(element, v, context, assigner)
+		if err != nil {
+			it.Close()
+			return err
+		}
+	}
+	return it.Close()
+}
+
+func assignObjectPattern(properties []*dvgrammar.BuildNode, value interface{}, context *dvgrammar.ExpressionContext, assigner PatternAssigner) error {
+	if IsNullOrUndefined(value) {
+		return NewScriptError(ERROR_KIND_TYPE, "Cannot destructure '"+AnyToString(value)+"' as it is null or undefined.")
+	}
+	src := AnyToDvGrammarExpressionValue(value)
+	used := make([]string, 0, len(properties))
+	for _, property := range properties {
+		if property == nil {
+			continue
+		}
+		if isSpreadNode(property) {
+			rest := &DvVariable{Kind: FIELD_OBJECT, Fields: make([]*DvVariable, 0, 7)}
+			err := SpreadIntoObject(rest, src.Value, used)
+			if err != nil {
+				return err
+			}
+			err = AssignPattern(property, rest, context, assigner)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		key, target := property, property
+		switch property.Operator {
+		case ":":
+			key, target = property.Children[0], property.Children[1]
+		case "=":
+			key = property.Children[0]
+		}
+		name := isSimpleKey(key)
+		if name == "" {
+			return errors.New("Invalid destructuring property")
+		}
+		used = append(used, name)
+		item, err := GetExpressionValueChild(src, &dvgrammar.ExpressionValue{Value: name, DataType: dvgrammar.TYPE_STRING}, context)
+		if err != nil {
+			return err
+		}
+		err = AssignPattern(target, item, context, assigner)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BindParameters puts arguments of the function call into the scope by parameter patterns
+func BindParameters(params []*dvgrammar.BuildNode, args []interface{}, context *dvgrammar.ExpressionContext) error {
+	assigner := func(name string, value interface{}) error {
+		context.Scope.Set(name, value)
+		return nil
+	}
+	n := len(args)
+	for i, param := range params {
+		if isSpreadNode(param) {
+			rest := &DvVariable{Kind: FIELD_ARRAY, Fields: make([]*DvVariable, 0, 7)}
+			for ; i < n; i++ {
+				item := AnyToDvVariable(args[i])
+				if item == nil {
+					item = &DvVariable{Kind: FIELD_UNDEFINED}
+				}
+				rest.Fields = append(rest.Fields, item)
+			}
+			return AssignPattern(param, rest, context, assigner)
+		}
+		var arg interface{}
+		if i < n {
+			arg = args[i]
+		}
+		err := AssignPattern(param, arg, context, assigner)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
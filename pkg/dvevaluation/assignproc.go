@@ -20,6 +20,15 @@ func ProcessorAssign(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildN
 	if err != nil {
 		return nil, err
 	}
+	if dvgrammar.IsDestructuringPattern(tree.Children[0]) {
+		err = AssignPattern(tree.Children[0], valueRight, context, func(name string, value interface{}) error {
+			return ThrowScriptError(context.Scope.SetDeep(name, value), tree, context)
+		})
+		if err != nil {
+			return nil, ThrowScriptError(err, tree, context)
+		}
+		return valueRight, nil
+	}
 	oldVisitorOption := context.VisitorOptions
 	context.VisitorOptions = oldVisitorOption | dvgrammar.EVALUATE_OPTION_PARENT | dvgrammar.EVALUATE_OPTION_NAME
 	valueLeft, err := tree.GetChildrenExpressionValue(0, context)
@@ -35,13 +44,16 @@ func ProcessorAssign(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildN
 		valueRightDirect = valueRight.Value
 	}
 	if valueLeft.Parent == nil {
-		context.Scope.SetDeep(valueLeft.Name, valueRightDirect)
+		err = context.Scope.SetDeep(valueLeft.Name, valueRightDirect)
+		if err != nil {
+			return nil, ThrowScriptError(err, tree, context)
+		}
 	} else {
 		leftPart := AnyToDvVariable(valueLeft.Parent)
-		if leftPart == nil || leftPart.Kind != FIELD_ARRAY && leftPart.Kind != FIELD_OBJECT {
+		if leftPart == nil || leftPart.Kind != FIELD_ARRAY && leftPart.Kind != FIELD_OBJECT && leftPart.Kind != FIELD_FUNCTION {
 			return valueRight, errors.New("Invalid left-hand side in assignment")
 		}
-		err = AssignVariableByKey(leftPart, valueLeft.Name, valueRightDirect, false)
+		err = AssignPropertyByKey(context, leftPart, valueLeft.Name, valueRightDirect)
 		if err != nil {
 			return nil, err
 		}
@@ -49,7 +61,7 @@ func ProcessorAssign(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildN
 	return valueRight, nil
 }
 
-func reassign(res *dvgrammar.ExpressionValue, err error, values []*dvgrammar.ExpressionValue, context *dvgrammar.ExpressionContext, operator string) (*dvgrammar.ExpressionValue, error) {
+func reassign(res *dvgrammar.ExpressionValue, err error, values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string) (*dvgrammar.ExpressionValue, error) {
 	if err != nil {
 		return nil, err
 	}
@@ -62,88 +74,94 @@ func reassign(res *dvgrammar.ExpressionValue, err error, values []*dvgrammar.Exp
 		return nil, errors.New("Invalid left-hand side in assignment")
 	}
 	if valueLeft.Parent == nil {
-		context.Scope.SetDeep(valueLeft.Name, res)
+		err = context.Scope.SetDeep(valueLeft.Name, res)
+		if err != nil {
+			return nil, ThrowScriptError(err, tree, context)
+		}
 	} else {
 		dv := AnyToDvVariable(valueLeft.Parent)
 		if dv == nil {
 			return nil, errors.New("Invalid left-hand side in assignment")
 		}
-		AssignVariableByKey(dv, valueLeft.Name, res, false)
+		err = AssignPropertyByKey(context, dv, valueLeft.Name, res)
+		if err != nil {
+			return nil, err
+		}
 	}
 	return res, nil
 }
 
 func ProcessorPlusAssign(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string) (*dvgrammar.ExpressionValue, error) {
 	res, err := ProcessorPlus(values, tree, context, operator)
-	return reassign(res, err, values, context, operator)
+	return reassign(res, err, values, tree, context, operator)
 }
 
 func ProcessorMinusAssign(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string) (*dvgrammar.ExpressionValue, error) {
 	res, err := ProcessorMinus(values, tree, context, operator)
-	return reassign(res, err, values, context, operator)
+	return reassign(res, err, values, tree, context, operator)
 }
 
 func ProcessorMultiplyAssign(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string) (*dvgrammar.ExpressionValue, error) {
 	res, err := ProcessorMultiply(values, tree, context, operator)
-	return reassign(res, err, values, context, operator)
+	return reassign(res, err, values, tree, context, operator)
 }
 
 func ProcessorDivisionAssign(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string) (*dvgrammar.ExpressionValue, error) {
 	res, err := ProcessorDivision(values, tree, context, operator)
-	return reassign(res, err, values, context, operator)
+	return reassign(res, err, values, tree, context, operator)
 }
 
 func ProcessorBooleanAndAssign(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string) (*dvgrammar.ExpressionValue, error) {
 	res, err := ProcessorBooleanAnd(values, tree, context, operator)
-	return reassign(res, err, values, context, operator)
+	return reassign(res, err, values, tree, context, operator)
 }
 
 func ProcessorBooleanOrAssign(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string) (*dvgrammar.ExpressionValue, error) {
 	res, err := ProcessorBooleanOr(values, tree, context, operator)
-	return reassign(res, err, values, context, operator)
+	return reassign(res, err, values, tree, context, operator)
 }
 
 func ProcessorBooleanOrNullableAssign(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string) (*dvgrammar.ExpressionValue, error) {
 	res, err := ProcessorBooleanOrNullable(values, tree, context, operator)
-	return reassign(res, err, values, context, operator)
+	return reassign(res, err, values, tree, context, operator)
 }
 
 func ProcessorBoolAndAssign(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string) (*dvgrammar.ExpressionValue, error) {
 	res, err := ProcessorBoolAnd(values, tree, context, operator)
-	return reassign(res, err, values, context, operator)
+	return reassign(res, err, values, tree, context, operator)
 }
 
 func ProcessorBoolOrAssign(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string) (*dvgrammar.ExpressionValue, error) {
 	res, err := ProcessorBoolOr(values, tree, context, operator)
-	return reassign(res, err, values, context, operator)
+	return reassign(res, err, values, tree, context, operator)
 }
 
 func ProcessorBoolXorAssign(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string) (*dvgrammar.ExpressionValue, error) {
 	res, err := ProcessorBoolXor(values, tree, context, operator)
-	return reassign(res, err, values, context, operator)
+	return reassign(res, err, values, tree, context, operator)
 }
 
 func ProcessorLeftShiftAssign(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string) (*dvgrammar.ExpressionValue, error) {
 	res, err := ProcessorLeftShift(values, tree, context, operator)
-	return reassign(res, err, values, context, operator)
+	return reassign(res, err, values, tree, context, operator)
 }
 
 func ProcessorRightShiftAssign(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string) (*dvgrammar.ExpressionValue, error) {
 	res, err := ProcessorRightShift(values, tree, context, operator)
-	return reassign(res, err, values, context, operator)
+	return reassign(res, err, values, tree, context, operator)
 }
 
 func ProcessorLogicalRightShiftAssign(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string) (*dvgrammar.ExpressionValue, error) {
 	res, err := ProcessorLogicalRightShift(values, tree, context, operator)
-	return reassign(res, err, values, context, operator)
+	return reassign(res, err, values, tree, context, operator)
 }
 
 func ProcessorPowerAssign(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string) (*dvgrammar.ExpressionValue, error) {
 	res, err := ProcessorPower(values, tree, context, operator)
-	return reassign(res, err, values, context, operator)
+	return reassign(res, err, values, tree, context, operator)
 }
 
 func ProcessorPercentAssign(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string) (*dvgrammar.ExpressionValue, error) {
 	res, err := ProcessorPercent(values, tree, context, operator)
-	return reassign(res, err, values, context, operator)
+	return reassign(res, err, values, tree, context, operator)
 }
@@ -23,20 +23,31 @@ var BracketProcessors = map[string]dvgrammar.BracketOperatorVisitor{
 
 func SquareBracketNoParentProcessor(parent *dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, rest []*dvgrammar.BuildNode) (value *dvgrammar.ExpressionValue, parentValue *dvgrammar.ExpressionValue, toStop bool, err error, noNextParent bool) {
 	n := len(tree.Children)
-	val := &DvVariable{Kind: FIELD_ARRAY, Fields: make([]*DvVariable, n)}
+	val := &DvVariable{Kind: FIELD_ARRAY, Fields: make([]*DvVariable, 0, n)}
 	for i := 0; i < n; i++ {
 		t := tree.Children[i]
+		var vl *DvVariable
 		if t != nil {
 			_, r, err := t.ExecuteExpression(context)
 			if err != nil {
 				return nil, nil, false, err, false
 			}
-			if r == nil {
-				continue
+			if r != nil {
+				if spread, ok := r.Value.(*SpreadValue); ok {
+					items, err := GetIterableElements(context, spread.Value)
+					if err != nil {
+						return nil, nil, false, ThrowScriptError(err, t, context), false
+					}
+					val.Fields = append(val.Fields, items...)
+					continue
+				}
+				vl = AnyToDvVariable(r.Value)
 			}
-			vl := AnyToDvVariable(r.Value)
-			val.Fields[i] = vl
 		}
+		val.Fields = append(val.Fields, vl)
+	}
+	if err = context.Budget.Allocate(len(val.Fields)+1, tree, context); err != nil {
+		return
 	}
 	value = &dvgrammar.ExpressionValue{Value: val, DataType: dvgrammar.TYPE_OBJECT}
 	parentValue = parent
@@ -85,12 +96,18 @@ func SquareBracketParentProcessor(parent *dvgrammar.ExpressionValue, tree *dvgra
 		return
 	}
 	value, err = GetExpressionValueChild(parent, value, context)
+	if err != nil {
+		err = ThrowScriptError(err, tree, context)
+	}
 	return
 }
 
 func CurlyBraceNoParentProcessor(parent *dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, rest []*dvgrammar.BuildNode) (value *dvgrammar.ExpressionValue, parentValue *dvgrammar.ExpressionValue, toStop bool, err error, noNextParent bool) {
 	n := len(tree.Children)
 	if n == 0 {
+		if err = context.Budget.Allocate(1, tree, context); err != nil {
+			return
+		}
 		d := &DvVariable{
 			Kind:   FIELD_OBJECT,
 			Fields: make([]*DvVariable, 0, 16),
@@ -111,24 +128,38 @@ func CurlyBraceNoParentProcessor(parent *dvgrammar.ExpressionValue, tree *dvgram
 			Fields: make([]*DvVariable, 0, n),
 		}
 		for i := 0; i < n; i++ {
-			v, ok, err1 := ConvertToObjectKeyPair(tree.Children[i], context)
+			child := tree.Children[i]
+			if isSpreadNode(child) {
+				_, r, err1 := child.ExecuteExpression(context)
+				if err1 == nil && r != nil {
+					err1 = SpreadIntoObject(d, r.Value.(*SpreadValue).Value, nil)
+				}
+				if err1 != nil {
+					err = err1
+					return
+				}
+				continue
+			}
+			v, ok, err1 := ConvertToObjectKeyPair(child, context)
 			if err1 != nil {
 				err = err1
 				return
 			}
 			if ok {
-				d.Fields = append(d.Fields, v)
+				if k := d.IndexOfByKey(v.Name); k >= 0 {
+					d.Fields[k] = v
+				} else {
+					d.Fields = append(d.Fields, v)
+				}
 			}
 		}
+		if err = context.Budget.Allocate(len(d.Fields)+1, tree, context); err != nil {
+			return
+		}
 		value = AnyToDvGrammarExpressionValue(d)
 		return
 	}
-	for i := 0; i < n; i++ {
-		_, value, err = tree.Children[i].ExecuteExpression(context)
-		if err != nil {
-			break
-		}
-	}
+	_, value, err = dvgrammar.BlockExecution(tree.Children, context)
 	noNextParent = true
 	parent = nil
 	return
@@ -143,10 +174,52 @@ func isSimpleKey(node *dvgrammar.BuildNode) string {
 	return ""
 }
 
+func isComputedKey(node *dvgrammar.BuildNode) bool {
+	return node.Operator == "" && node.Value != nil && node.Value.DataType == dvgrammar.TYPE_FUNCTION && node.Value.Value == "" &&
+		len(node.Children) == 1 && node.Children[0].Operator == "[" && len(node.Children[0].Children) == 1
+}
+
+// isMethodShorthand checks for name() {...} or [expression]() {...} in the object literal
+func isMethodShorthand(node *dvgrammar.BuildNode) bool {
+	if node.Operator != "" || node.Value == nil || len(node.PreAttributes) != 0 && !(len(node.PreAttributes) == 1 && hasAsyncModifier(node)) {
+		return false
+	}
+	children := node.Children
+	n := len(children)
+	switch node.Value.DataType {
+	case dvgrammar.TYPE_DATA, dvgrammar.TYPE_STRING:
+		if n != 2 {
+			return false
+		}
+	case dvgrammar.TYPE_FUNCTION:
+		if n != 3 || node.Value.Value != "" || children[0].Operator != "[" || len(children[0].Children) != 1 {
+			return false
+		}
+		children = children[1:]
+	default:
+		return false
+	}
+	return children[0].Operator == "(" && children[1].Operator == "{"
+}
+
+func getObjectLiteralKey(node *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) (string, error) {
+	if node.Value.DataType != dvgrammar.TYPE_FUNCTION {
+		return node.Value.Value, nil
+	}
+	_, v, err := node.Children[0].Children[0].ExecuteExpression(context)
+	if err != nil {
+		return "", err
+	}
+	return AnyToString(v), nil
+}
+
 func isObjectLike(node *dvgrammar.BuildNode) bool {
+	if isSpreadNode(node) || isMethodShorthand(node) {
+		return true
+	}
 	if node.Operator == ":" && len(node.Children) == 2 {
 		k := isSimpleKey(node.Children[0])
-		if k != "" {
+		if k != "" || isComputedKey(node.Children[0]) {
 			return true
 		}
 	} else {
@@ -159,8 +232,29 @@ func isObjectLike(node *dvgrammar.BuildNode) bool {
 }
 
 func ConvertToObjectKeyPair(node *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) (*DvVariable, bool, error) {
+	if isMethodShorthand(node) {
+		k, err := getObjectLiteralKey(node, context)
+		if err != nil {
+			return nil, false, err
+		}
+		children := node.Children[len(node.Children)-2:]
+		params, patterns, err := GetParameterNames(children[0])
+		if err != nil {
+			return nil, false, err
+		}
+		d := createFunctionVariable(&CustomJsFunction{Params: params, Patterns: patterns, Body: children[1].Children, Options: FUNCTION_KIND_NORMAL, Async: hasAsyncModifier(node), Module: getContextModule(context), Closure: captureClosure(context)})
+		d.Name = []byte(k)
+		return d, true, nil
+	}
 	if node.Operator == ":" && len(node.Children) == 2 {
 		k := isSimpleKey(node.Children[0])
+		if k == "" && isComputedKey(node.Children[0]) {
+			var err error
+			k, err = getObjectLiteralKey(node.Children[0], context)
+			if err != nil {
+				return nil, false, err
+			}
+		}
 		if k != "" {
 			_, v, err := node.Children[1].ExecuteExpression(context)
 			if err != nil {
@@ -207,8 +301,13 @@ func ParentheseNoParentProcessor(parent *dvgrammar.ExpressionValue, tree *dvgram
 }
 
 func ParentheseParentProcessor(parent *dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, rest []*dvgrammar.BuildNode) (value *dvgrammar.ExpressionValue, parentValue *dvgrammar.ExpressionValue, toStop bool, err error, noNextParent bool) {
-	if parent == nil || parent.Value == nil {
-		return nil, nil, false, errors.New("Cannot execute function of null"), false
+	if parent == nil || parent.Value == nil || parent.Value == DvObject_null {
+		name := "undefined"
+		if parent != nil && parent.Name != "" {
+			name = parent.Name
+		}
+		err = NewScriptError(ERROR_KIND_TYPE, name+" is not a function")
+		return nil, nil, false, ThrowScriptError(err, tree, context), false
 	}
 	if dv, isDv := parent.Value.(*DvVariable); isDv && dv != nil && dv.Kind == FIELD_FUNCTION && dv.Extra != nil {
 		if dvf, isDvf := dv.Extra.(*DvFunctionObject); isDvf && dvf != nil && dvf.Executor != nil && dvf.Executor.Special {
@@ -280,9 +379,34 @@ func GetStringAtChar(s string, index int) *dvgrammar.ExpressionValue {
 	}
 }
 
+// getNullishName returns null or undefined for the values, which have no properties, otherwise the empty string
+func getNullishName(value *dvgrammar.ExpressionValue) string {
+	if value == nil || value.DataType == dvgrammar.TYPE_UNDEFINED {
+		return "undefined"
+	}
+	if value.Value == DvObject_null {
+		return "null"
+	}
+	if value.Value == nil {
+		if value.DataType == dvgrammar.TYPE_NULL || value.DataType == dvgrammar.TYPE_OBJECT {
+			return "undefined"
+		}
+		return ""
+	}
+	if v, ok := value.Value.(*DvVariable); ok {
+		switch {
+		case v == nil || v.Kind == FIELD_UNDEFINED:
+			return "undefined"
+		case v.Kind == FIELD_NULL:
+			return "null"
+		}
+	}
+	return ""
+}
+
 func GetExpressionValueChild(value *dvgrammar.ExpressionValue, index *dvgrammar.ExpressionValue, context *dvgrammar.ExpressionContext) (*dvgrammar.ExpressionValue, error) {
-	if value == nil {
-		return nil, errors.New("Cannot get child of undefined")
+	if nullish := getNullishName(value); nullish != "" {
+		return nil, NewScriptError(ERROR_KIND_TYPE, "Cannot read properties of "+nullish+" (reading '"+AnyToString(index)+"')")
 	}
 	indexInt64, intOk := AnyToNumberInt(index)
 	indexInt := int(indexInt64)
@@ -292,7 +416,7 @@ func GetExpressionValueChild(value *dvgrammar.ExpressionValue, index *dvgrammar.
 			return GetStringAtChar(AnyToString(value.Value), indexInt), nil
 		case dvgrammar.TYPE_OBJECT:
 			v := AnyToDvVariable(value.Value)
-			if v == nil || v.Kind == FIELD_NULL {
+			if v == nil {
 				return nil, errors.New("Cannot get child of undefined")
 			}
 			if v.Kind == FIELD_STRING {
@@ -316,12 +440,19 @@ func GetExpressionValueChild(value *dvgrammar.ExpressionValue, index *dvgrammar.
 	}
 	child := AnyToString(index)
 	v := AnyToDvVariable(value.Value)
-	r := v.ReadSimpleChild(child)
+	if child == "prototype" && v != nil && v.Kind == FIELD_FUNCTION && v.Prototype != nil {
+		rev := AnyToDvGrammarExpressionValue(v.Prototype)
+		if rev != nil && (context.VisitorOptions&dvgrammar.EVALUATE_OPTION_NAME) != 0 {
+			rev.Name = child
+		}
+		return rev, nil
+	}
+	r := ReadClassChild(v, child)
 	if r != nil {
 		rev := r.ToDvGrammarExpressionValue()
 		if r.Kind==FIELD_FUNCTION && r.Extra!=nil {
-			dvf:=r.Extra.(*DvFunction)
-			if dvf!=nil && dvf.Immediate {
+			dvf, ok := r.Extra.(*DvFunction)
+			if ok && dvf.Immediate {
 				val, err := ExecuteAnyFunction(context, r, v, nil)
 				if err!=nil {
 					return nil, err
@@ -194,7 +194,7 @@ func ProcessorColon(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNo
 	if l != 2 {
 		return nil, errors.New("Insufficient arguments for ternary operators")
 	}
-	condNode, err := GetLeftestQuestionNode(tree)
+	condNode, tree, err := GetLeftestQuestionNode(tree)
 	if err != nil {
 		return nil, err
 	}
@@ -211,30 +211,48 @@ func ProcessorColon(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNo
 	return val, err
 }
 
-func GetLeftestQuestionNode(tree *dvgrammar.BuildNode) (*dvgrammar.BuildNode, error) {
+func getLeftestQuestionPath(tree *dvgrammar.BuildNode, path []*dvgrammar.BuildNode) ([]*dvgrammar.BuildNode, error) {
 	if tree == nil || len(tree.Children) != 2 || tree.Children[0] == nil {
 		return nil, errors.New("No appropriate ? clause for :")
 	}
+	path = append(path, tree)
 	t := tree.Children[0]
 	operator := t.Operator
 	if operator == ":" {
-		return GetLeftestQuestionNode(tree.Children[0])
+		return getLeftestQuestionPath(t, path)
 	}
 	if operator != "?" {
 		return nil, errors.New("No relevant ? clause for :")
 	}
+	path = append(path, t)
 	for len(t.Children) == 2 && t.Children[0] != nil && (t.Children[0].Operator == "?" || t.Children[0].Operator == ":") {
 		if t.Children[0].Operator == ":" {
-			return GetLeftestQuestionNode(t.Children[0])
+			return getLeftestQuestionPath(t.Children[0], path)
 		}
 		t = t.Children[0]
+		path = append(path, t)
 	}
+	return path, nil
+}
+
+// GetLeftestQuestionNode returns the condition of the leftest ? clause and the copy of the tree,
+// in which this clause is replaced by its value; the original tree is kept intact to be evaluated again
+func GetLeftestQuestionNode(tree *dvgrammar.BuildNode) (*dvgrammar.BuildNode, *dvgrammar.BuildNode, error) {
+	path, err := getLeftestQuestionPath(tree, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	t := path[len(path)-1]
 	if len(t.Children) != 2 {
-		return nil, errors.New("Not enough clauses for ? (question) in the ternary operator")
+		return nil, nil, errors.New("Not enough clauses for ? (question) in the ternary operator")
+	}
+	replacement := t.Children[1]
+	for i := len(path) - 2; i >= 0; i-- {
+		node := *path[i]
+		node.Children = append([]*dvgrammar.BuildNode{replacement}, path[i].Children[1:]...)
+		replacement = &node
 	}
-	res := t.Children[0]
-	t.CloneFrom(t.Children[1])
-	return res, nil
+	return t.Children[0], replacement, nil
 }
 
 func ProcessorQuestion(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string) (*dvgrammar.ExpressionValue, error) {
@@ -402,15 +420,23 @@ func ProcessorBoolXor(values []*dvgrammar.ExpressionValue, tree *dvgrammar.Build
 	return &dvgrammar.ExpressionValue{Value: res, DataType: dataType}, nil
 }
 
+// isExactlyEqual compares the values the way === does, the missing value is undefined
+func isExactlyEqual(left *dvgrammar.ExpressionValue, right *dvgrammar.ExpressionValue) bool {
+	if left == nil {
+		left = &dvgrammar.ExpressionValue{Value: nil, DataType: dvgrammar.TYPE_UNDEFINED}
+	}
+	if right == nil {
+		right = &dvgrammar.ExpressionValue{Value: nil, DataType: dvgrammar.TYPE_UNDEFINED}
+	}
+	return left.DataType == right.DataType && AnyCompareAnyWithTypes(left.DataType, left.Value, right.DataType, right.Value) == 0
+}
+
 func ProcessorEqualExact(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string) (*dvgrammar.ExpressionValue, error) {
 	l := len(values)
 	if l != 2 {
 		return nil, errors.New("Only 2 parameters are allowed for " + operator)
 	}
-	res := values[0].DataType == values[1].DataType
-	if res && AnyCompareAnyWithTypes(values[0].DataType, values[0].Value, values[1].DataType, values[1].Value) != 0 {
-		res = false
-	}
+	res := isExactlyEqual(values[0], values[1])
 	return &dvgrammar.ExpressionValue{Value: res, DataType: dvgrammar.TYPE_BOOLEAN}, nil
 }
 
@@ -419,10 +445,7 @@ func ProcessorNotEqualExact(values []*dvgrammar.ExpressionValue, tree *dvgrammar
 	if l != 2 {
 		return nil, errors.New("Only 2 parameters are allowed for " + operator)
 	}
-	res := values[0].DataType == values[1].DataType
-	if res && AnyCompareAnyWithTypes(values[0].DataType, values[0].Value, values[1].DataType, values[1].Value) != 0 {
-		res = false
-	}
+	res := isExactlyEqual(values[0], values[1])
 	return &dvgrammar.ExpressionValue{Value: !res, DataType: dvgrammar.TYPE_BOOLEAN}, nil
 }
 
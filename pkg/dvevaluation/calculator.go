@@ -5,6 +5,7 @@ Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@
 package dvevaluation
 
 import (
+	gocontext "context"
 	"errors"
 	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
 )
@@ -18,7 +19,11 @@ func CalculatorDataGetter(token *dvgrammar.Token, context *dvgrammar.ExpressionC
 			name = token.Value
 			v, ok := context.Scope.Get(name)
 			if !ok {
-				return &dvgrammar.ExpressionValue{DataType: dvgrammar.TYPE_NULL, Name: name}, errors.New(token.Value + " is not defined")
+				return &dvgrammar.ExpressionValue{DataType: dvgrammar.TYPE_NULL, Name: name}, errors.New(token.Value + errorNotDefinedSuffix)
+			}
+			if v == uninitializedBinding {
+				err := NewScriptError(ERROR_KIND_REFERENCE, "Cannot access '"+name+"' before initialization")
+				return nil, ThrowScriptError(err, &dvgrammar.BuildNode{Value: token}, context)
 			}
 			rv := AnyToDvGrammarExpressionValue(v)
 			if rv != nil {
@@ -38,80 +43,115 @@ func CalculatorDataGetter(token *dvgrammar.Token, context *dvgrammar.ExpressionC
 }
 
 var CalculatorOperators = map[string]dvgrammar.InterOperatorVisitor{
-	"+":    ProcessorPlus,
-	"-":    ProcessorMinus,
-	"*":    ProcessorMultiply,
-	"/":    ProcessorDivision,
-	"%":    ProcessorPercent,
-	"&":    ProcessorBoolAnd,
-	"|":    ProcessorBoolOr,
-	"^":    ProcessorBoolXor,
-	"**":   ProcessorPower,
-	"||":   ProcessorBooleanOr,
-	"&&":   ProcessorBooleanAnd,
-	"??":   ProcessorBooleanOrNullable,
-	"<<":   ProcessorLeftShift,
-	">>>":  ProcessorLogicalRightShift,
-	">>":   ProcessorRightShift,
-	"===":  ProcessorEqualExact,
-	"!==":  ProcessorNotEqualExact,
-	"==":   ProcessorEqual,
-	"!=":   ProcessorNotEqual,
-	">":    ProcessorGreaterThan,
-	">=":   ProcessorGreaterEqual,
-	"<":    ProcessorLessThan,
-	"<=":   ProcessorLessEqual,
-	"IN":   ProcessorContainsIn,
-	":":    ProcessorColon,
-	"?":    ProcessorQuestion,
-	"=":    ProcessorAssign,
-	"+=":   ProcessorPlusAssign,
-	"-=":   ProcessorMinusAssign,
-	"*=":   ProcessorMultiplyAssign,
-	"/=":   ProcessorDivisionAssign,
-	"%=":   ProcessorPercentAssign,
-	"&=":   ProcessorBoolAndAssign,
-	"|=":   ProcessorBoolOrAssign,
-	"^=":   ProcessorBoolXorAssign,
-	"**=":  ProcessorPowerAssign,
-	"||=":  ProcessorBooleanOrAssign,
-	"&&=":  ProcessorBooleanAndAssign,
-	"??=":  ProcessorBooleanOrNullableAssign,
-	"<<=":  ProcessorLeftShiftAssign,
-	">>>=": ProcessorLogicalRightShiftAssign,
-	">>=":  ProcessorRightShiftAssign,
-	"in":   ProcessorInInsideFor,
-	"of":   ProcessorOfInsideFor,
-	"else": ProcessorElseInsideIf,
+	"+":          ProcessorPlus,
+	"-":          ProcessorMinus,
+	"*":          ProcessorMultiply,
+	"/":          ProcessorDivision,
+	"%":          ProcessorPercent,
+	"&":          ProcessorBoolAnd,
+	"|":          ProcessorBoolOr,
+	"^":          ProcessorBoolXor,
+	"**":         ProcessorPower,
+	"||":         ProcessorBooleanOr,
+	"&&":         ProcessorBooleanAnd,
+	"??":         ProcessorBooleanOrNullable,
+	"<<":         ProcessorLeftShift,
+	">>>":        ProcessorLogicalRightShift,
+	">>":         ProcessorRightShift,
+	"===":        ProcessorEqualExact,
+	"!==":        ProcessorNotEqualExact,
+	"==":         ProcessorEqual,
+	"!=":         ProcessorNotEqual,
+	">":          ProcessorGreaterThan,
+	">=":         ProcessorGreaterEqual,
+	"<":          ProcessorLessThan,
+	"<=":         ProcessorLessEqual,
+	"IN":         ProcessorContainsIn,
+	":":          ProcessorColon,
+	"?":          ProcessorQuestion,
+	"=":          ProcessorAssign,
+	"+=":         ProcessorPlusAssign,
+	"-=":         ProcessorMinusAssign,
+	"*=":         ProcessorMultiplyAssign,
+	"/=":         ProcessorDivisionAssign,
+	"%=":         ProcessorPercentAssign,
+	"&=":         ProcessorBoolAndAssign,
+	"|=":         ProcessorBoolOrAssign,
+	"^=":         ProcessorBoolXorAssign,
+	"**=":        ProcessorPowerAssign,
+	"||=":        ProcessorBooleanOrAssign,
+	"&&=":        ProcessorBooleanAndAssign,
+	"??=":        ProcessorBooleanOrNullableAssign,
+	"<<=":        ProcessorLeftShiftAssign,
+	">>>=":       ProcessorLogicalRightShiftAssign,
+	">>=":        ProcessorRightShiftAssign,
+	"in":         ProcessorInInsideFor,
+	"of":         ProcessorOfInsideFor,
+	"else":       ProcessorElseInsideIf,
+	"instanceof": ProcessorInstanceOf,
 }
 
 func CalculatorEvaluator(data []byte, scope dvgrammar.ScopeInterface, reference *dvgrammar.SourceReference, visitorOptions int) (*dvgrammar.ExpressionValue, error) {
+	return CalculatorEvaluatorWithLimits(data, scope, reference, visitorOptions, nil, nil)
+}
+
+// CalculatorEvaluatorWithLimits evaluates with the given limits (the default ones if nil);
+// the evaluation is stopped when done is cancelled
+func CalculatorEvaluatorWithLimits(data []byte, scope dvgrammar.ScopeInterface, reference *dvgrammar.SourceReference, visitorOptions int, limits *dvgrammar.ExecutionLimits, done gocontext.Context) (*dvgrammar.ExpressionValue, error) {
+	budget := dvgrammar.NewExecutionBudget(limits, done)
+	defer budget.Release()
+	return CalculatorEvaluatorWithBudget(data, scope, reference, visitorOptions, budget)
+}
+
+// CalculatorEvaluatorWithBudget evaluates within the budget, which is released by the caller
+func CalculatorEvaluatorWithBudget(data []byte, scope dvgrammar.ScopeInterface, reference *dvgrammar.SourceReference, visitorOptions int, budget *dvgrammar.ExecutionBudget) (*dvgrammar.ExpressionValue, error) {
 	context := &dvgrammar.ExpressionContext{
 		Scope:          scope,
 		Reference:      reference,
 		Rules:          CalculatorRules,
 		VisitorOptions: visitorOptions,
+		Budget:         budget,
 	}
-	return dvgrammar.FastEvaluation(data, context)
+	value, err := dvgrammar.FastEvaluation(data, context)
+	err = RunEventLoop(context, err)
+	return value, err
 }
 
 var CalculatorUnaryMap = map[string]dvgrammar.UnaryVisitor{
-	"!":  LogicalNotOperator,
-	"~":  BitwiseNotOperator,
-	"+":  UnaryPlusOperator,
-	"-":  UnaryMinusOperator,
-	"++": PrePlusPlusOperator,
-	"--": PreMinusMinusOperator,
+	"!":     LogicalNotOperator,
+	"~":     BitwiseNotOperator,
+	"+":     UnaryPlusOperator,
+	"-":     UnaryMinusOperator,
+	"++":    PrePlusPlusOperator,
+	"--":    PreMinusMinusOperator,
+	"new":   NewOperator,
+	"...":   SpreadOperator,
+	"await": AwaitOperator,
+	"async": AsyncOperator,
 }
 
 var LanguageOperatorMap = map[string]dvgrammar.LanguageOperatorVisitor{
-	"return":   ReturnOperator,
-	"break":    BreakOperator,
-	"continue": ContinueOperator,
-	"=>":       ArrowFunctionOperator,
-	"for":      ForCycleOperator,
-	"if":       IfClauseOperator,
-	"delete":   DeleteOperator,
+	"return":         ReturnOperator,
+	"break":          BreakOperator,
+	"continue":       ContinueOperator,
+	"=>":             ArrowFunctionOperator,
+	"for":            ForCycleOperator,
+	"if":             IfClauseOperator,
+	"delete":         DeleteOperator,
+	"while":          WhileCycleOperator,
+	"do":             DoWhileCycleOperator,
+	"switch":         SwitchOperator,
+	"case":           SwitchClauseOperator,
+	"default":        SwitchClauseOperator,
+	"try":            TryOperator,
+	"throw":          ThrowOperator,
+	"let":            DeclarationOperator,
+	"const":          DeclarationOperator,
+	"function":       FunctionOperator,
+	"async function": FunctionOperator,
+	"class":          ClassOperator,
+	"import":         ImportOperator,
+	"export":         ExportOperator,
 }
 
 var CalculatorPostUnaryMap = map[string]dvgrammar.UnaryVisitor{
@@ -133,12 +173,15 @@ func SetNodeValue(tree *dvgrammar.BuildNode, v interface{}, dataType int, contex
 	if lastParent != nil && lastParent.Name != "" && lastParent.Value != nil {
 		dv := AnyToDvVariable(lastParent.Value)
 		if dv != nil && (dv.Kind == FIELD_ARRAY || dv.Kind == FIELD_OBJECT || dv.Kind == FIELD_FUNCTION) {
-			err := AssignVariableByKey(dv, lastVarName, v, false)
+			err := AssignPropertyByKey(context, dv, lastVarName, v)
 			return err
 		}
 	}
 	if lastVarName != "" {
-		context.Scope.SetDeep(lastVarName, v)
+		err := context.Scope.SetDeep(lastVarName, v)
+		if err != nil {
+			return ThrowScriptError(err, tree, context)
+		}
 	}
 	return nil
 }
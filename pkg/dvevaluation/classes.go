@@ -0,0 +1,447 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvevaluation
+
+import (
+	"errors"
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
+)
+
+// JsClass is kept in Extra of the class variable; methods are in its Prototype, static members in its Fields
+type JsClass struct {
+	Name        string
+	Constructor *CustomJsFunction
+	Parent      *DvVariable
+	Fields      []*dvgrammar.BuildNode
+}
+
+// SuperBinding is kept in Extra of the 'super' variable available in class methods
+type SuperBinding struct {
+	Class *DvVariable
+	This  interface{}
+}
+
+// JsPrototype is kept in Extra of the prototype object created for the function or class,
+// it provides the not enumerable 'constructor' of the prototype and its instances
+type JsPrototype struct {
+	Constructor *DvVariable
+}
+
+func newPrototypeObject(ctor *DvVariable) *DvVariable {
+	return &DvVariable{Kind: FIELD_OBJECT, Fields: make([]*DvVariable, 0, 7), Extra: &JsPrototype{Constructor: ctor}}
+}
+
+func createFunctionVariable(fn *CustomJsFunction) *DvVariable {
+	v := &DvVariable{Kind: FIELD_FUNCTION, Extra: fn}
+	v.Prototype = newPrototypeObject(v)
+	return v
+}
+
+func declareNamedDefinition(tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, v *DvVariable) (*dvgrammar.ExpressionValue, error) {
+	name := ""
+	if tree.Value != nil {
+		name = tree.Value.Value
+	}
+	if name != "" && tree.Parent == nil {
+		err := context.Scope.Declare(name, v, dvgrammar.DECLARATION_VAR)
+		if err != nil {
+			return nil, ThrowScriptError(err, tree, context)
+		}
+	}
+	rev := AnyToDvGrammarExpressionValue(v)
+	if rev != nil {
+		rev.Name = name
+	}
+	return rev, nil
+}
+
+func FunctionOperator(tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) (int, *dvgrammar.ExpressionValue, error) {
+	if len(tree.Children) != 1 || tree.Children[0] == nil || len(tree.Children[0].Children) != 2 {
+		return dvgrammar.FLOW_NORMAL, nil, errors.New("Function requires parameters in round brackets and body in curly brackets")
+	}
+	parts := tree.Children[0].Children
+	params, patterns, err := GetParameterNames(parts[0])
+	if err != nil {
+		return dvgrammar.FLOW_NORMAL, nil, err
+	}
+	if parts[1] == nil || parts[1].Operator != "{" {
+		return dvgrammar.FLOW_NORMAL, nil, errors.New("Function body in curly brackets expected")
+	}
+	async := tree.Operator == "async function"
+	fn := createFunctionVariable(&CustomJsFunction{Params: params, Patterns: patterns, Body: parts[1].Children, Options: FUNCTION_KIND_NORMAL, Async: async, Module: getContextModule(context), Closure: captureClosure(context)})
+	val, err := declareNamedDefinition(tree, context, fn)
+	return dvgrammar.FLOW_NORMAL, val, err
+}
+
+func ClassOperator(tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) (int, *dvgrammar.ExpressionValue, error) {
+	if len(tree.Children) != 1 || tree.Children[0] == nil {
+		return dvgrammar.FLOW_NORMAL, nil, errors.New("Class body in curly brackets expected")
+	}
+	var parent *DvVariable
+	var body *dvgrammar.BuildNode
+	for _, part := range tree.Children[0].Children {
+		if part == nil {
+			continue
+		}
+		switch part.Operator {
+		case "extends":
+			_, val, err := dvgrammar.BuildNodeExecution(part.Children, context)
+			if err != nil {
+				return dvgrammar.FLOW_NORMAL, nil, err
+			}
+			if val == nil || val.DataType == dvgrammar.TYPE_NULL || val.DataType == dvgrammar.TYPE_UNDEFINED {
+				continue
+			}
+			parent = AnyToDvVariable(val.Value)
+			if parent == nil || parent.Kind != FIELD_FUNCTION {
+				err = NewScriptError(ERROR_KIND_TYPE, "Class extends value "+AnyToString(val.Value)+" is not a constructor or null")
+				return dvgrammar.FLOW_NORMAL, nil, ThrowScriptError(err, part, context)
+			}
+		case "{":
+			body = part
+		}
+	}
+	if body == nil {
+		return dvgrammar.FLOW_NORMAL, nil, errors.New("Class body in curly brackets expected")
+	}
+	name := ""
+	if tree.Value != nil {
+		name = tree.Value.Value
+	}
+	cls, err := CreateClass(context, name, parent, body.Children)
+	if err != nil {
+		return dvgrammar.FLOW_NORMAL, nil, err
+	}
+	val, err := declareNamedDefinition(tree, context, cls)
+	return dvgrammar.FLOW_NORMAL, val, err
+}
+
+func getClassMemberModifiers(node *dvgrammar.BuildNode) (static bool, getter bool, setter bool) {
+	for _, attr := range node.PreAttributes {
+		switch attr {
+		case "static":
+			static = true
+		case "get":
+			getter = true
+		case "set":
+			setter = true
+		}
+	}
+	return
+}
+
+func CreateClass(context *dvgrammar.ExpressionContext, name string, parent *DvVariable, body []*dvgrammar.BuildNode) (*DvVariable, error) {
+	jsClass := &JsClass{Name: name, Parent: parent}
+	cls := &DvVariable{Kind: FIELD_FUNCTION, Fields: make([]*DvVariable, 0, 7), Extra: jsClass}
+	proto := newPrototypeObject(cls)
+	if parent != nil {
+		proto.Prototype = parent.Prototype
+	}
+	cls.Prototype = proto
+	var statics []*dvgrammar.BuildNode
+	for _, member := range body {
+		if member == nil || member.Operator == "" && member.Value == nil && len(member.Children) == 0 {
+			continue
+		}
+		nameNode := member
+		if member.Operator == "=" && len(member.Children) == 2 {
+			nameNode = member.Children[0]
+		} else if member.Operator != "" {
+			return nil, errors.New("Unexpected " + member.Operator + " in class body")
+		}
+		key, err := ExtractPureName(nameNode)
+		if err != nil {
+			return nil, errors.New("Class member name expected")
+		}
+		static, getter, setter := getClassMemberModifiers(nameNode)
+		target := proto
+		if static {
+			target = cls
+		}
+		children := nameNode.Children
+		if nameNode == member && len(children) == 2 && children[0].Operator == "(" && children[1].Operator == "{" {
+			params, patterns, err := GetParameterNames(children[0])
+			if err != nil {
+				return nil, err
+			}
+			fn := &CustomJsFunction{Params: params, Patterns: patterns, Body: children[1].Children, Options: FUNCTION_KIND_NORMAL, Class: cls, Async: hasAsyncModifier(nameNode), Module: getContextModule(context), Closure: captureClosure(context)}
+			switch {
+			case getter || setter:
+				defineAccessor(target, key, fn, setter)
+			case !static && key == "constructor":
+				jsClass.Constructor = fn
+			default:
+				target.Fields = append(target.Fields, &DvVariable{Name: []byte(key), Kind: FIELD_FUNCTION, Extra: fn})
+			}
+			continue
+		}
+		if len(children) != 0 || getter || setter {
+			return nil, errors.New("Unexpected class member " + key)
+		}
+		if static {
+			statics = append(statics, member)
+		} else {
+			jsClass.Fields = append(jsClass.Fields, member)
+		}
+	}
+	for _, member := range statics {
+		err := initializeClassField(context, member, cls)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cls, nil
+}
+
+func undefinedAccessor(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+func defineAccessor(target *DvVariable, key string, fn *CustomJsFunction, isSetter bool) {
+	var accessor *DvFunction
+	field := target.ReadSimpleChild(key)
+	if field != nil && field.Kind == FIELD_FUNCTION {
+		accessor, _ = field.Extra.(*DvFunction)
+	}
+	if accessor == nil || !accessor.Immediate {
+		accessor = &DvFunction{Name: key, Immediate: true, Fn: undefinedAccessor}
+		target.Fields = append(target.Fields, &DvVariable{Name: []byte(key), Kind: FIELD_FUNCTION, Extra: accessor})
+	}
+	if isSetter {
+		accessor.Setter = func(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+			return ExecuteAnyFunction(context, fn, thisVariable, params)
+		}
+	} else {
+		accessor.Fn = func(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+			return ExecuteAnyFunction(context, fn, thisVariable, nil)
+		}
+	}
+}
+
+func initializeClassField(context *dvgrammar.ExpressionContext, member *dvgrammar.BuildNode, this *DvVariable) error {
+	nameNode := member
+	var value interface{}
+	if member.Operator == "=" {
+		nameNode = member.Children[0]
+		context.Scope.StackPush(FUNCTION_KIND_NORMAL)
+		context.Scope.Set("this", this)
+		_, val, err := member.Children[1].ExecuteExpression(context)
+		context.Scope.StackPop()
+		if err != nil {
+			return err
+		}
+		if val != nil {
+			value = val.Value
+		}
+	}
+	return AssignVariableByKey(this, nameNode.Value.Value, value, false)
+}
+
+func initializeClassFields(context *dvgrammar.ExpressionContext, cls *DvVariable, this *DvVariable) error {
+	for _, member := range cls.Extra.(*JsClass).Fields {
+		err := initializeClassField(context, member, this)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadClassChild reads the child and, for classes, looks for inherited static members;
+// the 'constructor' of objects is taken from their prototype chain
+func ReadClassChild(v *DvVariable, child string) *DvVariable {
+	r := v.ReadSimpleChild(child)
+	if r == nil && child == "constructor" && v != nil && v.Kind == FIELD_OBJECT {
+		for p := v; p != nil; p = p.Prototype {
+			if proto, ok := p.Extra.(*JsPrototype); ok {
+				return proto.Constructor
+			}
+		}
+	}
+	for r == nil && v != nil && v.Kind == FIELD_FUNCTION {
+		jsClass, ok := v.Extra.(*JsClass)
+		if !ok || jsClass.Parent == nil {
+			break
+		}
+		v = jsClass.Parent
+		r = v.ReadSimpleChild(child)
+	}
+	return r
+}
+
+// AssignPropertyByKey calls the setter if the property is an accessor, otherwise it assigns the value
+func AssignPropertyByKey(context *dvgrammar.ExpressionContext, parent *DvVariable, key string, value interface{}) error {
+	field := parent.ReadSimpleChild(key)
+	if field != nil && field.Kind == FIELD_FUNCTION {
+		accessor, ok := field.Extra.(*DvFunction)
+		if ok && accessor.Immediate && accessor.Setter != nil {
+			_, err := accessor.Setter(context, parent, []interface{}{value})
+			return err
+		}
+	}
+	return AssignVariableByKey(parent, key, value, false)
+}
+
+func NewSuperVariable(cls *DvVariable, this interface{}) *DvVariable {
+	jsClass, ok := cls.Extra.(*JsClass)
+	if !ok || jsClass.Parent == nil {
+		return nil
+	}
+	prototype := jsClass.Parent.Prototype
+	if AnyToDvVariable(this) == cls {
+		prototype = jsClass.Parent
+	}
+	return &DvVariable{
+		Kind:      FIELD_FUNCTION,
+		Extra:     &SuperBinding{Class: cls, This: this},
+		Prototype: prototype,
+	}
+}
+
+func resolveSuperThis(this interface{}) interface{} {
+	v := AnyToDvVariable(this)
+	if v != nil && v.Kind == FIELD_FUNCTION {
+		if super, ok := v.Extra.(*SuperBinding); ok {
+			return super.This
+		}
+	}
+	return this
+}
+
+// CallParentConstructor executes super(...) in the constructor of the derived class
+func (super *SuperBinding) CallParentConstructor(context *dvgrammar.ExpressionContext, args []interface{}) error {
+	this := AnyToDvVariable(super.This)
+	if this == nil {
+		return NewScriptError(ERROR_KIND_REFERENCE, "'super' keyword unexpected here")
+	}
+	err := InitializeInstance(context, super.Class.Extra.(*JsClass).Parent, this, args)
+	if err != nil {
+		return err
+	}
+	return initializeClassFields(context, super.Class, this)
+}
+
+// InitializeInstance runs field initializers and constructors of the class chain for the new object
+func InitializeInstance(context *dvgrammar.ExpressionContext, ctor *DvVariable, this *DvVariable, args []interface{}) error {
+	switch ctor.Extra.(type) {
+	case *JsClass:
+		jsClass := ctor.Extra.(*JsClass)
+		if jsClass.Constructor == nil {
+			if jsClass.Parent != nil {
+				err := InitializeInstance(context, jsClass.Parent, this, args)
+				if err != nil {
+					return err
+				}
+			}
+			return initializeClassFields(context, ctor, this)
+		}
+		if jsClass.Parent == nil {
+			err := initializeClassFields(context, ctor, this)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := ExecuteAnyFunction(context, jsClass.Constructor, this, args)
+		return err
+	case *CustomJsFunction:
+		_, err := ExecuteAnyFunction(context, ctor.Extra, this, args)
+		return err
+	case *DvFunction:
+		v, err := ctor.Extra.(*DvFunction).Fn(context, nil, args)
+		if err != nil {
+			return err
+		}
+		adoptNativeInstance(this, AnyToDvVariable(v))
+		return nil
+	}
+	return NewScriptError(ERROR_KIND_TYPE, "Class extends value is not a constructor")
+}
+
+func adoptNativeInstance(this *DvVariable, v *DvVariable) {
+	if v == nil {
+		return
+	}
+	this.Fields = append(this.Fields, v.Fields...)
+	this.Value = v.Value
+	this.Extra = v.Extra
+	if _, ok := v.Extra.(*ErrorValue); ok {
+		this.Extra = &ErrorValue{Object: this}
+	}
+}
+
+// ConstructObject creates the object the way 'new' does
+func ConstructObject(context *dvgrammar.ExpressionContext, ctor interface{}, args []interface{}) (interface{}, error) {
+	dv := AnyToDvVariable(ctor)
+	if dv == nil || dv.Kind != FIELD_FUNCTION || dv.Extra == nil {
+		return nil, NewScriptError(ERROR_KIND_TYPE, AnyToString(ctor)+" is not a constructor")
+	}
+	switch dv.Extra.(type) {
+	case *JsClass, *CustomJsFunction:
+		this := &DvVariable{Kind: FIELD_OBJECT, Fields: make([]*DvVariable, 0, 7), Prototype: dv.Prototype}
+		err := InitializeInstance(context, dv, this, args)
+		return this, err
+	case *DvFunction:
+		return dv.Extra.(*DvFunction).Fn(context, nil, args)
+	case *DvFunctionObject:
+		return dv.Extra.(*DvFunctionObject).ExecuteDvFunctionWithTreeArguments(args, context)
+	}
+	return nil, NewScriptError(ERROR_KIND_TYPE, AnyToString(ctor)+" is not a constructor")
+}
+
+func NewOperator(value *dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string, lastVarName string, lastParent *dvgrammar.ExpressionValue) (*dvgrammar.ExpressionValue, error) {
+	var args []interface{}
+	var err error
+	if tree != nil {
+		args, err = CalculateAllNodeParams(tree.Children, context)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if value == nil || value.Value == nil {
+		err = NewScriptError(ERROR_KIND_TYPE, lastVarName+" is not a constructor")
+		return nil, ThrowScriptError(err, tree, context)
+	}
+	res, err := ConstructObject(context, value.Value, args)
+	if err != nil {
+		return nil, ThrowScriptError(err, tree, context)
+	}
+	return AnyToDvGrammarExpressionValue(res), nil
+}
+
+// IsInstanceOf checks whether the prototype chain of the object contains the constructor or its prototype
+func IsInstanceOf(obj *DvVariable, ctor *DvVariable) bool {
+	if obj == nil || ctor == nil {
+		return false
+	}
+	if ctor == ObjectMaster && (obj.Kind == FIELD_OBJECT || obj.Kind == FIELD_ARRAY || obj.Kind == FIELD_FUNCTION) {
+		return true
+	}
+	if ctor == ArrayMaster && obj.Kind == FIELD_ARRAY {
+		return true
+	}
+	for p := obj.Prototype; p != nil; p = p.Prototype {
+		if p == ctor || p == ctor.Prototype {
+			return true
+		}
+	}
+	return false
+}
+
+func ProcessorInstanceOf(values []*dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string) (*dvgrammar.ExpressionValue, error) {
+	if len(values) != 2 || values[1] == nil {
+		return nil, errors.New("instanceof requires 2 operands")
+	}
+	ctor := AnyToDvVariable(values[1].Value)
+	if ctor == nil || ctor.Kind != FIELD_FUNCTION && ctor.Kind != FIELD_OBJECT {
+		err := NewScriptError(ERROR_KIND_TYPE, "Right-hand side of 'instanceof' is not callable")
+		return nil, ThrowScriptError(err, tree, context)
+	}
+	var obj *DvVariable
+	if values[0] != nil {
+		obj = AnyToDvVariable(values[0].Value)
+	}
+	return &dvgrammar.ExpressionValue{DataType: dvgrammar.TYPE_BOOLEAN, Value: IsInstanceOf(obj, ctor)}, nil
+}
@@ -0,0 +1,145 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvevaluation
+
+import (
+	"strconv"
+)
+
+const (
+	COLLECTION_MAP = iota
+	COLLECTION_SET
+	COLLECTION_WEAK_MAP
+)
+
+// DvCollection keeps the entries of Map, Set and WeakMap in the insertion order,
+// primitive keys are compared by value and objects by reference; the sets have no values
+type DvCollection struct {
+	Kind   int
+	Keys   []*DvVariable
+	Values []*DvVariable
+	index  map[interface{}]int
+}
+
+type collectionPrimitiveKey struct {
+	kind  int
+	value string
+}
+
+func NewDvCollection(kind int) *DvCollection {
+	return &DvCollection{Kind: kind, index: make(map[interface{}]int)}
+}
+
+// GetDvCollection returns the Map, Set or WeakMap entries kept by the variable or nil
+func GetDvCollection(v *DvVariable) *DvCollection {
+	if v == nil || v.Extra == nil {
+		return nil
+	}
+	c, _ := v.Extra.(*DvCollection)
+	return c
+}
+
+func collectionKey(v *DvVariable) interface{} {
+	if v == nil {
+		return collectionPrimitiveKey{kind: FIELD_UNDEFINED}
+	}
+	switch v.Kind {
+	case FIELD_UNDEFINED, FIELD_NULL:
+		return collectionPrimitiveKey{kind: v.Kind}
+	case FIELD_NUMBER:
+		s := string(v.Value)
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			if f == 0 {
+				f = 0
+			}
+			s = strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		return collectionPrimitiveKey{kind: FIELD_NUMBER, value: s}
+	case FIELD_BOOLEAN, FIELD_STRING:
+		return collectionPrimitiveKey{kind: v.Kind, value: string(v.Value)}
+	}
+	return v
+}
+
+func (c *DvCollection) Size() int {
+	return len(c.Keys)
+}
+
+func (c *DvCollection) Has(key *DvVariable) bool {
+	_, ok := c.index[collectionKey(key)]
+	return ok
+}
+
+func (c *DvCollection) Get(key *DvVariable) (*DvVariable, bool) {
+	i, ok := c.index[collectionKey(key)]
+	if !ok || c.Values == nil {
+		return nil, ok
+	}
+	return c.Values[i], true
+}
+
+// Set adds the entry or replaces the value of the existing one keeping its position
+func (c *DvCollection) Set(key *DvVariable, value *DvVariable) {
+	if key == nil {
+		key = &DvVariable{Kind: FIELD_UNDEFINED}
+	}
+	if value == nil {
+		value = &DvVariable{Kind: FIELD_UNDEFINED}
+	}
+	k := collectionKey(key)
+	if i, ok := c.index[k]; ok {
+		if c.Kind != COLLECTION_SET {
+			c.Values[i] = value
+		}
+		return
+	}
+	c.index[k] = len(c.Keys)
+	c.Keys = append(c.Keys, key)
+	if c.Kind != COLLECTION_SET {
+		c.Values = append(c.Values, value)
+	}
+}
+
+func (c *DvCollection) Delete(key *DvVariable) bool {
+	k := collectionKey(key)
+	i, ok := c.index[k]
+	if !ok {
+		return false
+	}
+	c.Keys = append(c.Keys[:i], c.Keys[i+1:]...)
+	if c.Values != nil {
+		c.Values = append(c.Values[:i], c.Values[i+1:]...)
+	}
+	delete(c.index, k)
+	for j := i; j < len(c.Keys); j++ {
+		c.index[collectionKey(c.Keys[j])] = j
+	}
+	return true
+}
+
+func (c *DvCollection) Clear() {
+	c.Keys = nil
+	c.Values = nil
+	c.index = make(map[interface{}]int)
+}
+
+// ToPlainVariable gives the serializable form of the collection: an array for Set
+// and an object with the keys converted to strings for Map and WeakMap
+func (c *DvCollection) ToPlainVariable() *DvVariable {
+	n := len(c.Keys)
+	if c.Kind == COLLECTION_SET {
+		fields := make([]*DvVariable, n)
+		copy(fields, c.Keys)
+		return &DvVariable{Kind: FIELD_ARRAY, Fields: fields}
+	}
+	res := &DvVariable{Kind: FIELD_OBJECT, Fields: make([]*DvVariable, 0, n)}
+	for i := 0; i < n; i++ {
+		field := *c.Values[i]
+		field.Name = []byte(c.Keys[i].GetStringValue())
+		res.Fields = append(res.Fields, &field)
+	}
+	return res
+}
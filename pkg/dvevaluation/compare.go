@@ -100,6 +100,10 @@ func getCompareType(kind int, val interface{}) int {
 				res = COMPARE_AS_NUMBERS
 			case FIELD_BOOLEAN, FIELD_NULL:
 				res = COMPARE_AS_INTEGERS
+			case FIELD_OBJECT:
+				if _, ok := GetPrimitiveConvertible(v); ok {
+					res = COMPARE_AS_NUMBERS
+				}
 			}
 		}
 	}
@@ -17,10 +17,47 @@ type MethodToStringConverter func(v interface{}) (string, bool)
 
 var poolStringConverters = make([]MethodToStringConverter, 0, 7)
 
+// PrimitiveStringConvertible is implemented by native values kept in DvVariable.Extra
+// which have their own string representation (such as Error)
+type PrimitiveStringConvertible interface {
+	ToPrimitiveString() string
+}
+
+// PrimitiveConvertible is implemented by native values kept in DvVariable.Extra
+// (such as Date) which behave as primitives in arithmetic, comparison and concatenation
+type PrimitiveConvertible interface {
+	PrimitiveStringConvertible
+	ToPrimitiveNumber() float64
+}
+
+// JsonConvertible is implemented by native values kept in DvVariable.Extra
+// (such as Date) which are written to json as another value
+type JsonConvertible interface {
+	ToJsonVariable() *DvVariable
+}
+
 func RegisterToStringConverter(converter MethodToStringConverter) {
 	poolStringConverters = append(poolStringConverters, converter)
 }
 
+func GetPrimitiveConvertible(v interface{}) (PrimitiveConvertible, bool) {
+	dv, ok := v.(*DvVariable)
+	if !ok || dv == nil || dv.Extra == nil {
+		return nil, false
+	}
+	p, ok := dv.Extra.(PrimitiveConvertible)
+	return p, ok
+}
+
+func GetPrimitiveStringConvertible(v interface{}) (PrimitiveStringConvertible, bool) {
+	dv, ok := v.(*DvVariable)
+	if !ok || dv == nil || dv.Extra == nil {
+		return nil, false
+	}
+	p, ok := dv.Extra.(PrimitiveStringConvertible)
+	return p, ok
+}
+
 func AnyToString(v interface{}) string {
 	return AnyToStringWithOptions(v, ConversionOptionJsonLike)
 }
@@ -63,6 +100,9 @@ func AnyToStringWithOptions(v interface{}, options int) string {
 			return AnyToString(b.Value)
 		}
 	default:
+		if p, ok := GetPrimitiveStringConvertible(v); ok {
+			return p.ToPrimitiveString()
+		}
 		n := len(poolStringConverters)
 		done := false
 		for i := 0; i < n; i++ {
@@ -142,6 +182,9 @@ func AnyToNumber(v interface{}) float64 {
 		b := v.(*DvVariable)
 		switch b.Kind {
 		case FIELD_OBJECT, FIELD_ARRAY, FIELD_FUNCTION:
+			if p, ok := GetPrimitiveConvertible(b); ok {
+				return p.ToPrimitiveNumber()
+			}
 			f = float64(len(b.Fields))
 		case FIELD_NULL, FIELD_UNDEFINED:
 			f = 0
@@ -358,6 +401,9 @@ func AnyToNumberInt(v interface{}) (f int64, ok bool) {
 		vr := v.(*DvVariable)
 		switch vr.Kind {
 		case FIELD_OBJECT, FIELD_ARRAY, FIELD_FUNCTION:
+			if p, ok := GetPrimitiveConvertible(vr); ok {
+				return NumberToInt(p.ToPrimitiveNumber())
+			}
 			f = int64(len(vr.Fields))
 		case FIELD_UNDEFINED, FIELD_NULL:
 			f = 0
@@ -448,6 +494,9 @@ func AnyToDvVariable(v interface{}) *DvVariable {
 		s := AnyToString(v)
 		return &DvVariable{Kind: FIELD_NUMBER, Value: []byte(s)}
 	case *dvgrammar.ExpressionValue:
+		if v.(*dvgrammar.ExpressionValue) == nil {
+			return nil
+		}
 		return AnyToDvVariable(v.(*dvgrammar.ExpressionValue).Value)
 	case []string:
 		rs := v.([]string)
@@ -462,6 +511,8 @@ func AnyToDvVariable(v interface{}) *DvVariable {
 		return rd
 	case map[string]string:
 		return ConvertStringMapToDvVariable(v.(map[string]string))
+	case *CustomJsFunction:
+		return &DvVariable{Kind: FIELD_FUNCTION, Extra: v}
 	}
 	return nil
 }
@@ -5,6 +5,7 @@ Copyright 2020 - 2020 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@
 package dvevaluation
 
 import (
+	"context"
 	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
 	"math"
 )
@@ -23,10 +24,16 @@ var reservedWords = map[string]int{
 }
 
 type DvObject struct {
-	Value      interface{}
-	Options    int
-	Properties map[string]interface{}
-	Prototype  *DvObject
+	Value        interface{}
+	Options      int
+	Properties   map[string]interface{}
+	Prototype    *DvObject
+	Declarations map[string]int
+	// Limits and Done restrict the evaluations made with this object or its descendants
+	Limits       *dvgrammar.ExecutionLimits
+	Done         context.Context
+	// Debug is called before each statement of the evaluations made with this object or its descendants
+	Debug        dvgrammar.DebugHook
 }
 
 var buildinTypes map[string]interface{} = map[string]interface{}{
@@ -20,6 +20,7 @@ type DvFunction struct {
 	FnSpecial DvSpecialFunc
 	Immediate bool
 	Special   bool
+	Setter    DvFunc
 }
 
 var dvFunctionPrototypeMap = map[string]interface{}{
@@ -5,6 +5,7 @@ Copyright 2020 - 2020 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@
 package dvevaluation
 
 import (
+	"context"
 	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
 )
 
@@ -162,6 +163,37 @@ func (obj *DvObject) FindFirstNotEmptyString(keys []string) string {
 	return ""
 }
 
+// GetExecutionLimits finds the limits and the cancellation context, which are set to the object or its prototypes
+func (obj *DvObject) GetExecutionLimits() (limits *dvgrammar.ExecutionLimits, done context.Context) {
+	for ; obj != nil && (limits == nil || done == nil); obj = obj.Prototype {
+		if limits == nil {
+			limits = obj.Limits
+		}
+		if done == nil {
+			done = obj.Done
+		}
+	}
+	return
+}
+
+// GetDebugHook finds the debugger, which is set to the object or its prototypes
+func (obj *DvObject) GetDebugHook() dvgrammar.DebugHook {
+	for ; obj != nil; obj = obj.Prototype {
+		if obj.Debug != nil {
+			return obj.Debug
+		}
+	}
+	return nil
+}
+
+// NewExecutionBudget starts the budget of the evaluation made with this object, Release must be called after it
+func (obj *DvObject) NewExecutionBudget() *dvgrammar.ExecutionBudget {
+	limits, done := obj.GetExecutionLimits()
+	budget := dvgrammar.NewExecutionBudget(limits, done)
+	budget.Debug = obj.GetDebugHook()
+	return budget
+}
+
 func NewDvObjectWithSpecialValues(value interface{}, kind int, proto *DvObject, properties map[string]interface{}) *DvObject {
 	return &DvObject{Value: value, Options: kind, Prototype: proto, Properties: properties}
 }
@@ -569,6 +569,9 @@ func (item *DvVariable) GetStringValue() string {
 	if item == nil || item.Kind == FIELD_UNDEFINED {
 		return ""
 	}
+	if c := GetDvCollection(item); c != nil {
+		return c.ToPlainVariable().GetStringValue()
+	}
 	switch item.Kind {
 	case FIELD_OBJECT, FIELD_FUNCTION:
 		res := "{"
@@ -607,6 +610,9 @@ func (item *DvVariable) GetStringValueJson() string {
 	if item == nil || item.Kind == FIELD_UNDEFINED {
 		return ""
 	}
+	if c := GetDvCollection(item); c != nil {
+		return c.ToPlainVariable().GetStringValueJson()
+	}
 	switch item.Kind {
 	case FIELD_OBJECT, FIELD_FUNCTION:
 		res := "{"
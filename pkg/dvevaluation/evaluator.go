@@ -109,7 +109,9 @@ func ParseForDvObject(data []byte, params *DvObject, row int, column int, place
 		Place:  place,
 	}
 	stack := NewObjectStack(params)
-	ev, err := CalculatorEvaluator(data, stack, ref, visitorOptions)
+	budget := params.NewExecutionBudget()
+	ev, err := CalculatorEvaluatorWithBudget(data, stack, ref, visitorOptions, budget)
+	budget.Release()
 	var res interface{}
 	if err == nil {
 		if ev == nil {
@@ -0,0 +1,334 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvevaluation
+
+import (
+	"errors"
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
+	"sync"
+	"time"
+)
+
+// EventLoop runs the asynchronous jobs of one evaluation: promise reactions go first,
+// then the due timers in the order of their time and creation, then the results of the goroutines
+// in the order they come. The script is executed by one goroutine at a time, the async functions
+// pass the control to each other through their coroutines.
+type EventLoop struct {
+	microtasks []func() error
+	timers     []*loopTimer
+	timerId    int
+	timerSeq   int
+	pending    int
+	mu         sync.Mutex
+	completed  []func() error
+	signal     chan bool
+	suspended  map[*coroutine]bool
+	rejected   []*JsPromise
+	closed     bool
+	// root is the evaluation context, its budget interrupts the waiting for timers and goroutines
+	root *dvgrammar.ExpressionContext
+}
+
+// AsyncState is kept in the expression context; the context of an async function has its own coroutine,
+// which is suspended by await
+type AsyncState struct {
+	Loop    *EventLoop
+	routine *coroutine
+}
+
+type coroutine struct {
+	resume chan bool
+	yield  chan bool
+}
+
+type loopTimer struct {
+	id       int
+	seq      int
+	due      time.Time
+	interval time.Duration
+	repeat   bool
+	job      func() error
+}
+
+var errAsyncAborted = errors.New("Asynchronous operation is aborted because the evaluation is finished")
+
+func NewEventLoop() *EventLoop {
+	return &EventLoop{signal: make(chan bool, 1), suspended: make(map[*coroutine]bool)}
+}
+
+func getAsyncState(context *dvgrammar.ExpressionContext) *AsyncState {
+	if context.Async == nil {
+		loop := NewEventLoop()
+		loop.root = context
+		context.Async = &AsyncState{Loop: loop}
+	}
+	return context.Async.(*AsyncState)
+}
+
+func GetEventLoop(context *dvgrammar.ExpressionContext) *EventLoop {
+	return getAsyncState(context).Loop
+}
+
+// ForkAsyncContext gives the context for the code run later by the event loop; the scope levels are shared
+// with the original context, so the callbacks see the variables available at the moment of their registration
+func ForkAsyncContext(context *dvgrammar.ExpressionContext) *dvgrammar.ExpressionContext {
+	return forkContext(context, nil)
+}
+
+func forkContext(context *dvgrammar.ExpressionContext, routine *coroutine) *dvgrammar.ExpressionContext {
+	state := getAsyncState(context)
+	scope := context.Scope
+	if stack, ok := scope.(*ObjectStack); ok {
+		scope = stack.Fork()
+	}
+	res := &dvgrammar.ExpressionContext{
+		Scope:          scope,
+		Reference:      context.Reference,
+		Rules:          context.Rules,
+		VisitorOptions: context.VisitorOptions,
+		Async:          &AsyncState{Loop: state.Loop, routine: routine},
+		Budget:         context.Budget,
+		Module:         context.Module,
+	}
+	if routine != nil {
+		res.Depth = context.Depth
+	}
+	return res
+}
+
+func (l *EventLoop) EnqueueJob(job func() error) {
+	if l.closed {
+		return
+	}
+	l.microtasks = append(l.microtasks, job)
+}
+
+// StartOperation registers the work done by a goroutine; the returned function can be called
+// from any goroutine only once to pass the job, which is run by the loop with the result of the work
+func (l *EventLoop) StartOperation() func(job func() error) {
+	l.pending++
+	return func(job func() error) {
+		l.mu.Lock()
+		l.completed = append(l.completed, job)
+		l.mu.Unlock()
+		select {
+		case l.signal <- true:
+		default:
+		}
+	}
+}
+
+func (l *EventLoop) SetTimer(delay time.Duration, repeat bool, job func() error) int {
+	if delay < 0 {
+		delay = 0
+	}
+	l.timerId++
+	t := &loopTimer{id: l.timerId, interval: delay, repeat: repeat, job: job}
+	if !l.closed {
+		l.scheduleTimer(t)
+	}
+	return t.id
+}
+
+func (l *EventLoop) scheduleTimer(t *loopTimer) {
+	l.timerSeq++
+	t.seq = l.timerSeq
+	t.due = time.Now().Add(t.interval)
+	l.timers = append(l.timers, t)
+}
+
+func (l *EventLoop) ClearTimer(id int) {
+	for i, t := range l.timers {
+		if t.id == id {
+			l.timers = append(l.timers[:i], l.timers[i+1:]...)
+			return
+		}
+	}
+}
+
+func (l *EventLoop) nextTimer() *loopTimer {
+	var res *loopTimer
+	for _, t := range l.timers {
+		if res == nil || t.due.Before(res.due) || t.due.Equal(res.due) && t.seq < res.seq {
+			res = t
+		}
+	}
+	return res
+}
+
+func (l *EventLoop) runTimer(t *loopTimer) error {
+	l.ClearTimer(t.id)
+	if t.repeat {
+		l.scheduleTimer(t)
+	}
+	return t.job()
+}
+
+func (l *EventLoop) takeCompleted() []func() error {
+	l.mu.Lock()
+	jobs := l.completed
+	l.completed = nil
+	l.mu.Unlock()
+	l.pending -= len(jobs)
+	return jobs
+}
+
+// runUntil runs the jobs until the condition is met or nothing is left to wait for
+func (l *EventLoop) runUntil(done func() bool) error {
+	var budget *dvgrammar.ExecutionBudget
+	if l.root != nil {
+		budget = l.root.Budget
+	}
+	for !l.closed {
+		if done != nil && done() {
+			return nil
+		}
+		if err := budget.Check(nil, l.root); err != nil {
+			return err
+		}
+		if len(l.microtasks) > 0 {
+			job := l.microtasks[0]
+			l.microtasks = l.microtasks[1:]
+			if err := job(); err != nil {
+				return err
+			}
+			continue
+		}
+		if jobs := l.takeCompleted(); len(jobs) > 0 {
+			l.microtasks = append(l.microtasks, jobs...)
+			continue
+		}
+		t := l.nextTimer()
+		if t == nil && l.pending == 0 {
+			return nil
+		}
+		if t != nil {
+			wait := time.Until(t.due)
+			if wait <= 0 {
+				if err := l.runTimer(t); err != nil {
+					return err
+				}
+				continue
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-l.signal:
+			case <-timer.C:
+			case <-budget.Done():
+			}
+			timer.Stop()
+			continue
+		}
+		select {
+		case <-l.signal:
+		case <-budget.Done():
+		}
+	}
+	return errAsyncAborted
+}
+
+// Run is called by the evaluator at the end of the script to run all remaining jobs;
+// the loop is closed afterwards, the async functions waiting for promises never settled are aborted
+func (l *EventLoop) Run(context *dvgrammar.ExpressionContext) error {
+	err := l.runUntil(nil)
+	if err == nil {
+		for _, p := range l.rejected {
+			if !p.handled {
+				err = dvgrammar.NewThrownError(p.Value, "(in promise) "+getThrownValueDescription(p.Value), nil, context)
+				break
+			}
+		}
+	}
+	l.Close()
+	return err
+}
+
+func (l *EventLoop) Close() {
+	if l.closed {
+		return
+	}
+	l.closed = true
+	l.microtasks = nil
+	l.timers = nil
+	for len(l.suspended) > 0 {
+		for r := range l.suspended {
+			delete(l.suspended, r)
+			r.resume <- false
+			<-r.yield
+		}
+	}
+}
+
+// RunEventLoop finishes the asynchronous jobs started by the evaluation, if any
+func RunEventLoop(context *dvgrammar.ExpressionContext, err error) error {
+	if context.Async == nil {
+		return err
+	}
+	state := context.Async.(*AsyncState)
+	if err != nil {
+		state.Loop.Close()
+		return err
+	}
+	return state.Loop.Run(context)
+}
+
+// AwaitValue waits for the promise: an async function is suspended until the promise is settled,
+// elsewhere the event loop is run in the meantime
+func AwaitValue(context *dvgrammar.ExpressionContext, v interface{}) (interface{}, bool, error) {
+	state := getAsyncState(context)
+	loop := state.Loop
+	if loop.closed {
+		return nil, false, errAsyncAborted
+	}
+	p := PromiseResolve(context, v)
+	if r := state.routine; r != nil {
+		p.OnSettled(func() error {
+			delete(loop.suspended, r)
+			r.resume <- true
+			<-r.yield
+			return nil
+		})
+		loop.suspended[r] = true
+		r.yield <- true
+		if !<-r.resume {
+			return nil, false, errAsyncAborted
+		}
+	} else {
+		resumed := false
+		p.OnSettled(func() error {
+			resumed = true
+			return nil
+		})
+		err := loop.runUntil(func() bool { return resumed })
+		if err != nil {
+			return nil, false, err
+		}
+		if !resumed {
+			return nil, false, errors.New("Awaited promise is never settled")
+		}
+	}
+	return p.Value, p.State == PROMISE_FULFILLED, nil
+}
+
+// callAsyncFunction runs the function body in its coroutine until the first await and returns the promise of its result
+func callAsyncFunction(context *dvgrammar.ExpressionContext, cf *CustomJsFunction, thisArg interface{}, args []interface{}) (interface{}, error) {
+	res, p := NewPromise(context)
+	r := &coroutine{resume: make(chan bool), yield: make(chan bool)}
+	ctx := forkContext(context, r)
+	go func() {
+		<-r.resume
+		value, err := executeCustomFunction(ctx, cf, thisArg, args)
+		if err != nil {
+			p.Reject(GetCatchValue(err))
+		} else {
+			p.Resolve(value)
+		}
+		r.yield <- true
+	}()
+	r.resume <- true
+	<-r.yield
+	return res, nil
+}
@@ -0,0 +1,231 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvevaluation
+
+import (
+	"errors"
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
+	"strconv"
+	"strings"
+)
+
+const (
+	ERROR_KIND_ERROR     = "Error"
+	ERROR_KIND_TYPE      = "TypeError"
+	ERROR_KIND_RANGE     = "RangeError"
+	ERROR_KIND_SYNTAX    = "SyntaxError"
+	ERROR_KIND_REFERENCE = "ReferenceError"
+
+	errorNotDefinedSuffix = " is not defined"
+)
+
+type ErrorObjectCreator func(kind string, message string) *DvVariable
+
+var errorObjectCreator ErrorObjectCreator = CreateSimpleErrorObject
+
+func RegisterErrorObjectCreator(creator ErrorObjectCreator) {
+	errorObjectCreator = creator
+}
+
+func NewErrorObject(kind string, message string) *DvVariable {
+	return errorObjectCreator(kind, message)
+}
+
+// ErrorValue is kept in Extra of Error objects to recognize them
+// and to convert them to strings the way JavaScript does
+type ErrorValue struct {
+	Object *DvVariable
+}
+
+func (e *ErrorValue) ToPrimitiveString() string {
+	return GetErrorObjectDescription(e.Object)
+}
+
+// ScriptError is raised by native code and becomes an Error object of the given kind when caught by scripts
+type ScriptError struct {
+	Kind    string
+	Message string
+}
+
+func (e *ScriptError) Error() string {
+	return e.Kind + ": " + e.Message
+}
+
+func NewScriptError(kind string, message string) *ScriptError {
+	return &ScriptError{Kind: kind, Message: message}
+}
+
+// ThrowScriptError converts ScriptError to the exception, which keeps the Error object for catch clauses;
+// other errors are returned unchanged
+func ThrowScriptError(err error, node *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) error {
+	scriptError, ok := err.(*ScriptError)
+	if !ok {
+		return err
+	}
+	v := NewErrorObject(scriptError.Kind, scriptError.Message)
+	thrown := dvgrammar.NewThrownError(v, scriptError.Error(), node, context)
+	addLocationToErrorStack(v, thrown)
+	return thrown
+}
+
+// throwLimitError is used by the evaluator to raise RangeError, when the script exceeds its execution limits
+func throwLimitError(message string, node *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) error {
+	return ThrowScriptError(NewScriptError(ERROR_KIND_RANGE, message), node, context)
+}
+
+func CreateSimpleErrorObject(kind string, message string) *DvVariable {
+	v := &DvVariable{
+		Kind: FIELD_OBJECT,
+		Fields: []*DvVariable{
+			{Name: []byte("name"), Kind: FIELD_STRING, Value: []byte(kind)},
+			{Name: []byte("message"), Kind: FIELD_STRING, Value: []byte(message)},
+			{Name: []byte("stack"), Kind: FIELD_STRING, Value: []byte(kind + ": " + message)},
+		},
+	}
+	v.Extra = &ErrorValue{Object: v}
+	return v
+}
+
+func IsErrorObject(dv *DvVariable) bool {
+	if dv == nil || dv.Kind != FIELD_OBJECT || dv.Extra == nil {
+		return false
+	}
+	_, ok := dv.Extra.(*ErrorValue)
+	return ok
+}
+
+func GetErrorObjectDescription(dv *DvVariable) string {
+	name := dv.ReadSimpleChildValue("name")
+	message := dv.ReadSimpleChildValue("message")
+	if name == "" {
+		name = ERROR_KIND_ERROR
+	}
+	if message == "" {
+		return name
+	}
+	return name + ": " + message
+}
+
+func getThrownValueDescription(v interface{}) string {
+	dv, ok := v.(*DvVariable)
+	if ok && IsErrorObject(dv) {
+		return GetErrorObjectDescription(dv)
+	}
+	return AnyToString(v)
+}
+
+func addLocationToErrorStack(dv *DvVariable, err *dvgrammar.ThrownError) {
+	stack := dv.ReadSimpleChild("stack")
+	if stack == nil || stack.Kind != FIELD_STRING || strings.Contains(string(stack.Value), "\n    at ") {
+		return
+	}
+	location := "\n    at " + err.Place + " (" + strconv.Itoa(err.Row) + ":" + strconv.Itoa(err.Column) + ")"
+	stack.Value = append(stack.Value, []byte(location)...)
+}
+
+func ThrowOperator(tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) (int, *dvgrammar.ExpressionValue, error) {
+	n := len(tree.Children)
+	if n == 0 || tree.Children[n-1] == nil {
+		return dvgrammar.FLOW_NORMAL, nil, errors.New("'throw' requires an argument")
+	}
+	_, val, err := tree.Children[n-1].ExecuteExpression(context)
+	if err != nil {
+		return dvgrammar.FLOW_NORMAL, nil, err
+	}
+	var v interface{}
+	if val != nil {
+		v = val.Value
+	}
+	thrown := dvgrammar.NewThrownError(v, getThrownValueDescription(v), tree, context)
+	if dv, ok := v.(*DvVariable); ok && IsErrorObject(dv) {
+		addLocationToErrorStack(dv, thrown)
+	}
+	return dvgrammar.FLOW_NORMAL, nil, thrown
+}
+
+// GetCatchValue converts any error to the value which is bound to the catch parameter:
+// values thrown by scripts are passed unchanged, native errors become Error objects
+// (ReferenceError for the identifiers, which are not defined)
+func GetCatchValue(err error) interface{} {
+	if thrown, ok := err.(*dvgrammar.ThrownError); ok {
+		return thrown.Value
+	}
+	if scriptError, ok := err.(*ScriptError); ok {
+		return NewErrorObject(scriptError.Kind, scriptError.Message)
+	}
+	message := err.Error()
+	if strings.HasSuffix(message, errorNotDefinedSuffix) {
+		return NewErrorObject(ERROR_KIND_REFERENCE, message)
+	}
+	return NewErrorObject(ERROR_KIND_ERROR, message)
+}
+
+func TryOperator(tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) (int, *dvgrammar.ExpressionValue, error) {
+	n := len(tree.Children)
+	if n != 1 || tree.Children[0] == nil || len(tree.Children[0].Children) < 3 {
+		return dvgrammar.FLOW_NORMAL, nil, errors.New("Try requires curly brackets followed by catch or finally")
+	}
+	parts := tree.Children[0].Children
+	tryBody := parts[0].Children
+	var catchParam *dvgrammar.BuildNode
+	var catchBody, finallyBody []*dvgrammar.BuildNode
+	hasCatch := false
+	hasFinally := false
+	m := len(parts)
+	for i := 1; i < m; i++ {
+		switch parts[i].Operator {
+		case "catch":
+			hasCatch = true
+			if i+1 < m && parts[i+1].Operator == "(" {
+				i++
+				if len(parts[i].Children) != 1 {
+					return dvgrammar.FLOW_NORMAL, nil, errors.New("Catch requires one parameter")
+				}
+				catchParam = parts[i].Children[0]
+			}
+			if i+1 >= m || parts[i+1].Operator != "{" {
+				return dvgrammar.FLOW_NORMAL, nil, errors.New("Catch requires curly brackets")
+			}
+			i++
+			catchBody = parts[i].Children
+		case "finally":
+			if i+1 >= m || parts[i+1].Operator != "{" {
+				return dvgrammar.FLOW_NORMAL, nil, errors.New("Finally requires curly brackets")
+			}
+			hasFinally = true
+			i++
+			finallyBody = parts[i].Children
+		default:
+			return dvgrammar.FLOW_NORMAL, nil, errors.New("Unexpected " + parts[i].Operator + " in try")
+		}
+	}
+	flow, val, err := dvgrammar.BlockExecution(tryBody, context)
+	if err != nil && hasCatch {
+		flow, val, err = executeCatchClause(context, catchParam, catchBody, GetCatchValue(err))
+	}
+	if hasFinally {
+		finallyFlow, finallyVal, finallyErr := dvgrammar.BlockExecution(finallyBody, context)
+		if finallyErr != nil || finallyFlow != dvgrammar.FLOW_NORMAL {
+			return finallyFlow, finallyVal, finallyErr
+		}
+	}
+	return flow, val, err
+}
+
+func executeCatchClause(context *dvgrammar.ExpressionContext, catchParam *dvgrammar.BuildNode, catchBody []*dvgrammar.BuildNode, value interface{}) (int, *dvgrammar.ExpressionValue, error) {
+	if catchParam == nil {
+		return dvgrammar.BlockExecution(catchBody, context)
+	}
+	name, err := ExtractPureName(catchParam)
+	if err != nil {
+		return dvgrammar.FLOW_NORMAL, nil, err
+	}
+	context.Scope.StackPush(SCOPE_OPTION_BLOCK)
+	context.Scope.Set(name, value)
+	flow, val, err := dvgrammar.BlockExecution(catchBody, context)
+	context.Scope.StackPop()
+	return flow, val, err
+}
@@ -18,16 +18,23 @@ const (
 )
 
 type CustomJsFunction struct {
-	Params  []string
-	Body    []*dvgrammar.BuildNode
-	Options int
+	Params   []string
+	Patterns []*dvgrammar.BuildNode
+	Body     []*dvgrammar.BuildNode
+	Options  int
+	Class    *DvVariable
+	Async    bool
+	Module   *JsModule
+	// Closure keeps the scope levels, where the function is created, they are visible in the function body
+	Closure []*DvObject
 }
 
-func CreateFunctionContainer(params []string, body []*dvgrammar.BuildNode, options int, name string) (*dvgrammar.ExpressionValue, error) {
+func CreateFunctionContainer(params []string, patterns []*dvgrammar.BuildNode, body []*dvgrammar.BuildNode, options int, name string) (*dvgrammar.ExpressionValue, error) {
 	jsfunc := &CustomJsFunction{
-		Params:  params,
-		Body:    body,
-		Options: options,
+		Params:   params,
+		Patterns: patterns,
+		Body:     body,
+		Options:  options,
 	}
 	gram := &dvgrammar.ExpressionValue{
 		Value:    jsfunc,
@@ -37,31 +44,58 @@ func CreateFunctionContainer(params []string, body []*dvgrammar.BuildNode, optio
 	return gram, nil
 }
 
-func GetFunctionParameterList(tree *dvgrammar.BuildNode) ([]string, error) {
+func GetFunctionParameterList(tree *dvgrammar.BuildNode) ([]string, []*dvgrammar.BuildNode, error) {
+	if tree != nil && tree.Operator == "" && len(tree.Children) == 0 && tree.Value != nil && tree.Value.DataType == dvgrammar.TYPE_DATA {
+		return []string{tree.Value.Value}, nil, nil
+	}
 	if tree == nil || len(tree.Children) != 1 || tree.Value == nil || tree.Value.DataType != dvgrammar.TYPE_FUNCTION {
-		return nil, errors.New("Expected parameters in round brackets only")
+		return nil, nil, errors.New("Expected parameters in round brackets only")
 	}
-	tree = tree.Children[0]
+	return GetParameterNames(tree.Children[0])
+}
+
+// GetParameterNames returns the names of simple parameters; if any parameter has a default value,
+// a destructuring pattern or is a rest parameter, all the parameter nodes are returned as patterns
+func GetParameterNames(tree *dvgrammar.BuildNode) ([]string, []*dvgrammar.BuildNode, error) {
 	if tree == nil || tree.Operator != "(" {
-		return nil, errors.New("Only expected parameters in round brackets")
+		return nil, nil, errors.New("Only expected parameters in round brackets")
 	}
 	n := len(tree.Children)
 	if n == 0 {
-		return nil, nil
+		return nil, nil, nil
 	}
 	res := make([]string, n)
+	simple := true
 	for i := 0; i < n; i++ {
-		p, err := ExtractPureName(tree.Children[i])
+		node := tree.Children[i]
+		if node != nil && isSpreadNode(node) {
+			if i != n-1 {
+				return nil, nil, errors.New("Rest parameter must be last formal parameter")
+			}
+			simple = false
+		}
+		if node != nil && node.Operator == "=" && len(node.Children) == 2 {
+			node = node.Children[0]
+			simple = false
+		}
+		if dvgrammar.IsDestructuringPattern(node) {
+			simple = false
+			continue
+		}
+		p, err := ExtractPureName(node)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		pos := dvtextutils.IsValidVariableName(p, true)
 		if pos >= 0 {
-			return nil, fmt.Errorf("Bad variable name %s at %d", p, pos)
+			return nil, nil, fmt.Errorf("Bad variable name %s at %d", p, pos)
 		}
 		res[i] = p
 	}
-	return res, nil
+	if simple {
+		return res, nil, nil
+	}
+	return res, tree.Children, nil
 }
 
 func ExtractPureName(tree *dvgrammar.BuildNode) (string, error) {
@@ -71,26 +105,39 @@ func ExtractPureName(tree *dvgrammar.BuildNode) (string, error) {
 	return tree.Value.Value, nil
 }
 
+// GetFunctionCodeList returns the statements of the arrow function body; the body without curly brackets
+// is the expression returned by the function
 func GetFunctionCodeList(tree *dvgrammar.BuildNode) ([]*dvgrammar.BuildNode, error) {
-	if tree == nil || len(tree.Children) == 0 || tree.Value == nil || tree.Value.DataType != dvgrammar.TYPE_FUNCTION {
+	if tree == nil {
 		return nil, nil
 	}
-	tree = tree.Children[0]
-	if tree == nil || tree.Operator != "{" {
-		return nil, nil
+	if len(tree.Children) == 1 && tree.Value != nil && tree.Value.DataType == dvgrammar.TYPE_FUNCTION && tree.Children[0] != nil && tree.Children[0].Operator == "{" {
+		return tree.Children[0].Children, nil
 	}
-	return tree.Children, nil
+	return []*dvgrammar.BuildNode{{Operator: "return", Children: []*dvgrammar.BuildNode{tree}}}, nil
 }
 
 func CalculateAllNodeParams(args []*dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) ([]interface{}, error) {
 	n := len(args)
-	interfaceArgs := make([]interface{}, n)
-	var err error
+	interfaceArgs := make([]interface{}, 0, n)
 	for i := 0; i < n; i++ {
-		_, interfaceArgs[i], err = args[i].ExecuteExpression(context)
+		_, v, err := args[i].ExecuteExpression(context)
 		if err != nil {
 			return nil, err
 		}
+		if v != nil {
+			if spread, ok := v.Value.(*SpreadValue); ok {
+				items, err := GetIterableElements(context, spread.Value)
+				if err != nil {
+					return nil, ThrowScriptError(err, args[i], context)
+				}
+				for _, item := range items {
+					interfaceArgs = append(interfaceArgs, AnyToDvGrammarExpressionValue(item))
+				}
+				continue
+			}
+		}
+		interfaceArgs = append(interfaceArgs, v)
 	}
 	return interfaceArgs, nil
 }
@@ -118,6 +165,13 @@ func ExecuteAnyFunction(context *dvgrammar.ExpressionContext, fn interface{}, th
 			case *DvFunctionObject:
 				value, err = dv.Extra.(*DvFunctionObject).ExecuteDvFunctionWithTreeArguments(args, context)
 				return
+			case *CustomJsFunction:
+				return ExecuteAnyFunction(context, dv.Extra, thisArg, args)
+			case *JsClass:
+				return nil, NewScriptError(ERROR_KIND_TYPE, "Class constructor "+dv.Extra.(*JsClass).Name+" cannot be invoked without 'new'")
+			case *SuperBinding:
+				err = dv.Extra.(*SuperBinding).CallParentConstructor(context, args)
+				return
 			case *DvFunction:
 				functionObject := &DvFunctionObject{
 					SelfRef:  thisArg,
@@ -130,20 +184,57 @@ func ExecuteAnyFunction(context *dvgrammar.ExpressionContext, fn interface{}, th
 		}
 	case *CustomJsFunction:
 		cf := fn.(*CustomJsFunction)
-		context.Scope.StackPush(cf.Options)
-		context.Scope.Set("this", thisArg)
-		context.Scope.Set("arguments", args)
-		err = PutVariablesInScope(cf.Params, args, context)
-		if err == nil {
-			_, value, err = dvgrammar.BuildNodeExecution(cf.Body, context)
+		if cf.Async {
+			return callAsyncFunction(context, cf, thisArg, args)
 		}
-		context.Scope.StackPop()
-		return
+		return executeCustomFunction(context, cf, thisArg, args)
 	case *dvgrammar.ExpressionValue:
 		dvg := fn.(*dvgrammar.ExpressionValue)
-		if dvg != nil && dvg.DataType == dvgrammar.TYPE_FUNCTION && dvg.Value != nil {
+		if dvg != nil && (dvg.DataType == dvgrammar.TYPE_FUNCTION || dvg.DataType == dvgrammar.TYPE_OBJECT) && dvg.Value != nil {
 			return ExecuteAnyFunction(context, dvg.Value, thisArg, args)
 		}
 	}
-	return nil, fmt.Errorf("Value of %v is not a function", fn)
+	return nil, NewScriptError(ERROR_KIND_TYPE, fmt.Sprintf("%v is not a function", fn))
+}
+
+func executeCustomFunction(context *dvgrammar.ExpressionContext, cf *CustomJsFunction, thisArg interface{}, args []interface{}) (value interface{}, err error) {
+	var start *dvgrammar.BuildNode
+	if len(cf.Body) > 0 {
+		start = cf.Body[0]
+	}
+	if err = context.EnterCall(start); err != nil {
+		return
+	}
+	defer context.LeaveCall()
+	if err = context.Budget.Allocate(len(args)+1, start, context); err != nil {
+		return
+	}
+	if cf.Module != nil && cf.Module != context.Module {
+		if restore := enterModuleScope(context, cf.Module); restore != nil {
+			defer restore()
+		}
+	}
+	if restore := enterClosureScope(context, cf.Closure); restore != nil {
+		defer restore()
+	}
+	thisArg = resolveSuperThis(thisArg)
+	context.Scope.StackPush(cf.Options)
+	context.Scope.Set("this", thisArg)
+	if cf.Class != nil {
+		super := NewSuperVariable(cf.Class, thisArg)
+		if super != nil {
+			context.Scope.Set("super", super)
+		}
+	}
+	context.Scope.Set("arguments", args)
+	if cf.Patterns != nil {
+		err = BindParameters(cf.Patterns, args, context)
+	} else {
+		err = PutVariablesInScope(cf.Params, args, context)
+	}
+	if err == nil {
+		_, value, err = dvgrammar.BlockExecution(cf.Body, context)
+	}
+	context.Scope.StackPop()
+	return
 }
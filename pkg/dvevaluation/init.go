@@ -5,8 +5,13 @@ Copyright 2020 - 2020 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@
 
 package dvevaluation
 
+import (
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
+)
+
 func Init() bool {
 	dvfunction_init()
+	dvgrammar.LimitErrorThrower = throwLimitError
 	return true
 }
 
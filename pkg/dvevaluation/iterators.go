@@ -0,0 +1,152 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvevaluation
+
+import (
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
+)
+
+// IteratorMethodKey is the property key of the method returning an iterator (obj[Symbol.iterator])
+const IteratorMethodKey = "@@iterator"
+
+// ValueIterator walks arrays, strings and objects following the iterator protocol,
+// the protocol iterators are read lazily, so the infinite ones can be stopped by break
+type ValueIterator struct {
+	items    []*DvVariable
+	pos      int
+	iterator *DvVariable
+	context  *dvgrammar.ExpressionContext
+	done     bool
+}
+
+func getCallableChild(v *DvVariable, key string) *DvVariable {
+	r := v.ReadSimpleChild(key)
+	if r == nil || r.Kind != FIELD_FUNCTION {
+		return nil
+	}
+	return r
+}
+
+// IsIterableObject checks whether the object has the iterator method or is an iterator itself
+func IsIterableObject(dv *DvVariable) bool {
+	if dv == nil || dv.Kind != FIELD_OBJECT && dv.Kind != FIELD_FUNCTION {
+		return false
+	}
+	return getCallableChild(dv, IteratorMethodKey) != nil || getCallableChild(dv, "next") != nil
+}
+
+func NewValueIterator(context *dvgrammar.ExpressionContext, v interface{}) (*ValueIterator, error) {
+	dv := AnyToDvVariable(v)
+	if dv != nil {
+		switch dv.Kind {
+		case FIELD_ARRAY:
+			return &ValueIterator{items: dv.Fields}, nil
+		case FIELD_STRING:
+			s := []rune(string(dv.Value))
+			items := make([]*DvVariable, len(s))
+			for i, c := range s {
+				items[i] = &DvVariable{Kind: FIELD_STRING, Value: []byte(string(c))}
+			}
+			return &ValueIterator{items: items}, nil
+		case FIELD_OBJECT, FIELD_FUNCTION:
+			if context == nil {
+				break
+			}
+			iterator := dv
+			if method := getCallableChild(dv, IteratorMethodKey); method != nil {
+				r, err := ExecuteAnyFunction(context, method, dv, nil)
+				if err != nil {
+					return nil, err
+				}
+				iterator = AnyToDvVariable(r)
+				if iterator == nil || iterator.Kind != FIELD_OBJECT && iterator.Kind != FIELD_ARRAY {
+					return nil, NewScriptError(ERROR_KIND_TYPE, "Result of the Symbol.iterator method is not an object")
+				}
+				if iterator.Kind == FIELD_ARRAY && getCallableChild(iterator, "next") == nil {
+					return &ValueIterator{items: iterator.Fields}, nil
+				}
+			}
+			if getCallableChild(iterator, "next") != nil {
+				return &ValueIterator{iterator: iterator, context: context}, nil
+			}
+		}
+	}
+	return nil, NewScriptError(ERROR_KIND_TYPE, AnyToString(v)+" is not iterable")
+}
+
+// Next returns the next element and false or nil and true when the iteration is over
+func (it *ValueIterator) Next() (*DvVariable, bool, error) {
+	if it.done {
+		return nil, true, nil
+	}
+	if it.iterator == nil {
+		if it.pos >= len(it.items) {
+			it.done = true
+			return nil, true, nil
+		}
+		it.pos++
+		return it.items[it.pos-1], false, nil
+	}
+	next := getCallableChild(it.iterator, "next")
+	if next == nil {
+		return nil, true, NewScriptError(ERROR_KIND_TYPE, "iterator.next is not a function")
+	}
+	r, err := ExecuteAnyFunction(it.context, next, it.iterator, nil)
+	if err != nil {
+		it.done = true
+		return nil, true, err
+	}
+	res := AnyToDvVariable(r)
+	if res == nil || res.Kind != FIELD_OBJECT {
+		it.done = true
+		return nil, true, NewScriptError(ERROR_KIND_TYPE, "Iterator result "+AnyToString(r)+" is not an object")
+	}
+	done := res.ReadSimpleChild("done")
+	if done != nil && AnyToBoolean(done) {
+		it.done = true
+		return nil, true, nil
+	}
+	return res.ReadSimpleChild("value"), false, nil
+}
+
+// Close lets the protocol iterator release its resources by calling its return() method
+// when the iteration stops before the end
+func (it *ValueIterator) Close() error {
+	if it.done {
+		return nil
+	}
+	it.done = true
+	if it.iterator == nil {
+		return nil
+	}
+	if method := getCallableChild(it.iterator, "return"); method != nil {
+		_, err := ExecuteAnyFunction(it.context, method, it.iterator, nil)
+		return err
+	}
+	return nil
+}
+
+// GetIterableElements returns the elements, which ...value produces in calls, array literals and array patterns
+func GetIterableElements(context *dvgrammar.ExpressionContext, v interface{}) ([]*DvVariable, error) {
+	it, err := NewValueIterator(context, v)
+	if err != nil {
+		return nil, err
+	}
+	if it.iterator == nil {
+		return it.items, nil
+	}
+	res := make([]*DvVariable, 0, 16)
+	for {
+		item, done, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			return res, nil
+		}
+		res = append(res, item)
+	}
+}
@@ -26,7 +26,7 @@ func ArrowFunctionOperator(tree *dvgrammar.BuildNode, context *dvgrammar.Express
 	if n != 2 {
 		return dvgrammar.FLOW_NORMAL, nil, errors.New("Arrow function requires 2 parameters")
 	}
-	params, err := GetFunctionParameterList(tree.Children[0])
+	params, patterns, err := GetFunctionParameterList(tree.Children[0])
 	if err != nil {
 		return dvgrammar.FLOW_NORMAL, nil, err
 	}
@@ -34,7 +34,13 @@ func ArrowFunctionOperator(tree *dvgrammar.BuildNode, context *dvgrammar.Express
 	if err != nil {
 		return dvgrammar.FLOW_NORMAL, nil, err
 	}
-	val, err := CreateFunctionContainer(params, code, FUNCTION_KIND_ARROW, "")
+	val, err := CreateFunctionContainer(params, patterns, code, FUNCTION_KIND_ARROW, "")
+	if err == nil {
+		fn := val.Value.(*CustomJsFunction)
+		fn.Async = hasAsyncModifier(tree.Children[0])
+		fn.Module = getContextModule(context)
+		fn.Closure = captureClosure(context)
+	}
 	return dvgrammar.FLOW_NORMAL, val, err
 }
 
@@ -48,10 +54,111 @@ func ForCycleOperator(tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionCo
 	initValues := collectAtLevel(forInside, 0)
 	condValue := collectAtLevel(forInside, 1)
 	stepValues := collectAtLevel(forInside, 2)
-	flow, val, err := ExecuteCycleCommon(context, initValues, condValue, stepValues, cycleBody, true)
+	flow, val, err := ExecuteCycleCommon(context, initValues, condValue, stepValues, cycleBody, true, tree.Label)
 	return flow, val, err
 }
 
+func WhileCycleOperator(tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) (int, *dvgrammar.ExpressionValue, error) {
+	n := len(tree.Children)
+	if n != 1 || tree.Children[0] == nil || len(tree.Children[0].Children) < 2 {
+		return dvgrammar.FLOW_NORMAL, nil, errors.New("While cycle requires only parentheses and curly brackets")
+	}
+	condValue := tree.Children[0].Children[0].Children
+	cycleBody := tree.Children[0].Children[1].Children
+	flow, val, err := ExecuteCycleCommon(context, nil, condValue, nil, cycleBody, true, tree.Label)
+	return flow, val, err
+}
+
+func DoWhileCycleOperator(tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) (int, *dvgrammar.ExpressionValue, error) {
+	n := len(tree.Children)
+	if n != 1 || tree.Children[0] == nil || len(tree.Children[0].Children) < 2 {
+		return dvgrammar.FLOW_NORMAL, nil, errors.New("Do-while cycle requires curly brackets followed by while with parentheses")
+	}
+	cycleBody := tree.Children[0].Children[0].Children
+	condValue := tree.Children[0].Children[1].Children
+	flow, val, err := ExecuteCycleCommon(context, nil, condValue, nil, cycleBody, false, tree.Label)
+	return flow, val, err
+}
+
+func SwitchOperator(tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) (int, *dvgrammar.ExpressionValue, error) {
+	n := len(tree.Children)
+	if n != 1 || tree.Children[0] == nil || len(tree.Children[0].Children) < 2 {
+		return dvgrammar.FLOW_NORMAL, nil, errors.New("Switch requires parentheses and curly brackets")
+	}
+	_, val, err := dvgrammar.BuildNodeExecution(tree.Children[0].Children[0].Children, context)
+	if err != nil {
+		return dvgrammar.FLOW_NORMAL, nil, err
+	}
+	switchBody := tree.Children[0].Children[1].Children
+	pushed, err := dvgrammar.EnterBlock(switchBody, context)
+	if err != nil {
+		return dvgrammar.FLOW_NORMAL, nil, err
+	}
+	if pushed {
+		defer context.Scope.StackPop()
+	}
+	n = len(switchBody)
+	start := -1
+	defaultStart := -1
+	for i := 0; i < n && start < 0; i++ {
+		node := switchBody[i]
+		if node == nil {
+			continue
+		}
+		switch node.Operator {
+		case "default":
+			if defaultStart >= 0 {
+				return dvgrammar.FLOW_NORMAL, nil, errors.New("More than one default clause in switch")
+			}
+			defaultStart = i
+		case "case":
+			m := len(node.Children)
+			if m == 0 || node.Children[m-1] == nil {
+				return dvgrammar.FLOW_NORMAL, nil, errors.New("'case' requires an argument")
+			}
+			_, caseVal, err := node.Children[m-1].ExecuteExpression(context)
+			if err != nil {
+				return dvgrammar.FLOW_NORMAL, nil, err
+			}
+			if isEqualExactValues(val, caseVal) {
+				start = i
+			}
+		}
+	}
+	if start < 0 {
+		start = defaultStart
+	}
+	if start < 0 {
+		return dvgrammar.FLOW_NORMAL, nil, nil
+	}
+	flow, res, err := dvgrammar.BuildNodeExecution(switchBody[start+1:], context)
+	if err != nil {
+		return dvgrammar.FLOW_NORMAL, nil, err
+	}
+	if flow == dvgrammar.FLOW_BREAK && isFlowForCycle(tree.Label, res) {
+		return dvgrammar.FLOW_NORMAL, nil, nil
+	}
+	return flow, res, nil
+}
+
+func SwitchClauseOperator(tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) (int, *dvgrammar.ExpressionValue, error) {
+	return dvgrammar.FLOW_NORMAL, nil, nil
+}
+
+func isEqualExactValues(a *dvgrammar.ExpressionValue, b *dvgrammar.ExpressionValue) bool {
+	if a == nil {
+		a = &dvgrammar.ExpressionValue{DataType: dvgrammar.TYPE_UNDEFINED}
+	}
+	if b == nil {
+		b = &dvgrammar.ExpressionValue{DataType: dvgrammar.TYPE_UNDEFINED}
+	}
+	return a.DataType == b.DataType && AnyCompareAnyWithTypes(a.DataType, a.Value, b.DataType, b.Value) == 0
+}
+
+func isFlowForCycle(label string, val *dvgrammar.ExpressionValue) bool {
+	return val == nil || val.Name == "" || val.Name == label
+}
+
 func collectAtLevel(src []*dvgrammar.BuildNode, group int) []*dvgrammar.BuildNode {
 	n := len(src)
 	m := 0
@@ -71,13 +178,20 @@ func collectAtLevel(src []*dvgrammar.BuildNode, group int) []*dvgrammar.BuildNod
 	return res
 }
 
-func ExecuteCycleCommon(context *dvgrammar.ExpressionContext, initValues []*dvgrammar.BuildNode, condValue []*dvgrammar.BuildNode, stepValues []*dvgrammar.BuildNode, cycleBody []*dvgrammar.BuildNode, condAtFirst bool) (int, *dvgrammar.ExpressionValue, error) {
-	mode, err := checkForInOfCase(initValues, condValue, stepValues)
+func ExecuteCycleCommon(context *dvgrammar.ExpressionContext, initValues []*dvgrammar.BuildNode, condValue []*dvgrammar.BuildNode, stepValues []*dvgrammar.BuildNode, cycleBody []*dvgrammar.BuildNode, condAtFirst bool, label string) (int, *dvgrammar.ExpressionValue, error) {
+	pushed, err := dvgrammar.EnterBlock(initValues, context)
+	if err != nil {
+		return dvgrammar.FLOW_NORMAL, nil, err
+	}
+	if pushed {
+		defer context.Scope.StackPop()
+	}
+	mode, err := checkForInOfCase(context, initValues, condValue, stepValues)
 	if err != nil {
 		return dvgrammar.FLOW_NORMAL, nil, err
 	}
 	if mode >= 0 {
-		return ExecuteCycleInOf(context, initValues[0], cycleBody, mode)
+		return ExecuteCycleInOf(context, initValues[0], cycleBody, mode, label)
 	}
 	if len(condValue) > 1 {
 		return 0, nil, errors.New("The cycle must have no more than one condition")
@@ -89,25 +203,29 @@ func ExecuteCycleCommon(context *dvgrammar.ExpressionContext, initValues []*dvgr
 		}
 	}
 	condPresent := len(condValue) == 1 && condValue[0] != nil
-	for i := 0; i < 1000000000; i++ {
-		flow, val, err := dvgrammar.BuildNodeExecution(cycleBody, context)
-		if err != nil {
-			return flow, val, err
-		}
-		if flow == dvgrammar.FLOW_BREAK {
-			flow = dvgrammar.FLOW_NORMAL
-			break
-		} else if flow == dvgrammar.FLOW_CONTINUE {
-			flow = dvgrammar.FLOW_NORMAL
-		} else if flow != dvgrammar.FLOW_NORMAL {
-			return flow, val, err
-		}
-		_, _, err = dvgrammar.BuildNodeExecution(stepValues, context)
+	var bindings []cycleBinding
+	if pushed {
+		bindings = getCycleBindings(initValues, context)
+		err = pushIterationScope(context, bindings, false)
+		defer context.Scope.StackPop()
 		if err != nil {
 			return dvgrammar.FLOW_NORMAL, nil, err
 		}
+	}
+	for i := 0; i < 1000000000; i++ {
+		if i > 0 {
+			if pushed {
+				if err = pushIterationScope(context, bindings, true); err != nil {
+					return dvgrammar.FLOW_NORMAL, nil, err
+				}
+			}
+			_, _, err = dvgrammar.BuildNodeExecution(stepValues, context)
+			if err != nil {
+				return dvgrammar.FLOW_NORMAL, nil, err
+			}
+		}
 		if condPresent && (i > 0 || condAtFirst) {
-			_, val, err = condValue[0].ExecuteExpression(context)
+			_, val, err := condValue[0].ExecuteExpression(context)
 			if err != nil {
 				return dvgrammar.FLOW_NORMAL, nil, err
 			}
@@ -116,14 +234,73 @@ func ExecuteCycleCommon(context *dvgrammar.ExpressionContext, initValues []*dvgr
 				break
 			}
 		}
+		flow, val, err := dvgrammar.BlockExecution(cycleBody, context)
+		if err != nil {
+			return flow, val, err
+		}
+		if flow == dvgrammar.FLOW_BREAK {
+			if !isFlowForCycle(label, val) {
+				return flow, val, nil
+			}
+			break
+		} else if flow == dvgrammar.FLOW_CONTINUE {
+			if !isFlowForCycle(label, val) {
+				return flow, val, nil
+			}
+		} else if flow != dvgrammar.FLOW_NORMAL {
+			return flow, val, err
+		}
 	}
 	return dvgrammar.FLOW_NORMAL, nil, nil
 }
 
-func checkForInOfCase(src []*dvgrammar.BuildNode, lev1 []*dvgrammar.BuildNode, lev2 []*dvgrammar.BuildNode) (r int, err error) {
+type cycleBinding struct {
+	name string
+	kind int
+}
+
+// getCycleBindings returns let/const variables declared in the initialization of the cycle
+func getCycleBindings(initValues []*dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) []cycleBinding {
+	var bindings []cycleBinding
+	for _, node := range initValues {
+		kind := dvgrammar.GetDeclarationKind(node, context)
+		if kind == 0 {
+			continue
+		}
+		for _, name := range dvgrammar.GetPatternNames(dvgrammar.GetDeclaredNameNode(node), nil) {
+			bindings = append(bindings, cycleBinding{name: name, kind: kind})
+		}
+	}
+	return bindings
+}
+
+// pushIterationScope pushes the scope of the iteration with the copies of the cycle variables (replacing
+// the scope of the previous iteration), so the closures created in different iterations keep their own values
+func pushIterationScope(context *dvgrammar.ExpressionContext, bindings []cycleBinding, replace bool) error {
+	values := make([]interface{}, len(bindings))
+	for i, binding := range bindings {
+		values[i], _ = context.Scope.Get(binding.name)
+	}
+	if replace {
+		context.Scope.StackPop()
+	}
+	context.Scope.StackPush(SCOPE_OPTION_BLOCK)
+	for i, binding := range bindings {
+		if err := context.Scope.Declare(binding.name, values[i], binding.kind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkForInOfCase(context *dvgrammar.ExpressionContext, src []*dvgrammar.BuildNode, lev1 []*dvgrammar.BuildNode, lev2 []*dvgrammar.BuildNode) (r int, err error) {
 	r = -1
 	if len(src) >= 1 && src[0] != nil {
-		c := src[0].Operator
+		node := src[0]
+		if dvgrammar.GetDeclarationKind(node, context) != 0 && len(node.Children) == 1 && node.Children[0] != nil {
+			node = node.Children[0]
+		}
+		c := node.Operator
 		if c == "in" {
 			r = 0
 		}
@@ -139,7 +316,11 @@ func checkForInOfCase(src []*dvgrammar.BuildNode, lev1 []*dvgrammar.BuildNode, l
 	return
 }
 
-func ExecuteCycleInOf(context *dvgrammar.ExpressionContext, inof *dvgrammar.BuildNode, cycleBody []*dvgrammar.BuildNode, mode int) (int, *dvgrammar.ExpressionValue, error) {
+func ExecuteCycleInOf(context *dvgrammar.ExpressionContext, inof *dvgrammar.BuildNode, cycleBody []*dvgrammar.BuildNode, mode int, label string) (int, *dvgrammar.ExpressionValue, error) {
+	declaration := dvgrammar.GetDeclarationKind(inof, context)
+	if declaration != 0 && len(inof.Children) == 1 {
+		inof = inof.Children[0]
+	}
 	if inof == nil || len(inof.Children) != 2 || inof.Children[0] == nil || inof.Children[1] == nil || inof.Children[0].Operator != "" {
 		return 0, nil, errors.New("in/of parameters are incorrect")
 	}
@@ -147,76 +328,127 @@ func ExecuteCycleInOf(context *dvgrammar.ExpressionContext, inof *dvgrammar.Buil
 	if err != nil {
 		return flow, v, err
 	}
-	dv := AnyToDvVariable(v)
-	if dv == nil || dv.Kind != FIELD_OBJECT && dv.Kind != FIELD_ARRAY && dv.Kind != FIELD_STRING {
-		return 0, nil, errors.New("Cannot cycle by non-object")
-	}
-	n := len(dv.Fields)
-	var r []interface{}
-	switch dv.Kind {
-	case FIELD_STRING:
-		s := string(dv.Value)
-		n = len(s)
-		if mode == 1 {
-			r = make([]interface{}, n)
-			for i := 0; i < n; i++ {
-				r[i] = string([]byte{s[i]})
-			}
-		} else {
-			r = createIndexArray(n)
-		}
-	case FIELD_OBJECT:
-		if mode == 1 {
-			r = createDvVariableArray(dv.Fields)
-		} else {
-			r = createDvVariableArrayKeys(dv.Fields)
+	var it *ValueIterator
+	if mode == 1 {
+		it, err = getCycleOfIterator(context, v)
+	} else {
+		it = getCycleInIterator(v)
+	}
+	if err != nil {
+		return 0, nil, ThrowScriptError(err, inof, context)
+	}
+	target := inof.Children[0]
+	pattern := dvgrammar.IsDestructuringPattern(target) || len(target.Children) != 0
+	p := ""
+	if !pattern {
+		p, err = ExtractPureName(target)
+		if err != nil {
+			return 0, nil, err
 		}
-	case FIELD_ARRAY:
-		if mode == 1 {
-			r = createDvVariableArray(dv.Fields)
-		} else {
-			r = createIndexArray(n)
+		pos := dvtextutils.IsValidVariableName(p, true)
+		if pos >= 0 {
+			return 0, nil, fmt.Errorf("Bad variable name %s at %d", p, pos)
 		}
 	}
-	p, err := ExtractPureName(inof.Children[0])
-	if err != nil {
-		return 0, nil, err
+	declare := func(name string, value interface{}) error {
+		return context.Scope.Declare(name, value, declaration)
 	}
-	pos := dvtextutils.IsValidVariableName(p, true)
-	if pos >= 0 {
-		return 0, nil, fmt.Errorf("Bad variable name %s at %d", p, pos)
+	set := func(name string, value interface{}) error {
+		return context.Scope.SetDeep(name, value)
 	}
-	for i := 0; i < n; i++ {
-		context.Scope.Set(p, r[i])
-		flow, val, err := dvgrammar.BuildNodeExecution(cycleBody, context)
+	for {
+		item, done, err := it.Next()
+		if err != nil {
+			return 0, nil, ThrowScriptError(err, inof, context)
+		}
+		if done {
+			break
+		}
+		var r interface{}
+		if item != nil {
+			r = item
+		}
+		var val *dvgrammar.ExpressionValue
+		if declaration != 0 {
+			context.Scope.StackPush(SCOPE_OPTION_BLOCK)
+			if pattern {
+				err = AssignPattern(target, r, context, declare)
+			} else {
+				err = context.Scope.Declare(p, r, declaration)
+			}
+			if err == nil {
+				flow, val, err = dvgrammar.BlockExecution(cycleBody, context)
+			}
+			context.Scope.StackPop()
+		} else {
+			if pattern {
+				err = AssignPattern(target, r, context, set)
+			} else {
+				context.Scope.Set(p, r)
+			}
+			if err == nil {
+				flow, val, err = dvgrammar.BlockExecution(cycleBody, context)
+			}
+		}
 		if err != nil {
+			it.Close()
 			return 0, nil, err
 		}
 		if flow == dvgrammar.FLOW_CONTINUE {
+			if !isFlowForCycle(label, val) {
+				it.Close()
+				return flow, val, nil
+			}
 			flow = dvgrammar.FLOW_NORMAL
 		} else if flow == dvgrammar.FLOW_BREAK {
 			flow = dvgrammar.FLOW_NORMAL
+			err = it.Close()
+			if !isFlowForCycle(label, val) {
+				return dvgrammar.FLOW_BREAK, val, err
+			}
 			break
 		} else if flow != dvgrammar.FLOW_NORMAL {
-			return flow, val, nil
+			err = it.Close()
+			return flow, val, err
 		}
 	}
 	return flow, nil, err
 }
 
-func createIndexArray(n int) []interface{} {
-	r := make([]interface{}, n)
-	for i := 0; i < n; i++ {
-		r[i] = i
+// getCycleOfIterator provides the values for for...of, plain objects give their property values
+func getCycleOfIterator(context *dvgrammar.ExpressionContext, v interface{}) (*ValueIterator, error) {
+	dv := AnyToDvVariable(v)
+	if dv != nil && dv.Kind == FIELD_OBJECT && !IsIterableObject(dv) {
+		return &ValueIterator{items: dv.Fields}, nil
 	}
-	return r
+	return NewValueIterator(context, v)
 }
 
-func createDvVariableArray(src []*DvVariable) []interface{} {
-	n := len(src)
+// getCycleInIterator provides the keys for for...in, there are no keys for null, undefined and primitives
+func getCycleInIterator(v interface{}) *ValueIterator {
+	dv := AnyToDvVariable(v)
+	var keys []interface{}
+	if dv != nil {
+		switch dv.Kind {
+		case FIELD_STRING:
+			keys = createIndexArray(len([]rune(string(dv.Value))))
+		case FIELD_ARRAY:
+			keys = createIndexArray(len(dv.Fields))
+		case FIELD_OBJECT, FIELD_FUNCTION:
+			keys = createDvVariableArrayKeys(dv.Fields)
+		}
+	}
+	items := make([]*DvVariable, len(keys))
+	for i, k := range keys {
+		items[i] = AnyToDvVariable(k)
+	}
+	return &ValueIterator{items: items}
+}
+
+func createIndexArray(n int) []interface{} {
 	r := make([]interface{}, n)
 	for i := 0; i < n; i++ {
-		r[i] = src[i]
+		r[i] = i
 	}
 	return r
 }
@@ -263,24 +495,34 @@ func ExecuteIfClauseCommon(context *dvgrammar.ExpressionContext, ifClauseConditi
 	val = nil
 	if v {
 		if len(ifThenClause) > 0 {
-			flow, val, err = dvgrammar.BuildNodeExecution(ifThenClause, context)
+			flow, val, err = dvgrammar.BlockExecution(ifThenClause, context)
 		}
 	} else {
 		if len(ifElseClause) > 0 {
-			flow, val, err = dvgrammar.BuildNodeExecution(ifElseClause, context)
+			flow, val, err = dvgrammar.BlockExecution(ifElseClause, context)
 		}
 	}
 	return flow, val, err
 }
 
 func BreakOperator(tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) (int, *dvgrammar.ExpressionValue, error) {
+	label, err := getFlowLabel(tree, "break")
+	if err != nil {
+		return dvgrammar.FLOW_RETURN, nil, err
+	}
+	return dvgrammar.FLOW_BREAK, label, nil
+}
+
+func getFlowLabel(tree *dvgrammar.BuildNode, operator string) (*dvgrammar.ExpressionValue, error) {
 	n := len(tree.Children)
-	if n > 0 {
-		if tree.Children[0] != nil && (tree.Children[0].Value != nil || tree.Children[0].Operator != "") {
-			return dvgrammar.FLOW_RETURN, nil, errors.New("'break' has no parameters")
-		}
+	if n == 0 || tree.Children[0] == nil || tree.Children[0].Value == nil && tree.Children[0].Operator == "" {
+		return nil, nil
 	}
-	return dvgrammar.FLOW_BREAK, nil, nil
+	node := tree.Children[0]
+	if n > 1 || node.Operator != "" || len(node.Children) != 0 || node.Value.DataType != dvgrammar.TYPE_DATA {
+		return nil, errors.New("'" + operator + "' has no parameters except a label")
+	}
+	return &dvgrammar.ExpressionValue{DataType: dvgrammar.TYPE_UNDEFINED, Name: node.Value.Value}, nil
 }
 
 func DeleteOperator(tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) (int, *dvgrammar.ExpressionValue, error) {
@@ -309,11 +551,55 @@ func DeleteOperator(tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionCont
 }
 
 func ContinueOperator(tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) (int, *dvgrammar.ExpressionValue, error) {
-	n := len(tree.Children)
-	if n > 0 {
-		if tree.Children[0] != nil && (tree.Children[0].Value != nil || tree.Children[0].Operator != "") {
-			return dvgrammar.FLOW_RETURN, nil, errors.New("'continue' has no parameters")
+	label, err := getFlowLabel(tree, "continue")
+	if err != nil {
+		return dvgrammar.FLOW_RETURN, nil, err
+	}
+	return dvgrammar.FLOW_CONTINUE, label, nil
+}
+
+func DeclarationOperator(tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) (int, *dvgrammar.ExpressionValue, error) {
+	kind := dvgrammar.GetDeclarationKind(tree, context)
+	nameNode := dvgrammar.GetDeclaredNameNode(tree)
+	pattern := dvgrammar.IsDestructuringPattern(nameNode)
+	name, err := ExtractPureName(nameNode)
+	if !pattern && (err != nil || nameNode.Value.DataType != dvgrammar.TYPE_DATA) {
+		return dvgrammar.FLOW_NORMAL, nil, errors.New("Variable name expected after " + tree.Operator)
+	}
+	node := tree.Children[len(tree.Children)-1]
+	var value interface{}
+	var val *dvgrammar.ExpressionValue
+	switch node.Operator {
+	case "=":
+		_, val, err = node.Children[1].ExecuteExpression(context)
+		if err != nil {
+			return dvgrammar.FLOW_NORMAL, nil, err
+		}
+		if val != nil {
+			value = val.Value
+		}
+	case "":
+		if pattern {
+			return dvgrammar.FLOW_NORMAL, nil, ThrowScriptError(NewScriptError(ERROR_KIND_SYNTAX, "Missing initializer in destructuring declaration"), tree, context)
 		}
+		if kind == dvgrammar.DECLARATION_CONST {
+			return dvgrammar.FLOW_NORMAL, nil, ThrowScriptError(NewScriptError(ERROR_KIND_SYNTAX, "Missing initializer in const declaration"), tree, context)
+		}
+	default:
+		return dvgrammar.FLOW_NORMAL, nil, errors.New("Unexpected " + node.Operator + " in " + tree.Operator + " declaration")
+	}
+	if pattern {
+		err = AssignPattern(nameNode, value, context, func(name string, v interface{}) error {
+			if err := context.Budget.Allocate(1, tree, context); err != nil {
+				return err
+			}
+			return context.Scope.Declare(name, v, kind)
+		})
+	} else if err = context.Budget.Allocate(1, tree, context); err == nil {
+		err = context.Scope.Declare(name, value, kind)
+	}
+	if err != nil {
+		return dvgrammar.FLOW_NORMAL, nil, ThrowScriptError(err, tree, context)
 	}
-	return dvgrammar.FLOW_CONTINUE, nil, nil
+	return dvgrammar.FLOW_NORMAL, val, nil
 }
@@ -0,0 +1,335 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+package dvevaluation
+
+import (
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	MODULE_STATE_EVALUATING = iota + 1
+	MODULE_STATE_EVALUATED
+	MODULE_STATE_FAILED
+)
+
+// JsModule is a script file loaded by import, its scope is kept after the evaluation to read the exported bindings
+type JsModule struct {
+	Path     string
+	Scope    *ObjectStack
+	State    int
+	exports  map[string]*moduleBinding
+	stars    []*JsModule
+	err      error
+	registry *moduleRegistry
+}
+
+// moduleBinding refers to the local name of the module, "*" refers to the whole module namespace
+type moduleBinding struct {
+	module *JsModule
+	name   string
+}
+
+// moduleRegistry keeps the modules loaded by one evaluation, so each module is evaluated once
+type moduleRegistry struct {
+	modules map[string]*JsModule
+	global  *DvObject
+}
+
+var moduleFinder func(name string) string
+
+// ProvideModuleFinder sets the function to find the modules imported by a name, which is not relative to the importing file
+func ProvideModuleFinder(finder func(name string) string) {
+	moduleFinder = finder
+}
+
+func isFile(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && !info.IsDir()
+}
+
+func findModuleFile(name string, importer string) string {
+	found := ""
+	if strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../") {
+		dir := "."
+		if importer != "" {
+			dir = filepath.Dir(importer)
+		}
+		path := filepath.Join(dir, name)
+		if isFile(path) {
+			found = path
+		} else if filepath.Ext(path) == "" && isFile(path+".js") {
+			found = path + ".js"
+		}
+	} else if filepath.IsAbs(name) {
+		if isFile(name) {
+			found = name
+		}
+	}
+	if found == "" && moduleFinder != nil && !filepath.IsAbs(name) {
+		found = moduleFinder(name)
+		if found == "" && filepath.Ext(name) == "" {
+			found = moduleFinder(name + ".js")
+		}
+	}
+	if found == "" {
+		return ""
+	}
+	path, err := filepath.Abs(found)
+	if err != nil {
+		return found
+	}
+	return path
+}
+
+// CompileModule returns the compiled statements of the script file, they are kept in the cache
+// of the compiled scripts and parsed again only when the file is modified
+func CompileModule(path string, rules *dvgrammar.GrammarRuleDefinitions) ([]*dvgrammar.BuildNode, error) {
+	context := &dvgrammar.ExpressionContext{
+		Reference: &dvgrammar.SourceReference{Row: 1, Column: 1, Place: path},
+		Rules:     rules,
+	}
+	return dvgrammar.CompileFileOrCache(path, context)
+}
+
+func newModule(path string, registry *moduleRegistry) *JsModule {
+	m := &JsModule{Path: path, exports: make(map[string]*moduleBinding), registry: registry}
+	registry.modules[path] = m
+	return m
+}
+
+func getContextModule(context *dvgrammar.ExpressionContext) *JsModule {
+	if context == nil || context.Module == nil {
+		return nil
+	}
+	return context.Module.(*JsModule)
+}
+
+func (m *JsModule) execute(forest []*dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) (*dvgrammar.ExpressionValue, error) {
+	m.State = MODULE_STATE_EVALUATING
+	_, value, err := dvgrammar.ModuleExecution(forest, context)
+	if err != nil {
+		m.State = MODULE_STATE_FAILED
+		m.err = err
+		return nil, err
+	}
+	m.State = MODULE_STATE_EVALUATED
+	return value, nil
+}
+
+func loadModule(name string, node *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) (*JsModule, error) {
+	importer := getContextModule(context)
+	if importer == nil {
+		return nil, ThrowScriptError(NewScriptError(ERROR_KIND_SYNTAX, "Cannot use "+node.Operator+" statement outside a module"), node, context)
+	}
+	path := findModuleFile(name, importer.Path)
+	if path == "" {
+		return nil, ThrowScriptError(NewScriptError(ERROR_KIND_ERROR, "Cannot find module '"+name+"' imported from "+importer.Path), node, context)
+	}
+	m := importer.registry.modules[path]
+	if m != nil {
+		switch m.State {
+		case MODULE_STATE_EVALUATING:
+			return nil, ThrowScriptError(NewScriptError(ERROR_KIND_ERROR, "Circular import of module '"+name+"' from "+importer.Path), node, context)
+		case MODULE_STATE_FAILED:
+			return nil, m.err
+		}
+		return m, nil
+	}
+	forest, err := CompileModule(path, context.Rules)
+	if err != nil {
+		return nil, ThrowScriptError(NewScriptError(ERROR_KIND_SYNTAX, err.Error()), node, context)
+	}
+	m = newModule(path, importer.registry)
+	m.Scope = NewObjectStack(&DvObject{Properties: make(map[string]interface{}), Prototype: importer.registry.global})
+	getAsyncState(context)
+	moduleContext := &dvgrammar.ExpressionContext{
+		Scope:          m.Scope,
+		Reference:      &dvgrammar.SourceReference{Place: path},
+		Rules:          context.Rules,
+		VisitorOptions: context.VisitorOptions,
+		Async:          context.Async,
+		Budget:         context.Budget,
+		Depth:          context.Depth,
+		Module:         m,
+	}
+	_, err = m.execute(forest, moduleContext)
+	return m, err
+}
+
+// GetExport returns the current value of the exported binding
+func (m *JsModule) GetExport(name string) (interface{}, bool) {
+	binding := m.exports[name]
+	if binding == nil {
+		if name != "default" {
+			for _, star := range m.stars {
+				if v, ok := star.GetExport(name); ok {
+					return v, true
+				}
+			}
+		}
+		return nil, false
+	}
+	if binding.module != m {
+		if binding.name == "*" {
+			return binding.module.GetNamespace(), true
+		}
+		return binding.module.GetExport(binding.name)
+	}
+	v, ok := m.Scope.Get(binding.name)
+	if v == uninitializedBinding {
+		v = nil
+	}
+	return v, ok
+}
+
+func (m *JsModule) collectExportNames(names map[string]bool) {
+	for name := range m.exports {
+		names[name] = true
+	}
+	for _, star := range m.stars {
+		starNames := make(map[string]bool)
+		star.collectExportNames(starNames)
+		for name := range starNames {
+			if name != "default" {
+				names[name] = true
+			}
+		}
+	}
+}
+
+// GetNamespace returns the object with all the exports of the module
+func (m *JsModule) GetNamespace() *DvVariable {
+	names := make(map[string]bool)
+	m.collectExportNames(names)
+	keys := make([]string, 0, len(names))
+	for name := range names {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	namespace := &DvVariable{Kind: FIELD_OBJECT, Fields: make([]*DvVariable, 0, len(keys))}
+	for _, name := range keys {
+		v, _ := m.GetExport(name)
+		AssignVariableByKey(namespace, name, v, false)
+	}
+	return namespace
+}
+
+// enterModuleScope adds the levels of the module scope, so the function defined in the module
+// sees the variables of its module; the returned function restores the scope
+func enterModuleScope(context *dvgrammar.ExpressionContext, m *JsModule) func() {
+	stack, ok := context.Scope.(*ObjectStack)
+	if !ok || m.Scope == nil {
+		return nil
+	}
+	var levels []*DvObject
+	for level := m.Scope.CurrentLevel; level != nil; level = level.Prototype {
+		levels = append(levels, level)
+		if level == m.Scope.BaseLevel {
+			break
+		}
+	}
+	saved := stack.CurrentLevel
+	savedModule := context.Module
+	stack.overlayLevels(levels)
+	context.Module = m
+	return func() {
+		stack.CurrentLevel = saved
+		context.Module = savedModule
+	}
+}
+
+func ImportOperator(tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) (int, *dvgrammar.ExpressionValue, error) {
+	if len(tree.Children) == 0 || tree.Children[0].Value == nil {
+		return dvgrammar.FLOW_NORMAL, nil, dvgrammar.ErrorMessageForNode("Module name expected after import", tree, context)
+	}
+	name := tree.Children[0].Value.Value
+	m, err := loadModule(name, tree, context)
+	if err != nil {
+		return dvgrammar.FLOW_NORMAL, nil, err
+	}
+	for _, binding := range tree.Children[1:] {
+		imported := binding.Children[0].Value.Value
+		local := binding.Children[1].Value.Value
+		var v interface{}
+		if imported == "*" {
+			v = m.GetNamespace()
+		} else {
+			var ok bool
+			v, ok = m.GetExport(imported)
+			if !ok {
+				err = NewScriptError(ERROR_KIND_SYNTAX, "The requested module '"+name+"' does not provide an export named '"+imported+"'")
+				return dvgrammar.FLOW_NORMAL, nil, ThrowScriptError(err, binding, context)
+			}
+		}
+		err = context.Scope.Declare(local, v, dvgrammar.DECLARATION_CONST)
+		if err != nil {
+			return dvgrammar.FLOW_NORMAL, nil, ThrowScriptError(err, binding, context)
+		}
+	}
+	return dvgrammar.FLOW_NORMAL, nil, nil
+}
+
+func ExportOperator(tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext) (int, *dvgrammar.ExpressionValue, error) {
+	m := getContextModule(context)
+	if m == nil {
+		err := NewScriptError(ERROR_KIND_SYNTAX, "Cannot use export statement outside a module")
+		return dvgrammar.FLOW_NORMAL, nil, ThrowScriptError(err, tree, context)
+	}
+	source := m
+	if len(tree.Children) > 0 && tree.Children[0].Value != nil {
+		var err error
+		source, err = loadModule(tree.Children[0].Value.Value, tree, context)
+		if err != nil {
+			return dvgrammar.FLOW_NORMAL, nil, err
+		}
+	}
+	for _, binding := range tree.Children[1:] {
+		local := binding.Children[0].Value.Value
+		exported := binding.Children[1].Value.Value
+		if exported == "*" {
+			m.stars = append(m.stars, source)
+			continue
+		}
+		if m.exports[exported] != nil {
+			err := NewScriptError(ERROR_KIND_SYNTAX, "Duplicate export of '"+exported+"'")
+			return dvgrammar.FLOW_NORMAL, nil, ThrowScriptError(err, binding, context)
+		}
+		m.exports[exported] = &moduleBinding{module: source, name: local}
+	}
+	return dvgrammar.FLOW_NORMAL, nil, nil
+}
+
+// EvaluateScriptFile evaluates the script file as a module, which can import other script files
+func (obj *DvObject) EvaluateScriptFile(fileName string) (interface{}, error) {
+	path, err := filepath.Abs(fileName)
+	if err != nil {
+		return nil, err
+	}
+	forest, err := CompileModule(path, CalculatorRules)
+	if err != nil {
+		return nil, err
+	}
+	budget := obj.NewExecutionBudget()
+	defer budget.Release()
+	m := newModule(path, &moduleRegistry{modules: make(map[string]*JsModule), global: obj})
+	m.Scope = NewObjectStack(obj)
+	context := &dvgrammar.ExpressionContext{
+		Scope:     m.Scope,
+		Reference: &dvgrammar.SourceReference{Place: path},
+		Rules:     CalculatorRules,
+		Budget:    budget,
+		Module:    m,
+	}
+	value, err := m.execute(forest, context)
+	err = RunEventLoop(context, err)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	return value.Value, nil
+}
@@ -5,6 +5,13 @@ Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@
 
 package dvevaluation
 
+import "github.com/Dobryvechir/microcore/pkg/dvgrammar"
+
+const SCOPE_OPTION_BLOCK = dvgrammar.SCOPE_OPTION_BLOCK
+
+// uninitializedBinding is kept for let/const variables until their declarations are executed
+var uninitializedBinding = &DvObject{}
+
 type ObjectStack struct {
 	BaseLevel    *DvObject
 	CurrentLevel *DvObject
@@ -18,6 +25,14 @@ func NewObjectStack(object *DvObject) *ObjectStack {
 	return objStack
 }
 
+// Fork gives the stack sharing the current levels, so the new levels can be pushed independently
+func (obj *ObjectStack) Fork() *ObjectStack {
+	return &ObjectStack{
+		BaseLevel:    obj.BaseLevel,
+		CurrentLevel: obj.CurrentLevel,
+	}
+}
+
 func (obj *ObjectStack) Get(key string) (interface{}, bool) {
 	return obj.CurrentLevel.Get(key)
 }
@@ -40,18 +55,105 @@ func (obj *ObjectStack) StackPop() {
 	}
 }
 
-func (obj *ObjectStack) SetDeep(key string, value interface{}) {
+func (obj *ObjectStack) SetDeep(key string, value interface{}) error {
 	dvobj := obj.CurrentLevel
 	for {
-		_, ok := dvobj.Properties[key]
+		old, ok := dvobj.Properties[key]
 		if ok {
+			if old == uninitializedBinding {
+				return NewScriptError(ERROR_KIND_REFERENCE, "Cannot access '"+key+"' before initialization")
+			}
+			if dvobj.Declarations[key] == dvgrammar.DECLARATION_CONST {
+				return NewScriptError(ERROR_KIND_TYPE, "Assignment to constant variable.")
+			}
 			dvobj.Properties[key] = value
-			return
+			return nil
 		}
 		if dvobj == obj.BaseLevel {
 			break
 		}
 		dvobj = dvobj.Prototype
 	}
-	obj.CurrentLevel.Properties[key] = value
+	dvobj = obj.CurrentLevel
+	for dvobj != obj.BaseLevel && (dvobj.Options&SCOPE_OPTION_BLOCK) != 0 {
+		dvobj = dvobj.Prototype
+	}
+	dvobj.Properties[key] = value
+	return nil
+}
+
+// Declare creates let/const bindings in the current level; with DECLARATION_HOISTED
+// the binding is only reserved and stays inaccessible until its declaration is executed
+func (obj *ObjectStack) Declare(key string, value interface{}, kind int) error {
+	dvobj := obj.CurrentLevel
+	if kind == dvgrammar.DECLARATION_VAR {
+		for dvobj != obj.BaseLevel && (dvobj.Options&SCOPE_OPTION_BLOCK) != 0 {
+			dvobj = dvobj.Prototype
+		}
+		if dvobj.Declarations[key] != 0 {
+			return NewScriptError(ERROR_KIND_SYNTAX, "Identifier '"+key+"' has already been declared")
+		}
+		dvobj.Properties[key] = value
+		return nil
+	}
+	declared := dvobj.Declarations[key]
+	if kind&dvgrammar.DECLARATION_HOISTED != 0 {
+		if declared != 0 {
+			return NewScriptError(ERROR_KIND_SYNTAX, "Identifier '"+key+"' has already been declared")
+		}
+		value = uninitializedBinding
+		kind &^= dvgrammar.DECLARATION_HOISTED
+	} else if declared != 0 && dvobj.Properties[key] != uninitializedBinding {
+		return NewScriptError(ERROR_KIND_SYNTAX, "Identifier '"+key+"' has already been declared")
+	}
+	if dvobj.Declarations == nil {
+		dvobj.Declarations = make(map[string]int)
+	}
+	dvobj.Declarations[key] = kind
+	dvobj.Properties[key] = value
+	return nil
+}
+
+// overlayLevels pushes the levels sharing the variables of the given levels (the top level is the first one)
+func (obj *ObjectStack) overlayLevels(levels []*DvObject) {
+	for i := len(levels) - 1; i >= 0; i-- {
+		obj.CurrentLevel = &DvObject{
+			Options:      SCOPE_OPTION_BLOCK,
+			Prototype:    obj.CurrentLevel,
+			Properties:   levels[i].Properties,
+			Declarations: levels[i].Declarations,
+		}
+	}
+}
+
+// captureClosure returns the levels of the current scope above the base level for the function being created
+func captureClosure(context *dvgrammar.ExpressionContext) []*DvObject {
+	stack, ok := context.Scope.(*ObjectStack)
+	if !ok {
+		return nil
+	}
+	var levels []*DvObject
+	for level := stack.CurrentLevel; level != nil && level != stack.BaseLevel; level = level.Prototype {
+		levels = append(levels, level)
+	}
+	return levels
+}
+
+// enterClosureScope makes the scope levels of the function creation visible during its call,
+// unless the call is made inside them; it returns the function restoring the scope
+func enterClosureScope(context *dvgrammar.ExpressionContext, closure []*DvObject) func() {
+	stack, ok := context.Scope.(*ObjectStack)
+	if !ok || len(closure) == 0 {
+		return nil
+	}
+	for level := stack.CurrentLevel; level != nil && level != stack.BaseLevel; level = level.Prototype {
+		if level == closure[0] {
+			return nil
+		}
+	}
+	saved := stack.CurrentLevel
+	stack.overlayLevels(closure)
+	return func() {
+		stack.CurrentLevel = saved
+	}
 }
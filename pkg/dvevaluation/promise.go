@@ -0,0 +1,237 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvevaluation
+
+import (
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
+)
+
+const (
+	PROMISE_PENDING = iota
+	PROMISE_FULFILLED
+	PROMISE_REJECTED
+)
+
+// JsPromise is kept in Extra of Promise objects; its reactions are run as jobs of the event loop
+// of the evaluation, which created the promise
+type JsPromise struct {
+	State     int
+	Value     interface{}
+	context   *dvgrammar.ExpressionContext
+	reactions []func() error
+	handled   bool
+}
+
+func NewPromise(context *dvgrammar.ExpressionContext) (*DvVariable, *JsPromise) {
+	p := &JsPromise{context: ForkAsyncContext(context)}
+	return &DvVariable{Kind: FIELD_OBJECT, Extra: p, Prototype: PromiseMaster}, p
+}
+
+func GetJsPromise(v interface{}) *JsPromise {
+	dv := AnyToDvVariable(v)
+	if dv == nil || dv.Extra == nil {
+		return nil
+	}
+	p, _ := dv.Extra.(*JsPromise)
+	return p
+}
+
+func IsCallableValue(v interface{}) bool {
+	dv := AnyToDvVariable(v)
+	return dv != nil && dv.Kind == FIELD_FUNCTION
+}
+
+// PromiseResolve returns the promise of the value itself or the new promise resolved with the value
+func PromiseResolve(context *dvgrammar.ExpressionContext, v interface{}) *JsPromise {
+	if p := GetJsPromise(v); p != nil {
+		return p
+	}
+	_, p := NewPromise(context)
+	p.Resolve(v)
+	return p
+}
+
+func normalizePromiseValue(v interface{}) interface{} {
+	if ev, ok := v.(*dvgrammar.ExpressionValue); ok {
+		if ev == nil {
+			return nil
+		}
+		return ev.Value
+	}
+	return v
+}
+
+func (p *JsPromise) loop() *EventLoop {
+	return p.context.Async.(*AsyncState).Loop
+}
+
+// Resolve fulfills the promise with the value or makes it follow the value, when the value is a promise or a thenable
+func (p *JsPromise) Resolve(v interface{}) {
+	if p.State != PROMISE_PENDING {
+		return
+	}
+	v = normalizePromiseValue(v)
+	if other := GetJsPromise(v); other != nil {
+		if other == p {
+			p.Reject(NewErrorObject(ERROR_KIND_TYPE, "Chaining cycle detected for promise #<Promise>"))
+			return
+		}
+		other.OnSettled(func() error {
+			p.settle(other.State, other.Value)
+			return nil
+		})
+		return
+	}
+	dv := AnyToDvVariable(v)
+	if dv != nil && (dv.Kind == FIELD_OBJECT || dv.Kind == FIELD_FUNCTION) {
+		if then := getCallableChild(dv, "then"); then != nil {
+			p.loop().EnqueueJob(func() error {
+				resolve, reject := p.ResolvingFunctions()
+				_, err := ExecuteAnyFunction(p.context, then, dv, []interface{}{resolve, reject})
+				if err != nil {
+					_, err = ExecuteAnyFunction(p.context, reject, nil, []interface{}{GetCatchValue(err)})
+				}
+				return err
+			})
+			return
+		}
+	}
+	p.settle(PROMISE_FULFILLED, v)
+}
+
+func (p *JsPromise) Reject(v interface{}) {
+	if p.State != PROMISE_PENDING {
+		return
+	}
+	p.settle(PROMISE_REJECTED, normalizePromiseValue(v))
+}
+
+func (p *JsPromise) settle(state int, v interface{}) {
+	if p.State != PROMISE_PENDING {
+		return
+	}
+	p.State = state
+	p.Value = v
+	loop := p.loop()
+	for _, job := range p.reactions {
+		loop.EnqueueJob(job)
+	}
+	p.reactions = nil
+	if state == PROMISE_REJECTED && !p.handled {
+		loop.rejected = append(loop.rejected, p)
+	}
+}
+
+// OnSettled registers the job, which is run by the event loop after the promise is settled
+func (p *JsPromise) OnSettled(job func() error) {
+	p.handled = true
+	if p.State == PROMISE_PENDING {
+		p.reactions = append(p.reactions, job)
+	} else {
+		p.loop().EnqueueJob(job)
+	}
+}
+
+// ResolvingFunctions returns resolve and reject for executors and thenables, only the first call of them matters
+func (p *JsPromise) ResolvingFunctions() (*DvVariable, *DvVariable) {
+	done := false
+	resolve := &DvVariable{
+		Kind: FIELD_FUNCTION,
+		Extra: &DvFunction{
+			Fn: func(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+				if !done {
+					done = true
+					p.Resolve(getPromiseParam(params))
+				}
+				return nil, nil
+			},
+		},
+	}
+	reject := &DvVariable{
+		Kind: FIELD_FUNCTION,
+		Extra: &DvFunction{
+			Fn: func(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+				if !done {
+					done = true
+					p.Reject(getPromiseParam(params))
+				}
+				return nil, nil
+			},
+		},
+	}
+	return resolve, reject
+}
+
+func getPromiseParam(params []interface{}) interface{} {
+	if len(params) == 0 {
+		return nil
+	}
+	return params[0]
+}
+
+// Then creates the promise resolved by the result of the handler, the handlers, which are not functions,
+// pass the value or the reason unchanged
+func (p *JsPromise) Then(context *dvgrammar.ExpressionContext, onFulfilled interface{}, onRejected interface{}) *DvVariable {
+	res, q := NewPromise(context)
+	p.OnSettled(func() error {
+		handler := onFulfilled
+		if p.State == PROMISE_REJECTED {
+			handler = onRejected
+		}
+		if !IsCallableValue(handler) {
+			q.settleAs(p)
+			return nil
+		}
+		v, err := ExecuteAnyFunction(q.context, handler, nil, []interface{}{p.Value})
+		if err != nil {
+			q.Reject(GetCatchValue(err))
+		} else {
+			q.Resolve(v)
+		}
+		return nil
+	})
+	return res
+}
+
+func (p *JsPromise) settleAs(other *JsPromise) {
+	if other.State == PROMISE_FULFILLED {
+		p.Resolve(other.Value)
+	} else {
+		p.Reject(other.Value)
+	}
+}
+
+func AwaitOperator(value *dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string, lastVarName string, lastParent *dvgrammar.ExpressionValue) (*dvgrammar.ExpressionValue, error) {
+	var v interface{}
+	if value != nil {
+		v = value.Value
+	}
+	res, ok, err := AwaitValue(context, v)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, dvgrammar.NewThrownError(res, getThrownValueDescription(res), tree, context)
+	}
+	return AnyToDvGrammarExpressionValue(res), nil
+}
+
+// AsyncOperator leaves the value unchanged, async is taken into account when the functions are created
+func AsyncOperator(value *dvgrammar.ExpressionValue, tree *dvgrammar.BuildNode, context *dvgrammar.ExpressionContext, operator string, lastVarName string, lastParent *dvgrammar.ExpressionValue) (*dvgrammar.ExpressionValue, error) {
+	return value, nil
+}
+
+func hasAsyncModifier(node *dvgrammar.BuildNode) bool {
+	if node == nil {
+		return false
+	}
+	for _, attr := range node.PreAttributes {
+		if attr == "async" {
+			return true
+		}
+	}
+	return false
+}
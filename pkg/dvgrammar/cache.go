@@ -0,0 +1,180 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+package dvgrammar
+
+import (
+	"container/list"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// DefaultCompiledCacheCapacity is the number of compiled scripts kept by CompiledScripts by default
+const DefaultCompiledCacheCapacity = 4096
+
+// compiledKey identifies the compiled script by its rules, its place and either its source text
+// or the modification time and the size of its file
+type compiledKey struct {
+	rules   *GrammarRuleDefinitions
+	place   string
+	row     int
+	column  int
+	source  string
+	file    bool
+	modTime int64
+	size    int64
+}
+
+type compiledEntry struct {
+	key    compiledKey
+	forest []*BuildNode
+}
+
+// CompiledCacheStats shows the efficiency of the cache
+type CompiledCacheStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Size      int   `json:"size"`
+	Capacity  int   `json:"capacity"`
+}
+
+// CompiledCache is the bounded LRU cache of the compiled scripts; the cached trees are never modified
+// by the execution, so they are reused by the concurrent evaluations
+type CompiledCache struct {
+	mu        sync.Mutex
+	capacity  int
+	items     map[compiledKey]*list.Element
+	order     *list.List
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+// CompiledScripts keeps the scripts and expressions compiled by FastEvaluation and the script files
+var CompiledScripts = NewCompiledCache(DefaultCompiledCacheCapacity)
+
+// NewCompiledCache creates the cache, zero or negative capacity disables the caching
+func NewCompiledCache(capacity int) *CompiledCache {
+	return &CompiledCache{
+		capacity: capacity,
+		items:    make(map[compiledKey]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *CompiledCache) get(key compiledKey) ([]*BuildNode, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.capacity <= 0 {
+		return nil, false
+	}
+	element, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*compiledEntry).forest, true
+}
+
+// put returns false when the caching is disabled
+func (c *CompiledCache) put(key compiledKey, forest []*BuildNode) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.capacity <= 0 {
+		return false
+	}
+	if element, ok := c.items[key]; ok {
+		element.Value.(*compiledEntry).forest = forest
+		c.order.MoveToFront(element)
+		return true
+	}
+	c.items[key] = c.order.PushFront(&compiledEntry{key: key, forest: forest})
+	c.shrink()
+	return true
+}
+
+func (c *CompiledCache) shrink() {
+	for c.order.Len() > 0 && c.order.Len() > c.capacity {
+		element := c.order.Back()
+		c.order.Remove(element)
+		delete(c.items, element.Value.(*compiledEntry).key)
+		c.evictions++
+	}
+}
+
+// SetCapacity changes the maximum number of the cached scripts, zero or negative value disables the caching
+func (c *CompiledCache) SetCapacity(capacity int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.capacity = capacity
+	c.shrink()
+}
+
+func (c *CompiledCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = make(map[compiledKey]*list.Element)
+	c.order.Init()
+}
+
+func (c *CompiledCache) Stats() CompiledCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CompiledCacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Size:      c.order.Len(),
+		Capacity:  c.capacity,
+	}
+}
+
+func sourceKey(data []byte, context *ExpressionContext) compiledKey {
+	key := compiledKey{rules: context.Rules, source: string(data)}
+	if context.Reference != nil {
+		key.place = context.Reference.Place
+		key.row = context.Reference.Row
+		key.column = context.Reference.Column
+	}
+	return key
+}
+
+// compileCached returns the compiled script and true if the script is kept by the cache
+func compileCached(data []byte, context *ExpressionContext) ([]*BuildNode, bool, error) {
+	key := sourceKey(data, context)
+	if forest, ok := CompiledScripts.get(key); ok {
+		return forest, true, nil
+	}
+	forest, err := Compile(data, context)
+	if err != nil {
+		return nil, false, err
+	}
+	return forest, CompiledScripts.put(key, forest), nil
+}
+
+// CompileFileOrCache returns the compiled script file, it is compiled again only when the file is modified
+func CompileFileOrCache(fileName string, context *ExpressionContext) ([]*BuildNode, error) {
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return nil, err
+	}
+	key := compiledKey{rules: context.Rules, place: fileName, file: true, modTime: info.ModTime().UnixNano(), size: info.Size()}
+	if forest, ok := CompiledScripts.get(key); ok {
+		return forest, nil
+	}
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	forest, err := Compile(data, context)
+	if err != nil {
+		return nil, err
+	}
+	CompiledScripts.put(key, forest)
+	return forest, nil
+}
@@ -0,0 +1,18 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+package dvgrammar
+
+// DebugHook is called before each statement of the debugged evaluation, it may block the evaluation
+// while the debugger is paused; the returned error stops the evaluation
+type DebugHook interface {
+	BeforeStatement(node *BuildNode, context *ExpressionContext) error
+}
+
+func (context *ExpressionContext) debugStatement(tree *BuildNode) error {
+	if context.Budget == nil || context.Budget.Debug == nil {
+		return nil
+	}
+	return context.Budget.Debug.BeforeStatement(tree, context)
+}
@@ -0,0 +1,167 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvgrammar
+
+func GetDeclarationKind(node *BuildNode, context *ExpressionContext) int {
+	if node == nil || node.Operator == "" || context.Rules.BaseGrammar == nil {
+		return 0
+	}
+	lang, ok := context.Rules.BaseGrammar.Language[node.Operator]
+	if !ok {
+		return 0
+	}
+	return lang.Declaration
+}
+
+// GetDeclaredNameNode returns the node with the variable name or the destructuring pattern of let/const declaration
+// (let a; let a = 1; let [a, b] = c; for (let a of b))
+func GetDeclaredNameNode(node *BuildNode) *BuildNode {
+	n := len(node.Children)
+	if n == 0 || node.Children[n-1] == nil {
+		return nil
+	}
+	node = node.Children[n-1]
+	switch node.Operator {
+	case "":
+		return node
+	case "=", "in", "of":
+		if len(node.Children) == 2 && node.Children[0] != nil && node.Children[0].Operator == "" {
+			return node.Children[0]
+		}
+	}
+	return nil
+}
+
+// IsDestructuringPattern checks whether the node is an array or object pattern ([a, b] or {a, b})
+func IsDestructuringPattern(node *BuildNode) bool {
+	if node == nil || node.Operator != "" || node.Value == nil || node.Value.DataType != TYPE_FUNCTION || len(node.Children) != 1 {
+		return false
+	}
+	body := node.Children[0]
+	return body != nil && (body.Operator == "[" || body.Operator == "{")
+}
+
+// GetPatternNames adds names of variables bound by the variable name or the destructuring pattern
+func GetPatternNames(node *BuildNode, names []string) []string {
+	if node == nil {
+		return names
+	}
+	switch node.Operator {
+	case "=":
+		if len(node.Children) == 2 {
+			return GetPatternNames(node.Children[0], names)
+		}
+	case ":":
+		if len(node.Children) == 2 {
+			return GetPatternNames(node.Children[1], names)
+		}
+	case "":
+		if IsDestructuringPattern(node) {
+			for _, child := range node.Children[0].Children {
+				names = GetPatternNames(child, names)
+			}
+		} else if node.Value != nil && node.Value.DataType == TYPE_DATA && len(node.Children) == 0 {
+			names = append(names, node.Value.Value)
+		}
+	}
+	return names
+}
+
+// EnterBlock adds a new scope level when the block has let/const declarations
+// and reserves their names in the temporal dead zone; it returns true if the level was added
+func EnterBlock(nodes []*BuildNode, context *ExpressionContext) (bool, error) {
+	pushed := false
+	for _, node := range nodes {
+		kind := GetDeclarationKind(node, context)
+		if kind == 0 {
+			continue
+		}
+		nameNode := GetDeclaredNameNode(node)
+		names := GetPatternNames(nameNode, nil)
+		if len(names) == 0 {
+			if pushed {
+				context.Scope.StackPop()
+			}
+			return false, ErrorMessageForNode("Variable name expected after "+node.Operator, node, context)
+		}
+		if !pushed {
+			context.Scope.StackPush(SCOPE_OPTION_BLOCK)
+			pushed = true
+		}
+		for _, name := range names {
+			err := context.Scope.Declare(name, nil, kind|DECLARATION_HOISTED)
+			if err != nil {
+				context.Scope.StackPop()
+				return false, ErrorMessageForNode(err.Error(), nameNode, context)
+			}
+		}
+	}
+	return pushed, nil
+}
+
+func isHoistedNode(node *BuildNode, context *ExpressionContext) bool {
+	if node == nil || node.Value == nil || node.Operator == "" || context.Rules.BaseGrammar == nil {
+		return false
+	}
+	lang, ok := context.Rules.BaseGrammar.Language[node.Operator]
+	return ok && lang.Hoisted
+}
+
+// hoistDeclarations executes named function declarations before other statements of the block
+// and returns the rest of the statements
+func hoistDeclarations(nodes []*BuildNode, context *ExpressionContext) ([]*BuildNode, error) {
+	var rest []*BuildNode
+	for i, node := range nodes {
+		if !isHoistedNode(node, context) {
+			if rest != nil {
+				rest = append(rest, node)
+			}
+			continue
+		}
+		if rest == nil {
+			rest = make([]*BuildNode, i, len(nodes))
+			copy(rest, nodes[:i])
+		}
+		_, _, err := node.ExecuteExpression(context)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if rest == nil {
+		return nodes, nil
+	}
+	return rest, nil
+}
+
+func BlockExecution(nodes []*BuildNode, context *ExpressionContext) (flow int, value *ExpressionValue, err error) {
+	pushed, err := EnterBlock(nodes, context)
+	if err != nil {
+		return FLOW_NORMAL, nil, err
+	}
+	nodes, err = hoistDeclarations(nodes, context)
+	if err == nil {
+		flow, value, err = BuildNodeExecution(nodes, context)
+	}
+	if pushed {
+		context.Scope.StackPop()
+	}
+	return
+}
+
+// ModuleExecution executes the statements of a module; unlike BlockExecution it keeps the scope levels
+// with the module declarations, so the exported bindings remain available after the execution
+func ModuleExecution(nodes []*BuildNode, context *ExpressionContext) (flow int, value *ExpressionValue, err error) {
+	context.Scope.StackPush(SCOPE_OPTION_BLOCK)
+	_, err = EnterBlock(nodes, context)
+	if err != nil {
+		return FLOW_NORMAL, nil, err
+	}
+	nodes, err = hoistDeclarations(nodes, context)
+	if err == nil {
+		flow, value, err = BuildNodeExecution(nodes, context)
+	}
+	return
+}
@@ -26,6 +26,11 @@ const (
 	FEATURE_CURLY_BRACKETS_OR_CODE = 1 << iota
 	FEATURE_FINISH                 = 1 << iota
 	FEATURE_FINISH_OR_ELSE         = 1 << iota
+	FEATURE_WHILE_FOLLOWS          = 1 << iota
+	FEATURE_CATCH_OR_FINALLY       = 1 << iota
+	FEATURE_FINALLY_OPTIONAL       = 1 << iota
+	FEATURE_NAME_OPTIONAL          = 1 << iota
+	FEATURE_EXTENDS_OPTIONAL       = 1 << iota
 )
 
 const (
@@ -43,6 +48,7 @@ const (
 	TYPE_DATA       = iota
 	TYPE_OBJECT     = iota
 	TYPE_ERROR      = iota
+	TYPE_TEMPLATE   = iota
 	TYPE_MASK       = 0x7ff
 )
 
@@ -53,6 +59,19 @@ const (
 	FLOW_CONTINUE
 )
 
+// SCOPE_OPTION_BLOCK marks stack levels created for blocks (such as catch clauses or blocks with let/const),
+// which keep their own bindings but do not receive implicitly created variables
+const SCOPE_OPTION_BLOCK = 0x100
+
+//kinds of lexical declarations passed to ScopeInterface.Declare
+const (
+	DECLARATION_LET     = 1
+	DECLARATION_CONST   = 2
+	DECLARATION_VAR     = 4
+	// DECLARATION_HOISTED registers the name at the block start, before the declaration itself is reached
+	DECLARATION_HOISTED = 0x10
+)
+
 type Token struct {
 	DataType int
 	Row      int
@@ -63,6 +82,9 @@ type Token struct {
 
 type InterOperator struct {
 	Precedence         int
+	// LeftPrecedence is used instead of Precedence to take the left operand,
+	// so the arrow takes only its parameters, but its body extends to the end of the expression
+	LeftPrecedence     int
 	Multi              bool
 	RightToLeft        bool
 	LazyLoadedOperands bool
@@ -78,6 +100,10 @@ type LanguageOperator struct {
 	ParenthesesFollow            bool
 	CurlyBracesFollowParentheses bool
 	FeatureOptions               int
+	SwitchBodyOnly               bool
+	AcceptsLabel                 bool
+	Declaration                  int
+	Hoisted                      bool
 }
 
 type UnaryOperator struct {
@@ -90,7 +116,8 @@ type ScopeInterface interface {
 	Set(key string, value interface{})
 	StackPush(option int)
 	StackPop()
-	SetDeep(key string, value interface{})
+	SetDeep(key string, value interface{}) error
+	Declare(key string, value interface{}, kind int) error
 }
 
 type GrammarBaseDefinition struct {
@@ -109,6 +136,7 @@ type BuildNode struct {
 	PreAttributes  []string
 	PostAttributes []string
 	Group          int
+	Label          string
 	closed         bool
 }
 
@@ -156,7 +184,6 @@ type GrammarRuleDefinitions struct {
 	UnaryPostVisitors map[string]UnaryVisitor
 	UnaryPreVisitors  map[string]UnaryVisitor
 	DataGetter        func(*Token, *ExpressionContext) (*ExpressionValue, error)
-	cache             map[string][]*BuildNode
 	Grammar           *GrammarTable
 }
 
@@ -165,4 +192,11 @@ type ExpressionContext struct {
 	Reference      *SourceReference
 	Rules          *GrammarRuleDefinitions
 	VisitorOptions int
+	// Async keeps the event loop of the evaluation, it is created by the evaluator on demand
+	Async          interface{}
+	// Budget limits the resources of the evaluation, Depth is the number of the nested function calls
+	Budget         *ExecutionBudget
+	Depth          int
+	// Module is the script module being executed, nil outside modules
+	Module         interface{}
 }
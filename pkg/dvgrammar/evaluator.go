@@ -21,33 +21,19 @@ func CompileOrCache(data []byte, context *ExpressionContext, cache bool) ([]*Bui
 	if !cache {
 		return Compile(data, context)
 	}
-	key := string(data)
-	if context.Rules.cache == nil {
-		context.Rules.cache = make(map[string][]*BuildNode)
-	}
-	tree, ok := context.Rules.cache[key]
-	if ok {
-		return tree, nil
-	}
-	var err error
-	tree, err = Compile(data, context)
-	if err != nil {
-		return nil, err
-	}
-	context.Rules.cache[key] = tree
-	return tree, nil
+	tree, _, err := compileCached(data, context)
+	return tree, err
 }
 
 func FastEvaluation(data []byte, context *ExpressionContext) (*ExpressionValue, error) {
-	cache := (context.VisitorOptions & VISITOR_OPTION_CASHED) != 0
-	forest, err := CompileOrCache(data, context, cache)
+	forest, cached, err := compileCached(data, context)
 	if err != nil {
 		return nil, err
 	}
 	var value *ExpressionValue
-	_, value, err = BuildNodeExecution(forest, context)
+	_, value, err = BlockExecution(forest, context)
 	n:=len(forest)
-	if !cache {
+	if !cached {
 		for i := 0; i < n; i++ {
 			tree := forest[i]
 			fullTreeClean(tree)
@@ -62,6 +48,9 @@ func BuildNodeExecution(nodes []*BuildNode, context *ExpressionContext) (flow in
 forestTrack:
 	for i := 0; i < n; i++ {
 		tree := nodes[i]
+		if err = context.debugStatement(tree); err != nil {
+			break
+		}
 		flow, value, err = tree.ExecuteExpression(context)
 		if err != nil {
 			break
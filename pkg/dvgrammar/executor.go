@@ -15,6 +15,9 @@ func (tree *BuildNode) ExecuteExpression(context *ExpressionContext) (int, *Expr
 	l := len(tree.Children)
 	var lastVarName string
 	var lastParent *ExpressionValue
+	if err = context.Budget.Step(tree, context); err != nil {
+		return flow, nil, err
+	}
 	if tree.Operator != "" {
 		visitor, ok := context.Rules.Visitors[tree.Operator]
 		operator, ok1 := context.Rules.BaseGrammar.Operators[tree.Operator]
@@ -45,6 +48,9 @@ func (tree *BuildNode) ExecuteExpression(context *ExpressionContext) (int, *Expr
 		}
 		value, err = visitor(v, tree, context, tree.Operator)
 		if err != nil {
+			if _, ok := err.(*ThrownError); ok {
+				return flow, nil, err
+			}
 			return flow, nil, ErrorMessageForNode(err.Error(), tree, context)
 		}
 	} else if tree.Value != nil {
@@ -61,6 +67,14 @@ func (tree *BuildNode) ExecuteExpression(context *ExpressionContext) (int, *Expr
 				return flow, value, err
 			}
 		}
+		constructing := len(tree.PreAttributes) != 0 && tree.PreAttributes[0] == constructorOperator
+		if constructing {
+			value, err = executeConstructor(value, hasNoParent, tree, context)
+			if err != nil {
+				return flow, nil, err
+			}
+			l = 0
+		}
 		if l > 0 {
 			needName := false
 			if (context.VisitorOptions & EVALUATE_OPTION_NAME) == 0 {
@@ -100,7 +114,11 @@ func (tree *BuildNode) ExecuteExpression(context *ExpressionContext) (int, *Expr
 		if lastParent != nil && value != nil && value.Name != "" {
 			lastVarName = value.Name
 		}
-		for _, vl := range tree.PreAttributes {
+		preAttributes := tree.PreAttributes
+		if preAttributes[0] == constructorOperator && tree.Operator == "" && tree.Value != nil {
+			preAttributes = preAttributes[1:]
+		}
+		for _, vl := range preAttributes {
 			v, err := context.Rules.UnaryPreVisitors[vl](value, tree, context, vl, lastVarName, lastParent)
 			if err != nil {
 				return flow, nil, err
@@ -111,6 +129,45 @@ func (tree *BuildNode) ExecuteExpression(context *ExpressionContext) (int, *Expr
 	return flow, value, nil
 }
 
+const constructorOperator = "new"
+
+// executeConstructor applies 'new' to the part of the chain before the first call,
+// the call arguments go to the 'new' visitor as its node and the rest of the chain is applied to the new object
+func executeConstructor(value *ExpressionValue, hasNoParent bool, tree *BuildNode, context *ExpressionContext) (*ExpressionValue, error) {
+	nodes := tree.Children
+	n := len(nodes)
+	k := 0
+	for k < n && nodes[k].Operator != "(" {
+		k++
+	}
+	var err error
+	if k > 0 {
+		value, _, err = ExecuteBracketExpression(value, hasNoParent, nodes[:k], context)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var args *BuildNode
+	if k < n {
+		args = nodes[k]
+		k++
+	}
+	visitor := context.Rules.UnaryPreVisitors[constructorOperator]
+	if visitor == nil {
+		return nil, ErrorMessageForNode("Operator new is not supported", tree, context)
+	}
+	name := ""
+	if tree.Value != nil {
+		name = tree.Value.Value
+	}
+	value, err = visitor(value, args, context, constructorOperator, name, nil)
+	if err != nil || k >= n {
+		return value, err
+	}
+	value, _, err = ExecuteBracketExpression(value, false, nodes[k:], context)
+	return value, err
+}
+
 func ExecuteBracketExpression(parent *ExpressionValue, hasNoParent bool, nodes []*BuildNode, context *ExpressionContext) (*ExpressionValue, *ExpressionValue, error) {
 	n := len(nodes)
 	var child *ExpressionValue = parent
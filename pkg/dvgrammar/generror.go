@@ -51,6 +51,50 @@ func errorMessageForContext(message string, context *ExpressionContext) error {
 	return errors.New(err)
 }
 
+// ThrownError is an exception raised by a script; it keeps the thrown value
+// so that try/catch can get it back unchanged
+type ThrownError struct {
+	Value   interface{}
+	Message string
+	Row     int
+	Column  int
+	Place   string
+}
+
+func (e *ThrownError) Error() string {
+	return "Uncaught " + e.Message + " in " + e.Place + " (" + strconv.Itoa(e.Row) + ":" + strconv.Itoa(e.Column) + ")"
+}
+
+func NewThrownError(value interface{}, message string, node *BuildNode, context *ExpressionContext) *ThrownError {
+	err := &ThrownError{Value: value, Message: message}
+	token := node.FirstToken()
+	if token != nil {
+		err.Row = token.Row
+		err.Column = token.Column
+		err.Place = token.Place
+	} else if context != nil && context.Reference != nil {
+		err.Row = context.Reference.Row
+		err.Column = context.Reference.Column
+		err.Place = context.Reference.Place
+	}
+	return err
+}
+
+func (b *BuildNode) FirstToken() *Token {
+	if b == nil {
+		return nil
+	}
+	if b.Value != nil && b.Value.DataType != TYPE_FUNCTION {
+		return b.Value
+	}
+	for _, child := range b.Children {
+		if token := child.FirstToken(); token != nil {
+			return token
+		}
+	}
+	return nil
+}
+
 func EnrichErrorStr(err error, info string) error {
 	if err == nil {
 		return err
@@ -7,9 +7,14 @@ package dvgrammar
 import (
 	"log"
 	"strings"
+	"sync"
 )
 
+var grammarTableMutex sync.Mutex
+
 func CheckCreateGrammarTable(rules *GrammarRuleDefinitions) {
+	grammarTableMutex.Lock()
+	defer grammarTableMutex.Unlock()
 	if rules.Grammar != nil {
 		return
 	}
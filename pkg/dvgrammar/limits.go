@@ -0,0 +1,167 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+package dvgrammar
+
+import (
+	gocontext "context"
+	"strconv"
+	"time"
+)
+
+// ExecutionLimits restricts the resources of one evaluation, zero means no limit;
+// Timeout is in milliseconds
+type ExecutionLimits struct {
+	MaxSteps     int `json:"max_steps"`
+	Timeout      int `json:"timeout"`
+	MaxVariables int `json:"max_variables"`
+	MaxDepth     int `json:"max_depth"`
+}
+
+// DefaultExecutionLimits are used by all evaluations unless they are overridden,
+// the depth is limited by default to keep the runaway recursion from exhausting the stack
+var DefaultExecutionLimits = &ExecutionLimits{MaxDepth: 1000}
+
+// LimitErrorThrower makes the catchable error raised when a limit is exceeded,
+// the evaluator replaces it to throw RangeError objects
+var LimitErrorThrower = func(message string, node *BuildNode, context *ExpressionContext) error {
+	return ErrorMessageForNode(message, node, context)
+}
+
+// limitCheckPeriod is the number of steps between the checks of the deadline and the cancellation
+const limitCheckPeriod = 64
+
+// ExecutionBudget counts the resources spent by one evaluation, it is shared by all contexts
+// forked from the evaluation context, including those of its asynchronous jobs
+type ExecutionBudget struct {
+	Limits    ExecutionLimits
+	Steps     int
+	Variables int
+	done      gocontext.Context
+	cancel    gocontext.CancelFunc
+	// Debug is called before each statement, when the evaluation is debugged
+	Debug DebugHook
+}
+
+// Merge returns the limits, in which the non-zero values of other override these ones
+func (limits *ExecutionLimits) Merge(other *ExecutionLimits) *ExecutionLimits {
+	res := &ExecutionLimits{}
+	if limits != nil {
+		*res = *limits
+	}
+	if other != nil {
+		if other.MaxSteps != 0 {
+			res.MaxSteps = other.MaxSteps
+		}
+		if other.Timeout != 0 {
+			res.Timeout = other.Timeout
+		}
+		if other.MaxVariables != 0 {
+			res.MaxVariables = other.MaxVariables
+		}
+		if other.MaxDepth != 0 {
+			res.MaxDepth = other.MaxDepth
+		}
+	}
+	return res
+}
+
+// NewExecutionBudget starts the budget of the evaluation; the parent context (usually the one of the http request)
+// stops the evaluation when it is cancelled; Release must be called when the evaluation is finished
+func NewExecutionBudget(limits *ExecutionLimits, parent gocontext.Context) *ExecutionBudget {
+	if limits == nil {
+		limits = DefaultExecutionLimits
+	}
+	b := &ExecutionBudget{}
+	if limits != nil {
+		b.Limits = *limits
+	}
+	if parent == nil {
+		parent = gocontext.Background()
+	}
+	if b.Limits.Timeout > 0 {
+		b.done, b.cancel = gocontext.WithTimeout(parent, time.Duration(b.Limits.Timeout)*time.Millisecond)
+	} else if parent.Done() != nil {
+		b.done = parent
+	}
+	return b
+}
+
+func (b *ExecutionBudget) Release() {
+	if b != nil && b.cancel != nil {
+		b.cancel()
+	}
+}
+
+// Done returns the channel closed when the deadline is reached or the evaluation is cancelled,
+// nil channel is returned, when there is nothing to wait for
+func (b *ExecutionBudget) Done() <-chan struct{} {
+	if b == nil || b.done == nil {
+		return nil
+	}
+	return b.done.Done()
+}
+
+// Check raises the error, when the deadline is reached or the evaluation is cancelled
+func (b *ExecutionBudget) Check(node *BuildNode, context *ExpressionContext) error {
+	if b == nil || b.done == nil {
+		return nil
+	}
+	switch b.done.Err() {
+	case nil:
+		return nil
+	case gocontext.DeadlineExceeded:
+		if b.Limits.Timeout > 0 {
+			return LimitErrorThrower("Script execution timed out after "+strconv.Itoa(b.Limits.Timeout)+" ms", node, context)
+		}
+		return LimitErrorThrower("Script execution deadline exceeded", node, context)
+	}
+	return LimitErrorThrower("Script execution is cancelled", node, context)
+}
+
+// Step is called for each executed node
+func (b *ExecutionBudget) Step(node *BuildNode, context *ExpressionContext) error {
+	if b == nil {
+		return nil
+	}
+	b.Steps++
+	if b.Limits.MaxSteps > 0 && b.Steps > b.Limits.MaxSteps {
+		return LimitErrorThrower("Script exceeded the limit of "+strconv.Itoa(b.Limits.MaxSteps)+" executed steps", node, context)
+	}
+	if b.Steps%limitCheckPeriod == 0 {
+		return b.Check(node, context)
+	}
+	return nil
+}
+
+// Allocate is called when the script creates variables, object properties or array elements
+func (b *ExecutionBudget) Allocate(count int, node *BuildNode, context *ExpressionContext) error {
+	if b == nil {
+		return nil
+	}
+	b.Variables += count
+	if b.Limits.MaxVariables > 0 && b.Variables > b.Limits.MaxVariables {
+		return LimitErrorThrower("Script exceeded the limit of "+strconv.Itoa(b.Limits.MaxVariables)+" allocated variables", node, context)
+	}
+	return nil
+}
+
+// EnterCall is called before a script function is executed, LeaveCall must follow it, if no error is returned
+func (context *ExpressionContext) EnterCall(node *BuildNode) error {
+	if context.Budget == nil {
+		return nil
+	}
+	context.Depth++
+	if context.Budget.Limits.MaxDepth > 0 && context.Depth > context.Budget.Limits.MaxDepth {
+		context.Depth--
+		return LimitErrorThrower("Maximum call stack size exceeded", node, context)
+	}
+	return nil
+}
+
+func (context *ExpressionContext) LeaveCall() {
+	if context.Budget != nil {
+		context.Depth--
+	}
+}
@@ -0,0 +1,301 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+package dvgrammar
+
+// ModuleDefaultBinding is the hidden variable of a module keeping the value of export default expression
+const ModuleDefaultBinding = "*default*"
+
+// isModuleStatement checks whether import or export statement starts at pos, import(...) is left for the expressions
+func isModuleStatement(tokens []Token, pos int, opt *GrammarBaseDefinition) bool {
+	value := &tokens[pos]
+	if value.DataType == TYPE_CONTROL || (value.Value != "import" && value.Value != "export") || opt.Language == nil || opt.Language[value.Value] == nil {
+		return false
+	}
+	if value.Value == "import" && pos+1 < len(tokens) && tokens[pos+1].DataType == TYPE_CONTROL && (tokens[pos+1].Value == "(" || tokens[pos+1].Value == ".") {
+		return false
+	}
+	return true
+}
+
+func newModuleBinding(name *Token, local *Token) *BuildNode {
+	return &BuildNode{Operator: "as", Children: []*BuildNode{{Value: name}, {Value: local}}}
+}
+
+func isNameToken(token *Token) bool {
+	return token.DataType == TYPE_DATA || token.DataType == TYPE_OPERATOR && token.Value != "" && isLetterByte(token.Value[0])
+}
+
+func isLetterByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$'
+}
+
+func isTokenOf(tokens []Token, pos int, value string) bool {
+	return pos < len(tokens) && tokens[pos].Value == value && tokens[pos].DataType != TYPE_STRING
+}
+
+func expectedModuleToken(tokens []Token, pos int, what string) error {
+	if pos < len(tokens) {
+		return errorMessage("Expected "+what+" but found", &tokens[pos])
+	}
+	return errorMessageAtEnd("Expected "+what, &tokens[len(tokens)-1])
+}
+
+// readModuleSpecifiers reads { name as local, ... } starting at the opening curly bracket
+func readModuleSpecifiers(tokens []Token, pos int) ([]*BuildNode, int, error) {
+	end, err := findClosingTag(tokens, pos)
+	if err != nil {
+		return nil, 0, err
+	}
+	var res []*BuildNode
+	for pos++; pos < end; pos++ {
+		if !isNameToken(&tokens[pos]) {
+			return nil, 0, expectedModuleToken(tokens, pos, "name")
+		}
+		name := &tokens[pos]
+		local := name
+		if isTokenOf(tokens, pos+1, "as") {
+			pos += 2
+			if pos >= end || !isNameToken(&tokens[pos]) {
+				return nil, 0, expectedModuleToken(tokens, pos, "name after as")
+			}
+			local = &tokens[pos]
+		}
+		res = append(res, newModuleBinding(name, local))
+		if pos+1 < end && !isTokenOf(tokens, pos+1, ",") {
+			return nil, 0, expectedModuleToken(tokens, pos+1, ",")
+		}
+		pos++
+	}
+	return res, end + 1, nil
+}
+
+// readModuleSource reads from 'module' and skips the semicolon after it
+func readModuleSource(tokens []Token, pos int) (*BuildNode, int, error) {
+	if !isTokenOf(tokens, pos, "from") {
+		return nil, 0, expectedModuleToken(tokens, pos, "from")
+	}
+	pos++
+	if pos >= len(tokens) || tokens[pos].DataType != TYPE_STRING {
+		return nil, 0, expectedModuleToken(tokens, pos, "module name")
+	}
+	return &BuildNode{Value: &tokens[pos]}, skipModuleStatementEnd(tokens, pos+1), nil
+}
+
+func skipModuleStatementEnd(tokens []Token, pos int) int {
+	if pos < len(tokens) && tokens[pos].DataType == TYPE_CONTROL && tokens[pos].Value == ";" {
+		pos++
+	}
+	return pos
+}
+
+func buildImportStatement(tokens []Token, pos int) ([]*BuildNode, int, error) {
+	node := &BuildNode{Operator: "import", Value: &tokens[pos]}
+	pos++
+	if pos < len(tokens) && tokens[pos].DataType == TYPE_STRING {
+		node.Children = []*BuildNode{{Value: &tokens[pos]}}
+		return []*BuildNode{node}, skipModuleStatementEnd(tokens, pos+1), nil
+	}
+	var bindings []*BuildNode
+	if pos < len(tokens) && tokens[pos].DataType == TYPE_DATA && tokens[pos].Value != "from" {
+		bindings = append(bindings, newModuleBinding(&Token{DataType: TYPE_DATA, Value: "default"}, &tokens[pos]))
+		pos++
+		if !isTokenOf(tokens, pos, ",") {
+			return buildImportFrom(node, bindings, tokens, pos)
+		}
+		pos++
+	}
+	switch {
+	case isTokenOf(tokens, pos, "*"):
+		if !isTokenOf(tokens, pos+1, "as") || pos+2 >= len(tokens) || !isNameToken(&tokens[pos+2]) {
+			return nil, 0, expectedModuleToken(tokens, pos+1, "as name")
+		}
+		bindings = append(bindings, newModuleBinding(&tokens[pos], &tokens[pos+2]))
+		pos += 3
+	case isTokenOf(tokens, pos, "{"):
+		specifiers, next, err := readModuleSpecifiers(tokens, pos)
+		if err != nil {
+			return nil, 0, err
+		}
+		bindings = append(bindings, specifiers...)
+		pos = next
+	default:
+		return nil, 0, expectedModuleToken(tokens, pos, "{ or *")
+	}
+	return buildImportFrom(node, bindings, tokens, pos)
+}
+
+func buildImportFrom(node *BuildNode, bindings []*BuildNode, tokens []Token, pos int) ([]*BuildNode, int, error) {
+	source, next, err := readModuleSource(tokens, pos)
+	if err != nil {
+		return nil, 0, err
+	}
+	node.Children = append([]*BuildNode{source}, bindings...)
+	return []*BuildNode{node}, next, nil
+}
+
+// findDeclarationEnd returns the position after the exported function, class or variable declaration
+func findDeclarationEnd(tokens []Token, pos int) (int, error) {
+	switch tokens[pos].Value {
+	case "function", "async function", "class":
+		start, err := findExtendsEnd(tokens, pos+1)
+		if err != nil {
+			return 0, err
+		}
+		end, err := findClosingTag(tokens, start)
+		if err != nil {
+			return 0, err
+		}
+		return end + 1, nil
+	}
+	return findEndingTag(tokens, pos)
+}
+
+func removeEmptyNodes(forest []*BuildNode) []*BuildNode {
+	res := forest[:0]
+	for _, tree := range forest {
+		if tree != nil && (tree.Operator != "" || tree.Value != nil || len(tree.Children) != 0) {
+			res = append(res, tree)
+		}
+	}
+	return res
+}
+
+// getDeclaredNames collects the names of the variables, functions and classes declared by the statement
+func getDeclaredNames(tree *BuildNode, opt *GrammarBaseDefinition, names []string) []string {
+	if tree == nil {
+		return names
+	}
+	if lang, ok := opt.Language[tree.Operator]; ok && lang.Declaration != 0 {
+		return GetPatternNames(GetDeclaredNameNode(tree), names)
+	}
+	switch tree.Operator {
+	case "function", "async function", "class":
+		if tree.Value != nil && tree.Value.DataType == TYPE_DATA {
+			names = append(names, tree.Value.Value)
+		}
+	case "=":
+		if len(tree.Children) == 2 {
+			names = GetPatternNames(tree.Children[0], names)
+		}
+	case "":
+		names = GetPatternNames(tree, names)
+	}
+	return names
+}
+
+func buildExportStatement(tokens []Token, pos int, opt *GrammarBaseDefinition) ([]*BuildNode, int, error) {
+	keyword := &tokens[pos]
+	node := &BuildNode{Operator: "export", Value: keyword, Children: []*BuildNode{{}}}
+	pos++
+	amount := len(tokens)
+	if pos >= amount {
+		return nil, 0, expectedModuleToken(tokens, pos, "declaration")
+	}
+	switch {
+	case isTokenOf(tokens, pos, "{"):
+		specifiers, next, err := readModuleSpecifiers(tokens, pos)
+		if err != nil {
+			return nil, 0, err
+		}
+		node.Children = append(node.Children, specifiers...)
+		if isTokenOf(tokens, next, "from") {
+			node.Children[0], next, err = readModuleSource(tokens, next)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		return []*BuildNode{node}, skipModuleStatementEnd(tokens, next), nil
+	case isTokenOf(tokens, pos, "*"):
+		local := &tokens[pos]
+		next := pos + 1
+		if isTokenOf(tokens, next, "as") {
+			if next+1 >= amount || !isNameToken(&tokens[next+1]) {
+				return nil, 0, expectedModuleToken(tokens, next+1, "name after as")
+			}
+			local = &tokens[next+1]
+			next += 2
+		}
+		source, next, err := readModuleSource(tokens, next)
+		if err != nil {
+			return nil, 0, err
+		}
+		node.Children = []*BuildNode{source, newModuleBinding(&tokens[pos], local)}
+		return []*BuildNode{node}, next, nil
+	case isTokenOf(tokens, pos, "default"):
+		pos++
+		if pos >= amount {
+			return nil, 0, expectedModuleToken(tokens, pos, "expression")
+		}
+		declaration := tokens[pos].Value
+		isDeclaration := (declaration == "function" || declaration == "async function" || declaration == "class") && tokens[pos].DataType == TYPE_OPERATOR
+		if isDeclaration && pos+1 < amount && tokens[pos+1].DataType == TYPE_DATA {
+			return buildExportDeclaration(node, tokens, pos, opt, "default")
+		}
+		var end int
+		var err error
+		if isDeclaration {
+			end, err = findDeclarationEnd(tokens, pos)
+		} else {
+			end, err = findEndingTag(tokens, pos-1)
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		forest, err := buildExpressionTreeAtLevel(tokens[pos:end], opt, blockKindCode)
+		if err != nil {
+			return nil, 0, err
+		}
+		forest = removeEmptyNodes(forest)
+		if len(forest) != 1 {
+			return nil, 0, errorMessage("Expected one expression after export default but found", &tokens[pos])
+		}
+		target := &BuildNode{Value: &Token{DataType: TYPE_DATA, Value: ModuleDefaultBinding, Row: keyword.Row, Column: keyword.Column, Place: keyword.Place}}
+		assignment := &BuildNode{Operator: "=", Children: []*BuildNode{target, forest[0]}}
+		node.Children = append(node.Children, newModuleBinding(target.Value, &Token{DataType: TYPE_DATA, Value: "default"}))
+		return []*BuildNode{node, assignment}, skipModuleStatementEnd(tokens, end), nil
+	}
+	return buildExportDeclaration(node, tokens, pos, opt, "")
+}
+
+// buildExportDeclaration builds the declaration itself and the export of the names declared by it
+func buildExportDeclaration(node *BuildNode, tokens []Token, pos int, opt *GrammarBaseDefinition, exportedAs string) ([]*BuildNode, int, error) {
+	end, err := findDeclarationEnd(tokens, pos)
+	if err != nil {
+		return nil, 0, err
+	}
+	forest, err := buildExpressionTreeAtLevel(tokens[pos:end], opt, blockKindCode)
+	if err != nil {
+		return nil, 0, err
+	}
+	forest = removeEmptyNodes(forest)
+	var names []string
+	for _, tree := range forest {
+		names = getDeclaredNames(tree, opt, names)
+	}
+	if len(names) == 0 {
+		return nil, 0, errorMessage("Expected declaration after export but found", &tokens[pos])
+	}
+	for _, name := range names {
+		local := &Token{DataType: TYPE_DATA, Value: name, Row: tokens[pos].Row, Column: tokens[pos].Column, Place: tokens[pos].Place}
+		exported := local
+		if exportedAs != "" {
+			exported = &Token{DataType: TYPE_DATA, Value: exportedAs}
+		}
+		node.Children = append(node.Children, newModuleBinding(local, exported))
+	}
+	return append(forest, node), skipModuleStatementEnd(tokens, end), nil
+}
+
+// buildModuleStatement parses import or export statement, which starts at pos; it returns the nodes
+// to be placed to the forest and the position after the statement.
+// The statement node keeps the keyword in Value, its first child keeps the module name (nil Value for export
+// without from), the other children are "as" nodes with the imported and the local names for import
+// or with the local and the exported names for export; "*" stands for the whole module namespace
+func buildModuleStatement(tokens []Token, pos int, opt *GrammarBaseDefinition) ([]*BuildNode, int, error) {
+	if tokens[pos].Value == "import" {
+		return buildImportStatement(tokens, pos)
+	}
+	return buildExportStatement(tokens, pos, opt)
+}
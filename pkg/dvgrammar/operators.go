@@ -184,6 +184,9 @@ var CommonGrammarBaseDefinition = &GrammarBaseDefinition{
 		">=": &InterOperator{
 			Precedence: 11,
 		},
+		"instanceof": &InterOperator{
+			Precedence: 11,
+		},
 		"<<": &InterOperator{
 			Precedence: 12,
 		},
@@ -220,8 +223,8 @@ var CommonGrammarBaseDefinition = &GrammarBaseDefinition{
 			Precedence: 16,
 		},
 		"=>": &InterOperator{
-			Precedence: 18,
-			Multi:      true,
+			Precedence:     2,
+			LeftPrecedence: 19,
 		},
 		"(": &InterOperator{
 			Precedence: 19,
@@ -270,33 +273,32 @@ var CommonGrammarBaseDefinition = &GrammarBaseDefinition{
 			Pre:  true,
 		},
 		"...": &UnaryOperator{
-			Post: true,
-			Pre:  false,
+			Post: false,
+			Pre:  true,
+		},
+		"new": &UnaryOperator{
+			Post: false,
+			Pre:  true,
+		},
+		"await": &UnaryOperator{
+			Post: false,
+			Pre:  true,
+		},
+		"async": &UnaryOperator{
+			Post: false,
+			Pre:  true,
 		},
 	},
 	VoidOperators: map[string]int{
 		"var":        1,
-		"const":      1,
-		"let":        1,
-		"try":        1,
-		"catch":      1,
 		"public":     1,
 		"private":    1,
 		"protected":  1,
-		"await":      1,
-		"class":      1,
 		"debugger":   1,
 		"enum":       1,
-		"export":     1,
-		"extends":    1,
-		"finally":    1,
 		"implements": 1,
-		"import":     1,
-		"instanceof": 1,
 		"interface":  1,
-		"new":        1,
 		"package":    1,
-		"super":      1,
 		"static":     1,
 		"void":       1,
 		"with":       1,
@@ -315,6 +317,7 @@ var CommonGrammarBaseDefinition = &GrammarBaseDefinition{
 			ParenthesesFollow:            true,
 			CurlyBracesFollowParentheses: true,
 			FeatureOptions:               FEATURE_ROUND_BRACKET | FEATURE_CURLY_BRACKETS_OR_CODE | FEATURE_FINISH,
+			AcceptsLabel:                 true,
 		},
 		"if": {
 			AlwaysFirst:                  true,
@@ -345,16 +348,20 @@ var CommonGrammarBaseDefinition = &GrammarBaseDefinition{
 			MustHaveArgument:             false,
 			ParenthesesFollow:            true,
 			CurlyBracesFollowParentheses: true,
+			FeatureOptions:               FEATURE_ROUND_BRACKET | FEATURE_CURLY_BRACKETS | FEATURE_FINISH,
+			AcceptsLabel:                 true,
 		},
 		"case": {
 			AlwaysFirst:      true,
 			CanHaveArgument:  true,
 			MustHaveArgument: true,
+			SwitchBodyOnly:   true,
 		},
 		"default": {
 			AlwaysFirst:      true,
-			CanHaveArgument:  false,
+			CanHaveArgument:  true,
 			MustHaveArgument: false,
+			SwitchBodyOnly:   true,
 		},
 		"function": {
 			AlwaysFirst:                  false,
@@ -362,6 +369,23 @@ var CommonGrammarBaseDefinition = &GrammarBaseDefinition{
 			MustHaveArgument:             false,
 			ParenthesesFollow:            true,
 			CurlyBracesFollowParentheses: true,
+			FeatureOptions:               FEATURE_NAME_OPTIONAL | FEATURE_ROUND_BRACKET | FEATURE_CURLY_BRACKETS | FEATURE_FINISH,
+			Hoisted:                      true,
+		},
+		"async function": {
+			AlwaysFirst:                  false,
+			CanHaveArgument:              true,
+			MustHaveArgument:             false,
+			ParenthesesFollow:            true,
+			CurlyBracesFollowParentheses: true,
+			FeatureOptions:               FEATURE_NAME_OPTIONAL | FEATURE_ROUND_BRACKET | FEATURE_CURLY_BRACKETS | FEATURE_FINISH,
+			Hoisted:                      true,
+		},
+		"class": {
+			AlwaysFirst:      false,
+			CanHaveArgument:  true,
+			MustHaveArgument: false,
+			FeatureOptions:   FEATURE_NAME_OPTIONAL | FEATURE_EXTENDS_OPTIONAL | FEATURE_CURLY_BRACKETS | FEATURE_FINISH,
 		},
 		"while": {
 			AlwaysFirst:                  true,
@@ -369,6 +393,8 @@ var CommonGrammarBaseDefinition = &GrammarBaseDefinition{
 			MustHaveArgument:             false,
 			ParenthesesFollow:            true,
 			CurlyBracesFollowParentheses: true,
+			FeatureOptions:               FEATURE_ROUND_BRACKET | FEATURE_CURLY_BRACKETS_OR_CODE | FEATURE_FINISH,
+			AcceptsLabel:                 true,
 		},
 		"typeof": {
 			AlwaysFirst:      true,
@@ -377,19 +403,51 @@ var CommonGrammarBaseDefinition = &GrammarBaseDefinition{
 		},
 		"do": {
 			AlwaysFirst:      true,
-			CanHaveArgument:  false,
+			CanHaveArgument:  true,
+			MustHaveArgument: false,
+			FeatureOptions:   FEATURE_CURLY_BRACKETS_OR_CODE | FEATURE_WHILE_FOLLOWS | FEATURE_FINISH,
+			AcceptsLabel:     true,
+		},
+		"try": {
+			AlwaysFirst:      true,
+			CanHaveArgument:  true,
 			MustHaveArgument: false,
+			FeatureOptions:   FEATURE_CURLY_BRACKETS | FEATURE_CATCH_OR_FINALLY | FEATURE_FINISH,
 		},
 		"throw": {
 			AlwaysFirst:      true,
 			CanHaveArgument:  true,
 			MustHaveArgument: true,
 		},
+		"let": {
+			AlwaysFirst:      true,
+			CanHaveArgument:  true,
+			MustHaveArgument: true,
+			Declaration:      DECLARATION_LET,
+		},
+		"const": {
+			AlwaysFirst:      true,
+			CanHaveArgument:  true,
+			MustHaveArgument: true,
+			Declaration:      DECLARATION_CONST,
+		},
 		"delete": {
 			AlwaysFirst:      true,
 			CanHaveArgument:  true,
 			MustHaveArgument: true,
 		},
+		"import": {
+			AlwaysFirst:      true,
+			CanHaveArgument:  true,
+			MustHaveArgument: true,
+			Hoisted:          true,
+		},
+		"export": {
+			AlwaysFirst:      true,
+			CanHaveArgument:  true,
+			MustHaveArgument: true,
+			Hoisted:          true,
+		},
 	},
 	DefaultOperator: "",
 }
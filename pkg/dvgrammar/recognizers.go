@@ -39,30 +39,12 @@ func RecognizerByteQuotation(grammar *GrammarTable, data []byte, pos int, n int)
 	i := pos
 	switch c {
 	case '`':
-		g := 0
-		for i < n && data[i] == '`' && g < 3 {
-			g++
-			i++
-		}
-		for i < n {
-			ge := data[i]
-			if ge == '`' {
-				gc := 0
-				for i < n && data[i] == '`' && gc < g {
-					gc++
-					i++
-				}
-				if gc == g {
-					i--
-					break
-				}
-			}
-		}
-		if i == n {
-			return nil, 0, errors.New("Unclosed string with `")
+		i, err = findTemplateEnd(data, pos+1, n)
+		if err != nil {
+			return nil, 0, err
 		}
+		t = &Token{DataType: TYPE_TEMPLATE, Value: string(data[pos+1 : i])}
 		i++
-		t = &Token{DataType: TYPE_STRING, Value: string(data[pos+g : i-g])}
 	case '"', '\'':
 		i++
 		for i < n && data[i] != c {
@@ -0,0 +1,157 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+package dvgrammar
+
+import (
+	"bytes"
+	"errors"
+)
+
+var errorUnclosedTemplate = errors.New("Unclosed string with `")
+
+func findTemplateEnd(data []byte, i int, n int) (int, error) {
+	for i < n {
+		switch data[i] {
+		case '\\':
+			i++
+		case '`':
+			return i, nil
+		case '$':
+			if i+1 < n && data[i+1] == '{' {
+				var err error
+				i, err = findTemplateExpressionEnd(data, i+2, n)
+				if err != nil {
+					return 0, err
+				}
+			}
+		}
+		i++
+	}
+	return 0, errorUnclosedTemplate
+}
+
+func findTemplateExpressionEnd(data []byte, i int, n int) (int, error) {
+	depth := 0
+	for i < n {
+		c := data[i]
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return i, nil
+			}
+			depth--
+		case '"', '\'':
+			for i++; i < n && data[i] != c; i++ {
+				if data[i] == '\\' {
+					i++
+				}
+			}
+		case '`':
+			var err error
+			i, err = findTemplateEnd(data, i+1, n)
+			if err != nil {
+				return 0, err
+			}
+		}
+		i++
+	}
+	return 0, errors.New("Unclosed ${ in template string")
+}
+
+// splitTemplate divides the template string into the string parts and the expressions between them,
+// so there is always one string part more than the expressions
+func splitTemplate(data []byte) (parts []string, expressions [][]byte, err error) {
+	n := len(data)
+	start := 0
+	for i := 0; i < n; i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '$':
+			if i+1 < n && data[i+1] == '{' {
+				end, err := findTemplateExpressionEnd(data, i+2, n)
+				if err != nil {
+					return nil, nil, err
+				}
+				if len(bytes.TrimSpace(data[i+2:end])) == 0 {
+					return nil, nil, errors.New("Expression expected in ${} of template string")
+				}
+				parts = append(parts, cookTemplatePart(data[start:i]))
+				expressions = append(expressions, data[i+2:end])
+				i = end
+				start = end + 1
+			}
+		}
+	}
+	parts = append(parts, cookTemplatePart(data[start:]))
+	return
+}
+
+func cookTemplatePart(data []byte) string {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	return GetEscapedString(data)
+}
+
+// isTemplateTag checks whether the template follows a function expression (tag`...`)
+func isTemplateTag(tokens []Token) bool {
+	n := len(tokens)
+	if n == 0 {
+		return false
+	}
+	t := tokens[n-1]
+	return t.DataType == TYPE_DATA || t.DataType == TYPE_CONTROL && (t.Value == ")" || t.Value == "]")
+}
+
+// appendTemplateTokens replaces the template string with the tokens of the equivalent expression:
+// `a${x}b` becomes ("a"+(x)+"b") and tag`a${x}b` becomes tag(["a","b"],(x))
+func appendTemplateTokens(tokens []Token, t *Token, grammar *GrammarTable) ([]Token, error) {
+	parts, expressions, err := splitTemplate([]byte(t.Value))
+	if err != nil {
+		return nil, err
+	}
+	tagged := isTemplateTag(tokens)
+	if len(expressions) == 0 && !tagged {
+		return append(tokens, Token{DataType: TYPE_STRING, Value: parts[0], Row: t.Row, Column: t.Column, Place: t.Place}), nil
+	}
+	add := func(dataType int, value string) {
+		tokens = append(tokens, Token{DataType: dataType, Value: value, Row: t.Row, Column: t.Column, Place: t.Place})
+	}
+	separator := "+"
+	separatorType := TYPE_OPERATOR
+	add(TYPE_CONTROL, "(")
+	if tagged {
+		separator = ","
+		separatorType = TYPE_CONTROL
+		add(TYPE_CONTROL, "[")
+		for i, part := range parts {
+			if i > 0 {
+				add(TYPE_CONTROL, ",")
+			}
+			add(TYPE_STRING, part)
+		}
+		add(TYPE_CONTROL, "]")
+	} else {
+		add(TYPE_STRING, parts[0])
+	}
+	for i, expression := range expressions {
+		src := &SourceReference{Row: t.Row, Column: t.Column, Place: t.Place}
+		subTokens, err := Tokenize(src, expression, grammar)
+		if err != nil {
+			return nil, err
+		}
+		add(separatorType, separator)
+		add(TYPE_CONTROL, "(")
+		tokens = append(tokens, subTokens...)
+		add(TYPE_CONTROL, ")")
+		if !tagged {
+			add(separatorType, separator)
+			add(TYPE_STRING, parts[i+1])
+		}
+	}
+	add(TYPE_CONTROL, ")")
+	return tokens, nil
+}
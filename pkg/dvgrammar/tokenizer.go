@@ -56,7 +56,15 @@ func Tokenize(src *SourceReference, data []byte, grammar *GrammarTable) ([]Token
 			t.Row = src.Row
 			t.Column = src.Column
 			t.Place = src.Place
-			tokens = append(tokens, *t)
+			if t.DataType == TYPE_TEMPLATE {
+				var err error
+				tokens, err = appendTemplateTokens(tokens, t, grammar)
+				if err != nil {
+					return nil, err
+				}
+			} else {
+				tokens = append(tokens, *t)
+			}
 		} else {
 			return nil, errors.New("Unknown character " + string(c) + "(" + strconv.Itoa(int(c)) + ")" + src.Place + " (" + strconv.Itoa(src.Row) + ":" + strconv.Itoa(src.Column) + ")")
 		}
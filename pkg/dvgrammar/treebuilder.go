@@ -8,6 +8,19 @@ import "errors"
 
 const dataOperator string = "DATA"
 
+// kinds of blocks, which are parsed with their own rules
+const (
+	blockKindCode = iota
+	blockKindSwitch
+	blockKindClass
+)
+
+var classMemberModifiers = map[string]bool{
+	"static": true,
+	"get":    true,
+	"set":    true,
+}
+
 func invertStrings(data []string) []string {
 	n := len(data)
 	if n == 0 {
@@ -25,8 +38,17 @@ func newNode(parent *BuildNode, opt *GrammarBaseDefinition, preAttributes []stri
 	return &BuildNode{Parent: parent, PreAttributes: attr}
 }
 
+// retainedOperators keep their subtrees after the evaluation,
+// because functions defined by them can be called later
+var retainedOperators = map[string]bool{
+	"function":       true,
+	"async function": true,
+	"class":          true,
+	"=>":             true,
+}
+
 func fullTreeClean(tree *BuildNode) {
-	if tree != nil {
+	if tree != nil && !retainedOperators[tree.Operator] {
 		tree.Parent = nil
 		tree.Value = nil
 		l := len(tree.Children)
@@ -176,6 +198,41 @@ func envelopWithCurlyBrackets(tokens []Token, pos int) ([]Token, error) {
 }
 
 func buildExpressionTree(tokens []Token, opt *GrammarBaseDefinition) (forest []*BuildNode, err error) {
+	return buildExpressionTreeAtLevel(tokens, opt, blockKindCode)
+}
+
+func isLabelDefinition(tokens []Token, pos int, opt *GrammarBaseDefinition) bool {
+	if pos+2 >= len(tokens) || opt.Language == nil || tokens[pos].DataType != TYPE_DATA {
+		return false
+	}
+	if tokens[pos+1].DataType != TYPE_OPERATOR || tokens[pos+1].Value != ":" || tokens[pos+2].DataType != TYPE_OPERATOR {
+		return false
+	}
+	lang, ok := opt.Language[tokens[pos+2].Value]
+	return ok && lang.AcceptsLabel
+}
+
+func findExtendsEnd(tokens []Token, pos int) (int, error) {
+	amount := len(tokens)
+	for ; pos < amount; pos++ {
+		if tokens[pos].DataType != TYPE_CONTROL {
+			continue
+		}
+		switch tokens[pos].Value {
+		case "{":
+			return pos, nil
+		case "(", "[":
+			end, err := findClosingTag(tokens, pos)
+			if err != nil {
+				return 0, err
+			}
+			pos = end
+		}
+	}
+	return 0, errors.New("Expected { after extends")
+}
+
+func buildExpressionTreeAtLevel(tokens []Token, opt *GrammarBaseDefinition, blockKind int) (forest []*BuildNode, err error) {
 	forest = make([]*BuildNode, 0, 16)
 	currentPreAttributes := make([]string, 0, 16)
 	tree := newNode(nil, opt, nil)
@@ -183,12 +240,90 @@ func buildExpressionTree(tokens []Token, opt *GrammarBaseDefinition) (forest []*
 	amount := len(tokens)
 	group := 0
 	features := 0
+	label := ""
+	caseColon := false
+	caseTernary := 0
 tokenRunner:
 	for i := 0; i < amount; i++ {
 		value := &tokens[i]
 		operator := value.Value
+		if caseColon && value.DataType == TYPE_OPERATOR {
+			if operator == "?" {
+				caseTernary++
+			} else if operator == ":" {
+				if caseTernary > 0 {
+					caseTernary--
+				} else {
+					caseColon = false
+					operator = ";"
+					value = &Token{
+						Row:      value.Row,
+						Column:   value.Column,
+						Place:    value.Place,
+						DataType: TYPE_CONTROL,
+						Value:    operator,
+					}
+				}
+			}
+		}
+		if blockKind == blockKindClass && value.DataType == TYPE_DATA && classMemberModifiers[operator] && current == tree &&
+			current.Value == nil && current.Operator == "" && i+1 < amount && tokens[i+1].DataType == TYPE_DATA {
+			currentPreAttributes = append(currentPreAttributes, operator)
+			continue tokenRunner
+		}
+		if features == 0 && current == tree && current.Value == nil && current.Operator == "" && len(current.Children) == 0 && len(currentPreAttributes) == 0 && isLabelDefinition(tokens, i, opt) {
+			label = operator
+			i++
+			continue tokenRunner
+		}
+		if features == 0 && blockKind == blockKindCode && current == tree && current.Value == nil && current.Operator == "" && len(current.Children) == 0 && len(currentPreAttributes) == 0 && isModuleStatement(tokens, i, opt) {
+			nodes, next, err := buildModuleStatement(tokens, i, opt)
+			if err != nil {
+				fullTreeForestClean(forest, tree)
+				return nil, err
+			}
+			for _, node := range nodes {
+				node.Group = group
+				forest = append(forest, node)
+			}
+			group++
+			label = ""
+			i = next - 1
+			continue tokenRunner
+		}
 		if features != 0 {
-			if (features & FEATURE_ROUND_BRACKET) != 0 {
+			if (features & FEATURE_NAME_OPTIONAL) != 0 {
+				features ^= FEATURE_NAME_OPTIONAL
+				if value.DataType == TYPE_DATA && operator != "extends" && current.Parent != nil {
+					current.Parent.Value = value
+				} else {
+					i--
+				}
+				continue tokenRunner
+			} else if (features & FEATURE_EXTENDS_OPTIONAL) != 0 {
+				features ^= FEATURE_EXTENDS_OPTIONAL
+				if operator == "extends" && value.DataType == TYPE_DATA {
+					pos, err := findExtendsEnd(tokens, i+1)
+					if err != nil {
+						fullTreeForestClean(forest, tree)
+						return nil, err
+					}
+					if pos == i+1 {
+						fullTreeForestClean(forest, tree)
+						return nil, errorMessage("Expected class name after extends", value)
+					}
+					subForest, err := buildExpressionTreeAtLevel(tokens[i+1:pos], opt, blockKindCode)
+					if err != nil {
+						fullTreeForestClean(forest, tree)
+						return nil, err
+					}
+					current.Children = append(current.Children, &BuildNode{Parent: current, Operator: operator, Children: subForest})
+					i = pos - 1
+				} else {
+					i--
+				}
+				continue tokenRunner
+			} else if (features & FEATURE_ROUND_BRACKET) != 0 {
 				features ^= FEATURE_ROUND_BRACKET
 				if operator != "(" {
 					fullTreeForestClean(forest, tree)
@@ -220,6 +355,39 @@ tokenRunner:
 					value = &tokens[i]
 					operator = value.Value
 				}
+			} else if (features & FEATURE_WHILE_FOLLOWS) != 0 {
+				features ^= FEATURE_WHILE_FOLLOWS
+				if operator != "while" || value.DataType != TYPE_OPERATOR {
+					fullTreeForestClean(forest, tree)
+					return nil, errorMessage("Expected while but found "+operator, value)
+				}
+				features |= FEATURE_ROUND_BRACKET
+				continue tokenRunner
+			} else if (features & FEATURE_CATCH_OR_FINALLY) != 0 {
+				features ^= FEATURE_CATCH_OR_FINALLY
+				if operator == "catch" && value.DataType != TYPE_CONTROL {
+					current.Children = append(current.Children, &BuildNode{Parent: current, Operator: operator})
+					if i+1 < amount && tokens[i+1].Value == "(" && tokens[i+1].DataType == TYPE_CONTROL {
+						features |= FEATURE_ROUND_BRACKET
+					}
+					features |= FEATURE_CURLY_BRACKETS | FEATURE_FINALLY_OPTIONAL
+				} else if operator == "finally" && value.DataType != TYPE_CONTROL {
+					current.Children = append(current.Children, &BuildNode{Parent: current, Operator: operator})
+					features |= FEATURE_CURLY_BRACKETS
+				} else {
+					fullTreeForestClean(forest, tree)
+					return nil, errorMessage("Expected catch or finally but found "+operator, value)
+				}
+				continue tokenRunner
+			} else if (features & FEATURE_FINALLY_OPTIONAL) != 0 {
+				features ^= FEATURE_FINALLY_OPTIONAL
+				if operator == "finally" && value.DataType != TYPE_CONTROL {
+					current.Children = append(current.Children, &BuildNode{Parent: current, Operator: operator})
+					features |= FEATURE_CURLY_BRACKETS
+				} else {
+					i--
+				}
+				continue tokenRunner
 			} else if (features & FEATURE_FINISH_OR_ELSE) != 0 {
 				features ^= FEATURE_FINISH_OR_ELSE
 				if operator == "else" && i+1 < amount {
@@ -276,6 +444,12 @@ tokenRunner:
 		if value.DataType == TYPE_CONTROL {
 			switch operator {
 			case ";", ",":
+				declaration := ""
+				if operator == "," && opt.Language != nil {
+					if lang, ok := opt.Language[tree.Operator]; ok && lang.Declaration != 0 {
+						declaration = tree.Operator
+					}
+				}
 				forest, err = placeTreeToForest(forest, current, tree, tokens, opt, currentPreAttributes, group)
 				if err != nil {
 					return
@@ -283,8 +457,15 @@ tokenRunner:
 				if operator == ";" {
 					group++
 				}
+				label = ""
 				tree = newNode(nil, opt, nil)
 				current = tree
+				if declaration != "" {
+					node := newNode(current, opt, nil)
+					current.Operator = declaration
+					current.Children = append(current.Children, node)
+					current = node
+				}
 				continue tokenRunner
 			case ".":
 				holdDot := current
@@ -321,7 +502,15 @@ tokenRunner:
 				}
 				var subForest []*BuildNode
 				if i+1 < pos {
-					subForest, err = buildExpressionTree(tokens[i+1:pos], opt)
+					subKind := blockKindCode
+					if operator == "{" && current.Parent != nil {
+						if current.Parent.Operator == "switch" && len(current.Children) == 1 {
+							subKind = blockKindSwitch
+						} else if current.Parent.Operator == "class" {
+							subKind = blockKindClass
+						}
+					}
+					subForest, err = buildExpressionTreeAtLevel(tokens[i+1:pos], opt, subKind)
 					if err != nil {
 						fullTreeForestClean(forest, tree)
 						return nil, err
@@ -334,6 +523,9 @@ tokenRunner:
 					PreAttributes: invertStrings(currentPreAttributes),
 				}
 				currentPreAttributes = currentPreAttributes[:0]
+				if blockKind == blockKindClass && operator == "{" && current == tree {
+					features |= FEATURE_FINISH
+				}
 				holderNode := current
 				for holderNode.Operator != "" {
 					m := len(holderNode.Children)
@@ -345,6 +537,10 @@ tokenRunner:
 				holderNode.Children = append(holderNode.Children, node)
 				if holderNode.Value == nil {
 					holderNode.Value = &Token{DataType: TYPE_FUNCTION}
+					if len(holderNode.PreAttributes) == 0 {
+						holderNode.PreAttributes = node.PreAttributes
+						node.PreAttributes = nil
+					}
 				}
 				if current.Operator == "" && current.Parent != nil && (current.Parent.Operator == "" || opt.Operators[current.Parent.Operator] != nil) {
 					current = current.Parent
@@ -354,18 +550,41 @@ tokenRunner:
 		} else if value.DataType == TYPE_OPERATOR {
 			if opt.Language != nil {
 				lang, isLang := opt.Language[operator]
+				if isLang && lang.SwitchBodyOnly && blockKind != blockKindSwitch {
+					value = &Token{
+						Row:      value.Row,
+						Column:   value.Column,
+						Place:    value.Place,
+						DataType: TYPE_DATA,
+						Value:    operator,
+					}
+					operator = dataOperator
+					isLang = false
+				}
 				if isLang {
-					if lang.AlwaysFirst && (current != tree || len(currentPreAttributes) != 0 || current.Value != nil || current.Operator != "") {
+					atStatementStart := current == tree && len(currentPreAttributes) == 0 && current.Value == nil && current.Operator == ""
+					if lang.AlwaysFirst && !atStatementStart {
 						return nil, errors.New("Language operator " + operator + " must come at first place")
 					} else if !lang.AlwaysFirst && len(currentPreAttributes) != 0 {
 						return nil, errors.New("Language operator " + operator + " cannot be preceded by usual operators")
 					}
 					node := newNode(current, opt, nil)
 					current.Operator = operator
+					if lang.AcceptsLabel {
+						current.Label = label
+					}
+					label = ""
 					current.Children = append(current.Children, node)
 					current = node
 					if lang.FeatureOptions != 0 {
 						features |= lang.FeatureOptions
+						if !atStatementStart {
+							features &^= FEATURE_FINISH
+						}
+					}
+					if lang.SwitchBodyOnly {
+						caseColon = true
+						caseTernary = 0
 					}
 					continue tokenRunner
 				}
@@ -453,6 +672,9 @@ tokenRunner:
 						current = node
 					} else {
 						precedence := properties.Precedence
+						if properties.LeftPrecedence != 0 {
+							precedence = properties.LeftPrecedence
+						}
 						for current.Parent != nil && opt.Operators[current.Parent.Operator] != nil &&
 							(current.closed || opt.Operators[current.Operator].Precedence > precedence) {
 							current = current.Parent
@@ -583,6 +805,7 @@ func (b *BuildNode) CloneFrom(other *BuildNode) {
 			b.PostAttributes = nil
 			b.Parent = nil
 			b.Group = 0
+			b.Label = ""
 			b.closed = false
 		} else {
 			b.Value = other.Value
@@ -592,6 +815,7 @@ func (b *BuildNode) CloneFrom(other *BuildNode) {
 			b.PostAttributes = other.PostAttributes
 			b.Parent = other.Parent
 			b.Group = other.Group
+			b.Label = other.Label
 			b.closed = other.closed
 		}
 	}
@@ -294,6 +294,9 @@ func Array_push(context *dvgrammar.ExpressionContext, thisVariable interface{},
 		return nil, errors.New("Cannot convert null to object")
 	}
 	n := len(params)
+	if err := context.Budget.Allocate(n, nil, context); err != nil {
+		return nil, err
+	}
 	for i := 0; i < n; i++ {
 		d := dvevaluation.AnyToDvVariable(params[i])
 		v.Fields = append(v.Fields, d)
@@ -1019,6 +1022,13 @@ func Array_from(context *dvgrammar.ExpressionContext, thisVariable interface{},
 		return nil, errors.New("Array.from requires parameters")
 	}
 	self := params[0]
+	if dv := dvevaluation.AnyToDvVariable(self); dvevaluation.IsIterableObject(dv) {
+		items, err := dvevaluation.GetIterableElements(context, dv)
+		if err != nil {
+			return nil, err
+		}
+		self = &dvevaluation.DvVariable{Kind: dvevaluation.FIELD_ARRAY, Fields: items}
+	}
 	params = params[1:]
 	v, err := Array_map(context, self, params)
 	return v, err
@@ -0,0 +1,329 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvjsmaster
+
+import (
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
+)
+
+var MapMaster *dvevaluation.DvVariable
+var SetMaster *dvevaluation.DvVariable
+var WeakMapMaster *dvevaluation.DvVariable
+
+var collectionNames = map[int]string{
+	dvevaluation.COLLECTION_MAP:      "Map",
+	dvevaluation.COLLECTION_SET:      "Set",
+	dvevaluation.COLLECTION_WEAK_MAP: "WeakMap",
+}
+
+func collectionMethod(name string, fn dvevaluation.DvFunc) *dvevaluation.DvVariable {
+	return &dvevaluation.DvVariable{
+		Name: []byte(name),
+		Kind: dvevaluation.FIELD_FUNCTION,
+		Extra: &dvevaluation.DvFunction{
+			Fn: fn,
+		},
+	}
+}
+
+func collections_init() {
+	MapMaster = dvevaluation.RegisterMasterVariable("Map", &dvevaluation.DvVariable{
+		Fields: make([]*dvevaluation.DvVariable, 0, 7),
+		Kind:   dvevaluation.FIELD_FUNCTION,
+		Extra: &dvevaluation.DvFunction{
+			Fn: Map_constructor,
+		},
+		Prototype: &dvevaluation.DvVariable{
+			Fields: []*dvevaluation.DvVariable{
+				collectionMethod("clear", Collection_clear),
+				collectionMethod("delete", Collection_delete),
+				collectionMethod("entries", Map_entries),
+				collectionMethod("forEach", Collection_forEach),
+				collectionMethod("get", Map_get),
+				collectionMethod("has", Collection_has),
+				collectionMethod("keys", Collection_keys),
+				collectionMethod("set", Map_set),
+				{
+					Name: []byte("size"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn:        Collection_size,
+						Immediate: true,
+					},
+				},
+				collectionMethod("values", Map_values),
+				collectionMethod(dvevaluation.IteratorMethodKey, Map_entries),
+			},
+			Kind: dvevaluation.FIELD_OBJECT,
+		},
+	})
+	SetMaster = dvevaluation.RegisterMasterVariable("Set", &dvevaluation.DvVariable{
+		Fields: make([]*dvevaluation.DvVariable, 0, 7),
+		Kind:   dvevaluation.FIELD_FUNCTION,
+		Extra: &dvevaluation.DvFunction{
+			Fn: Set_constructor,
+		},
+		Prototype: &dvevaluation.DvVariable{
+			Fields: []*dvevaluation.DvVariable{
+				collectionMethod("add", Set_add),
+				collectionMethod("clear", Collection_clear),
+				collectionMethod("delete", Collection_delete),
+				collectionMethod("entries", Set_entries),
+				collectionMethod("forEach", Collection_forEach),
+				collectionMethod("has", Collection_has),
+				collectionMethod("keys", Collection_keys),
+				{
+					Name: []byte("size"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn:        Collection_size,
+						Immediate: true,
+					},
+				},
+				collectionMethod("values", Collection_keys),
+				collectionMethod(dvevaluation.IteratorMethodKey, Collection_keys),
+			},
+			Kind: dvevaluation.FIELD_OBJECT,
+		},
+	})
+	WeakMapMaster = dvevaluation.RegisterMasterVariable("WeakMap", &dvevaluation.DvVariable{
+		Fields: make([]*dvevaluation.DvVariable, 0, 7),
+		Kind:   dvevaluation.FIELD_FUNCTION,
+		Extra: &dvevaluation.DvFunction{
+			Fn: WeakMap_constructor,
+		},
+		Prototype: &dvevaluation.DvVariable{
+			Fields: []*dvevaluation.DvVariable{
+				collectionMethod("delete", Collection_delete),
+				collectionMethod("get", Map_get),
+				collectionMethod("has", Collection_has),
+				collectionMethod("set", Map_set),
+			},
+			Kind: dvevaluation.FIELD_OBJECT,
+		},
+	})
+}
+
+func newCollectionObject(kind int, master *dvevaluation.DvVariable) (*dvevaluation.DvVariable, *dvevaluation.DvCollection) {
+	c := dvevaluation.NewDvCollection(kind)
+	return &dvevaluation.DvVariable{Kind: dvevaluation.FIELD_OBJECT, Extra: c, Prototype: master}, c
+}
+
+func getCollectionOfKind(thisVariable interface{}, kinds ...int) (*dvevaluation.DvCollection, error) {
+	c := dvevaluation.GetDvCollection(dvevaluation.AnyToDvVariable(thisVariable))
+	if c != nil {
+		for _, kind := range kinds {
+			if c.Kind == kind {
+				return c, nil
+			}
+		}
+	}
+	return nil, dvevaluation.NewScriptError(dvevaluation.ERROR_KIND_TYPE, "Method "+collectionNames[kinds[0]]+".prototype function called on incompatible receiver "+dvevaluation.AnyToString(thisVariable))
+}
+
+func getCollection(thisVariable interface{}) (*dvevaluation.DvCollection, error) {
+	return getCollectionOfKind(thisVariable, dvevaluation.COLLECTION_MAP, dvevaluation.COLLECTION_SET, dvevaluation.COLLECTION_WEAK_MAP)
+}
+
+func collectionParam(params []interface{}, i int) *dvevaluation.DvVariable {
+	if i >= len(params) {
+		return nil
+	}
+	return dvevaluation.AnyToDvVariable(params[i])
+}
+
+func isWeakKey(key *dvevaluation.DvVariable) bool {
+	return key != nil && (key.Kind == dvevaluation.FIELD_OBJECT || key.Kind == dvevaluation.FIELD_ARRAY || key.Kind == dvevaluation.FIELD_FUNCTION)
+}
+
+func fillMapFromIterable(context *dvgrammar.ExpressionContext, c *dvevaluation.DvCollection, params []interface{}) error {
+	if len(params) == 0 || params[0] == nil || dvevaluation.IsNullOrUndefined(params[0]) {
+		return nil
+	}
+	items, err := dvevaluation.GetIterableElements(context, params[0])
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if item == nil || item.Kind != dvevaluation.FIELD_ARRAY && item.Kind != dvevaluation.FIELD_OBJECT {
+			return dvevaluation.NewScriptError(dvevaluation.ERROR_KIND_TYPE, "Iterator value "+dvevaluation.AnyToString(item)+" is not an entry object")
+		}
+		key := item.ReadSimpleChild("0")
+		if c.Kind == dvevaluation.COLLECTION_WEAK_MAP && !isWeakKey(key) {
+			return dvevaluation.NewScriptError(dvevaluation.ERROR_KIND_TYPE, "Invalid value used as weak map key")
+		}
+		c.Set(key, item.ReadSimpleChild("1"))
+	}
+	return nil
+}
+
+func Map_constructor(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	v, c := newCollectionObject(dvevaluation.COLLECTION_MAP, MapMaster)
+	return v, fillMapFromIterable(context, c, params)
+}
+
+func WeakMap_constructor(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	v, c := newCollectionObject(dvevaluation.COLLECTION_WEAK_MAP, WeakMapMaster)
+	return v, fillMapFromIterable(context, c, params)
+}
+
+func Set_constructor(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	v, c := newCollectionObject(dvevaluation.COLLECTION_SET, SetMaster)
+	if len(params) == 0 || params[0] == nil || dvevaluation.IsNullOrUndefined(params[0]) {
+		return v, nil
+	}
+	items, err := dvevaluation.GetIterableElements(context, params[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		c.Set(item, nil)
+	}
+	return v, nil
+}
+
+func Map_get(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	c, err := getCollectionOfKind(thisVariable, dvevaluation.COLLECTION_MAP, dvevaluation.COLLECTION_WEAK_MAP)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := c.Get(collectionParam(params, 0))
+	if !ok {
+		return nil, nil
+	}
+	return v, nil
+}
+
+func Map_set(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	c, err := getCollectionOfKind(thisVariable, dvevaluation.COLLECTION_MAP, dvevaluation.COLLECTION_WEAK_MAP)
+	if err != nil {
+		return nil, err
+	}
+	key := collectionParam(params, 0)
+	if c.Kind == dvevaluation.COLLECTION_WEAK_MAP && !isWeakKey(key) {
+		return nil, dvevaluation.NewScriptError(dvevaluation.ERROR_KIND_TYPE, "Invalid value used as weak map key")
+	}
+	c.Set(key, collectionParam(params, 1))
+	return thisVariable, nil
+}
+
+func Set_add(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	c, err := getCollectionOfKind(thisVariable, dvevaluation.COLLECTION_SET)
+	if err != nil {
+		return nil, err
+	}
+	c.Set(collectionParam(params, 0), nil)
+	return thisVariable, nil
+}
+
+func Collection_has(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	c, err := getCollection(thisVariable)
+	if err != nil {
+		return nil, err
+	}
+	return c.Has(collectionParam(params, 0)), nil
+}
+
+func Collection_delete(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	c, err := getCollection(thisVariable)
+	if err != nil {
+		return nil, err
+	}
+	return c.Delete(collectionParam(params, 0)), nil
+}
+
+func Collection_clear(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	c, err := getCollectionOfKind(thisVariable, dvevaluation.COLLECTION_MAP, dvevaluation.COLLECTION_SET)
+	if err != nil {
+		return nil, err
+	}
+	c.Clear()
+	return nil, nil
+}
+
+func Collection_size(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	c, err := getCollectionOfKind(thisVariable, dvevaluation.COLLECTION_MAP, dvevaluation.COLLECTION_SET)
+	if err != nil {
+		return nil, err
+	}
+	return c.Size(), nil
+}
+
+func Collection_forEach(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	c, err := getCollectionOfKind(thisVariable, dvevaluation.COLLECTION_MAP, dvevaluation.COLLECTION_SET)
+	if err != nil {
+		return nil, err
+	}
+	if len(params) == 0 {
+		return nil, dvevaluation.NewScriptError(dvevaluation.ERROR_KIND_TYPE, "undefined is not a function")
+	}
+	var thisArg interface{}
+	if len(params) > 1 {
+		thisArg = params[1]
+	}
+	keys := append([]*dvevaluation.DvVariable(nil), c.Keys...)
+	for _, key := range keys {
+		value, ok := c.Get(key)
+		if !ok {
+			continue
+		}
+		if c.Kind == dvevaluation.COLLECTION_SET {
+			value = key
+		}
+		_, err = dvevaluation.ExecuteAnyFunction(context, params[0], thisArg, []interface{}{value, key, thisVariable})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func Collection_keys(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	c, err := getCollectionOfKind(thisVariable, dvevaluation.COLLECTION_MAP, dvevaluation.COLLECTION_SET)
+	if err != nil {
+		return nil, err
+	}
+	fields := append([]*dvevaluation.DvVariable(nil), c.Keys...)
+	return createArrayIterator(&dvevaluation.DvVariable{Kind: dvevaluation.FIELD_ARRAY, Fields: fields}), nil
+}
+
+func Map_values(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	c, err := getCollectionOfKind(thisVariable, dvevaluation.COLLECTION_MAP)
+	if err != nil {
+		return nil, err
+	}
+	fields := append([]*dvevaluation.DvVariable(nil), c.Values...)
+	return createArrayIterator(&dvevaluation.DvVariable{Kind: dvevaluation.FIELD_ARRAY, Fields: fields}), nil
+}
+
+func createEntriesIterator(keys []*dvevaluation.DvVariable, values []*dvevaluation.DvVariable) *dvevaluation.DvVariable {
+	n := len(keys)
+	res := &dvevaluation.DvVariable{Kind: dvevaluation.FIELD_ARRAY, Fields: make([]*dvevaluation.DvVariable, n)}
+	for i := 0; i < n; i++ {
+		res.Fields[i] = &dvevaluation.DvVariable{
+			Kind:   dvevaluation.FIELD_ARRAY,
+			Fields: []*dvevaluation.DvVariable{keys[i], values[i]},
+		}
+	}
+	return createArrayIterator(res)
+}
+
+func Map_entries(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	c, err := getCollectionOfKind(thisVariable, dvevaluation.COLLECTION_MAP)
+	if err != nil {
+		return nil, err
+	}
+	return createEntriesIterator(c.Keys, c.Values), nil
+}
+
+func Set_entries(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	c, err := getCollectionOfKind(thisVariable, dvevaluation.COLLECTION_SET)
+	if err != nil {
+		return nil, err
+	}
+	return createEntriesIterator(c.Keys, c.Keys), nil
+}
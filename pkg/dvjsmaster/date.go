@@ -1,10 +1,1017 @@
 /***********************************************************************
 MicroCore
-Copyright 2020 - 2020 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
 ************************************************************************/
 
 package dvjsmaster
 
+import (
+	"errors"
+	"fmt"
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DATE_MAX_TIME       = 8.64e15
+	DATE_INVALID_STRING = "Invalid Date"
+)
+
+const (
+	DATE_FIELD_YEAR = iota
+	DATE_FIELD_MONTH
+	DATE_FIELD_DATE
+	DATE_FIELD_HOURS
+	DATE_FIELD_MINUTES
+	DATE_FIELD_SECONDS
+	DATE_FIELD_MILLISECONDS
+	DATE_FIELD_AMOUNT
+)
+
+type DateValue struct {
+	Time float64
+}
+
+var DateMaster *dvevaluation.DvVariable
+
 func date_init() {
+	DateMaster = dvevaluation.RegisterMasterVariable("Date", &dvevaluation.DvVariable{
+		Fields: []*dvevaluation.DvVariable{
+			{
+				Name: []byte("now"),
+				Kind: dvevaluation.FIELD_FUNCTION,
+				Extra: &dvevaluation.DvFunction{
+					Fn: Date_now,
+				},
+			},
+			{
+				Name: []byte("parse"),
+				Kind: dvevaluation.FIELD_FUNCTION,
+				Extra: &dvevaluation.DvFunction{
+					Fn: Date_parse,
+				},
+			},
+			{
+				Name: []byte("UTC"),
+				Kind: dvevaluation.FIELD_FUNCTION,
+				Extra: &dvevaluation.DvFunction{
+					Fn: Date_UTC,
+				},
+			},
+		},
+		Kind: dvevaluation.FIELD_FUNCTION,
+		Extra: &dvevaluation.DvFunction{
+			Fn: Date_constructor,
+		},
+		Prototype: &dvevaluation.DvVariable{
+			Fields: []*dvevaluation.DvVariable{
+				{
+					Name: []byte("getTime"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_getTime,
+					},
+				},
+				{
+					Name: []byte("valueOf"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_getTime,
+					},
+				},
+				{
+					Name: []byte("getTimezoneOffset"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_getTimezoneOffset,
+					},
+				},
+				{
+					Name: []byte("getFullYear"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_getFullYear,
+					},
+				},
+				{
+					Name: []byte("getYear"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_getYear,
+					},
+				},
+				{
+					Name: []byte("getMonth"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_getMonth,
+					},
+				},
+				{
+					Name: []byte("getDate"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_getDate,
+					},
+				},
+				{
+					Name: []byte("getDay"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_getDay,
+					},
+				},
+				{
+					Name: []byte("getHours"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_getHours,
+					},
+				},
+				{
+					Name: []byte("getMinutes"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_getMinutes,
+					},
+				},
+				{
+					Name: []byte("getSeconds"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_getSeconds,
+					},
+				},
+				{
+					Name: []byte("getMilliseconds"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_getMilliseconds,
+					},
+				},
+				{
+					Name: []byte("getUTCFullYear"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_getUTCFullYear,
+					},
+				},
+				{
+					Name: []byte("getUTCMonth"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_getUTCMonth,
+					},
+				},
+				{
+					Name: []byte("getUTCDate"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_getUTCDate,
+					},
+				},
+				{
+					Name: []byte("getUTCDay"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_getUTCDay,
+					},
+				},
+				{
+					Name: []byte("getUTCHours"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_getUTCHours,
+					},
+				},
+				{
+					Name: []byte("getUTCMinutes"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_getUTCMinutes,
+					},
+				},
+				{
+					Name: []byte("getUTCSeconds"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_getUTCSeconds,
+					},
+				},
+				{
+					Name: []byte("getUTCMilliseconds"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_getUTCMilliseconds,
+					},
+				},
+				{
+					Name: []byte("setTime"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_setTime,
+					},
+				},
+				{
+					Name: []byte("setFullYear"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_setFullYear,
+					},
+				},
+				{
+					Name: []byte("setMonth"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_setMonth,
+					},
+				},
+				{
+					Name: []byte("setDate"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_setDate,
+					},
+				},
+				{
+					Name: []byte("setHours"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_setHours,
+					},
+				},
+				{
+					Name: []byte("setMinutes"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_setMinutes,
+					},
+				},
+				{
+					Name: []byte("setSeconds"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_setSeconds,
+					},
+				},
+				{
+					Name: []byte("setMilliseconds"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_setMilliseconds,
+					},
+				},
+				{
+					Name: []byte("setUTCFullYear"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_setUTCFullYear,
+					},
+				},
+				{
+					Name: []byte("setUTCMonth"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_setUTCMonth,
+					},
+				},
+				{
+					Name: []byte("setUTCDate"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_setUTCDate,
+					},
+				},
+				{
+					Name: []byte("setUTCHours"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_setUTCHours,
+					},
+				},
+				{
+					Name: []byte("setUTCMinutes"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_setUTCMinutes,
+					},
+				},
+				{
+					Name: []byte("setUTCSeconds"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_setUTCSeconds,
+					},
+				},
+				{
+					Name: []byte("setUTCMilliseconds"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_setUTCMilliseconds,
+					},
+				},
+				{
+					Name: []byte("toISOString"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_toISOString,
+					},
+				},
+				{
+					Name: []byte("toJSON"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_toJSON,
+					},
+				},
+				{
+					Name: []byte("toString"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_toString,
+					},
+				},
+				{
+					Name: []byte("toDateString"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_toDateString,
+					},
+				},
+				{
+					Name: []byte("toTimeString"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_toTimeString,
+					},
+				},
+				{
+					Name: []byte("toUTCString"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_toUTCString,
+					},
+				},
+				{
+					Name: []byte("toGMTString"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_toUTCString,
+					},
+				},
+				{
+					Name: []byte("toLocaleString"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_toLocaleString,
+					},
+				},
+				{
+					Name: []byte("toLocaleDateString"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_toLocaleDateString,
+					},
+				},
+				{
+					Name: []byte("toLocaleTimeString"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Date_toLocaleTimeString,
+					},
+				},
+			},
+			Kind: dvevaluation.FIELD_OBJECT,
+		},
+	})
+}
+
+func (d *DateValue) ToPrimitiveNumber() float64 {
+	return d.Time
+}
+
+func (d *DateValue) ToPrimitiveString() string {
+	return DateFormat(d.Time, time.Local, "Mon Jan 02 2006 15:04:05 GMT-0700 (MST)")
+}
+
+// ToJsonVariable returns the ISO string of the date as toJSON does, null for the invalid date
+func (d *DateValue) ToJsonVariable() *dvevaluation.DvVariable {
+	s, err := DateToISOString(d.Time)
+	if err != nil {
+		return &dvevaluation.DvVariable{Kind: dvevaluation.FIELD_NULL}
+	}
+	return &dvevaluation.DvVariable{Kind: dvevaluation.FIELD_STRING, Value: []byte(s)}
+}
+
+func NewDateVariable(ms float64) *dvevaluation.DvVariable {
+	return &dvevaluation.DvVariable{
+		Kind:      dvevaluation.FIELD_OBJECT,
+		Prototype: DateMaster.Prototype,
+		Extra:     &DateValue{Time: DateTimeClip(ms)},
+	}
+}
+
+func getDateValue(item interface{}) *DateValue {
+	v := dvevaluation.AnyToDvVariable(item)
+	if v == nil || v.Extra == nil {
+		return nil
+	}
+	d, ok := v.Extra.(*DateValue)
+	if !ok {
+		return nil
+	}
+	return d
+}
+
+func getDateValueStrict(item interface{}) (*DateValue, error) {
+	d := getDateValue(item)
+	if d == nil {
+		return nil, errors.New("this is not a Date object")
+	}
+	return d, nil
+}
+
+func DateTimeClip(ms float64) float64 {
+	if math.IsNaN(ms) || math.IsInf(ms, 0) || math.Abs(ms) > DATE_MAX_TIME {
+		return math.NaN()
+	}
+	return math.Trunc(ms) + 0
+}
+
+func DateNow() float64 {
+	return float64(time.Now().UnixMilli())
+}
+
+func DateToTime(ms float64, loc *time.Location) time.Time {
+	return time.UnixMilli(int64(ms)).In(loc)
+}
+
+func DateFromComponents(components []float64, loc *time.Location) float64 {
+	for i := 0; i < DATE_FIELD_AMOUNT; i++ {
+		if math.IsNaN(components[i]) || math.IsInf(components[i], 0) {
+			return math.NaN()
+		}
+	}
+	year := components[DATE_FIELD_YEAR]
+	if math.Abs(year) > 400000 || math.Abs(components[DATE_FIELD_MONTH]) > 12*400000 || math.Abs(components[DATE_FIELD_DATE]) > 1e9 {
+		return math.NaN()
+	}
+	t := time.Date(int(year), time.Month(int(components[DATE_FIELD_MONTH])+1), int(components[DATE_FIELD_DATE]), 0, 0, 0, 0, loc)
+	ms := float64(t.UnixMilli()) + components[DATE_FIELD_HOURS]*3600000 + components[DATE_FIELD_MINUTES]*60000 +
+		components[DATE_FIELD_SECONDS]*1000 + components[DATE_FIELD_MILLISECONDS]
+	if loc != time.UTC {
+		// the offset may differ between midnight and the requested time in case of daylight saving
+		midnightOffset := dateZoneOffset(t)
+		actualOffset := dateZoneOffset(DateToTime(ms, loc))
+		ms += float64(midnightOffset-actualOffset) * 1000
+	}
+	return DateTimeClip(ms)
+}
+
+func dateZoneOffset(t time.Time) int {
+	_, offset := t.Zone()
+	return offset
+}
+
+func DateToComponents(ms float64, loc *time.Location) []float64 {
+	t := DateToTime(ms, loc)
+	return []float64{
+		float64(t.Year()),
+		float64(t.Month() - 1),
+		float64(t.Day()),
+		float64(t.Hour()),
+		float64(t.Minute()),
+		float64(t.Second()),
+		float64(t.Nanosecond() / 1000000),
+	}
+}
+
+func dateArgumentNumber(param interface{}) float64 {
+	v := dvevaluation.AnyToNumber(param)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return v
+	}
+	return math.Trunc(v)
+}
+
+func dateComponentsFromParams(params []interface{}) []float64 {
+	components := []float64{0, 0, 1, 0, 0, 0, 0}
+	n := len(params)
+	if n > DATE_FIELD_AMOUNT {
+		n = DATE_FIELD_AMOUNT
+	}
+	for i := 0; i < n; i++ {
+		components[i] = dateArgumentNumber(params[i])
+	}
+	year := components[DATE_FIELD_YEAR]
+	if year >= 0 && year <= 99 {
+		components[DATE_FIELD_YEAR] = 1900 + year
+	}
+	return components
+}
+
+func DateFormat(ms float64, loc *time.Location, layout string) string {
+	if math.IsNaN(ms) {
+		return DATE_INVALID_STRING
+	}
+	return DateToTime(ms, loc).Format(layout)
+}
+
+func DateToISOString(ms float64) (string, error) {
+	if math.IsNaN(ms) {
+		return "", errors.New("RangeError: Invalid time value")
+	}
+	t := DateToTime(ms, time.UTC)
+	year := t.Year()
+	var yearStr string
+	if year >= 0 && year <= 9999 {
+		yearStr = fmt.Sprintf("%04d", year)
+	} else if year < 0 {
+		yearStr = fmt.Sprintf("-%06d", -year)
+	} else {
+		yearStr = fmt.Sprintf("+%06d", year)
+	}
+	return yearStr + t.Format("-01-02T15:04:05.000Z"), nil
+}
+
+func Date_constructor(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	n := len(params)
+	switch n {
+	case 0:
+		return NewDateVariable(DateNow()), nil
+	case 1:
+		if d := getDateValue(params[0]); d != nil {
+			return NewDateVariable(d.Time), nil
+		}
+		if dvevaluation.AnyGetType(params[0]) == dvgrammar.TYPE_STRING {
+			return NewDateVariable(DateParse(dvevaluation.AnyToString(params[0]))), nil
+		}
+		return NewDateVariable(dvevaluation.AnyToNumber(params[0])), nil
+	}
+	return NewDateVariable(DateFromComponents(dateComponentsFromParams(params), time.Local)), nil
+}
+
+func Date_now(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return time.Now().UnixMilli(), nil
+}
+
+func Date_parse(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	if len(params) == 0 {
+		return math.NaN(), nil
+	}
+	return dateResult(DateParse(dvevaluation.AnyToString(params[0]))), nil
+}
+
+func Date_UTC(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	if len(params) == 0 {
+		return math.NaN(), nil
+	}
+	return dateResult(DateFromComponents(dateComponentsFromParams(params), time.UTC)), nil
+}
+
+func dateResult(ms float64) interface{} {
+	if math.IsNaN(ms) {
+		return ms
+	}
+	return int64(ms)
+}
+
+func dateGetComponent(thisVariable interface{}, loc *time.Location, field int) (interface{}, error) {
+	d, err := getDateValueStrict(thisVariable)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsNaN(d.Time) {
+		return d.Time, nil
+	}
+	return int64(DateToComponents(d.Time, loc)[field]), nil
+}
+
+func Date_getTime(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	d, err := getDateValueStrict(thisVariable)
+	if err != nil {
+		return nil, err
+	}
+	return dateResult(d.Time), nil
+}
+
+func Date_getTimezoneOffset(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	d, err := getDateValueStrict(thisVariable)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsNaN(d.Time) {
+		return d.Time, nil
+	}
+	return -dateZoneOffset(DateToTime(d.Time, time.Local)) / 60, nil
+}
+
+func Date_getFullYear(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateGetComponent(thisVariable, time.Local, DATE_FIELD_YEAR)
+}
+
+func Date_getYear(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	year, err := dateGetComponent(thisVariable, time.Local, DATE_FIELD_YEAR)
+	if err != nil {
+		return nil, err
+	}
+	if y, ok := year.(int64); ok {
+		return y - 1900, nil
+	}
+	return year, nil
+}
+
+func Date_getMonth(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateGetComponent(thisVariable, time.Local, DATE_FIELD_MONTH)
+}
+
+func Date_getDate(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateGetComponent(thisVariable, time.Local, DATE_FIELD_DATE)
+}
+
+func Date_getHours(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateGetComponent(thisVariable, time.Local, DATE_FIELD_HOURS)
+}
+
+func Date_getMinutes(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateGetComponent(thisVariable, time.Local, DATE_FIELD_MINUTES)
+}
+
+func Date_getSeconds(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateGetComponent(thisVariable, time.Local, DATE_FIELD_SECONDS)
+}
+
+func Date_getMilliseconds(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateGetComponent(thisVariable, time.Local, DATE_FIELD_MILLISECONDS)
+}
+
+func Date_getUTCFullYear(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateGetComponent(thisVariable, time.UTC, DATE_FIELD_YEAR)
+}
+
+func Date_getUTCMonth(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateGetComponent(thisVariable, time.UTC, DATE_FIELD_MONTH)
+}
+
+func Date_getUTCDate(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateGetComponent(thisVariable, time.UTC, DATE_FIELD_DATE)
+}
+
+func Date_getUTCHours(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateGetComponent(thisVariable, time.UTC, DATE_FIELD_HOURS)
+}
+
+func Date_getUTCMinutes(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateGetComponent(thisVariable, time.UTC, DATE_FIELD_MINUTES)
+}
+
+func Date_getUTCSeconds(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateGetComponent(thisVariable, time.UTC, DATE_FIELD_SECONDS)
+}
+
+func Date_getUTCMilliseconds(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateGetComponent(thisVariable, time.UTC, DATE_FIELD_MILLISECONDS)
+}
+
+func dateGetWeekDay(thisVariable interface{}, loc *time.Location) (interface{}, error) {
+	d, err := getDateValueStrict(thisVariable)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsNaN(d.Time) {
+		return d.Time, nil
+	}
+	return int(DateToTime(d.Time, loc).Weekday()), nil
+}
+
+func Date_getDay(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateGetWeekDay(thisVariable, time.Local)
+}
+
+func Date_getUTCDay(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateGetWeekDay(thisVariable, time.UTC)
+}
+
+// dateSetComponents replaces up to maxAmount date components starting from field
+// and returns the new time value as JavaScript setters do
+func dateSetComponents(thisVariable interface{}, params []interface{}, loc *time.Location, field int, maxAmount int) (interface{}, error) {
+	d, err := getDateValueStrict(thisVariable)
+	if err != nil {
+		return nil, err
+	}
+	base := d.Time
+	if math.IsNaN(base) {
+		if field != DATE_FIELD_YEAR {
+			return base, nil
+		}
+		base = 0
+	}
+	components := DateToComponents(base, loc)
+	n := len(params)
+	if n == 0 {
+		d.Time = math.NaN()
+		return d.Time, nil
+	}
+	if n > maxAmount {
+		n = maxAmount
+	}
+	for i := 0; i < n; i++ {
+		components[field+i] = dateArgumentNumber(params[i])
+	}
+	d.Time = DateFromComponents(components, loc)
+	return dateResult(d.Time), nil
+}
+
+func Date_setTime(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	d, err := getDateValueStrict(thisVariable)
+	if err != nil {
+		return nil, err
+	}
+	d.Time = math.NaN()
+	if len(params) > 0 {
+		d.Time = DateTimeClip(dvevaluation.AnyToNumber(params[0]))
+	}
+	return dateResult(d.Time), nil
+}
+
+func Date_setFullYear(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateSetComponents(thisVariable, params, time.Local, DATE_FIELD_YEAR, 3)
+}
+
+func Date_setMonth(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateSetComponents(thisVariable, params, time.Local, DATE_FIELD_MONTH, 2)
+}
+
+func Date_setDate(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateSetComponents(thisVariable, params, time.Local, DATE_FIELD_DATE, 1)
+}
+
+func Date_setHours(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateSetComponents(thisVariable, params, time.Local, DATE_FIELD_HOURS, 4)
+}
+
+func Date_setMinutes(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateSetComponents(thisVariable, params, time.Local, DATE_FIELD_MINUTES, 3)
+}
+
+func Date_setSeconds(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateSetComponents(thisVariable, params, time.Local, DATE_FIELD_SECONDS, 2)
+}
+
+func Date_setMilliseconds(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateSetComponents(thisVariable, params, time.Local, DATE_FIELD_MILLISECONDS, 1)
+}
+
+func Date_setUTCFullYear(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateSetComponents(thisVariable, params, time.UTC, DATE_FIELD_YEAR, 3)
+}
+
+func Date_setUTCMonth(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateSetComponents(thisVariable, params, time.UTC, DATE_FIELD_MONTH, 2)
+}
+
+func Date_setUTCDate(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateSetComponents(thisVariable, params, time.UTC, DATE_FIELD_DATE, 1)
+}
+
+func Date_setUTCHours(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateSetComponents(thisVariable, params, time.UTC, DATE_FIELD_HOURS, 4)
+}
+
+func Date_setUTCMinutes(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateSetComponents(thisVariable, params, time.UTC, DATE_FIELD_MINUTES, 3)
+}
+
+func Date_setUTCSeconds(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateSetComponents(thisVariable, params, time.UTC, DATE_FIELD_SECONDS, 2)
+}
+
+func Date_setUTCMilliseconds(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateSetComponents(thisVariable, params, time.UTC, DATE_FIELD_MILLISECONDS, 1)
+}
+
+func Date_toISOString(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	d, err := getDateValueStrict(thisVariable)
+	if err != nil {
+		return nil, err
+	}
+	return DateToISOString(d.Time)
+}
+
+func Date_toJSON(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	d, err := getDateValueStrict(thisVariable)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsNaN(d.Time) {
+		return nil, nil
+	}
+	return DateToISOString(d.Time)
+}
+
+func dateFormatThis(thisVariable interface{}, loc *time.Location, layout string) (interface{}, error) {
+	d, err := getDateValueStrict(thisVariable)
+	if err != nil {
+		return nil, err
+	}
+	return DateFormat(d.Time, loc, layout), nil
+}
+
+func Date_toString(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateFormatThis(thisVariable, time.Local, "Mon Jan 02 2006 15:04:05 GMT-0700 (MST)")
+}
+
+func Date_toDateString(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateFormatThis(thisVariable, time.Local, "Mon Jan 02 2006")
+}
+
+func Date_toTimeString(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateFormatThis(thisVariable, time.Local, "15:04:05 GMT-0700 (MST)")
+}
+
+func Date_toUTCString(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateFormatThis(thisVariable, time.UTC, "Mon, 02 Jan 2006 15:04:05 GMT")
+}
+
+func Date_toLocaleString(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateFormatThis(thisVariable, time.Local, "1/2/2006, 3:04:05 PM")
+}
+
+func Date_toLocaleDateString(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateFormatThis(thisVariable, time.Local, "1/2/2006")
+}
+
+func Date_toLocaleTimeString(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return dateFormatThis(thisVariable, time.Local, "3:04:05 PM")
+}
+
+var dateFallbackLayouts = []string{
+	"Mon Jan 02 2006 15:04:05 GMT-0700",
+	"Mon Jan 02 2006 15:04:05",
+	"Mon Jan 02 2006",
+	time.RFC1123,
+	time.RFC1123Z,
+	time.RFC850,
+	time.RFC822,
+	time.RFC822Z,
+	time.ANSIC,
+	time.UnixDate,
+	"Mon, 02 Jan 2006 15:04:05 GMT",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"January 2, 2006 15:04:05",
+	"January 2, 2006",
+	"Jan 2, 2006 15:04:05",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"2006/01/02",
+	"1/2/2006, 3:04:05 PM",
+	"1/2/2006 15:04:05",
+	"1/2/2006",
+}
+
+// DateParse converts the ISO 8601 format (which JavaScript requires)
+// and a set of widespread formats into milliseconds since the epoch, NaN if not recognized
+func DateParse(s string) float64 {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return math.NaN()
+	}
+	if ms, ok := dateParseISO(s); ok {
+		return ms
+	}
+	if p := strings.Index(s, " ("); p > 0 && s[len(s)-1] == ')' {
+		s = s[:p]
+	}
+	for _, layout := range dateFallbackLayouts {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err == nil {
+			return DateTimeClip(float64(t.UnixMilli()))
+		}
+	}
+	return math.NaN()
+}
+
+func dateReadDigits(s string, pos int, amount int) (int, int, bool) {
+	if pos+amount > len(s) {
+		return 0, pos, false
+	}
+	r := 0
+	for i := 0; i < amount; i++ {
+		c := s[pos+i]
+		if c < '0' || c > '9' {
+			return 0, pos, false
+		}
+		r = r*10 + int(c-'0')
+	}
+	return r, pos + amount, true
+}
 
+func dateParseISO(s string) (float64, bool) {
+	n := len(s)
+	pos := 0
+	var ok bool
+	components := []float64{0, 0, 1, 0, 0, 0, 0}
+	var year int
+	if s[0] == '+' || s[0] == '-' {
+		year, pos, ok = dateReadDigits(s, 1, 6)
+		if !ok || s[0] == '-' && year == 0 {
+			return 0, false
+		}
+		if s[0] == '-' {
+			year = -year
+		}
+	} else {
+		year, pos, ok = dateReadDigits(s, 0, 4)
+		if !ok {
+			return 0, false
+		}
+	}
+	components[DATE_FIELD_YEAR] = float64(year)
+	var v int
+	if pos < n && s[pos] == '-' {
+		v, pos, ok = dateReadDigits(s, pos+1, 2)
+		if !ok || v < 1 || v > 12 {
+			return 0, false
+		}
+		components[DATE_FIELD_MONTH] = float64(v - 1)
+		if pos < n && s[pos] == '-' {
+			v, pos, ok = dateReadDigits(s, pos+1, 2)
+			if !ok || v < 1 || v > 31 {
+				return 0, false
+			}
+			components[DATE_FIELD_DATE] = float64(v)
+		}
+	}
+	if pos == n {
+		return DateFromComponents(components, time.UTC), true
+	}
+	if s[pos] != 'T' && s[pos] != 't' && s[pos] != ' ' {
+		return 0, false
+	}
+	v, pos, ok = dateReadDigits(s, pos+1, 2)
+	if !ok || v > 24 || pos >= n || s[pos] != ':' {
+		return 0, false
+	}
+	components[DATE_FIELD_HOURS] = float64(v)
+	v, pos, ok = dateReadDigits(s, pos+1, 2)
+	if !ok || v > 59 {
+		return 0, false
+	}
+	components[DATE_FIELD_MINUTES] = float64(v)
+	if pos < n && s[pos] == ':' {
+		v, pos, ok = dateReadDigits(s, pos+1, 2)
+		if !ok || v > 59 {
+			return 0, false
+		}
+		components[DATE_FIELD_SECONDS] = float64(v)
+		if pos < n && (s[pos] == '.' || s[pos] == ',') {
+			start := pos + 1
+			pos = start
+			for pos < n && s[pos] >= '0' && s[pos] <= '9' {
+				pos++
+			}
+			if pos == start {
+				return 0, false
+			}
+			fraction, _ := strconv.ParseFloat("0."+s[start:pos], 64)
+			components[DATE_FIELD_MILLISECONDS] = math.Trunc(fraction * 1000)
+		}
+	}
+	if components[DATE_FIELD_HOURS] == 24 && (components[DATE_FIELD_MINUTES] != 0 || components[DATE_FIELD_SECONDS] != 0 || components[DATE_FIELD_MILLISECONDS] != 0) {
+		return 0, false
+	}
+	if pos == n {
+		return DateFromComponents(components, time.Local), true
+	}
+	if (s[pos] == 'Z' || s[pos] == 'z') && pos+1 == n {
+		return DateFromComponents(components, time.UTC), true
+	}
+	if s[pos] != '+' && s[pos] != '-' {
+		return 0, false
+	}
+	sign := 1.0
+	if s[pos] == '-' {
+		sign = -1
+	}
+	var hours, minutes int
+	hours, pos, ok = dateReadDigits(s, pos+1, 2)
+	if !ok {
+		return 0, false
+	}
+	if pos < n && s[pos] == ':' {
+		pos++
+	}
+	minutes, pos, ok = dateReadDigits(s, pos, 2)
+	if !ok || pos != n || hours > 23 || minutes > 59 {
+		return 0, false
+	}
+	ms := DateFromComponents(components, time.UTC)
+	return DateTimeClip(ms - sign*float64(hours*60+minutes)*60000), true
 }
@@ -0,0 +1,78 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvjsmaster
+
+import (
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
+)
+
+var ErrorMasters = make(map[string]*dvevaluation.DvVariable)
+
+var errorKinds = []string{
+	dvevaluation.ERROR_KIND_ERROR,
+	dvevaluation.ERROR_KIND_TYPE,
+	dvevaluation.ERROR_KIND_RANGE,
+	dvevaluation.ERROR_KIND_SYNTAX,
+	dvevaluation.ERROR_KIND_REFERENCE,
+}
+
+func error_init() {
+	for _, kind := range errorKinds {
+		ErrorMasters[kind] = dvevaluation.RegisterMasterVariable(kind, &dvevaluation.DvVariable{
+			Fields: make([]*dvevaluation.DvVariable, 0, 7),
+			Kind:   dvevaluation.FIELD_FUNCTION,
+			Extra: &dvevaluation.DvFunction{
+				Fn: errorConstructorOfKind(kind),
+			},
+			Prototype: &dvevaluation.DvVariable{
+				Fields: []*dvevaluation.DvVariable{
+					{
+						Name: []byte("toString"),
+						Kind: dvevaluation.FIELD_FUNCTION,
+						Extra: &dvevaluation.DvFunction{
+							Fn: Error_toString,
+						},
+					},
+				},
+				Kind: dvevaluation.FIELD_OBJECT,
+			},
+		})
+	}
+	for _, kind := range errorKinds {
+		if kind != dvevaluation.ERROR_KIND_ERROR {
+			ErrorMasters[kind].Prototype.Prototype = ErrorMasters[dvevaluation.ERROR_KIND_ERROR].Prototype
+		}
+	}
+	dvevaluation.RegisterErrorObjectCreator(NewErrorObject)
+}
+
+func NewErrorObject(kind string, message string) *dvevaluation.DvVariable {
+	v := dvevaluation.CreateSimpleErrorObject(kind, message)
+	v.Prototype = ErrorMasters[kind]
+	if v.Prototype == nil {
+		v.Prototype = ErrorMasters[dvevaluation.ERROR_KIND_ERROR]
+	}
+	return v
+}
+
+func errorConstructorOfKind(kind string) dvevaluation.DvFunc {
+	return func(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+		message := ""
+		if len(params) > 0 && params[0] != nil {
+			message = dvevaluation.AnyToString(params[0])
+		}
+		return NewErrorObject(kind, message), nil
+	}
+}
+
+func Error_toString(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	v := dvevaluation.AnyToDvVariable(thisVariable)
+	if v == nil {
+		return dvevaluation.ERROR_KIND_ERROR, nil
+	}
+	return dvevaluation.GetErrorObjectDescription(v), nil
+}
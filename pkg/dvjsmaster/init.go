@@ -17,9 +17,14 @@ func Init() bool {
 	net_init()
 	math_init()
 	date_init()
+	error_init()
 	json_init()
 	string_init()
 	regexp_init()
+	collections_init()
+	symbol_init()
+	promise_init()
+	timers_init()
 	return true
 }
 
@@ -8,6 +8,7 @@ package dvjsmaster
 import (
 	"errors"
 	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
 	"github.com/Dobryvechir/microcore/pkg/dvjson"
 )
 
@@ -22,12 +23,16 @@ func json_init() {
 				{
 					Name: []byte("stringify"),
 					Kind: dvevaluation.FIELD_FUNCTION,
-					Extra:   JSON_stringify,
+					Extra: &dvevaluation.DvFunction{
+						Fn: JSON_stringify,
+					},
 				},
 				{
 					Name: []byte("parse"),
 					Kind: dvevaluation.FIELD_FUNCTION,
-					Extra:   JSON_parse,
+					Extra: &dvevaluation.DvFunction{
+						Fn: JSON_parse,
+					},
 				},
 			},
 			Kind: dvevaluation.FIELD_OBJECT,
@@ -36,22 +41,32 @@ func json_init() {
 
 }
 
-func JSON_stringify(context , thisVariable *dvevaluation.DvVariable, params []*dvevaluation.DvVariable) (*dvevaluation.DvVariable, error) {
-	var data []byte
+func JSON_stringify(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
 	if len(params) == 0 || params[0] == nil {
-		data = []byte{}
-	} else {
-		data = params[1].JsonStringify()
+		return nil, nil
 	}
-	res := dvevaluation.DvVariableFromString(nil, string(data))
-	return res, nil
+	v := dvevaluation.AnyToDvVariable(params[0])
+	if v == nil || v.Kind == dvevaluation.FIELD_UNDEFINED || v.Kind == dvevaluation.FIELD_FUNCTION {
+		return nil, nil
+	}
+	indent := 0
+	if len(params) > 2 && params[2] != nil {
+		if n, ok := dvevaluation.AnyToNumberInt(params[2]); ok {
+			indent = int(n)
+		}
+	}
+	return string(dvjson.PrintToJson(v, indent)), nil
 }
 
-func JSON_parse(context *dvevaluation.DvContext, thisVariable *dvevaluation.DvVariable, params []*dvevaluation.DvVariable) (*dvevaluation.DvVariable, error) {
+func JSON_parse(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
 	if len(params) == 0 || params[0] == nil {
-		return &dvevaluation.DvVariable{}, nil
+		return nil, errors.New("Unexpected end of JSON input")
+	}
+	body := []byte(dvevaluation.AnyToString(params[0]))
+	if len(body) == 0 {
+		return nil, errors.New("Unexpected end of JSON input")
 	}
-	return JSON_parse_direct([]byte(params[1].GetStringValue()), "JSON.parse")
+	return JSON_parse_direct(body, "JSON.parse")
 }
 
 func convert_DvFieldInfo_to_DvVariable(field *dvevaluation.DvVariable) *dvevaluation.DvVariable {
@@ -99,19 +114,14 @@ func JSON_parse_direct(body []byte, info string) (*dvevaluation.DvVariable, erro
 	if len(body) == 0 {
 		return &dvevaluation.DvVariable{Kind: dvevaluation.FIELD_NULL}, nil
 	}
-	crudDetails := &dvjson.DvCrudDetails{}
-	highLevelObject := false
-	parsed := dvjson.JsonQuickParser(body, crudDetails, highLevelObject, dvjson.OPTIONS_FIELDS_DETAILED)
-	if parsed.Err != "" {
-		return nil, errors.New(parsed.Err)
+	parent, err := dvjson.JsonFullParser(body)
+	if err != nil {
+		return nil, err
 	}
-	parent := &dvevaluation.DvVariable{Kind: parsed.Kind, Value: parsed.Value}
-	switch parsed.Kind {
+	switch parent.Kind {
 	case dvevaluation.FIELD_OBJECT:
 		parent.Prototype = dvevaluation.ObjectMaster
-		parent.Fields = convert_Object_DvCrudItem_to_DvVariableMap(parsed.Items)
 	case dvevaluation.FIELD_ARRAY:
-		parent.Fields = convert_Array_DvCrudItem_to_DvVariableMap(parsed.Items)
 		parent.Prototype = dvevaluation.ArrayMaster
 	}
 	return parent, nil
@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"errors"
 	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
 	"github.com/Dobryvechir/microcore/pkg/dvlog"
 	"io/ioutil"
 	"log"
@@ -36,47 +37,126 @@ type ServerSettings struct {
 var NetMaster *dvevaluation.DvVariable
 var netServerInfo *NetServerInfo = &NetServerInfo{}
 
-func Net_GetText(context *dvevaluation.DvContext, thisVariable *dvevaluation.DvVariable, params []*dvevaluation.DvVariable) (*dvevaluation.DvVariable, error) {
-	return net_request(params[0], params[1], params[2], "GET", "PLAIN", "TEXT")
+func Net_GetText(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return net_request(netParam(params, 0), netParam(params, 1), netParam(params, 2), "GET", "PLAIN", "TEXT")
 }
 
-func Net_PostText(context *dvevaluation.DvContext, thisVariable *dvevaluation.DvVariable, params []*dvevaluation.DvVariable) (*dvevaluation.DvVariable, error) {
-	return net_request(params[0], params[1], params[2], "POST", "JSON", "TEXT")
+func Net_PostText(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return net_request(netParam(params, 0), netParam(params, 1), netParam(params, 2), "POST", "JSON", "TEXT")
 }
 
-func Net_PutText(context *dvevaluation.DvContext, thisVariable *dvevaluation.DvVariable, params []*dvevaluation.DvVariable) (*dvevaluation.DvVariable, error) {
-	return net_request(params[0], params[1], params[2], "PUT", "JSON", "TEXT")
+func Net_PutText(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return net_request(netParam(params, 0), netParam(params, 1), netParam(params, 2), "PUT", "JSON", "TEXT")
 }
 
-func Net_DeleteText(context *dvevaluation.DvContext, thisVariable *dvevaluation.DvVariable, params []*dvevaluation.DvVariable) (*dvevaluation.DvVariable, error) {
-	return net_request(params[0], params[1], params[2], "DELETE", "JSON", "TEXT")
+func Net_DeleteText(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return net_request(netParam(params, 0), netParam(params, 1), netParam(params, 2), "DELETE", "JSON", "TEXT")
 }
 
-func Net_RequestText(context *dvevaluation.DvContext, thisVariable *dvevaluation.DvVariable, params []*dvevaluation.DvVariable) (*dvevaluation.DvVariable, error) {
-	return net_request(params[0], params[1], params[2], params[3].GetStringValue(), "JSON", "TEXT")
+func Net_RequestText(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return net_request(netParam(params, 0), netParam(params, 1), netParam(params, 2), netParam(params, 3).GetStringValue(), "JSON", "TEXT")
 }
 
-func Net_Get(context *dvevaluation.DvContext, thisVariable *dvevaluation.DvVariable, params []*dvevaluation.DvVariable) (*dvevaluation.DvVariable, error) {
-	return net_request(params[0], params[1], params[2], "GET", "PLAIN", "JSON")
+func Net_Get(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return net_request(netParam(params, 0), netParam(params, 1), netParam(params, 2), "GET", "PLAIN", "JSON")
 }
 
-func Net_Post(context *dvevaluation.DvContext, thisVariable *dvevaluation.DvVariable, params []*dvevaluation.DvVariable) (*dvevaluation.DvVariable, error) {
-	return net_request(params[0], params[1], params[2], "POST", "JSON", "JSON")
+func Net_Post(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return net_request(netParam(params, 0), netParam(params, 1), netParam(params, 2), "POST", "JSON", "JSON")
 }
 
-func Net_Put(context *dvevaluation.DvContext, thisVariable *dvevaluation.DvVariable, params []*dvevaluation.DvVariable) (*dvevaluation.DvVariable, error) {
-	return net_request(params[0], params[1], params[2], "PUT", "JSON", "JSON")
+func Net_Put(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return net_request(netParam(params, 0), netParam(params, 1), netParam(params, 2), "PUT", "JSON", "JSON")
 }
 
-func Net_Delete(context *dvevaluation.DvContext, thisVariable *dvevaluation.DvVariable, params []*dvevaluation.DvVariable) (*dvevaluation.DvVariable, error) {
-	return net_request(params[0], params[1], params[2], "DELETE", "JSON", "JSON")
+func Net_Delete(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return net_request(netParam(params, 0), netParam(params, 1), netParam(params, 2), "DELETE", "JSON", "JSON")
 }
 
-func Net_Request(context *dvevaluation.DvContext, thisVariable *dvevaluation.DvVariable, params []*dvevaluation.DvVariable) (*dvevaluation.DvVariable, error) {
-	return net_request(params[0], params[1], params[2], params[3].GetStringValue(), "JSON", "JSON")
+func Net_Request(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return net_request(netParam(params, 0), netParam(params, 1), netParam(params, 2), netParam(params, 3).GetStringValue(), "JSON", "JSON")
+}
+
+func Net_GetTextAsync(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return net_request_async(context, netParam(params, 0), netParam(params, 1), netParam(params, 2), "GET", "PLAIN", "TEXT")
+}
+
+func Net_PostTextAsync(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return net_request_async(context, netParam(params, 0), netParam(params, 1), netParam(params, 2), "POST", "JSON", "TEXT")
+}
+
+func Net_PutTextAsync(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return net_request_async(context, netParam(params, 0), netParam(params, 1), netParam(params, 2), "PUT", "JSON", "TEXT")
+}
+
+func Net_DeleteTextAsync(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return net_request_async(context, netParam(params, 0), netParam(params, 1), netParam(params, 2), "DELETE", "JSON", "TEXT")
+}
+
+func Net_RequestTextAsync(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return net_request_async(context, netParam(params, 0), netParam(params, 1), netParam(params, 2), netParam(params, 3).GetStringValue(), "JSON", "TEXT")
+}
+
+func Net_GetAsync(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return net_request_async(context, netParam(params, 0), netParam(params, 1), netParam(params, 2), "GET", "PLAIN", "JSON")
+}
+
+func Net_PostAsync(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return net_request_async(context, netParam(params, 0), netParam(params, 1), netParam(params, 2), "POST", "JSON", "JSON")
+}
+
+func Net_PutAsync(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return net_request_async(context, netParam(params, 0), netParam(params, 1), netParam(params, 2), "PUT", "JSON", "JSON")
+}
+
+func Net_DeleteAsync(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return net_request_async(context, netParam(params, 0), netParam(params, 1), netParam(params, 2), "DELETE", "JSON", "JSON")
+}
+
+func Net_RequestAsync(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return net_request_async(context, netParam(params, 0), netParam(params, 1), netParam(params, 2), netParam(params, 3).GetStringValue(), "JSON", "JSON")
+}
+
+func netParam(params []interface{}, index int) *dvevaluation.DvVariable {
+	if index >= len(params) {
+		return nil
+	}
+	return dvevaluation.AnyToDvVariable(params[index])
 }
 
 func net_request(url *dvevaluation.DvVariable, data *dvevaluation.DvVariable, headers *dvevaluation.DvVariable, method string, inputFormat string, outputFormat string) (*dvevaluation.DvVariable, error) {
+	req, err := net_prepare_request(url, data, headers, method, inputFormat)
+	if err != nil {
+		return nil, err
+	}
+	return net_execute_request(req, outputFormat)
+}
+
+// net_request_async reads the parameters at once and sends the request in a separate goroutine,
+// the returned promise is settled by the event loop of the evaluation
+func net_request_async(context *dvgrammar.ExpressionContext, url *dvevaluation.DvVariable, data *dvevaluation.DvVariable, headers *dvevaluation.DvVariable, method string, inputFormat string, outputFormat string) (interface{}, error) {
+	res, p := dvevaluation.NewPromise(context)
+	req, err := net_prepare_request(url, data, headers, method, inputFormat)
+	if err != nil {
+		p.Reject(dvevaluation.GetCatchValue(err))
+		return res, nil
+	}
+	complete := dvevaluation.GetEventLoop(context).StartOperation()
+	go func() {
+		reply, err := net_execute_request(req, outputFormat)
+		complete(func() error {
+			if err != nil {
+				p.Reject(dvevaluation.GetCatchValue(err))
+			} else {
+				p.Resolve(reply)
+			}
+			return nil
+		})
+	}()
+	return res, nil
+}
+
+func net_prepare_request(url *dvevaluation.DvVariable, data *dvevaluation.DvVariable, headers *dvevaluation.DvVariable, method string, inputFormat string) (*http.Request, error) {
 	urlStr := url.GetStringValue()
 	if urlStr == "" {
 		return nil, errors.New("Cannot make net request with empty url")
@@ -91,15 +171,17 @@ func net_request(url *dvevaluation.DvVariable, data *dvevaluation.DvVariable, he
 	req, err := http.NewRequest(method, urlStr, bodyIo)
 	if err != nil {
 		if dvlog.CurrentLogLevel >= dvlog.LogError {
-			log.Print("Error making request %s: %s", urlStr, err.Error())
+			log.Printf("Error making request %s: %s", urlStr, err.Error())
 		}
 		return nil, err
 	}
 	req.Header = headers.GetStringArrayMap()
-	if netServerInfo.client == nil {
-		createClientForNetServerInfo(netServerInfo)
-	}
-	resp, err1 := netServerInfo.client.Do(req)
+	return req, nil
+}
+
+func net_execute_request(req *http.Request, outputFormat string) (*dvevaluation.DvVariable, error) {
+	urlStr := req.URL.String()
+	resp, err1 := getNetClient(netServerInfo).Do(req)
 	if err1 != nil {
 		if dvlog.CurrentLogLevel >= dvlog.LogError {
 			log.Printf("Error executing %s: %s", urlStr, err1.Error())
@@ -117,6 +199,9 @@ func net_request(url *dvevaluation.DvVariable, data *dvevaluation.DvVariable, he
 	if resp.StatusCode < 400 {
 		if outputFormat == "JSON" {
 			reply, err1 = JSON_parse_direct(body, "Net request "+urlStr)
+			if err1 != nil {
+				return nil, err1
+			}
 		} else {
 			reply = dvevaluation.DvVariableFromString(nil, string(body))
 		}
@@ -140,6 +225,19 @@ func createClientBySettings(settings *ServerSettings) *http.Client {
 	return &http.Client{Transport: tr, Timeout: time.Second * 60}
 }
 
+func getNetClient(server *NetServerInfo) *http.Client {
+	server.RLock()
+	client := server.client
+	server.RUnlock()
+	if client == nil {
+		createClientForNetServerInfo(server)
+		server.RLock()
+		client = server.client
+		server.RUnlock()
+	}
+	return client
+}
+
 func createClientForNetServerInfo(server *NetServerInfo) {
 	server.Lock()
 	if server.client != nil {
@@ -159,52 +257,142 @@ func net_init() {
 				{
 					Name: []byte("getText"),
 					Kind: dvevaluation.FIELD_FUNCTION,
-					Extra:   Net_GetText,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Net_GetText,
+					},
 				},
 				{
 					Name: []byte("postText"),
 					Kind: dvevaluation.FIELD_FUNCTION,
-					Extra:   Net_PostText,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Net_PostText,
+					},
 				},
 				{
 					Name: []byte("putText"),
 					Kind: dvevaluation.FIELD_FUNCTION,
-					Extra:   Net_PutText,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Net_PutText,
+					},
 				},
 				{
 					Name: []byte("deleteText"),
 					Kind: dvevaluation.FIELD_FUNCTION,
-					Extra:   Net_DeleteText,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Net_DeleteText,
+					},
 				},
 				{
 					Name: []byte("requestText"),
 					Kind: dvevaluation.FIELD_FUNCTION,
-					Extra:   Net_RequestText,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Net_RequestText,
+					},
 				},
 				{
 					Name: []byte("get"),
 					Kind: dvevaluation.FIELD_FUNCTION,
-					Extra:   Net_Get,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Net_Get,
+					},
 				},
 				{
 					Name: []byte("post"),
 					Kind: dvevaluation.FIELD_FUNCTION,
-					Extra:   Net_Post,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Net_Post,
+					},
 				},
 				{
 					Name: []byte("put"),
 					Kind: dvevaluation.FIELD_FUNCTION,
-					Extra:   Net_Put,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Net_Put,
+					},
 				},
 				{
 					Name: []byte("delete"),
 					Kind: dvevaluation.FIELD_FUNCTION,
-					Extra:   Net_Delete,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Net_Delete,
+					},
 				},
 				{
 					Name: []byte("request"),
 					Kind: dvevaluation.FIELD_FUNCTION,
-					Extra:   Net_Request,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Net_Request,
+					},
+				},
+				{
+					Name: []byte("getTextAsync"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Net_GetTextAsync,
+					},
+				},
+				{
+					Name: []byte("postTextAsync"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Net_PostTextAsync,
+					},
+				},
+				{
+					Name: []byte("putTextAsync"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Net_PutTextAsync,
+					},
+				},
+				{
+					Name: []byte("deleteTextAsync"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Net_DeleteTextAsync,
+					},
+				},
+				{
+					Name: []byte("requestTextAsync"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Net_RequestTextAsync,
+					},
+				},
+				{
+					Name: []byte("getAsync"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Net_GetAsync,
+					},
+				},
+				{
+					Name: []byte("postAsync"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Net_PostAsync,
+					},
+				},
+				{
+					Name: []byte("putAsync"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Net_PutAsync,
+					},
+				},
+				{
+					Name: []byte("deleteAsync"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Net_DeleteAsync,
+					},
+				},
+				{
+					Name: []byte("requestAsync"),
+					Kind: dvevaluation.FIELD_FUNCTION,
+					Extra: &dvevaluation.DvFunction{
+						Fn: Net_RequestAsync,
+					},
 				},
 			},
 			Kind: dvevaluation.FIELD_OBJECT,
@@ -0,0 +1,335 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvjsmaster
+
+import (
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
+)
+
+func promise_init() {
+	dvevaluation.PromiseMaster.Prototype = &dvevaluation.DvVariable{
+		Fields: []*dvevaluation.DvVariable{
+			{
+				Name: []byte("then"),
+				Kind: dvevaluation.FIELD_FUNCTION,
+				Extra: &dvevaluation.DvFunction{
+					Fn: Promise_then,
+				},
+			},
+			{
+				Name: []byte("catch"),
+				Kind: dvevaluation.FIELD_FUNCTION,
+				Extra: &dvevaluation.DvFunction{
+					Fn: Promise_catch,
+				},
+			},
+			{
+				Name: []byte("finally"),
+				Kind: dvevaluation.FIELD_FUNCTION,
+				Extra: &dvevaluation.DvFunction{
+					Fn: Promise_finally,
+				},
+			},
+		},
+		Kind: dvevaluation.FIELD_OBJECT,
+	}
+	dvevaluation.PromiseMaster.Fields = []*dvevaluation.DvVariable{
+		{
+			Name: []byte("resolve"),
+			Kind: dvevaluation.FIELD_FUNCTION,
+			Extra: &dvevaluation.DvFunction{
+				Fn: Promise_resolve,
+			},
+		},
+		{
+			Name: []byte("reject"),
+			Kind: dvevaluation.FIELD_FUNCTION,
+			Extra: &dvevaluation.DvFunction{
+				Fn: Promise_reject,
+			},
+		},
+		{
+			Name: []byte("all"),
+			Kind: dvevaluation.FIELD_FUNCTION,
+			Extra: &dvevaluation.DvFunction{
+				Fn: Promise_all,
+			},
+		},
+		{
+			Name: []byte("allSettled"),
+			Kind: dvevaluation.FIELD_FUNCTION,
+			Extra: &dvevaluation.DvFunction{
+				Fn: Promise_allSettled,
+			},
+		},
+		{
+			Name: []byte("race"),
+			Kind: dvevaluation.FIELD_FUNCTION,
+			Extra: &dvevaluation.DvFunction{
+				Fn: Promise_race,
+			},
+		},
+		{
+			Name: []byte("any"),
+			Kind: dvevaluation.FIELD_FUNCTION,
+			Extra: &dvevaluation.DvFunction{
+				Fn: Promise_any,
+			},
+		},
+	}
+	dvevaluation.PromiseMaster.Kind = dvevaluation.FIELD_FUNCTION
+	dvevaluation.PromiseMaster.Extra = &dvevaluation.DvFunction{
+		Fn: Promise_constructor,
+	}
+}
+
+func Promise_constructor(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	if len(params) == 0 || !dvevaluation.IsCallableValue(params[0]) {
+		var executor interface{}
+		if len(params) > 0 {
+			executor = params[0]
+		}
+		return nil, dvevaluation.NewScriptError(dvevaluation.ERROR_KIND_TYPE, "Promise resolver "+dvevaluation.AnyToString(executor)+" is not a function")
+	}
+	res, p := dvevaluation.NewPromise(context)
+	resolve, reject := p.ResolvingFunctions()
+	_, err := dvevaluation.ExecuteAnyFunction(context, params[0], nil, []interface{}{resolve, reject})
+	if err != nil {
+		_, err = dvevaluation.ExecuteAnyFunction(context, reject, nil, []interface{}{dvevaluation.GetCatchValue(err)})
+	}
+	return res, err
+}
+
+func getPromiseOfThis(thisVariable interface{}, method string) (*dvevaluation.JsPromise, error) {
+	p := dvevaluation.GetJsPromise(thisVariable)
+	if p == nil {
+		return nil, dvevaluation.NewScriptError(dvevaluation.ERROR_KIND_TYPE, "Method Promise.prototype."+method+" called on incompatible receiver "+dvevaluation.AnyToString(thisVariable))
+	}
+	return p, nil
+}
+
+func promiseParam(params []interface{}, index int) interface{} {
+	if index >= len(params) {
+		return nil
+	}
+	return params[index]
+}
+
+func Promise_then(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	p, err := getPromiseOfThis(thisVariable, "then")
+	if err != nil {
+		return nil, err
+	}
+	return p.Then(context, promiseParam(params, 0), promiseParam(params, 1)), nil
+}
+
+func Promise_catch(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	p, err := getPromiseOfThis(thisVariable, "catch")
+	if err != nil {
+		return nil, err
+	}
+	return p.Then(context, nil, promiseParam(params, 0)), nil
+}
+
+// Promise_finally passes the original outcome, unless the callback throws or returns a rejected promise
+func Promise_finally(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	p, err := getPromiseOfThis(thisVariable, "finally")
+	if err != nil {
+		return nil, err
+	}
+	fn := promiseParam(params, 0)
+	if !dvevaluation.IsCallableValue(fn) {
+		return p.Then(context, nil, nil), nil
+	}
+	res, q := dvevaluation.NewPromise(context)
+	ctx := dvevaluation.ForkAsyncContext(context)
+	p.OnSettled(func() error {
+		r, err := dvevaluation.ExecuteAnyFunction(ctx, fn, nil, nil)
+		if err != nil {
+			q.Reject(dvevaluation.GetCatchValue(err))
+			return nil
+		}
+		waiting := dvevaluation.PromiseResolve(ctx, r)
+		waiting.OnSettled(func() error {
+			if waiting.State == dvevaluation.PROMISE_REJECTED {
+				q.Reject(waiting.Value)
+			} else if p.State == dvevaluation.PROMISE_REJECTED {
+				q.Reject(p.Value)
+			} else {
+				q.Resolve(p.Value)
+			}
+			return nil
+		})
+		return nil
+	})
+	return res, nil
+}
+
+func Promise_resolve(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	v := promiseParam(params, 0)
+	if p := dvevaluation.GetJsPromise(v); p != nil {
+		return v, nil
+	}
+	res, p := dvevaluation.NewPromise(context)
+	p.Resolve(v)
+	return res, nil
+}
+
+func Promise_reject(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	res, p := dvevaluation.NewPromise(context)
+	p.Reject(promiseParam(params, 0))
+	return res, nil
+}
+
+// getPromiseList converts all elements of the iterable argument to promises
+func getPromiseList(context *dvgrammar.ExpressionContext, params []interface{}) ([]*dvevaluation.JsPromise, error) {
+	items, err := dvevaluation.GetIterableElements(context, promiseParam(params, 0))
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*dvevaluation.JsPromise, len(items))
+	for i, item := range items {
+		res[i] = dvevaluation.PromiseResolve(context, item)
+	}
+	return res, nil
+}
+
+func promiseValueToField(v interface{}) *dvevaluation.DvVariable {
+	dv := dvevaluation.AnyToDvVariable(v)
+	if dv == nil {
+		dv = &dvevaluation.DvVariable{Kind: dvevaluation.FIELD_UNDEFINED}
+	}
+	return dv
+}
+
+func Promise_all(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	list, err := getPromiseList(context, params)
+	if err != nil {
+		return nil, err
+	}
+	res, q := dvevaluation.NewPromise(context)
+	n := len(list)
+	fields := make([]*dvevaluation.DvVariable, n)
+	left := n
+	for i, p := range list {
+		index, item := i, p
+		item.OnSettled(func() error {
+			if item.State == dvevaluation.PROMISE_REJECTED {
+				q.Reject(item.Value)
+				return nil
+			}
+			fields[index] = promiseValueToField(item.Value)
+			left--
+			if left == 0 {
+				q.Resolve(&dvevaluation.DvVariable{Kind: dvevaluation.FIELD_ARRAY, Fields: fields})
+			}
+			return nil
+		})
+	}
+	if n == 0 {
+		q.Resolve(&dvevaluation.DvVariable{Kind: dvevaluation.FIELD_ARRAY, Fields: fields})
+	}
+	return res, nil
+}
+
+func Promise_allSettled(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	list, err := getPromiseList(context, params)
+	if err != nil {
+		return nil, err
+	}
+	res, q := dvevaluation.NewPromise(context)
+	n := len(list)
+	fields := make([]*dvevaluation.DvVariable, n)
+	left := n
+	for i, p := range list {
+		index, item := i, p
+		item.OnSettled(func() error {
+			outcome := &dvevaluation.DvVariable{Kind: dvevaluation.FIELD_OBJECT}
+			value := promiseValueToField(item.Value)
+			copied := *value
+			if item.State == dvevaluation.PROMISE_FULFILLED {
+				copied.Name = []byte("value")
+				outcome.Fields = []*dvevaluation.DvVariable{
+					{Name: []byte("status"), Kind: dvevaluation.FIELD_STRING, Value: []byte("fulfilled")},
+					&copied,
+				}
+			} else {
+				copied.Name = []byte("reason")
+				outcome.Fields = []*dvevaluation.DvVariable{
+					{Name: []byte("status"), Kind: dvevaluation.FIELD_STRING, Value: []byte("rejected")},
+					&copied,
+				}
+			}
+			fields[index] = outcome
+			left--
+			if left == 0 {
+				q.Resolve(&dvevaluation.DvVariable{Kind: dvevaluation.FIELD_ARRAY, Fields: fields})
+			}
+			return nil
+		})
+	}
+	if n == 0 {
+		q.Resolve(&dvevaluation.DvVariable{Kind: dvevaluation.FIELD_ARRAY, Fields: fields})
+	}
+	return res, nil
+}
+
+func Promise_race(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	list, err := getPromiseList(context, params)
+	if err != nil {
+		return nil, err
+	}
+	res, q := dvevaluation.NewPromise(context)
+	for _, p := range list {
+		item := p
+		item.OnSettled(func() error {
+			if item.State == dvevaluation.PROMISE_REJECTED {
+				q.Reject(item.Value)
+			} else {
+				q.Resolve(item.Value)
+			}
+			return nil
+		})
+	}
+	return res, nil
+}
+
+func Promise_any(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	list, err := getPromiseList(context, params)
+	if err != nil {
+		return nil, err
+	}
+	res, q := dvevaluation.NewPromise(context)
+	n := len(list)
+	reasons := make([]*dvevaluation.DvVariable, n)
+	left := n
+	for i, p := range list {
+		index, item := i, p
+		item.OnSettled(func() error {
+			if item.State == dvevaluation.PROMISE_FULFILLED {
+				q.Resolve(item.Value)
+				return nil
+			}
+			reasons[index] = promiseValueToField(item.Value)
+			left--
+			if left == 0 {
+				q.Reject(createAggregateError(reasons))
+			}
+			return nil
+		})
+	}
+	if n == 0 {
+		q.Reject(createAggregateError(reasons))
+	}
+	return res, nil
+}
+
+func createAggregateError(reasons []*dvevaluation.DvVariable) *dvevaluation.DvVariable {
+	err := dvevaluation.NewErrorObject("AggregateError", "All promises were rejected")
+	err.Fields = append(err.Fields, &dvevaluation.DvVariable{Name: []byte("errors"), Kind: dvevaluation.FIELD_ARRAY, Fields: reasons})
+	return err
+}
@@ -0,0 +1,43 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvjsmaster
+
+import (
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
+	"strconv"
+	"sync/atomic"
+)
+
+// Symbols are represented by their unique property keys, because the object properties are keyed by strings;
+// the keys start with @@, so they do not collide with ordinary property names
+var symbolCounter int64
+
+func symbol_init() {
+	dvevaluation.RegisterMasterVariable("Symbol", &dvevaluation.DvVariable{
+		Fields: []*dvevaluation.DvVariable{
+			{
+				Name:  []byte("iterator"),
+				Kind:  dvevaluation.FIELD_STRING,
+				Value: []byte(dvevaluation.IteratorMethodKey),
+			},
+		},
+		Kind: dvevaluation.FIELD_FUNCTION,
+		Extra: &dvevaluation.DvFunction{
+			Fn: Symbol_constructor,
+		},
+	})
+}
+
+func Symbol_constructor(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	description := ""
+	if len(params) > 0 && !dvevaluation.IsNullOrUndefined(params[0]) {
+		description = dvevaluation.AnyToString(params[0])
+	}
+	n := atomic.AddInt64(&symbolCounter, 1)
+	key := "@@" + description + "@" + strconv.FormatInt(n, 10)
+	return &dvevaluation.DvVariable{Kind: dvevaluation.FIELD_STRING, Value: []byte(key)}, nil
+}
@@ -0,0 +1,81 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvjsmaster
+
+import (
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
+	"time"
+)
+
+// Timers belong to the evaluation, which created them: the evaluation is not finished
+// until all its timeouts are fired and all its intervals are cleared
+func timers_init() {
+	timerFunctions := map[string]dvevaluation.DvFunc{
+		"setTimeout":     Window_setTimeout,
+		"setInterval":    Window_setInterval,
+		"clearTimeout":   Window_clearTimer,
+		"clearInterval":  Window_clearTimer,
+		"queueMicrotask": Window_queueMicrotask,
+	}
+	for name, fn := range timerFunctions {
+		dvevaluation.RegisterMasterVariable(name, &dvevaluation.DvVariable{
+			Kind: dvevaluation.FIELD_FUNCTION,
+			Extra: &dvevaluation.DvFunction{
+				Fn: fn,
+			},
+		})
+	}
+}
+
+func setTimer(context *dvgrammar.ExpressionContext, params []interface{}, repeat bool) (interface{}, error) {
+	fn := promiseParam(params, 0)
+	if !dvevaluation.IsCallableValue(fn) {
+		return nil, dvevaluation.NewScriptError(dvevaluation.ERROR_KIND_TYPE, "The callback must be a function, received "+dvevaluation.AnyToString(fn))
+	}
+	var delay time.Duration
+	if len(params) > 1 {
+		delay = time.Duration(dvevaluation.AnyToNumber(params[1]) * float64(time.Millisecond))
+	}
+	var args []interface{}
+	if len(params) > 2 {
+		args = params[2:]
+	}
+	ctx := dvevaluation.ForkAsyncContext(context)
+	id := dvevaluation.GetEventLoop(context).SetTimer(delay, repeat, func() error {
+		_, err := dvevaluation.ExecuteAnyFunction(ctx, fn, nil, args)
+		return err
+	})
+	return id, nil
+}
+
+func Window_setTimeout(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return setTimer(context, params, false)
+}
+
+func Window_setInterval(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	return setTimer(context, params, true)
+}
+
+func Window_clearTimer(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	if len(params) > 0 && !dvevaluation.IsNullOrUndefined(params[0]) {
+		dvevaluation.GetEventLoop(context).ClearTimer(int(dvevaluation.AnyToNumber(params[0])))
+	}
+	return nil, nil
+}
+
+func Window_queueMicrotask(context *dvgrammar.ExpressionContext, thisVariable interface{}, params []interface{}) (interface{}, error) {
+	fn := promiseParam(params, 0)
+	if !dvevaluation.IsCallableValue(fn) {
+		return nil, dvevaluation.NewScriptError(dvevaluation.ERROR_KIND_TYPE, "The callback must be a function, received "+dvevaluation.AnyToString(fn))
+	}
+	ctx := dvevaluation.ForkAsyncContext(context)
+	dvevaluation.GetEventLoop(context).EnqueueJob(func() error {
+		_, err := dvevaluation.ExecuteAnyFunction(ctx, fn, nil, nil)
+		return err
+	})
+	return nil, nil
+}
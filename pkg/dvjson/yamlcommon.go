@@ -11,6 +11,11 @@ func PrintToJsonAtLevel(dvEntry *dvevaluation.DvVariable, res []byte, level int,
 	if dvEntry == nil {
 		dvEntry = &dvevaluation.DvVariable{Kind: dvevaluation.FIELD_NULL}
 	}
+	if c := dvevaluation.GetDvCollection(dvEntry); c != nil {
+		dvEntry = c.ToPlainVariable()
+	} else if p, ok := dvEntry.Extra.(dvevaluation.JsonConvertible); ok {
+		dvEntry = p.ToJsonVariable()
+	}
 	n := indent * level
 	nextN := n + indent
 	nextLevel := level + 1
@@ -42,7 +47,10 @@ func PrintToJsonAtLevel(dvEntry *dvevaluation.DvVariable, res []byte, level int,
 
 			for i := 0; i < arrayAmount; i++ {
 				if i > 0 {
-					res = append(res, ',', byte(10))
+					res = append(res, ',')
+					if indent > 0 {
+						res = append(res, byte(10))
+					}
 				}
 				if indent > 0 {
 					res = append(res, nextIndentBuf...)
@@ -71,7 +79,10 @@ func PrintToJsonAtLevel(dvEntry *dvevaluation.DvVariable, res []byte, level int,
 			entryList := dvEntry.Fields
 			for _, v := range entryList {
 				if isNext {
-					res = append(res, ',', byte(10))
+					res = append(res, ',')
+					if indent > 0 {
+						res = append(res, byte(10))
+					}
 				} else {
 					isNext = true
 				}
@@ -79,7 +90,10 @@ func PrintToJsonAtLevel(dvEntry *dvevaluation.DvVariable, res []byte, level int,
 					res = append(res, nextIndentBuf...)
 				}
 				res = appendJsonEscapedString(res, v.Name)
-				res = append(res, ':', ' ')
+				res = append(res, ':')
+				if indent > 0 {
+					res = append(res, ' ')
+				}
 				res = PrintToJsonAtLevel(v, res, nextLevel, indent, true)
 			}
 			if indent > 0 {
@@ -162,6 +176,11 @@ func PrintToYamlAtLevel(dvEntry *dvevaluation.DvVariable, res []byte, level int,
 	if dvEntry == nil {
 		return res
 	}
+	if c := dvevaluation.GetDvCollection(dvEntry); c != nil {
+		dvEntry = c.ToPlainVariable()
+	} else if p, ok := dvEntry.Extra.(dvevaluation.JsonConvertible); ok {
+		dvEntry = p.ToJsonVariable()
+	}
 	n := indent * level
 	nextLevel := level + 1
 	var indentBuf []byte
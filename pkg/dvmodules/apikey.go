@@ -0,0 +1,64 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvmodules
+
+import (
+	"strconv"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvlog"
+	"github.com/Dobryvechir/microcore/pkg/dvsecurity"
+)
+
+const AuthApiKey = "apikey"
+
+// authorizeApiKey authenticates the api key of the request and sets its identity (USER, ROLES and API_KEY),
+// otherwise it responds with 401, 429 (the rate limit is exceeded) or 500 and returns false
+func authorizeApiKey(ctx *dvcontext.RequestContext) bool {
+	var info *dvcontext.SecurityServerInfo
+	if ctx.Server != nil {
+		info = ctx.Server.SecurityInfo
+	}
+	m, err := dvsecurity.GetApiKeyManager(info)
+	if err != nil {
+		dvlog.PrintfError("Request %s cannot check api key: %v", ctx.Url, err)
+		ctx.HandleInternalServerError()
+		return false
+	}
+	key := m.ReadApiKey(ctx.Reader)
+	if key == "" {
+		dvlog.PrintfError("Request %s is unauthorized: no api key", ctx.Url)
+		ctx.HandleHttpError(401)
+		return false
+	}
+	apiKey, err := m.Authenticate(key)
+	if err != nil {
+		dvlog.PrintfError("Request %s is unauthorized: %v", ctx.Url, err)
+		if err == dvsecurity.ErrApiKeyInvalid || err == dvsecurity.ErrApiKeyExpired {
+			ctx.HandleHttpError(401)
+		} else {
+			ctx.HandleInternalServerError()
+		}
+		return false
+	}
+	if ok, retryAfter := m.Allow(apiKey); !ok {
+		if ctx.Headers == nil {
+			ctx.Headers = make(map[string][]string)
+		}
+		ctx.Headers["Retry-After"] = []string{strconv.Itoa(retryAfter)}
+		ctx.HandleHttpError(429)
+		return false
+	}
+	ctx.SetUser(apiKey.UserInfo())
+	roles := &dvevaluation.DvVariable{Kind: dvevaluation.FIELD_ARRAY, Fields: make([]*dvevaluation.DvVariable, len(apiKey.Roles))}
+	for i, role := range apiKey.Roles {
+		roles.Fields[i] = &dvevaluation.DvVariable{Kind: dvevaluation.FIELD_STRING, Value: []byte(role)}
+	}
+	storeRoles(ctx, roles)
+	ctx.PrimaryContextEnvironment.Set("API_KEY", apiKey.Info())
+	return true
+}
@@ -52,6 +52,7 @@ func ValidateRequest(validations []*dvcontext.ValidatePattern, environment *dvev
 
 func FireAction(action *dvcontext.DvAction, request *dvcontext.RequestContext) bool {
 	request.Action = action
+	request.ApplyExecutionLimits(action)
 	proc, ok := registeredActionProcessors[action.Typ]
 	if !ok {
 		dvlog.Printf("Action %s url %s has incorrect type %s", action.Name, action.Url, action.Typ)
@@ -67,15 +68,33 @@ func FireAction(action *dvcontext.DvAction, request *dvcontext.RequestContext) b
 		request.HandleInternalServerError()
 		return true
 	}
-	if len(action.Roles) > 0 {
-		action.Auth = "required"
-	} else {
-		action.Auth = strings.ToLower(action.Auth)
+	auth := strings.ToLower(action.Auth)
+	if len(action.Roles) > 0 && auth != AuthApiKey {
+		auth = "required"
+	}
+	if auth == "" && action.Policy != "" {
+		auth = "optional"
 	}
-	if action.Auth != "" {
-		roles, err := AnalyzeAuthToken(request)
+	var claims *dvevaluation.DvVariable
+	if auth == AuthApiKey {
+		if !authorizeApiKey(request) {
+			return true
+		}
+		if !checkRoles(request, action.Roles) {
+			request.HandleHttpError(403)
+			return true
+		}
+	} else if auth != "" {
+		tokenDv, err := readAuthToken(request)
+		var roles *dvevaluation.DvVariable
+		if err == nil {
+			claims = tokenDv
+			storeTokenUser(request, tokenDv)
+			roles, err = readTokenRoles(request, tokenDv)
+		}
 		if err != nil {
-			if action.Auth == "required" {
+			if auth == "required" || err != ErrNoBearerToken && err != ErrNoTokenRoles {
+				dvlog.PrintfError("Request %s is unauthorized: %v", request.Url, err)
 				request.HandleHttpError(401)
 				return true
 			}
@@ -108,34 +127,83 @@ func FireAction(action *dvcontext.DvAction, request *dvcontext.RequestContext) b
 		}
 		request.StoreStoringSession()
 	}
+	if request.User == nil {
+		request.SetUser(dvcontext.ReadSessionUser(request.Session))
+	}
+	if action.Policy != "" && !CheckActionPolicy(request, action.Policy, claims) {
+		if request.User.Registered {
+			request.HandleHttpError(403)
+		} else {
+			request.HandleHttpError(401)
+		}
+		return true
+	}
 	return proc(request)
 }
 
+var ErrNoBearerToken = errors.New("No bearer token in Authorization Header")
+var ErrNoTokenRoles = errors.New("Token has no roles")
+
+// AnalyzeAuthToken verifies the bearer token by the security settings of the server and returns its roles
 func AnalyzeAuthToken(ctx *dvcontext.RequestContext) (*dvevaluation.DvVariable, error) {
-	s := strings.TrimSpace(ctx.Reader.Header.Get("Authorization"))
-	if s == "" {
-		return nil, errors.New("No Authorization Header")
+	tokenDv, err := readAuthToken(ctx)
+	if err != nil {
+		return nil, err
 	}
+	return readTokenRoles(ctx, tokenDv)
+}
+
+// readAuthToken verifies the bearer token and returns its claims
+func readAuthToken(ctx *dvcontext.RequestContext) (*dvevaluation.DvVariable, error) {
+	s := strings.TrimSpace(ctx.Reader.Header.Get("Authorization"))
 	p := strings.Index(s, " ")
 	if p < 0 || strings.ToLower(s[:p]) != "bearer" {
-		return nil, errors.New("Unaccepted Authorization Header")
+		return nil, ErrNoBearerToken
 	}
 	tokenRaw := strings.TrimSpace(s[p+1:])
-	token, err := dvsecurity.DecodeMainTokenPart(tokenRaw)
+	var securityInfo *dvcontext.SecurityServerInfo
+	if ctx.Server != nil {
+		securityInfo = ctx.Server.SecurityInfo
+	}
+	verifier, err := dvsecurity.GetJwtVerifier(securityInfo)
 	if err != nil {
 		return nil, err
 	}
-	tokenDv, err := dvjson.JsonFullParser([]byte(token))
+	token, err := verifier.Verify(tokenRaw)
 	if err != nil {
 		return nil, err
 	}
+	return dvjson.JsonFullParser([]byte(token))
+}
+
+func readTokenRoles(ctx *dvcontext.RequestContext, tokenDv *dvevaluation.DvVariable) (*dvevaluation.DvVariable, error) {
 	roles, _, err := tokenDv.ReadPath("realm_access.roles", false, ctx.PrimaryContextEnvironment)
 	if err != nil || roles == nil || roles.Kind != dvevaluation.FIELD_ARRAY {
-		return nil, errors.New("Unaccepted token")
+		return nil, ErrNoTokenRoles
 	}
 	return roles, nil
 }
 
+// storeTokenUser sets the user of the request by the claims of the token
+func storeTokenUser(ctx *dvcontext.RequestContext, tokenDv *dvevaluation.DvVariable) {
+	user := &dvcontext.UserInfo{
+		Registered: true,
+		Id:         tokenDv.ReadSimpleChildValue("sub"),
+		Name:       tokenDv.ReadSimpleChildValue("preferred_username"),
+		Email:      tokenDv.ReadSimpleChildValue("email"),
+		TenantId:   tokenDv.ReadSimpleChildValue("tenant-id"),
+		Source:     dvcontext.UserSourceToken,
+	}
+	if user.Name == "" {
+		user.Name = user.Id
+	}
+	roles, _, err := tokenDv.ReadPath("realm_access.roles", false, ctx.PrimaryContextEnvironment)
+	if err == nil && roles != nil && roles.Kind == dvevaluation.FIELD_ARRAY {
+		user.Roles = roles.GetStringArrayValue()
+	}
+	ctx.SetUser(user)
+}
+
 func storeRoles(ctx *dvcontext.RequestContext, roles *dvevaluation.DvVariable) {
 	n := len(roles.Fields)
 	fields := make([]*dvevaluation.DvVariable, n)
@@ -0,0 +1,143 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvmodules
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvlog"
+)
+
+const (
+	PolicyEffectAllow = "allow"
+	PolicyEffectDeny  = "deny"
+)
+
+// CheckActionPolicy decides if the request is allowed by the named policy of the server,
+// claims are the claims of the verified token or nil; the unknown policy denies all requests,
+// the policy in dry run mode logs its decision and allows all requests
+func CheckActionPolicy(ctx *dvcontext.RequestContext, name string, claims *dvevaluation.DvVariable) bool {
+	var policy *dvcontext.ActionPolicy
+	if ctx.Server != nil && ctx.Server.ActionPolicies != nil {
+		policy = ctx.Server.ActionPolicies[name]
+	}
+	if policy == nil {
+		dvlog.PrintfError("Policy %s of request %s is not defined", name, ctx.Url)
+		return false
+	}
+	allowed, rule := EvaluateActionPolicy(ctx, policy, claims)
+	decision := "allowed"
+	if !allowed {
+		decision = "denied"
+	}
+	reason := "by default"
+	if rule >= 0 {
+		reason = "by rule " + strconv.Itoa(rule+1)
+	}
+	if policy.DryRun {
+		dvlog.PrintfFullOnly("Policy %s (dry run): %s %s is %s %s", name, ctx.Reader.Method, ctx.Url, decision, reason)
+		return true
+	}
+	if !allowed {
+		dvlog.PrintfError("Policy %s: %s %s is %s %s", name, ctx.Reader.Method, ctx.Url, decision, reason)
+	}
+	return allowed
+}
+
+// EvaluateActionPolicy returns the decision of the policy and the index of the decisive rule (-1 if no rule matches);
+// any matching deny rule wins over the matching allow rules
+func EvaluateActionPolicy(ctx *dvcontext.RequestContext, policy *dvcontext.ActionPolicy, claims *dvevaluation.DvVariable) (bool, int) {
+	allowRule := -1
+	for i, rule := range policy.Rules {
+		if rule == nil || !matchPolicyRule(ctx, rule, claims) {
+			continue
+		}
+		if !strings.EqualFold(rule.Effect, PolicyEffectAllow) {
+			return false, i
+		}
+		if allowRule < 0 {
+			allowRule = i
+		}
+	}
+	if allowRule >= 0 {
+		return true, allowRule
+	}
+	return strings.EqualFold(policy.Default, PolicyEffectAllow), -1
+}
+
+func matchPolicyRule(ctx *dvcontext.RequestContext, rule *dvcontext.PolicyRule, claims *dvevaluation.DvVariable) bool {
+	user := ctx.User
+	if user == nil {
+		user = &dvcontext.UserInfo{}
+	}
+	if len(rule.Methods) > 0 && !matchPolicyValue(rule.Methods, ctx.Reader.Method, true) {
+		return false
+	}
+	if len(rule.Roles) > 0 && !matchPolicyValues(rule.Roles, user.Roles) {
+		return false
+	}
+	if len(rule.Tenants) > 0 && (user.TenantId == "" || !matchPolicyValue(rule.Tenants, user.TenantId, false)) {
+		return false
+	}
+	for path, values := range rule.Claims {
+		if claims == nil || !matchPolicyValues(strings.Split(values, ","), readPolicyClaim(ctx, claims, path)) {
+			return false
+		}
+	}
+	for name, values := range rule.Params {
+		value, ok := ctx.UrlInlineParams[name]
+		if !ok {
+			value, ok = ctx.Queries[name]
+		}
+		if !ok || !matchPolicyValue(strings.Split(values, ","), value, false) {
+			return false
+		}
+	}
+	if rule.Condition != "" {
+		res, err := ctx.PrimaryContextEnvironment.EvaluateBooleanExpression(rule.Condition)
+		if err != nil {
+			dvlog.PrintfError("Policy condition %s failed: %v", rule.Condition, err)
+			return false
+		}
+		return res
+	}
+	return true
+}
+
+// readPolicyClaim returns the claim value, the array claim returns all its elements
+func readPolicyClaim(ctx *dvcontext.RequestContext, claims *dvevaluation.DvVariable, path string) []string {
+	v, _, err := claims.ReadPath(path, false, ctx.PrimaryContextEnvironment)
+	if err != nil || v == nil {
+		return nil
+	}
+	if v.Kind == dvevaluation.FIELD_ARRAY {
+		return v.GetStringArrayValue()
+	}
+	return []string{v.GetStringValue()}
+}
+
+// matchPolicyValues checks if any of values is one of patterns, "*" matches any non-empty value
+func matchPolicyValues(patterns []string, values []string) bool {
+	for _, value := range values {
+		if matchPolicyValue(patterns, value, false) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPolicyValue(patterns []string, value string, ignoreCase bool) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "*" && value != "" || pattern == value || ignoreCase && strings.EqualFold(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
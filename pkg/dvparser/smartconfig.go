@@ -722,6 +722,7 @@ func ReadPropertiesFileWithEnvironmentVariables(currentDir string, propertiesNam
 	initializeRegisteredFunctions()
 	GlobalPropertiesAsDvObject = dvevaluation.NewDvObjectFrom2Maps(nil, GlobalProperties)
 	dvevaluation.ProvideRootValues(GlobalPropertiesAsDvObject)
+	dvevaluation.ProvideModuleFinder(FindInGeneralPaths)
 	GeneralFilePaths = make([]string, 1, 4)
 	GeneralFilePaths[0] = currentDir
 	err := setFilePaths()
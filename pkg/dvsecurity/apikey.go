@@ -0,0 +1,342 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvsecurity
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvlog"
+)
+
+const (
+	ApiKeyHeaderDefault = "X-API-Key"
+	ApiKeyQueryDefault  = "api_key"
+	ApiKeyPrefix        = "mk_"
+
+	apiKeyRatePeriodDefault = 60
+)
+
+var ErrApiKeyInvalid = errors.New("api key is invalid")
+var ErrApiKeyExpired = errors.New("api key is expired")
+
+// ApiKeyManager authenticates the api keys, limits their request rate, creates and revokes them
+type ApiKeyManager struct {
+	Info    *dvcontext.ApiKeyInfo
+	Store   ApiKeyStore
+	mutex   sync.Mutex
+	windows map[string]*apiKeyWindow
+}
+
+// apiKeyWindow counts the requests of the key in the current period
+type apiKeyWindow struct {
+	start int64
+	count int
+}
+
+var apiKeyManagers = make(map[*dvcontext.SecurityServerInfo]*ApiKeyManager)
+var apiKeyManagersMutex sync.Mutex
+
+// GetApiKeyManager returns the api key manager of the security settings of the host server
+func GetApiKeyManager(security *dvcontext.SecurityServerInfo) (*ApiKeyManager, error) {
+	if security == nil || security.ApiKeys == nil {
+		return nil, errors.New("api keys are not configured in security settings")
+	}
+	apiKeyManagersMutex.Lock()
+	defer apiKeyManagersMutex.Unlock()
+	if m, ok := apiKeyManagers[security]; ok {
+		return m, nil
+	}
+	store, err := newApiKeyStore(security.ApiKeys)
+	if err != nil {
+		return nil, err
+	}
+	m := &ApiKeyManager{Info: security.ApiKeys, Store: store, windows: make(map[string]*apiKeyWindow)}
+	apiKeyManagers[security] = m
+	return m, nil
+}
+
+func HashApiKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ReadApiKey returns the api key of the request header or query parameter
+func (m *ApiKeyManager) ReadApiKey(r *http.Request) string {
+	header := m.Info.Header
+	if header == "" {
+		header = ApiKeyHeaderDefault
+	}
+	if key := strings.TrimSpace(r.Header.Get(header)); key != "" {
+		return key
+	}
+	query := m.Info.Query
+	if query == "" {
+		query = ApiKeyQueryDefault
+	}
+	if query == "-" {
+		return ""
+	}
+	return strings.TrimSpace(r.URL.Query().Get(query))
+}
+
+// Authenticate returns the stored key of the not expired api key
+func (m *ApiKeyManager) Authenticate(key string) (*ApiKey, error) {
+	if !strings.HasPrefix(key, ApiKeyPrefix) {
+		return nil, ErrApiKeyInvalid
+	}
+	apiKey, err := m.Store.FindKey(HashApiKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == nil {
+		return nil, ErrApiKeyInvalid
+	}
+	if apiKey.ExpiresAt > 0 && apiKey.ExpiresAt < GetCurrentSeconds() {
+		return nil, ErrApiKeyExpired
+	}
+	return apiKey, nil
+}
+
+// Allow counts the request of the key and returns false with the seconds to wait if its rate limit is exceeded,
+// the limit of the key or the default limit of the settings is the number of requests per period (60 seconds by default)
+func (m *ApiKeyManager) Allow(apiKey *ApiKey) (bool, int) {
+	limit, period := apiKey.RateLimit, apiKey.RatePeriod
+	if limit <= 0 {
+		limit = m.Info.RateLimit
+	}
+	if limit <= 0 {
+		return true, 0
+	}
+	if period <= 0 {
+		period = m.Info.RatePeriod
+	}
+	if period <= 0 {
+		period = apiKeyRatePeriodDefault
+	}
+	now := GetCurrentSeconds()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	w := m.windows[apiKey.Id]
+	if w == nil || now >= w.start+int64(period) {
+		w = &apiKeyWindow{start: now}
+		m.windows[apiKey.Id] = w
+	}
+	if w.count >= limit {
+		return false, int(w.start + int64(period) - now)
+	}
+	w.count++
+	return true, 0
+}
+
+// Create stores the new key and returns it, the key itself is not stored and cannot be read later;
+// expiresIn is in seconds, 0 means the key never expires
+func (m *ApiKeyManager) Create(name string, roles []string, tenantId string, expiresIn int64, rateLimit int, ratePeriod int) (string, *ApiKey, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return "", nil, err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return "", nil, err
+	}
+	key := ApiKeyPrefix + secret
+	now := GetCurrentSeconds()
+	apiKey := &ApiKey{
+		Id:         id,
+		Hash:       HashApiKey(key),
+		Name:       name,
+		Roles:      roles,
+		TenantId:   tenantId,
+		RateLimit:  rateLimit,
+		RatePeriod: ratePeriod,
+		CreatedAt:  now,
+	}
+	if expiresIn > 0 {
+		apiKey.ExpiresAt = now + expiresIn
+	}
+	if err = m.Store.SaveKey(apiKey); err != nil {
+		return "", nil, err
+	}
+	return key, apiKey, nil
+}
+
+// Revoke deletes the key by its id and returns false if there is no such key
+func (m *ApiKeyManager) Revoke(id string) (bool, error) {
+	ok, err := m.Store.DeleteKey(id)
+	if ok {
+		m.mutex.Lock()
+		delete(m.windows, id)
+		m.mutex.Unlock()
+	}
+	return ok, err
+}
+
+// Info returns the key without its hash
+func (apiKey *ApiKey) Info() map[string]interface{} {
+	roles := apiKey.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	return map[string]interface{}{
+		"id":          apiKey.Id,
+		"name":        apiKey.Name,
+		"roles":       roles,
+		"tenant_id":   apiKey.TenantId,
+		"expires_at":  apiKey.ExpiresAt,
+		"rate_limit":  apiKey.RateLimit,
+		"rate_period": apiKey.RatePeriod,
+		"created_at":  apiKey.CreatedAt,
+	}
+}
+
+// UserInfo returns the identity of the request with the key
+func (apiKey *ApiKey) UserInfo() *dvcontext.UserInfo {
+	return &dvcontext.UserInfo{
+		Registered: true,
+		Id:         apiKey.Id,
+		Name:       apiKey.Name,
+		Roles:      apiKey.Roles,
+		TenantId:   apiKey.TenantId,
+		Source:     dvcontext.UserSourceApiKey,
+	}
+}
+
+/************************ Admin End Points **************************************/
+
+// readApiKeyForm reads the parameters of the json or url encoded body, arrays are joined by comma
+func readApiKeyForm(ctx *dvcontext.RequestContext) map[string]string {
+	res := make(map[string]string)
+	s := strings.TrimSpace(string(ctx.Input))
+	if strings.HasPrefix(s, "{") {
+		values := make(map[string]interface{})
+		json.Unmarshal([]byte(s), &values)
+		for k, v := range values {
+			switch t := v.(type) {
+			case string:
+				res[k] = t
+			case float64:
+				res[k] = strconv.FormatInt(int64(t), 10)
+			case []interface{}:
+				items := make([]string, 0, len(t))
+				for _, item := range t {
+					if str, ok := item.(string); ok {
+						items = append(items, str)
+					}
+				}
+				res[k] = strings.Join(items, ",")
+			}
+		}
+		return res
+	}
+	form, _ := url.ParseQuery(s)
+	for k := range form {
+		res[k] = form.Get(k)
+	}
+	return res
+}
+
+func setApiKeyError(ctx *dvcontext.RequestContext, status int, err error) {
+	if status == http.StatusInternalServerError {
+		dvlog.PrintfError("Api key request %s failed: %v", ctx.Url, err)
+		err = errors.New("api key request failed")
+	}
+	setOAuthResult(ctx, status, map[string]string{"error": err.Error()})
+}
+
+func getApiKeyManagerOfRequest(ctx *dvcontext.RequestContext) (*ApiKeyManager, error) {
+	var info *dvcontext.SecurityServerInfo
+	if ctx.Server != nil {
+		info = ctx.Server.SecurityInfo
+	}
+	return GetApiKeyManager(info)
+}
+
+// ApiKeyCreateByRequestEndPointHandler creates the key by name, roles, tenant_id, expires_in (seconds),
+// rate_limit and rate_period; the response (201) has the key, which is shown only once
+func ApiKeyCreateByRequestEndPointHandler(ctx *dvcontext.RequestContext) bool {
+	m, err := getApiKeyManagerOfRequest(ctx)
+	if err != nil {
+		setApiKeyError(ctx, http.StatusInternalServerError, err)
+		return true
+	}
+	form := readApiKeyForm(ctx)
+	name := strings.TrimSpace(form["name"])
+	expiresIn, err1 := strconv.ParseInt("0"+form["expires_in"], 10, 64)
+	rateLimit, err2 := strconv.Atoi("0" + form["rate_limit"])
+	ratePeriod, err3 := strconv.Atoi("0" + form["rate_period"])
+	if name == "" || err1 != nil || err2 != nil || err3 != nil {
+		setApiKeyError(ctx, http.StatusBadRequest, errors.New("name is required, expires_in, rate_limit and rate_period must be numbers"))
+		return true
+	}
+	key, apiKey, err := m.Create(name, splitOAuthList(form["roles"]), form["tenant_id"], expiresIn, rateLimit, ratePeriod)
+	if err != nil {
+		setApiKeyError(ctx, http.StatusInternalServerError, err)
+		return true
+	}
+	result := apiKey.Info()
+	result["key"] = key
+	setOAuthResult(ctx, http.StatusCreated, result)
+	return true
+}
+
+// ApiKeyListByRequestEndPointHandler returns the keys without their hashes
+func ApiKeyListByRequestEndPointHandler(ctx *dvcontext.RequestContext) bool {
+	m, err := getApiKeyManagerOfRequest(ctx)
+	var keys []*ApiKey
+	if err == nil {
+		keys, err = m.Store.ListKeys()
+	}
+	if err != nil {
+		setApiKeyError(ctx, http.StatusInternalServerError, err)
+		return true
+	}
+	result := make([]map[string]interface{}, len(keys))
+	for i, key := range keys {
+		result[i] = key.Info()
+	}
+	setOAuthResult(ctx, http.StatusOK, result)
+	return true
+}
+
+// ApiKeyRevokeByRequestEndPointHandler deletes the key by id of the url or of the body
+func ApiKeyRevokeByRequestEndPointHandler(ctx *dvcontext.RequestContext) bool {
+	m, err := getApiKeyManagerOfRequest(ctx)
+	if err != nil {
+		setApiKeyError(ctx, http.StatusInternalServerError, err)
+		return true
+	}
+	id := ctx.UrlInlineParams["id"]
+	if id == "" {
+		id = readApiKeyForm(ctx)["id"]
+	}
+	ok, err := m.Revoke(id)
+	if err != nil {
+		setApiKeyError(ctx, http.StatusInternalServerError, err)
+	} else if !ok {
+		setApiKeyError(ctx, http.StatusNotFound, errors.New("api key "+id+" is not found"))
+	} else {
+		setOAuthResult(ctx, http.StatusOK, map[string]string{})
+	}
+	return true
+}
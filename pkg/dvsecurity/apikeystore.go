@@ -0,0 +1,242 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvsecurity
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvdbmanager"
+)
+
+const (
+	ApiKeyStoreFile  = "file"
+	ApiKeyStoreTable = "table"
+	ApiKeyStoreSql   = "sql"
+
+	apiKeysTableDefault = "api_keys"
+)
+
+// ApiKey is the stored api key, only the hash (sha256) of the key itself is kept
+type ApiKey struct {
+	Id         string   `json:"id"`
+	Hash       string   `json:"hash"`
+	Name       string   `json:"name"`
+	Roles      []string `json:"roles"`
+	TenantId   string   `json:"tenant_id"`
+	ExpiresAt  int64    `json:"expires_at"`
+	RateLimit  int      `json:"rate_limit"`
+	RatePeriod int      `json:"rate_period"`
+	CreatedAt  int64    `json:"created_at"`
+}
+
+// ApiKeyStore keeps the api keys; FindKey returns nil if the key is not found,
+// DeleteKey returns false if the key was not present
+type ApiKeyStore interface {
+	FindKey(hash string) (*ApiKey, error)
+	SaveKey(key *ApiKey) error
+	ListKeys() ([]*ApiKey, error)
+	DeleteKey(id string) (bool, error)
+}
+
+type ApiKeyStoreProvider func(info *dvcontext.ApiKeyInfo) (ApiKeyStore, error)
+
+var apiKeySqlStoreProvider ApiKeyStoreProvider
+
+// RegisterApiKeySqlStore sets the provider of the store in the sql connection (dvdbdata registers it)
+func RegisterApiKeySqlStore(provider ApiKeyStoreProvider) bool {
+	apiKeySqlStoreProvider = provider
+	return true
+}
+
+// GetApiKeyTableName returns the table (or the sql table) of the api keys
+func GetApiKeyTableName(info *dvcontext.ApiKeyInfo) string {
+	if info.Table == "" {
+		return apiKeysTableDefault
+	}
+	return info.Table
+}
+
+// GetApiKeyStoreKind returns the kind of the store: sql if the connection is set,
+// file if the file is set, otherwise dvdbmanager table
+func GetApiKeyStoreKind(info *dvcontext.ApiKeyInfo) string {
+	switch {
+	case info.Store != "":
+		return info.Store
+	case info.Connection != "":
+		return ApiKeyStoreSql
+	case info.File != "":
+		return ApiKeyStoreFile
+	}
+	return ApiKeyStoreTable
+}
+
+func newApiKeyStore(info *dvcontext.ApiKeyInfo) (ApiKeyStore, error) {
+	switch kind := GetApiKeyStoreKind(info); kind {
+	case ApiKeyStoreFile:
+		return newApiKeyFileStore(info.File)
+	case ApiKeyStoreTable:
+		return &apiKeyTableStore{table: GetApiKeyTableName(info)}, nil
+	case ApiKeyStoreSql:
+		if apiKeySqlStoreProvider == nil {
+			return nil, errors.New("sql store of api keys is not registered")
+		}
+		return apiKeySqlStoreProvider(info)
+	default:
+		return nil, errors.New("unknown store of api keys " + kind)
+	}
+}
+
+// NewApiKey creates the key by the columns id, hash, name, roles (separated by comma), tenant_id,
+// expires_at, rate_limit, rate_period and created_at
+func NewApiKey(fields map[string]string) *ApiKey {
+	key := &ApiKey{
+		Id:       fields["id"],
+		Hash:     fields["hash"],
+		Name:     fields["name"],
+		Roles:    splitOAuthList(fields["roles"]),
+		TenantId: fields["tenant_id"],
+	}
+	key.ExpiresAt, _ = strconv.ParseInt(fields["expires_at"], 10, 64)
+	key.CreatedAt, _ = strconv.ParseInt(fields["created_at"], 10, 64)
+	key.RateLimit, _ = strconv.Atoi(fields["rate_limit"])
+	key.RatePeriod, _ = strconv.Atoi(fields["rate_period"])
+	return key
+}
+
+func sortApiKeys(keys []*ApiKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt < keys[j].CreatedAt || keys[i].CreatedAt == keys[j].CreatedAt && keys[i].Id < keys[j].Id
+	})
+}
+
+// apiKeyFileStore keeps the keys in the json file, the file is read once and written at each change
+type apiKeyFileStore struct {
+	fileName string
+	keys     []*ApiKey
+	mutex    sync.Mutex
+}
+
+func newApiKeyFileStore(fileName string) (ApiKeyStore, error) {
+	s := &apiKeyFileStore{fileName: fileName}
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &s.keys); err != nil {
+		return nil, errors.New("api keys file " + fileName + " is wrong: " + err.Error())
+	}
+	return s, nil
+}
+
+func (s *apiKeyFileStore) write(keys []*ApiKey) error {
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(s.fileName, data, 0600); err != nil {
+		return err
+	}
+	s.keys = keys
+	return nil
+}
+
+func (s *apiKeyFileStore) FindKey(hash string) (*ApiKey, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, key := range s.keys {
+		if key != nil && key.Hash == hash {
+			return key, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *apiKeyFileStore) SaveKey(key *ApiKey) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.write(append(append(make([]*ApiKey, 0, len(s.keys)+1), s.keys...), key))
+}
+
+func (s *apiKeyFileStore) ListKeys() ([]*ApiKey, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	keys := make([]*ApiKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		if key != nil {
+			keys = append(keys, key)
+		}
+	}
+	sortApiKeys(keys)
+	return keys, nil
+}
+
+func (s *apiKeyFileStore) DeleteKey(id string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	keys := make([]*ApiKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		if key != nil && key.Id != id {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == len(s.keys) {
+		return false, nil
+	}
+	return true, s.write(keys)
+}
+
+// apiKeyTableStore keeps the keys in dvdbmanager table with keyFirst "id"
+type apiKeyTableStore struct {
+	table string
+}
+
+func (s *apiKeyTableStore) FindKey(hash string) (*ApiKey, error) {
+	fields, err := findOAuthRecord(s.table, "hash", hash)
+	if err != nil || fields == nil {
+		return nil, err
+	}
+	return NewApiKey(fields), nil
+}
+
+func (s *apiKeyTableStore) SaveKey(key *ApiKey) error {
+	body, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	return dvdbmanager.RecordResultError(dvdbmanager.RecordCreate(s.table, string(body), key.Id))
+}
+
+func (s *apiKeyTableStore) ListKeys() ([]*ApiKey, error) {
+	all, err := dvdbmanager.RecordReadAll(s.table)
+	if err != nil || all == nil {
+		return nil, err
+	}
+	keys := make([]*ApiKey, 0, len(all.Fields))
+	for _, record := range all.Fields {
+		if record != nil {
+			keys = append(keys, NewApiKey(dvdbmanager.RecordToStringMap(record)))
+		}
+	}
+	sortApiKeys(keys)
+	return keys, nil
+}
+
+func (s *apiKeyTableStore) DeleteKey(id string) (bool, error) {
+	records, err := dvdbmanager.RecordFindByField(s.table, "id", id)
+	if err != nil || len(records) == 0 {
+		return false, err
+	}
+	return true, dvdbmanager.RecordResultError(dvdbmanager.RecordDelete(s.table, id))
+}
@@ -0,0 +1,245 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvsecurity
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dobryvechir/microcore/pkg/dvlog"
+)
+
+const (
+	jwksMinRefreshDefault = 30
+	jwksCacheTimeDefault  = 3600
+	jwksFetchTimeout      = 10 * time.Second
+	jwksMaxSize           = 1 << 20
+)
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jsonWebKeySet struct {
+	Keys []*jsonWebKey `json:"keys"`
+}
+
+// JwksKeySource loads the keys from the JWKS url or file and keeps them by kid; the keys are reloaded
+// after CacheTime or when the token has an unknown kid, but not more often than once in MinRefresh;
+// only one load runs at a time and it runs without the lock, the cached keys are served meanwhile
+type JwksKeySource struct {
+	Location    string
+	MinRefresh  time.Duration
+	CacheTime   time.Duration
+	client      *http.Client
+	mutex       sync.Mutex
+	keys        []*JwtKey
+	loaded      time.Time
+	lastAttempt time.Time
+	loading     chan struct{}
+}
+
+var jwkCurves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+func NewJwksKeySource(location string, minRefresh int, cacheTime int) *JwksKeySource {
+	if minRefresh <= 0 {
+		minRefresh = jwksMinRefreshDefault
+	}
+	if cacheTime <= 0 {
+		cacheTime = jwksCacheTimeDefault
+	}
+	return &JwksKeySource{
+		Location:   location,
+		MinRefresh: time.Duration(minRefresh) * time.Second,
+		CacheTime:  time.Duration(cacheTime) * time.Second,
+		client:     &http.Client{Timeout: jwksFetchTimeout},
+	}
+}
+
+func decodeJwkNumber(s string, name string) (*big.Int, error) {
+	data, err := DecodeJwtBase64(s)
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("jwk parameter " + name + " is wrong")
+	}
+	return new(big.Int).SetBytes([]byte(data)), nil
+}
+
+func parseJwk(jwk *jsonWebKey) (*JwtKey, error) {
+	key := &JwtKey{Kid: jwk.Kid, Alg: strings.ToUpper(jwk.Alg)}
+	if key.Alg != "" && jwtAlgorithms[key.Alg] == nil {
+		return nil, errors.New("unsupported algorithm " + jwk.Alg)
+	}
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeJwkNumber(jwk.N, "n")
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJwkNumber(jwk.E, "e")
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("jwk exponent is too big")
+		}
+		key.Key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		curve := jwkCurves[jwk.Crv]
+		if curve == nil {
+			return nil, errors.New("unsupported curve " + jwk.Crv)
+		}
+		x, err := decodeJwkNumber(jwk.X, "x")
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJwkNumber(jwk.Y, "y")
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("jwk point is not on curve " + jwk.Crv)
+		}
+		key.Key = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	default:
+		return nil, errors.New("unsupported key type " + jwk.Kty)
+	}
+	return key, nil
+}
+
+// ParseJwks reads the rsa and ec signature keys of the JWK set, other keys are skipped
+func ParseJwks(data []byte) ([]*JwtKey, error) {
+	set := &jsonWebKeySet{}
+	if err := json.Unmarshal(data, set); err != nil {
+		return nil, err
+	}
+	keys := make([]*JwtKey, 0, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk == nil || jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := parseJwk(jwk)
+		if err != nil {
+			dvlog.PrintfError("Jwk %s is skipped: %v", jwk.Kid, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (s *JwksKeySource) read() ([]byte, error) {
+	if !strings.HasPrefix(s.Location, "http://") && !strings.HasPrefix(s.Location, "https://") {
+		return ioutil.ReadFile(strings.TrimPrefix(s.Location, "file:"))
+	}
+	resp, err := s.client.Get(s.Location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("jwks " + s.Location + " responded " + resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, jwksMaxSize+1))
+	if err == nil && len(data) > jwksMaxSize {
+		err = errors.New("jwks " + s.Location + " is too big")
+	}
+	return data, err
+}
+
+// startLoad starts loading unless it is already in progress and returns the channel closed when it finishes,
+// it is called with the mutex locked
+func (s *JwksKeySource) startLoad(now time.Time) chan struct{} {
+	if s.loading == nil {
+		s.lastAttempt = now
+		s.loading = make(chan struct{})
+		go s.load(s.loading)
+	}
+	return s.loading
+}
+
+// load reads the keys again, the previous keys are kept if the keys cannot be read
+func (s *JwksKeySource) load(done chan struct{}) {
+	data, err := s.read()
+	var keys []*JwtKey
+	if err == nil {
+		keys, err = ParseJwks(data)
+	}
+	if err != nil {
+		dvlog.PrintfError("Cannot load jwks from %s: %v", s.Location, err)
+	}
+	s.mutex.Lock()
+	if err == nil {
+		s.keys = keys
+		s.loaded = time.Now()
+	}
+	s.loading = nil
+	s.mutex.Unlock()
+	close(done)
+}
+
+func (s *JwksKeySource) findKeys(kid string) []*JwtKey {
+	if kid == "" {
+		return s.keys
+	}
+	res := make([]*JwtKey, 0, 1)
+	for _, key := range s.keys {
+		if key.Kid == kid {
+			res = append(res, key)
+		}
+	}
+	return res
+}
+
+// GetKeys returns the keys with the kid (or all keys for the empty kid); the outdated keys are reloaded
+// in the background, the request waits for the load only if there are no keys yet or the kid is unknown
+func (s *JwksKeySource) GetKeys(kid string) []*JwtKey {
+	s.mutex.Lock()
+	now := time.Now()
+	canRefresh := now.Sub(s.lastAttempt) >= s.MinRefresh
+	var wait chan struct{}
+	if s.loaded.IsZero() {
+		if canRefresh || s.loading != nil {
+			wait = s.startLoad(now)
+		}
+	} else if canRefresh && now.Sub(s.loaded) >= s.CacheTime {
+		s.startLoad(now)
+		canRefresh = false
+	}
+	keys := s.findKeys(kid)
+	if wait == nil && len(keys) == 0 && kid != "" && (canRefresh || s.loading != nil) {
+		wait = s.startLoad(now)
+	}
+	s.mutex.Unlock()
+	if wait != nil {
+		<-wait
+		s.mutex.Lock()
+		keys = s.findKeys(kid)
+		s.mutex.Unlock()
+	}
+	return keys
+}
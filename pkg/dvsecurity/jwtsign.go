@@ -0,0 +1,159 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvsecurity
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+)
+
+// JwtSigner signs the tokens by the secret (HS algorithms), *rsa.PrivateKey (RS and PS algorithms)
+// or *ecdsa.PrivateKey (ES algorithms)
+type JwtSigner struct {
+	Kid       string
+	Alg       string
+	Key       interface{}
+	algorithm *jwtAlgorithm
+}
+
+// ParseJwtPrivateKey reads the PEM private key in PKCS8, PKCS1 (rsa) or SEC1 (ec) format
+func ParseJwtPrivateKey(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("private key must be in PEM format")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	return x509.ParsePKCS8PrivateKey(block.Bytes)
+}
+
+func defaultJwtAlgorithm(key interface{}) string {
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		return "RS256"
+	case *ecdsa.PrivateKey:
+		for alg, algorithm := range jwtAlgorithms {
+			if algorithm.curve == key.(*ecdsa.PrivateKey).Curve {
+				return alg
+			}
+		}
+		return ""
+	}
+	return "HS256"
+}
+
+// NewJwtSigner creates the signer by the secret or the private key ("file:" prefix means the PEM file),
+// the algorithm is HS256, RS256 or ES of the curve of the key if it is not specified
+func NewJwtSigner(info *dvcontext.JwtKeyInfo) (*JwtSigner, error) {
+	if info == nil {
+		return nil, errors.New("signing key is not specified")
+	}
+	signer := &JwtSigner{Kid: info.Kid, Alg: strings.ToUpper(info.Alg)}
+	if info.Secret != "" {
+		signer.Key = []byte(info.Secret)
+	} else {
+		data := []byte(info.PrivateKey)
+		if strings.HasPrefix(info.PrivateKey, "file:") {
+			var err error
+			data, err = ioutil.ReadFile(info.PrivateKey[5:])
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(strings.TrimSpace(string(data))) == 0 {
+			return nil, errors.New("either secret or private_key must be specified for signing key " + info.Kid)
+		}
+		key, err := ParseJwtPrivateKey(data)
+		if err != nil {
+			return nil, errors.New("signing key " + info.Kid + ": " + err.Error())
+		}
+		signer.Key = key
+	}
+	if signer.Alg == "" {
+		signer.Alg = defaultJwtAlgorithm(signer.Key)
+	}
+	signer.algorithm = jwtAlgorithms[signer.Alg]
+	if signer.algorithm == nil {
+		return nil, errors.New("unsupported algorithm " + info.Alg)
+	}
+	if !signer.VerificationKey().accepts(signer.Alg, signer.algorithm) {
+		return nil, errors.New("signing key " + info.Kid + " does not fit algorithm " + signer.Alg)
+	}
+	return signer, nil
+}
+
+// VerificationKey returns the key verifying the tokens of the signer
+func (signer *JwtSigner) VerificationKey() *JwtKey {
+	key := &JwtKey{Kid: signer.Kid, Alg: signer.Alg, Key: signer.Key}
+	switch signer.Key.(type) {
+	case *rsa.PrivateKey:
+		key.Key = &signer.Key.(*rsa.PrivateKey).PublicKey
+	case *ecdsa.PrivateKey:
+		key.Key = &signer.Key.(*ecdsa.PrivateKey).PublicKey
+	}
+	return key
+}
+
+func (signer *JwtSigner) sign(signed string) ([]byte, error) {
+	algorithm := signer.algorithm
+	if algorithm.family == jwtFamilyHmac {
+		h := hmac.New(algorithm.hash.New, signer.Key.([]byte))
+		h.Write([]byte(signed))
+		return h.Sum(nil), nil
+	}
+	h := algorithm.hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+	switch algorithm.family {
+	case jwtFamilyRsa:
+		return rsa.SignPKCS1v15(rand.Reader, signer.Key.(*rsa.PrivateKey), algorithm.hash, digest)
+	case jwtFamilyRsaPss:
+		options := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: algorithm.hash}
+		return rsa.SignPSS(rand.Reader, signer.Key.(*rsa.PrivateKey), algorithm.hash, digest, options)
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, signer.Key.(*ecdsa.PrivateKey), digest)
+	if err != nil {
+		return nil, err
+	}
+	size := (algorithm.curve.Params().BitSize + 7) / 8
+	return append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...), nil
+}
+
+// Sign returns the token with the claims
+func (signer *JwtSigner) Sign(claims map[string]interface{}) (string, error) {
+	header := map[string]string{"alg": signer.Alg, "typ": "JWT"}
+	if signer.Kid != "" {
+		header["kid"] = signer.Kid
+	}
+	headerData, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := EncodeJwtBase64(string(headerData)) + "." + EncodeJwtBase64(string(payload))
+	signature, err := signer.sign(signed)
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + EncodeJwtBase64(string(signature)), nil
+}
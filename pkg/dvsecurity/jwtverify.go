@@ -0,0 +1,382 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvsecurity
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvlog"
+)
+
+const (
+	jwtFamilyHmac   = 1
+	jwtFamilyRsa    = 2
+	jwtFamilyRsaPss = 3
+	jwtFamilyEcdsa  = 4
+
+	jwtClockSkewDefault = 60
+)
+
+type jwtAlgorithm struct {
+	family int
+	hash   crypto.Hash
+	curve  elliptic.Curve
+}
+
+var jwtAlgorithms = map[string]*jwtAlgorithm{
+	"HS256": {family: jwtFamilyHmac, hash: crypto.SHA256},
+	"HS384": {family: jwtFamilyHmac, hash: crypto.SHA384},
+	"HS512": {family: jwtFamilyHmac, hash: crypto.SHA512},
+	"RS256": {family: jwtFamilyRsa, hash: crypto.SHA256},
+	"RS384": {family: jwtFamilyRsa, hash: crypto.SHA384},
+	"RS512": {family: jwtFamilyRsa, hash: crypto.SHA512},
+	"PS256": {family: jwtFamilyRsaPss, hash: crypto.SHA256},
+	"PS384": {family: jwtFamilyRsaPss, hash: crypto.SHA384},
+	"PS512": {family: jwtFamilyRsaPss, hash: crypto.SHA512},
+	"ES256": {family: jwtFamilyEcdsa, hash: crypto.SHA256, curve: elliptic.P256()},
+	"ES384": {family: jwtFamilyEcdsa, hash: crypto.SHA384, curve: elliptic.P384()},
+	"ES512": {family: jwtFamilyEcdsa, hash: crypto.SHA512, curve: elliptic.P521()},
+}
+
+// JwtKey is the key verifying the signatures, the secret for HS algorithms,
+// *rsa.PublicKey for RS and PS algorithms or *ecdsa.PublicKey for ES algorithms;
+// the empty Alg allows all algorithms of the key type
+type JwtKey struct {
+	Kid string
+	Alg string
+	Key interface{}
+}
+
+// JwtVerifier verifies the signatures and the claims exp, nbf, iss and aud of the tokens,
+// the keys are either configured or loaded from jwks
+type JwtVerifier struct {
+	Keys      []*JwtKey
+	Jwks      *JwksKeySource
+	Issuers   []string
+	Audiences []string
+	ClockSkew int64
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+var jwtVerifiers = make(map[*dvcontext.SecurityServerInfo]*JwtVerifier)
+var jwtVerifiersMutex sync.Mutex
+var jwtNotConfiguredOnce sync.Once
+
+var ErrJwtNotConfigured = errors.New("jwt verification is not configured in security settings")
+
+// ParseJwtPublicKey reads the PEM public key, the PKCS1 rsa public key or the certificate
+func ParseJwtPublicKey(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("public key must be in PEM format")
+	}
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+func newJwtKey(info *dvcontext.JwtKeyInfo) (*JwtKey, error) {
+	key := &JwtKey{Kid: info.Kid, Alg: strings.ToUpper(info.Alg)}
+	if key.Alg != "" && jwtAlgorithms[key.Alg] == nil {
+		return nil, errors.New("unsupported algorithm " + info.Alg)
+	}
+	if info.Secret != "" {
+		key.Key = []byte(info.Secret)
+		return key, nil
+	}
+	data := []byte(info.PublicKey)
+	if strings.HasPrefix(info.PublicKey, "file:") {
+		var err error
+		data, err = ioutil.ReadFile(info.PublicKey[5:])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, errors.New("either secret or public_key must be specified for key " + info.Kid)
+	}
+	publicKey, err := ParseJwtPublicKey(data)
+	if err != nil {
+		return nil, errors.New("key " + info.Kid + ": " + err.Error())
+	}
+	switch publicKey.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		return nil, errors.New("key " + info.Kid + " is neither rsa nor ecdsa key")
+	}
+	key.Key = publicKey
+	return key, nil
+}
+
+func NewJwtVerifier(info *dvcontext.JwtVerificationInfo) (*JwtVerifier, error) {
+	v := &JwtVerifier{Issuers: info.Issuers, Audiences: info.Audiences, ClockSkew: int64(info.ClockSkew)}
+	if v.ClockSkew == 0 {
+		v.ClockSkew = jwtClockSkewDefault
+	}
+	for _, keyInfo := range info.Keys {
+		key, err := newJwtKey(keyInfo)
+		if err != nil {
+			return nil, err
+		}
+		v.Keys = append(v.Keys, key)
+	}
+	if info.Jwks != "" {
+		v.Jwks = NewJwksKeySource(info.Jwks, info.JwksMinRefresh, info.JwksCacheTime)
+	}
+	return v, nil
+}
+
+// GetJwtVerifier returns the verifier of the security settings of the host server, it also accepts
+// the tokens issued by the oauth settings; without settings no tokens are accepted
+func GetJwtVerifier(info *dvcontext.SecurityServerInfo) (*JwtVerifier, error) {
+	if info == nil || info.Jwt == nil && info.OAuth == nil {
+		jwtNotConfiguredOnce.Do(func() {
+			dvlog.PrintfError("JWT verification is not configured: specify jwt or oauth in security settings, all bearer tokens are rejected")
+		})
+		return nil, ErrJwtNotConfigured
+	}
+	jwtVerifiersMutex.Lock()
+	defer jwtVerifiersMutex.Unlock()
+	if v, ok := jwtVerifiers[info]; ok {
+		return v, nil
+	}
+	v := &JwtVerifier{ClockSkew: jwtClockSkewDefault}
+	if info.Jwt != nil {
+		var err error
+		v, err = NewJwtVerifier(info.Jwt)
+		if err != nil {
+			return nil, errors.New("Wrong jwt settings: " + err.Error())
+		}
+	}
+	if info.OAuth != nil {
+		signer, err := NewJwtSigner(info.OAuth.SigningKey)
+		if err != nil {
+			return nil, errors.New("Wrong oauth settings: " + err.Error())
+		}
+		v.Keys = append(v.Keys, signer.VerificationKey())
+		if info.Jwt == nil {
+			v.Issuers = []string{info.OAuth.Issuer}
+			if info.OAuth.Issuer == "" {
+				v.Issuers[0] = oauthIssuerDefault
+			}
+			if info.OAuth.Audience != "" {
+				v.Audiences = []string{info.OAuth.Audience}
+			}
+		}
+	}
+	jwtVerifiers[info] = v
+	return v, nil
+}
+
+func (key *JwtKey) accepts(alg string, algorithm *jwtAlgorithm) bool {
+	if key.Alg != "" && key.Alg != alg {
+		return false
+	}
+	switch key.Key.(type) {
+	case []byte:
+		return algorithm.family == jwtFamilyHmac
+	case *rsa.PublicKey:
+		return algorithm.family == jwtFamilyRsa || algorithm.family == jwtFamilyRsaPss
+	case *ecdsa.PublicKey:
+		return algorithm.family == jwtFamilyEcdsa && key.Key.(*ecdsa.PublicKey).Curve == algorithm.curve
+	}
+	return false
+}
+
+func (key *JwtKey) verify(algorithm *jwtAlgorithm, signed string, signature []byte) bool {
+	if algorithm.family == jwtFamilyHmac {
+		h := hmac.New(algorithm.hash.New, key.Key.([]byte))
+		h.Write([]byte(signed))
+		return hmac.Equal(h.Sum(nil), signature)
+	}
+	h := algorithm.hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+	switch algorithm.family {
+	case jwtFamilyRsa:
+		return rsa.VerifyPKCS1v15(key.Key.(*rsa.PublicKey), algorithm.hash, digest, signature) == nil
+	case jwtFamilyRsaPss:
+		options := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: algorithm.hash}
+		return rsa.VerifyPSS(key.Key.(*rsa.PublicKey), algorithm.hash, digest, signature, options) == nil
+	case jwtFamilyEcdsa:
+		size := (algorithm.curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(key.Key.(*ecdsa.PublicKey), digest, r, s)
+	}
+	return false
+}
+
+func getJwtTime(claims map[string]interface{}, name string) (int64, bool, error) {
+	v, ok := claims[name]
+	if !ok || v == nil {
+		return 0, false, nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false, errors.New("claim " + name + " is not a number")
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return 0, false, errors.New("claim " + name + " is not a number")
+	}
+	return int64(f), true, nil
+}
+
+func containsAnyOf(values []string, allowed []string) bool {
+	for _, v := range values {
+		for _, a := range allowed {
+			if v == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (v *JwtVerifier) checkClaims(claims map[string]interface{}) error {
+	now := GetCurrentSeconds()
+	exp, ok, err := getJwtTime(claims, "exp")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("token has no expiration time")
+	}
+	if now > exp+v.ClockSkew {
+		return errors.New("token is expired")
+	}
+	nbf, ok, err := getJwtTime(claims, "nbf")
+	if err != nil {
+		return err
+	}
+	if ok && now+v.ClockSkew < nbf {
+		return errors.New("token is not valid yet")
+	}
+	if len(v.Issuers) != 0 {
+		iss, _ := claims["iss"].(string)
+		if !containsAnyOf([]string{iss}, v.Issuers) {
+			return errors.New("token issuer " + iss + " is not accepted")
+		}
+	}
+	if len(v.Audiences) != 0 {
+		var aud []string
+		switch claims["aud"].(type) {
+		case string:
+			aud = []string{claims["aud"].(string)}
+		case []interface{}:
+			for _, a := range claims["aud"].([]interface{}) {
+				if s, ok := a.(string); ok {
+					aud = append(aud, s)
+				}
+			}
+		}
+		if !containsAnyOf(aud, v.Audiences) {
+			return errors.New("token audience " + strings.Join(aud, ",") + " is not accepted")
+		}
+	}
+	return nil
+}
+
+// Verify checks the signature and the claims of the token and returns its decoded payload,
+// refresh tokens are not accepted
+func (v *JwtVerifier) Verify(token string) (string, error) {
+	payload, _, err := v.verify(token, false)
+	return payload, err
+}
+
+func (v *JwtVerifier) verify(token string, refresh bool) (string, map[string]interface{}, error) {
+	t := strings.Split(token, ".")
+	if len(t) != 3 || len(t[1]) == 0 {
+		return "", nil, errors.New("Strange token")
+	}
+	headerData, err := DecodeJwtBase64(t[0])
+	if err != nil {
+		return "", nil, errors.New("token header is not base64: " + err.Error())
+	}
+	header := &jwtHeader{}
+	if err = json.Unmarshal([]byte(headerData), header); err != nil {
+		return "", nil, errors.New("token header is not json: " + err.Error())
+	}
+	algorithm := jwtAlgorithms[header.Alg]
+	if algorithm == nil {
+		return "", nil, errors.New("token algorithm " + header.Alg + " is not supported")
+	}
+	signature, err := DecodeJwtBase64(t[2])
+	if err != nil {
+		return "", nil, errors.New("token signature is not base64: " + err.Error())
+	}
+	signed := t[0] + "." + t[1]
+	keys := v.Keys
+	if v.Jwks != nil {
+		keys = append(append([]*JwtKey{}, v.Jwks.GetKeys(header.Kid)...), keys...)
+	}
+	found, verified := false, false
+	for _, key := range keys {
+		if header.Kid != "" && key.Kid != "" && key.Kid != header.Kid || !key.accepts(header.Alg, algorithm) {
+			continue
+		}
+		found = true
+		if key.verify(algorithm, signed, []byte(signature)) {
+			verified = true
+			break
+		}
+	}
+	if !found {
+		return "", nil, errors.New("no key for token algorithm " + header.Alg + " and kid " + header.Kid)
+	}
+	if !verified {
+		return "", nil, errors.New("token signature is invalid")
+	}
+	payload, err := DecodeJwtBase64(t[1])
+	if err != nil {
+		return "", nil, errors.New("token payload is not base64: " + err.Error())
+	}
+	decoder := json.NewDecoder(strings.NewReader(payload))
+	decoder.UseNumber()
+	claims := make(map[string]interface{})
+	if err = decoder.Decode(&claims); err != nil {
+		return "", nil, errors.New("token payload is not json object: " + err.Error())
+	}
+	if err = v.checkClaims(claims); err != nil {
+		return "", nil, err
+	}
+	if typ, _ := claims["typ"].(string); !refresh && strings.EqualFold(typ, "Refresh") {
+		return "", nil, errors.New("refresh token cannot be used as access token")
+	}
+	return payload, claims, nil
+}
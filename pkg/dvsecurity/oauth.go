@@ -4,83 +4,498 @@
 package dvsecurity
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
 	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvlog"
 )
 
 const (
 	Security = "security"
+
+	GrantPassword          = "password"
+	GrantClientCredentials = "client_credentials"
+	GrantRefreshToken      = "refresh_token"
+
+	oauthIssuerDefault           = "microcore"
+	oauthAccessTokenTimeDefault  = 300
+	oauthRefreshTokenTimeDefault = 1800
+	oauthRefreshTokenType        = "Refresh"
+	oauthMaxFormSize             = 65536
 )
 
-var validTimeDefault = int64(18000)
 var SecretKey = "6876jgj6876876hkh8989899"
 
-func LoginByRequestEndPointHandler(ctx *dvcontext.RequestContext) bool {
-	currentTime := GetCurrentSeconds()
-	expired := validTimeDefault + currentTime
-	token := GetToken(currentTime, expired)
-	refreshToken := GetRefreshToken(currentTime, expired)
+// OAuthServer issues the tokens of the token endpoint for the users and the clients of the store
+type OAuthServer struct {
+	Issuer           string
+	Audience         string
+	AccessTokenTime  int64
+	RefreshTokenTime int64
+	Signer           *JwtSigner
+	Store            OAuthStore
+	verifier         *JwtVerifier
+}
+
+// OAuthError is the error of the token endpoint, Code is the error code of rfc 6749
+type OAuthError struct {
+	Status      int
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func newOAuthError(status int, code string, description string) *OAuthError {
+	return &OAuthError{Status: status, Code: code, Description: description}
+}
+
+var oauthServers = make(map[*dvcontext.OAuthServerInfo]*OAuthServer)
+var oauthServersMutex sync.Mutex
+var oauthDummyHash string
+var oauthDummyHashOnce sync.Once
+
+func NewOAuthServer(info *dvcontext.OAuthServerInfo) (*OAuthServer, error) {
+	signer, err := NewJwtSigner(info.SigningKey)
+	if err != nil {
+		return nil, err
+	}
+	store, err := newOAuthStore(info)
+	if err != nil {
+		return nil, err
+	}
+	s := &OAuthServer{
+		Issuer:           info.Issuer,
+		Audience:         info.Audience,
+		AccessTokenTime:  int64(info.AccessTokenTime),
+		RefreshTokenTime: int64(info.RefreshTokenTime),
+		Signer:           signer,
+		Store:            store,
+	}
+	if s.Issuer == "" {
+		s.Issuer = oauthIssuerDefault
+	}
+	if s.AccessTokenTime <= 0 {
+		s.AccessTokenTime = oauthAccessTokenTimeDefault
+	}
+	if s.RefreshTokenTime <= 0 {
+		s.RefreshTokenTime = oauthRefreshTokenTimeDefault
+	}
+	s.verifier = &JwtVerifier{Keys: []*JwtKey{signer.VerificationKey()}, Issuers: []string{s.Issuer}, ClockSkew: jwtClockSkewDefault}
+	return s, nil
+}
+
+// GetOAuthServer returns the token endpoint of the security settings of the host server
+func GetOAuthServer(info *dvcontext.SecurityServerInfo) (*OAuthServer, error) {
+	if info == nil || info.OAuth == nil {
+		return nil, errors.New("oauth is not configured in security settings")
+	}
+	oauthServersMutex.Lock()
+	defer oauthServersMutex.Unlock()
+	if s, ok := oauthServers[info.OAuth]; ok {
+		return s, nil
+	}
+	s, err := NewOAuthServer(info.OAuth)
+	if err != nil {
+		return nil, errors.New("Wrong oauth settings: " + err.Error())
+	}
+	oauthServers[info.OAuth] = s
+	return s, nil
+}
+
+func newOAuthId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("random generator failed: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// checkOAuthPassword compares the password with the hash even for the unknown users,
+// so that the time of the response does not show whether the user exists
+func checkOAuthPassword(password string, hash string) bool {
+	if hash == "" {
+		oauthDummyHashOnce.Do(func() {
+			oauthDummyHash, _ = HashPassword(newOAuthId())
+		})
+		CheckPasswordHash(password, oauthDummyHash)
+		return false
+	}
+	return CheckPasswordHash(password, hash)
+}
+
+func containsOAuthValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveOAuthScope checks that the requested scope is allowed, the allowed scope is returned if nothing is requested
+func resolveOAuthScope(requested string, allowed []string) ([]string, error) {
+	scope := strings.Fields(requested)
+	if len(scope) == 0 {
+		return allowed, nil
+	}
+	if len(allowed) != 0 {
+		for _, v := range scope {
+			if !containsOAuthValue(allowed, v) {
+				return nil, newOAuthError(http.StatusBadRequest, "invalid_scope", "scope "+v+" is not allowed")
+			}
+		}
+	}
+	return scope, nil
+}
+
+// authenticateClient checks the client and whether it can use the grant type, the empty grant type
+// is for the requests which need only the client authentication
+func (s *OAuthServer) authenticateClient(clientId string, secret string, grantType string) (*OAuthClient, error) {
+	if clientId == "" {
+		return nil, newOAuthError(http.StatusUnauthorized, "invalid_client", "client is not specified")
+	}
+	client, err := s.Store.FindClient(clientId)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil || client.Disabled || client.SecretHash == "" && secret != "" ||
+		client.SecretHash != "" && !CheckPasswordHash(secret, client.SecretHash) {
+		return nil, newOAuthError(http.StatusUnauthorized, "invalid_client", "client authentication failed")
+	}
+	if client.SecretHash == "" && grantType == GrantClientCredentials {
+		return nil, newOAuthError(http.StatusBadRequest, "unauthorized_client", "public client cannot use "+grantType)
+	}
+	if grantType != "" && len(client.Grants) != 0 && !containsOAuthValue(client.Grants, grantType) {
+		return nil, newOAuthError(http.StatusBadRequest, "unauthorized_client", "client cannot use "+grantType)
+	}
+	return client, nil
+}
+
+func (s *OAuthServer) findActiveUser(username string) (*OAuthUser, error) {
+	user, err := s.Store.FindUser(username)
+	if err != nil || user == nil || user.Disabled {
+		return nil, err
+	}
+	return user, nil
+}
+
+// issue returns the access token and, for the users, the refresh token of the family (new family if it is empty)
+func (s *OAuthServer) issue(client *OAuthClient, user *OAuthUser, scope []string, family string) (map[string]interface{}, error) {
+	now := GetCurrentSeconds()
+	scopeStr := strings.Join(scope, " ")
+	claims := map[string]interface{}{
+		"jti":   newOAuthId(),
+		"iat":   now,
+		"nbf":   now,
+		"exp":   now + s.AccessTokenTime,
+		"iss":   s.Issuer,
+		"sub":   client.ClientId,
+		"typ":   "Bearer",
+		"azp":   client.ClientId,
+		"scope": scopeStr,
+	}
+	if s.Audience != "" {
+		claims["aud"] = s.Audience
+	}
+	roles, tenantId := client.Roles, client.TenantId
+	if user != nil {
+		if family == "" {
+			family = newOAuthId()
+		}
+		claims["sub"] = user.Id
+		if user.Id == "" {
+			claims["sub"] = user.Username
+		}
+		claims["sid"] = family
+		claims["preferred_username"] = user.Username
+		for name, value := range map[string]string{"email": user.Email, "name": user.Name, "given_name": user.GivenName, "family_name": user.FamilyName} {
+			if value != "" {
+				claims[name] = value
+			}
+		}
+		roles = user.Roles
+		if user.TenantId != "" {
+			tenantId = user.TenantId
+		}
+	}
+	if roles == nil {
+		roles = []string{}
+	}
+	claims["realm_access"] = map[string]interface{}{"roles": roles}
+	if tenantId != "" {
+		claims["tenant-id"] = tenantId
+	}
+	accessToken, err := s.Signer.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
 	result := map[string]interface{}{
-		"access_token":       token,
-		"expires_in":         validTimeDefault,
-		"refresh_expires_in": 1800,
-		"refresh_token":      refreshToken,
-		"token_type":         "bearer",
-		"not-before-policy":  0,
-		"session_state":      "467a8f63-3c4c-40a6-8e6c-d5388b59c7a0",
-		"scope":              "profile email",
-	}
-	ctx.PrimaryContextEnvironment.Set("OAUTH", result)
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   s.AccessTokenTime,
+		"scope":        scopeStr,
+	}
+	if user == nil {
+		return result, nil
+	}
+	refresh := &OAuthRefreshToken{
+		Id:        newOAuthId(),
+		Family:    family,
+		ClientId:  client.ClientId,
+		Username:  user.Username,
+		ExpiresAt: now + s.RefreshTokenTime,
+	}
+	refreshToken, err := s.Signer.Sign(map[string]interface{}{
+		"jti":                refresh.Id,
+		"iat":                now,
+		"exp":                refresh.ExpiresAt,
+		"iss":                s.Issuer,
+		"sub":                claims["sub"],
+		"typ":                oauthRefreshTokenType,
+		"azp":                client.ClientId,
+		"sid":                family,
+		"scope":              scopeStr,
+		"preferred_username": user.Username,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = s.Store.SaveRefreshToken(refresh); err != nil {
+		return nil, err
+	}
+	result["refresh_token"] = refreshToken
+	result["refresh_expires_in"] = s.RefreshTokenTime
+	return result, nil
+}
+
+// readRefreshToken returns the claims of the valid refresh token issued by this server
+func (s *OAuthServer) readRefreshToken(token string) (map[string]interface{}, error) {
+	_, claims, err := s.verifier.verify(token, true)
+	if err != nil {
+		return nil, err
+	}
+	if typ, _ := claims["typ"].(string); typ != oauthRefreshTokenType {
+		return nil, errors.New("token is not refresh token")
+	}
+	return claims, nil
+}
+
+func (s *OAuthServer) refresh(client *OAuthClient, token string, requestedScope string) (map[string]interface{}, error) {
+	claims, err := s.readRefreshToken(token)
+	if err != nil {
+		return nil, newOAuthError(http.StatusBadRequest, "invalid_grant", err.Error())
+	}
+	id, _ := claims["jti"].(string)
+	family, _ := claims["sid"].(string)
+	username, _ := claims["preferred_username"].(string)
+	scope, _ := claims["scope"].(string)
+	if azp, _ := claims["azp"].(string); azp != client.ClientId {
+		return nil, newOAuthError(http.StatusBadRequest, "invalid_grant", "refresh token is issued to another client")
+	}
+	ok, err := s.Store.DeleteRefreshToken(id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// the used or revoked token is presented, the whole family is revoked because the token may be stolen
+		dvlog.PrintfError("Refresh token %s of %s is used again, all tokens of session %s are revoked", id, username, family)
+		if err = s.Store.DeleteRefreshTokenFamily(family); err != nil {
+			return nil, err
+		}
+		return nil, newOAuthError(http.StatusBadRequest, "invalid_grant", "refresh token is revoked")
+	}
+	user, err := s.findActiveUser(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, newOAuthError(http.StatusBadRequest, "invalid_grant", "user is not active")
+	}
+	newScope, err := resolveOAuthScope(requestedScope, strings.Fields(scope))
+	if err != nil {
+		return nil, err
+	}
+	return s.issue(client, user, newScope, family)
+}
+
+// Token processes the request of the token endpoint with the form parameters and the client credentials
+// of basic authorization (or client_id and client_secret of the form)
+func (s *OAuthServer) Token(form url.Values, clientId string, clientSecret string) (map[string]interface{}, error) {
+	grantType := form.Get("grant_type")
+	switch grantType {
+	case GrantPassword, GrantClientCredentials, GrantRefreshToken:
+	case "":
+		return nil, newOAuthError(http.StatusBadRequest, "invalid_request", "grant_type is not specified")
+	default:
+		return nil, newOAuthError(http.StatusBadRequest, "unsupported_grant_type", "grant_type "+grantType+" is not supported")
+	}
+	client, err := s.authenticateClient(clientId, clientSecret, grantType)
+	if err != nil {
+		return nil, err
+	}
+	switch grantType {
+	case GrantPassword:
+		username, password := form.Get("username"), form.Get("password")
+		if username == "" || password == "" {
+			return nil, newOAuthError(http.StatusBadRequest, "invalid_request", "username and password are required")
+		}
+		user, err := s.findActiveUser(username)
+		if err != nil {
+			return nil, err
+		}
+		hash := ""
+		if user != nil {
+			hash = user.PasswordHash
+		}
+		if !checkOAuthPassword(password, hash) {
+			return nil, newOAuthError(http.StatusBadRequest, "invalid_grant", "invalid username or password")
+		}
+		scope, err := resolveOAuthScope(form.Get("scope"), client.Scopes)
+		if err != nil {
+			return nil, err
+		}
+		return s.issue(client, user, scope, "")
+	case GrantClientCredentials:
+		scope, err := resolveOAuthScope(form.Get("scope"), client.Scopes)
+		if err != nil {
+			return nil, err
+		}
+		return s.issue(client, nil, scope, "")
+	}
+	token := form.Get("refresh_token")
+	if token == "" {
+		return nil, newOAuthError(http.StatusBadRequest, "invalid_request", "refresh_token is required")
+	}
+	return s.refresh(client, token, form.Get("scope"))
+}
+
+// Revoke revokes the refresh token of the client together with all tokens rotated from the same token (rfc 7009),
+// the invalid tokens are ignored
+func (s *OAuthServer) Revoke(token string, clientId string, clientSecret string) error {
+	client, err := s.authenticateClient(clientId, clientSecret, "")
+	if err != nil {
+		return err
+	}
+	claims, err := s.readRefreshToken(token)
+	if err != nil {
+		return nil
+	}
+	family, _ := claims["sid"].(string)
+	if azp, _ := claims["azp"].(string); azp != client.ClientId || family == "" {
+		return nil
+	}
+	return s.Store.DeleteRefreshTokenFamily(family)
+}
+
+func readOAuthRequest(ctx *dvcontext.RequestContext) (url.Values, string, string, error) {
+	if ctx.Reader == nil || ctx.Reader.Method != http.MethodPost {
+		return nil, "", "", newOAuthError(http.StatusBadRequest, "invalid_request", "POST method is required")
+	}
+	data := ctx.Input
+	if data == nil && ctx.Reader.Body != nil {
+		var err error
+		data, err = ioutil.ReadAll(io.LimitReader(ctx.Reader.Body, oauthMaxFormSize))
+		if err != nil {
+			return nil, "", "", newOAuthError(http.StatusBadRequest, "invalid_request", err.Error())
+		}
+		ctx.Input = data
+	}
+	form, err := url.ParseQuery(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, "", "", newOAuthError(http.StatusBadRequest, "invalid_request", err.Error())
+	}
+	clientId, clientSecret, ok := ctx.Reader.BasicAuth()
+	if !ok {
+		clientId, clientSecret = form.Get("client_id"), form.Get("client_secret")
+	}
+	return form, clientId, clientSecret, nil
+}
+
+func setOAuthResult(ctx *dvcontext.RequestContext, status int, result interface{}) {
+	if ctx.Headers == nil {
+		ctx.Headers = make(map[string][]string)
+	}
+	ctx.Headers["Cache-Control"] = []string{"no-store"}
+	ctx.Headers["Pragma"] = []string{"no-cache"}
+	ctx.StatusCode = status
+	ctx.DataType = "application/json"
+	ctx.Output, _ = json.Marshal(result)
+}
+
+func setOAuthError(ctx *dvcontext.RequestContext, err error) {
+	e, ok := err.(*OAuthError)
+	if !ok {
+		dvlog.PrintfError("Token endpoint %s failed: %v", ctx.Url, err)
+		e = newOAuthError(http.StatusInternalServerError, "server_error", "token cannot be issued")
+	}
+	if e.Status == http.StatusUnauthorized {
+		if ctx.Headers == nil {
+			ctx.Headers = make(map[string][]string)
+		}
+		ctx.Headers["WWW-Authenticate"] = []string{"Basic realm=\"" + Security + "\""}
+	}
+	setOAuthResult(ctx, e.Status, map[string]string{"error": e.Code, "error_description": e.Description})
+}
+
+func getOAuthServerOfRequest(ctx *dvcontext.RequestContext) (*OAuthServer, error) {
+	var info *dvcontext.SecurityServerInfo
+	if ctx.Server != nil {
+		info = ctx.Server.SecurityInfo
+	}
+	return GetOAuthServer(info)
+}
+
+// LoginByRequestEndPointHandler is the token endpoint of password, client_credentials and refresh_token grants,
+// the issued tokens are also set in OAUTH variable
+func LoginByRequestEndPointHandler(ctx *dvcontext.RequestContext) bool {
+	s, err := getOAuthServerOfRequest(ctx)
+	if err != nil {
+		setOAuthError(ctx, err)
+		return true
+	}
+	form, clientId, clientSecret, err := readOAuthRequest(ctx)
+	if err == nil {
+		var result map[string]interface{}
+		result, err = s.Token(form, clientId, clientSecret)
+		if err == nil {
+			ctx.PrimaryContextEnvironment.Set("OAUTH", result)
+			setOAuthResult(ctx, 0, result)
+			return true
+		}
+	}
+	setOAuthError(ctx, err)
 	return true
 }
 
-func GetToken(current int64, expired int64) string {
-	claims := map[string]interface{}{
-		"jti":                "8b5e5323-7503-409d-8831-db0dbdb66b69",
-		"exp":                expired,
-		"nbf":                0,
-		"iat":                current,
-		"iss":                "microcore",
-		"aud":                "account",
-		"sub":                "6402d452-3004-4d87-9a0a-9b5783fbac97",
-		"typ":                "Bearer",
-		"azp":                "frontend",
-		"auth_time":          0,
-		"session_state":      "d11bf3d4-381b-4fe5-99f1-05f0338ab800",
-		"acr":                "1",
-		"scope":              "email profile",
-		"email_verified":     true,
-		"name":               "Tenant Admin",
-		"preferred_username": "admin@gmail.com",
-		"given_name":         "Tenant",
-		"family_name":        "Admin",
-		"tenant-id":          "4556c255-be35-47fb-aba1-8d888999db40",
-		"email":              "admin@gmail.com",
-	}
-	return GenerateHs256Jwt(claims, SecretKey, expired)
-}
-
-func GetRefreshToken(current int64, expired int64) string {
-	claims := map[string]interface{}{
-		"jti":                "8b5e5323-7503-409d-8831-db0dbdb66b69",
-		"exp":                expired,
-		"nbf":                0,
-		"iat":                current,
-		"iss":                "microcore",
-		"aud":                "account",
-		"sub":                "6402d452-3004-4d87-9a0a-9b5783fbac97",
-		"typ":                "Bearer",
-		"azp":                "frontend",
-		"auth_time":          0,
-		"session_state":      "d11bf3d4-381b-4fe5-99f1-05f0338ab800",
-		"acr":                "1",
-		"scope":              "email profile",
-		"email_verified":     true,
-		"name":               "Tenant Admin",
-		"preferred_username": "admin@gmail.com",
-		"given_name":         "Tenant",
-		"family_name":        "Admin",
-		"tenant-id":          "4556c255-be35-47fb-aba1-8d888999db40",
-		"email":              "admin@gmail.com",
-	}
-	return GenerateHs256Jwt(claims, SecretKey, expired)
+// RevokeByRequestEndPointHandler is the revocation endpoint of the refresh tokens
+func RevokeByRequestEndPointHandler(ctx *dvcontext.RequestContext) bool {
+	s, err := getOAuthServerOfRequest(ctx)
+	if err != nil {
+		setOAuthError(ctx, err)
+		return true
+	}
+	form, clientId, clientSecret, err := readOAuthRequest(ctx)
+	if err == nil {
+		token := form.Get("token")
+		if token == "" {
+			err = newOAuthError(http.StatusBadRequest, "invalid_request", "token is required")
+		} else if err = s.Revoke(token, clientId, clientSecret); err == nil {
+			setOAuthResult(ctx, http.StatusOK, map[string]string{})
+			return true
+		}
+	}
+	setOAuthError(ctx, err)
+	return true
 }
@@ -0,0 +1,248 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvsecurity
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvdbmanager"
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+)
+
+const (
+	oauthUsersDefault         = "oauth_users"
+	oauthClientsDefault       = "oauth_clients"
+	oauthRefreshTokensDefault = "oauth_refresh_tokens"
+)
+
+type OAuthUser struct {
+	Id           string
+	Username     string
+	PasswordHash string
+	TenantId     string
+	Email        string
+	Name         string
+	GivenName    string
+	FamilyName   string
+	Roles        []string
+	Disabled     bool
+}
+
+// OAuthClient is the client of the token endpoint, the client without SecretHash is public;
+// empty Grants allow all grants, empty Scopes allow any scope
+type OAuthClient struct {
+	ClientId   string
+	SecretHash string
+	Grants     []string
+	Scopes     []string
+	Roles      []string
+	TenantId   string
+	Disabled   bool
+}
+
+// OAuthRefreshToken is the issued refresh token, Family is the same for all tokens rotated from the first one
+type OAuthRefreshToken struct {
+	Id        string
+	Family    string
+	ClientId  string
+	Username  string
+	ExpiresAt int64
+}
+
+// OAuthStore keeps the users, the clients and the refresh tokens; FindUser and FindClient return nil
+// if they are not found, DeleteRefreshToken returns false if the token was not present
+type OAuthStore interface {
+	FindUser(username string) (*OAuthUser, error)
+	FindClient(clientId string) (*OAuthClient, error)
+	SaveRefreshToken(token *OAuthRefreshToken) error
+	DeleteRefreshToken(id string) (bool, error)
+	DeleteRefreshTokenFamily(family string) error
+}
+
+type OAuthStoreProvider func(info *dvcontext.OAuthServerInfo) (OAuthStore, error)
+
+var oauthSqlStoreProvider OAuthStoreProvider
+
+// RegisterOAuthSqlStore sets the provider of the store in the sql connection (dvdbdata registers it)
+func RegisterOAuthSqlStore(provider OAuthStoreProvider) bool {
+	oauthSqlStoreProvider = provider
+	return true
+}
+
+// GetOAuthTableNames returns the tables (or the sql tables) of the users, clients and refresh tokens
+func GetOAuthTableNames(info *dvcontext.OAuthServerInfo) (users string, clients string, refreshTokens string) {
+	users, clients, refreshTokens = info.Users, info.Clients, info.RefreshTokens
+	if users == "" {
+		users = oauthUsersDefault
+	}
+	if clients == "" {
+		clients = oauthClientsDefault
+	}
+	if refreshTokens == "" {
+		refreshTokens = oauthRefreshTokensDefault
+	}
+	return
+}
+
+func newOAuthStore(info *dvcontext.OAuthServerInfo) (OAuthStore, error) {
+	if info.Connection == "" {
+		users, clients, refreshTokens := GetOAuthTableNames(info)
+		return &oauthTableStore{users: users, clients: clients, refreshTokens: refreshTokens}, nil
+	}
+	if oauthSqlStoreProvider == nil {
+		return nil, errors.New("sql store of oauth is not registered")
+	}
+	return oauthSqlStoreProvider(info)
+}
+
+func splitOAuthList(s string) []string {
+	return strings.FieldsFunc(s, func(c rune) bool {
+		return c == ',' || c == ' '
+	})
+}
+
+func isOAuthFlagSet(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true", "t", "1", "yes", "y":
+		return true
+	}
+	return false
+}
+
+// NewOAuthUser creates the user by the columns id, username, password, tenant_id, email, name, given_name,
+// family_name, roles (separated by comma) and disabled
+func NewOAuthUser(fields map[string]string) *OAuthUser {
+	return &OAuthUser{
+		Id:           fields["id"],
+		Username:     fields["username"],
+		PasswordHash: fields["password"],
+		TenantId:     fields["tenant_id"],
+		Email:        fields["email"],
+		Name:         fields["name"],
+		GivenName:    fields["given_name"],
+		FamilyName:   fields["family_name"],
+		Roles:        splitOAuthList(fields["roles"]),
+		Disabled:     isOAuthFlagSet(fields["disabled"]),
+	}
+}
+
+// NewOAuthClient creates the client by the columns client_id, secret, grants, scope, roles, tenant_id and disabled
+func NewOAuthClient(fields map[string]string) *OAuthClient {
+	return &OAuthClient{
+		ClientId:   fields["client_id"],
+		SecretHash: fields["secret"],
+		Grants:     splitOAuthList(fields["grants"]),
+		Scopes:     splitOAuthList(fields["scope"]),
+		Roles:      splitOAuthList(fields["roles"]),
+		TenantId:   fields["tenant_id"],
+		Disabled:   isOAuthFlagSet(fields["disabled"]),
+	}
+}
+
+// oauthTableStore keeps the records in dvdbmanager tables, the arrays in the records are joined by comma
+type oauthTableStore struct {
+	users         string
+	clients       string
+	refreshTokens string
+	mutex         sync.Mutex
+}
+
+func findOAuthRecord(table string, name string, value string) (map[string]string, error) {
+	records, err := dvdbmanager.RecordFindByField(table, name, value)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return dvdbmanager.RecordToStringMap(records[0]), nil
+}
+
+func (s *oauthTableStore) FindUser(username string) (*OAuthUser, error) {
+	fields, err := findOAuthRecord(s.users, "username", username)
+	if err != nil || fields == nil {
+		return nil, err
+	}
+	return NewOAuthUser(fields), nil
+}
+
+func (s *oauthTableStore) FindClient(clientId string) (*OAuthClient, error) {
+	fields, err := findOAuthRecord(s.clients, "client_id", clientId)
+	if err != nil || fields == nil {
+		return nil, err
+	}
+	return NewOAuthClient(fields), nil
+}
+
+func (s *oauthTableStore) deleteRecords(records []*dvevaluation.DvVariable) error {
+	if len(records) == 0 {
+		return nil
+	}
+	ids := make([]string, len(records))
+	for i, record := range records {
+		ids[i] = record.ReadSimpleChildValue("id")
+	}
+	return dvdbmanager.RecordResultError(dvdbmanager.RecordDelete(s.refreshTokens, strings.Join(ids, ",")))
+}
+
+// SaveRefreshToken saves the token and removes the expired tokens
+func (s *oauthTableStore) SaveRefreshToken(token *OAuthRefreshToken) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	all, err := dvdbmanager.RecordReadAll(s.refreshTokens)
+	if err != nil {
+		return err
+	}
+	if all != nil {
+		now := GetCurrentSeconds()
+		expired := make([]*dvevaluation.DvVariable, 0, len(all.Fields))
+		for _, record := range all.Fields {
+			if record == nil {
+				continue
+			}
+			expiresAt, err := strconv.ParseInt(record.ReadSimpleChildValue("expires_at"), 10, 64)
+			if err == nil && expiresAt < now {
+				expired = append(expired, record)
+			}
+		}
+		if err = s.deleteRecords(expired); err != nil {
+			return err
+		}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"id":         token.Id,
+		"family":     token.Family,
+		"client_id":  token.ClientId,
+		"username":   token.Username,
+		"expires_at": token.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+	return dvdbmanager.RecordResultError(dvdbmanager.RecordCreate(s.refreshTokens, string(body), token.Id))
+}
+
+func (s *oauthTableStore) DeleteRefreshToken(id string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	records, err := dvdbmanager.RecordFindByField(s.refreshTokens, "id", id)
+	if err != nil || len(records) == 0 {
+		return false, err
+	}
+	return true, s.deleteRecords(records)
+}
+
+func (s *oauthTableStore) DeleteRefreshTokenFamily(family string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	records, err := dvdbmanager.RecordFindByField(s.refreshTokens, "family", family)
+	if err != nil {
+		return err
+	}
+	return s.deleteRecords(records)
+}
@@ -0,0 +1,66 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvsecurity
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HashPassword returns the bcrypt hash of the password
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// checkArgon2Password checks the hash in the format $argon2id$v=19$m=65536,t=3,p=4$salt$hash
+// (salt and hash are base64 without padding)
+func checkArgon2Password(password string, hash string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[2] != "v=19" {
+		return false
+	}
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false
+	}
+	var res []byte
+	if parts[1] == "argon2id" {
+		res = argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	} else {
+		res = argon2.Key([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	}
+	return subtle.ConstantTimeCompare(res, key) == 1
+}
+
+// CheckPasswordHash checks the password by the bcrypt ($2a$, $2b$, $2y$) or argon2 ($argon2id$, $argon2i$) hash,
+// other hashes are never accepted
+func CheckPasswordHash(password string, hash string) bool {
+	switch {
+	case strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$"):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case strings.HasPrefix(hash, "$argon2id$") || strings.HasPrefix(hash, "$argon2i$"):
+		return checkArgon2Password(password, hash)
+	}
+	return false
+}
@@ -1,16 +1,42 @@
 package dvuser
 
-type UserInfo struct {
-	Registered bool
-	Name       string
-	Email      string
-	Roles      []string
-}
+import (
+	"strings"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+)
+
+type UserInfo = dvcontext.UserInfo
 
-func GetCurrentUserInfo() UserInfo {
-	return UserInfo{}
+// GetCurrentUserInfo returns the user of the request (of the validated token or the session)
+func GetCurrentUserInfo(ctx *dvcontext.RequestContext) UserInfo {
+	if ctx == nil || ctx.User == nil {
+		return UserInfo{}
+	}
+	return *ctx.User
 }
 
-func GetSourceInfo(sourceRefs []string, baseInfo map[string]string) map[string]string {
+// GetSourceInfo adds the fields of the current user (id, name, email, roles, tenant_id)
+// referenced by sourceRefs to baseInfo
+func GetSourceInfo(ctx *dvcontext.RequestContext, sourceRefs []string, baseInfo map[string]string) map[string]string {
+	user := GetCurrentUserInfo(ctx)
+	if !user.Registered || len(sourceRefs) == 0 {
+		return baseInfo
+	}
+	values := map[string]string{
+		"id":        user.Id,
+		"name":      user.Name,
+		"email":     user.Email,
+		"roles":     strings.Join(user.Roles, ","),
+		"tenant_id": user.TenantId,
+	}
+	if baseInfo == nil {
+		baseInfo = make(map[string]string)
+	}
+	for _, ref := range sourceRefs {
+		if v, ok := values[ref]; ok {
+			baseInfo[ref] = v
+		}
+	}
 	return baseInfo
 }
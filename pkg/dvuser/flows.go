@@ -0,0 +1,347 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvuser
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvdbmanager"
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvlog"
+	"github.com/Dobryvechir/microcore/pkg/dvsecurity"
+)
+
+const (
+	UserResetToken     = "USER_RESET_TOKEN"
+	UserResetUsername  = "USER_RESET_USERNAME"
+	UserResetEmail     = "USER_RESET_EMAIL"
+	UserResetExpiresIn = "USER_RESET_EXPIRES_IN"
+
+	userMaxFormSize = 65536
+)
+
+// UserError is the error of the user request with the http status
+type UserError struct {
+	Status  int
+	Message string
+}
+
+func (e *UserError) Error() string {
+	return e.Message
+}
+
+func newUserError(status int, message string) *UserError {
+	return &UserError{Status: status, Message: message}
+}
+
+var registrationMutex sync.Mutex
+
+func (m *UserManager) checkPassword(password string) error {
+	if len(password) < m.Info.MinPasswordLength {
+		return newUserError(http.StatusBadRequest, "password must have at least "+strconv.Itoa(m.Info.MinPasswordLength)+" characters")
+	}
+	return nil
+}
+
+// Register creates the active user with the default roles, the tenant of the registering user
+// (or the default tenant) and the profile fields email, name, given_name and family_name
+func (m *UserManager) Register(username string, password string, profile map[string]string, tenantId string) (*UserRecord, error) {
+	if username == "" || strings.ContainsAny(username, " \t\r\n,") {
+		return nil, newUserError(http.StatusBadRequest, "username is empty or has spaces or commas")
+	}
+	if email := profile["email"]; email != "" && !strings.Contains(email, "@") {
+		return nil, newUserError(http.StatusBadRequest, "email "+email+" is wrong")
+	}
+	if err := m.checkPassword(password); err != nil {
+		return nil, err
+	}
+	hash, err := dvsecurity.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	if tenantId == "" {
+		tenantId = m.Info.DefaultTenant
+	}
+	user := &UserRecord{
+		Username:     username,
+		PasswordHash: hash,
+		Email:        profile["email"],
+		Name:         profile["name"],
+		GivenName:    profile["given_name"],
+		FamilyName:   profile["family_name"],
+		Roles:        m.Info.DefaultRoles,
+		TenantId:     tenantId,
+	}
+	registrationMutex.Lock()
+	defer registrationMutex.Unlock()
+	old, err := m.Store.FindUser(username)
+	if err != nil {
+		return nil, err
+	}
+	if old != nil {
+		return nil, newUserError(http.StatusConflict, "user "+username+" already exists")
+	}
+	if err = m.Store.CreateUser(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (m *UserManager) setPassword(user *UserRecord, password string) error {
+	if err := m.checkPassword(password); err != nil {
+		return err
+	}
+	hash, err := dvsecurity.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+	return m.Store.UpdateUser(user)
+}
+
+// Authenticate returns the active user with the password
+func (m *UserManager) Authenticate(username string, password string) (*UserRecord, error) {
+	user, err := m.Store.FindUser(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.Disabled || !dvsecurity.CheckPasswordHash(password, user.PasswordHash) {
+		return nil, newUserError(http.StatusUnauthorized, "invalid username or password")
+	}
+	return user, nil
+}
+
+func (m *UserManager) ChangePassword(username string, oldPassword string, newPassword string) error {
+	user, err := m.Authenticate(username, oldPassword)
+	if err != nil {
+		return err
+	}
+	return m.setPassword(user, newPassword)
+}
+
+func hashResetToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// RequestReset creates the password reset token of the active user, only its hash is stored;
+// the empty token is returned if there is no such active user
+func (m *UserManager) RequestReset(username string) (string, *UserRecord, error) {
+	user, err := m.Store.FindUser(username)
+	if err != nil || user == nil || user.Disabled {
+		return "", nil, err
+	}
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	token := hex.EncodeToString(b)
+	err = m.Store.SaveResetToken(&ResetToken{
+		Id:        hashResetToken(token),
+		Username:  user.Username,
+		ExpiresAt: dvsecurity.GetCurrentSeconds() + int64(m.Info.ResetTokenTime),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return token, user, nil
+}
+
+// Reset sets the new password by the reset token, the token can be used only once
+func (m *UserManager) Reset(token string, newPassword string) (*UserRecord, error) {
+	if err := m.checkPassword(newPassword); err != nil {
+		return nil, err
+	}
+	resetToken, err := m.Store.TakeResetToken(hashResetToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if resetToken == nil || resetToken.ExpiresAt < dvsecurity.GetCurrentSeconds() {
+		return nil, newUserError(http.StatusBadRequest, "reset token is invalid or expired")
+	}
+	user, err := m.Store.FindUser(resetToken.Username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.Disabled {
+		return nil, newUserError(http.StatusBadRequest, "user is not active")
+	}
+	return user, m.setPassword(user, newPassword)
+}
+
+/************************ End Points **************************************/
+
+// readUserForm reads the parameters of the json or url encoded body
+func readUserForm(ctx *dvcontext.RequestContext) map[string]string {
+	if body, ok := ctx.InputJson.(*dvevaluation.DvVariable); ok && body != nil && body.Kind == dvevaluation.FIELD_OBJECT {
+		return dvdbmanager.RecordToStringMap(body)
+	}
+	data := ctx.Input
+	if data == nil && ctx.Reader != nil && ctx.Reader.Body != nil {
+		data, _ = ioutil.ReadAll(io.LimitReader(ctx.Reader.Body, userMaxFormSize))
+		ctx.Input = data
+	}
+	res := make(map[string]string)
+	s := strings.TrimSpace(string(data))
+	if strings.HasPrefix(s, "{") {
+		values := make(map[string]interface{})
+		json.Unmarshal([]byte(s), &values)
+		for k, v := range values {
+			if str, ok := v.(string); ok {
+				res[k] = str
+			}
+		}
+		return res
+	}
+	form, _ := url.ParseQuery(s)
+	for k := range form {
+		res[k] = form.Get(k)
+	}
+	return res
+}
+
+func setUserResult(ctx *dvcontext.RequestContext, status int, result interface{}) {
+	ctx.StatusCode = status
+	ctx.DataType = "application/json"
+	ctx.Output, _ = json.Marshal(result)
+}
+
+func setUserError(ctx *dvcontext.RequestContext, err error) {
+	e, ok := err.(*UserError)
+	if !ok {
+		dvlog.PrintfError("User request %s failed: %v", ctx.Url, err)
+		e = newUserError(http.StatusInternalServerError, "user request failed")
+	}
+	setUserResult(ctx, e.Status, map[string]string{"error": e.Message})
+}
+
+func getUserManagerOfRequest(ctx *dvcontext.RequestContext) (*UserManager, map[string]string, error) {
+	var info *dvcontext.SecurityServerInfo
+	if ctx.Server != nil {
+		info = ctx.Server.SecurityInfo
+	}
+	m, err := GetUserManager(info)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, readUserForm(ctx), nil
+}
+
+// The end point handlers prepare the response and return true if the steps of the action should be executed
+
+// RegisterByRequestEndPointHandler registers the user by username, password, email, name, given_name, family_name
+func RegisterByRequestEndPointHandler(ctx *dvcontext.RequestContext) bool {
+	m, form, err := getUserManagerOfRequest(ctx)
+	if err == nil {
+		tenantId := ""
+		if ctx.User != nil {
+			tenantId = ctx.User.TenantId
+		}
+		var user *UserRecord
+		user, err = m.Register(form["username"], form["password"], form, tenantId)
+		if err == nil {
+			setUserResult(ctx, http.StatusCreated, user.Info().ToMap())
+			return true
+		}
+	}
+	setUserError(ctx, err)
+	return false
+}
+
+// PasswordByRequestEndPointHandler changes the password of the current user by password and new_password
+func PasswordByRequestEndPointHandler(ctx *dvcontext.RequestContext) bool {
+	m, form, err := getUserManagerOfRequest(ctx)
+	if err == nil {
+		if ctx.User == nil || !ctx.User.Registered {
+			err = newUserError(http.StatusUnauthorized, "user is not authenticated")
+		} else if err = m.ChangePassword(ctx.User.Name, form["password"], form["new_password"]); err == nil {
+			setUserResult(ctx, http.StatusOK, map[string]string{})
+			return true
+		}
+	}
+	setUserError(ctx, err)
+	return false
+}
+
+// ResetRequestByRequestEndPointHandler creates the reset token for username, the token is not in the response,
+// it is set in USER_RESET_TOKEN (and the user in USER_RESET_USERNAME and USER_RESET_EMAIL) for the steps
+// of the action, which deliver it to the user; the steps are not executed if there is no such active user
+func ResetRequestByRequestEndPointHandler(ctx *dvcontext.RequestContext) bool {
+	m, form, err := getUserManagerOfRequest(ctx)
+	if err == nil {
+		var token string
+		var user *UserRecord
+		token, user, err = m.RequestReset(form["username"])
+		if err == nil {
+			setUserResult(ctx, http.StatusAccepted, map[string]string{})
+			if user == nil {
+				return false
+			}
+			env := ctx.PrimaryContextEnvironment
+			env.Set(UserResetToken, token)
+			env.Set(UserResetUsername, user.Username)
+			env.Set(UserResetEmail, user.Email)
+			env.Set(UserResetExpiresIn, m.Info.ResetTokenTime)
+			return true
+		}
+	}
+	setUserError(ctx, err)
+	return false
+}
+
+// ResetByRequestEndPointHandler sets new_password by the reset token
+func ResetByRequestEndPointHandler(ctx *dvcontext.RequestContext) bool {
+	m, form, err := getUserManagerOfRequest(ctx)
+	if err == nil {
+		if _, err = m.Reset(form["token"], form["new_password"]); err == nil {
+			setUserResult(ctx, http.StatusOK, map[string]string{})
+			return true
+		}
+	}
+	setUserError(ctx, err)
+	return false
+}
+
+// LoginByRequestEndPointHandler stores the user with username and password in the session of the action
+func LoginByRequestEndPointHandler(ctx *dvcontext.RequestContext) bool {
+	m, form, err := getUserManagerOfRequest(ctx)
+	if err == nil {
+		if ctx.Session == nil {
+			err = newUserError(http.StatusInternalServerError, "session is not configured for the action")
+		} else {
+			var user *UserRecord
+			if user, err = m.Authenticate(form["username"], form["password"]); err == nil {
+				info := user.Info()
+				info.Source = dvcontext.UserSourceSession
+				dvcontext.StoreSessionUser(ctx.Session, info)
+				ctx.SetUser(info)
+				setUserResult(ctx, http.StatusOK, info.ToMap())
+				return true
+			}
+		}
+	}
+	setUserError(ctx, err)
+	return false
+}
+
+// LogoutByRequestEndPointHandler removes the user from the session of the action
+func LogoutByRequestEndPointHandler(ctx *dvcontext.RequestContext) bool {
+	dvcontext.StoreSessionUser(ctx.Session, nil)
+	ctx.SetUser(nil)
+	setUserResult(ctx, http.StatusOK, map[string]string{})
+	return true
+}
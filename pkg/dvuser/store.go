@@ -0,0 +1,267 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package dvuser
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvdbmanager"
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvsecurity"
+)
+
+const (
+	UserStoreTable = "table"
+	UserStoreSql   = "sql"
+
+	userResetTokensDefault    = "user_reset_tokens"
+	userResetTokenTimeDefault = 3600
+	userMinPasswordDefault    = 8
+)
+
+// UserRecord is the stored user, the same record is used by the oauth password grant
+type UserRecord struct {
+	Id           string   `json:"id"`
+	Username     string   `json:"username"`
+	PasswordHash string   `json:"password"`
+	Email        string   `json:"email"`
+	Name         string   `json:"name"`
+	GivenName    string   `json:"given_name"`
+	FamilyName   string   `json:"family_name"`
+	Roles        []string `json:"roles"`
+	TenantId     string   `json:"tenant_id"`
+	Disabled     bool     `json:"disabled"`
+}
+
+// ResetToken is the password reset token, Id is the hash of the token sent to the user
+type ResetToken struct {
+	Id        string
+	Username  string
+	ExpiresAt int64
+}
+
+// UserStore keeps the users and the reset tokens; FindUser returns nil if the user is not found,
+// TakeResetToken deletes the token and returns it or nil if it is not present
+type UserStore interface {
+	FindUser(username string) (*UserRecord, error)
+	CreateUser(user *UserRecord) error
+	UpdateUser(user *UserRecord) error
+	SaveResetToken(token *ResetToken) error
+	TakeResetToken(id string) (*ResetToken, error)
+}
+
+type UserStoreProvider func(info *dvcontext.UserManagementInfo) (UserStore, error)
+
+var userStoreProviders = map[string]UserStoreProvider{UserStoreTable: newUserTableStore}
+var userStores = make(map[*dvcontext.SecurityServerInfo]*UserManager)
+var userStoresMutex sync.Mutex
+
+// RegisterUserStore registers the backend of the users by its kind (the store of the user settings)
+func RegisterUserStore(kind string, provider UserStoreProvider) bool {
+	userStoresMutex.Lock()
+	defer userStoresMutex.Unlock()
+	if _, ok := userStoreProviders[kind]; ok {
+		panic("User store " + kind + " already registered")
+	}
+	userStoreProviders[kind] = provider
+	return true
+}
+
+// UserManager registers the users, changes and resets their passwords
+type UserManager struct {
+	Info  *dvcontext.UserManagementInfo
+	Store UserStore
+}
+
+// resolveUserManagementInfo fills the missing settings, the users table and the connection are shared with oauth
+func resolveUserManagementInfo(security *dvcontext.SecurityServerInfo) *dvcontext.UserManagementInfo {
+	info := &dvcontext.UserManagementInfo{}
+	if security.Users != nil {
+		*info = *security.Users
+	}
+	if security.OAuth != nil {
+		if info.Connection == "" && info.Store == "" {
+			info.Connection = security.OAuth.Connection
+		}
+		if info.Users == "" {
+			info.Users, _, _ = dvsecurity.GetOAuthTableNames(security.OAuth)
+		}
+	}
+	if info.Users == "" {
+		info.Users, _, _ = dvsecurity.GetOAuthTableNames(&dvcontext.OAuthServerInfo{})
+	}
+	if info.ResetTokens == "" {
+		info.ResetTokens = userResetTokensDefault
+	}
+	if info.ResetTokenTime <= 0 {
+		info.ResetTokenTime = userResetTokenTimeDefault
+	}
+	if info.MinPasswordLength <= 0 {
+		info.MinPasswordLength = userMinPasswordDefault
+	}
+	if info.Store == "" {
+		info.Store = UserStoreTable
+		if info.Connection != "" {
+			info.Store = UserStoreSql
+		}
+	}
+	return info
+}
+
+// GetUserManager returns the user management of the security settings of the host server
+func GetUserManager(security *dvcontext.SecurityServerInfo) (*UserManager, error) {
+	if security == nil || security.Users == nil && security.OAuth == nil {
+		return nil, errors.New("users are not configured in security settings")
+	}
+	userStoresMutex.Lock()
+	defer userStoresMutex.Unlock()
+	if m, ok := userStores[security]; ok {
+		return m, nil
+	}
+	info := resolveUserManagementInfo(security)
+	provider := userStoreProviders[info.Store]
+	if provider == nil {
+		return nil, errors.New("user store " + info.Store + " is not registered")
+	}
+	store, err := provider(info)
+	if err != nil {
+		return nil, err
+	}
+	m := &UserManager{Info: info, Store: store}
+	userStores[security] = m
+	return m, nil
+}
+
+// NewUserRecord creates the user by the columns of the users table
+func NewUserRecord(fields map[string]string) *UserRecord {
+	user := dvsecurity.NewOAuthUser(fields)
+	return &UserRecord{
+		Id:           user.Id,
+		Username:     user.Username,
+		PasswordHash: user.PasswordHash,
+		Email:        user.Email,
+		Name:         user.Name,
+		GivenName:    user.GivenName,
+		FamilyName:   user.FamilyName,
+		Roles:        user.Roles,
+		TenantId:     user.TenantId,
+		Disabled:     user.Disabled,
+	}
+}
+
+func (user *UserRecord) Info() *dvcontext.UserInfo {
+	return &dvcontext.UserInfo{
+		Registered: true,
+		Id:         user.Id,
+		Name:       user.Username,
+		Email:      user.Email,
+		Roles:      user.Roles,
+		TenantId:   user.TenantId,
+	}
+}
+
+// userTableStore keeps the users and the reset tokens in dvdbmanager tables,
+// the users have numeric ids because dvdbmanager updates only the records with numeric ids
+type userTableStore struct {
+	users       string
+	resetTokens string
+	mutex       sync.Mutex
+	lastId      int64
+}
+
+func newUserTableStore(info *dvcontext.UserManagementInfo) (UserStore, error) {
+	return &userTableStore{users: info.Users, resetTokens: info.ResetTokens}, nil
+}
+
+func (s *userTableStore) FindUser(username string) (*UserRecord, error) {
+	records, err := dvdbmanager.RecordFindByField(s.users, "username", username)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return NewUserRecord(dvdbmanager.RecordToStringMap(records[0])), nil
+}
+
+func (s *userTableStore) CreateUser(user *UserRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	id := time.Now().UnixNano()
+	if id <= s.lastId {
+		id = s.lastId + 1
+	}
+	s.lastId = id
+	user.Id = strconv.FormatInt(id, 10)
+	body, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	return dvdbmanager.RecordResultError(dvdbmanager.RecordCreate(s.users, string(body), user.Id))
+}
+
+func (s *userTableStore) UpdateUser(user *UserRecord) error {
+	body, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	res := dvdbmanager.RecordUpdate(s.users, string(body))
+	if err = dvdbmanager.RecordResultError(res); err != nil {
+		return err
+	}
+	if record, ok := res.(*dvevaluation.DvVariable); !ok || record == nil {
+		return errors.New("user " + user.Username + " is not found")
+	}
+	return nil
+}
+
+// SaveResetToken saves the token and removes the expired tokens
+func (s *userTableStore) SaveResetToken(token *ResetToken) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	all, err := dvdbmanager.RecordReadAll(s.resetTokens)
+	if err != nil {
+		return err
+	}
+	if all != nil {
+		now := dvsecurity.GetCurrentSeconds()
+		expired := make([]string, 0, len(all.Fields))
+		for _, record := range all.Fields {
+			expiresAt, err := strconv.ParseInt(record.ReadSimpleChildValue("expires_at"), 10, 64)
+			if err == nil && expiresAt < now {
+				expired = append(expired, record.ReadSimpleChildValue("id"))
+			}
+		}
+		if len(expired) != 0 {
+			if err = dvdbmanager.RecordResultError(dvdbmanager.RecordDelete(s.resetTokens, strings.Join(expired, ","))); err != nil {
+				return err
+			}
+		}
+	}
+	body, err := json.Marshal(map[string]interface{}{"id": token.Id, "username": token.Username, "expires_at": token.ExpiresAt})
+	if err != nil {
+		return err
+	}
+	return dvdbmanager.RecordResultError(dvdbmanager.RecordCreate(s.resetTokens, string(body), token.Id))
+}
+
+func (s *userTableStore) TakeResetToken(id string) (*ResetToken, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	records, err := dvdbmanager.RecordFindByField(s.resetTokens, "id", id)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	if err = dvdbmanager.RecordResultError(dvdbmanager.RecordDelete(s.resetTokens, id)); err != nil {
+		return nil, err
+	}
+	fields := dvdbmanager.RecordToStringMap(records[0])
+	expiresAt, _ := strconv.ParseInt(fields["expires_at"], 10, 64)
+	return &ResetToken{Id: id, Username: fields["username"], ExpiresAt: expiresAt}, nil
+}
@@ -0,0 +1,124 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvmodules"
+	"github.com/Dobryvechir/microcore/pkg/dvsecurity"
+)
+
+var apiKeyProbeUser *dvcontext.UserInfo
+
+func apiKeyContext(security *dvcontext.SecurityServerInfo, url string, body string) *dvcontext.RequestContext {
+	return &dvcontext.RequestContext{
+		Id:                        dvcontext.GetUniqueId(),
+		PrimaryContextEnvironment: dvevaluation.NewObjectWithPrototype(map[string]interface{}{}, env),
+		Reader:                    httptest.NewRequest("GET", url, nil),
+		Writer:                    httptest.NewRecorder(),
+		Url:                       url,
+		Server:                    &dvcontext.MicroCoreInfo{SecurityInfo: security},
+		Input:                     []byte(body),
+	}
+}
+
+func apiKeyAdmin(security *dvcontext.SecurityServerInfo, handler dvcontext.HandlerFunc, body string, result interface{}) int {
+	ctx := apiKeyContext(security, "/apikeys", body)
+	handler(ctx)
+	json.Unmarshal(ctx.Output, result)
+	return ctx.StatusCode
+}
+
+func apiKeyRequest(security *dvcontext.SecurityServerInfo, url string, key string, roles string) (bool, int) {
+	ctx := apiKeyContext(security, url, "")
+	if key != "" {
+		ctx.Reader.Header.Set("X-API-Key", key)
+	}
+	apiKeyProbeUser = nil
+	dvmodules.FireAction(&dvcontext.DvAction{Name: "machine", Typ: "apikey_test_probe", Auth: "apikey", Roles: roles}, ctx)
+	return apiKeyProbeUser != nil, ctx.StatusCode
+}
+
+func proveApiKeys() {
+	dvmodules.RegisterActionProcessor("apikey_test_probe", func(ctx *dvcontext.RequestContext) bool {
+		apiKeyProbeUser = ctx.User
+		return true
+	}, true)
+	dir := prepareOAuthTables("api_keys")
+	checkCondition("api key tables are created", dir != "")
+	if dir == "" {
+		return
+	}
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "keys.json")
+	security := &dvcontext.SecurityServerInfo{RolePrefix: "app", ApiKeys: &dvcontext.ApiKeyInfo{File: keyFile}}
+
+	created := make(map[string]interface{})
+	status := apiKeyAdmin(security, dvsecurity.ApiKeyCreateByRequestEndPointHandler,
+		`{"name":"billing","roles":["app-reader","app-writer"],"tenant_id":"t1","expires_in":3600,"rate_limit":2}`, &created)
+	key, _ := created["key"].(string)
+	checkCondition("api key is created", status == 201 && strings.HasPrefix(key, dvsecurity.ApiKeyPrefix) && created["tenant_id"] == "t1")
+	data, _ := os.ReadFile(keyFile)
+	checkCondition("api key is stored only as hash", len(data) > 0 && !strings.Contains(string(data), key) &&
+		strings.Contains(string(data), dvsecurity.HashApiKey(key)))
+	status = apiKeyAdmin(security, dvsecurity.ApiKeyCreateByRequestEndPointHandler, "roles=reader", &map[string]interface{}{})
+	checkCondition("api key without name is rejected", status == 400)
+	status = apiKeyAdmin(security, dvsecurity.ApiKeyCreateByRequestEndPointHandler, "name=report&roles=app-reader,app-auditor", &created)
+	reportKey, _ := created["key"].(string)
+	reportId, _ := created["id"].(string)
+	checkCondition("url encoded api key is created", status == 201 && reportKey != "" && reportKey != key)
+	var list []map[string]interface{}
+	status = apiKeyAdmin(security, dvsecurity.ApiKeyListByRequestEndPointHandler, "", &list)
+	checkCondition("api keys are listed without hashes and keys", status == 200 && len(list) == 2 &&
+		list[0]["name"] != list[1]["name"] && list[0]["hash"] == nil && list[0]["key"] == nil)
+
+	ok, _ := apiKeyRequest(security, "/machine", key, "")
+	checkCondition("api key in header is accepted", ok && apiKeyProbeUser.Name == "billing" && apiKeyProbeUser.TenantId == "t1" &&
+		apiKeyProbeUser.Source == dvcontext.UserSourceApiKey)
+	ok, _ = apiKeyRequest(security, "/machine?api_key="+reportKey, "", "app-auditor")
+	checkCondition("api key in query is accepted with roles", ok)
+	ok, status = apiKeyRequest(security, "/machine", key, "app-auditor")
+	checkCondition("api key without role is forbidden", !ok && status == 403)
+	ok, status = apiKeyRequest(security, "/machine", key, "")
+	checkCondition("api key rate limit is exceeded", !ok && status == 429)
+	ok, status = apiKeyRequest(security, "/machine", "", "")
+	checkCondition("request without api key is unauthorized", !ok && status == 401)
+	ok, status = apiKeyRequest(security, "/machine", key+"x", "")
+	checkCondition("wrong api key is unauthorized", !ok && status == 401)
+
+	reloaded := &dvcontext.SecurityServerInfo{ApiKeys: &dvcontext.ApiKeyInfo{File: keyFile}}
+	ok, _ = apiKeyRequest(reloaded, "/machine", reportKey, "")
+	checkCondition("api keys are loaded from the file", ok)
+	status = apiKeyAdmin(security, dvsecurity.ApiKeyRevokeByRequestEndPointHandler, "id="+reportId, &map[string]interface{}{})
+	checkCondition("api key is revoked", status == 200)
+	ok, status = apiKeyRequest(security, "/machine", reportKey, "")
+	checkCondition("revoked api key is unauthorized", !ok && status == 401)
+	status = apiKeyAdmin(security, dvsecurity.ApiKeyRevokeByRequestEndPointHandler, "id="+reportId, &map[string]interface{}{})
+	checkCondition("unknown api key is not revoked", status == 404)
+
+	tableSecurity := &dvcontext.SecurityServerInfo{RolePrefix: "app", ApiKeys: &dvcontext.ApiKeyInfo{Header: "X-Machine-Key"}}
+	status = apiKeyAdmin(tableSecurity, dvsecurity.ApiKeyCreateByRequestEndPointHandler, "name=table&roles=app-reader", &created)
+	key, _ = created["key"].(string)
+	ctx := apiKeyContext(tableSecurity, "/machine", "")
+	ctx.Reader.Header.Set("X-Machine-Key", key)
+	apiKeyProbeUser = nil
+	dvmodules.FireAction(&dvcontext.DvAction{Name: "machine", Typ: "apikey_test_probe", Auth: "apikey", Roles: "app-reader"}, ctx)
+	apiKeyEnv, _ := ctx.PrimaryContextEnvironment.Get("API_KEY")
+	checkCondition("api key of table store is accepted by configured header", status == 201 && apiKeyProbeUser != nil &&
+		apiKeyProbeUser.Name == "table" && strings.Contains(dvevaluation.AnyToString(apiKeyEnv), "table"))
+	m, _ := dvsecurity.GetApiKeyManager(tableSecurity)
+	expired := &dvsecurity.ApiKey{Id: "expired", Hash: dvsecurity.HashApiKey("mk_expired"), Name: "old", ExpiresAt: 1}
+	checkCondition("expired api key is saved", m != nil && m.Store.SaveKey(expired) == nil)
+	_, err := m.Authenticate("mk_expired")
+	checkCondition("expired api key is rejected", err == dvsecurity.ErrApiKeyExpired)
+}
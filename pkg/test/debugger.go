@@ -0,0 +1,169 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Dobryvechir/microcore/pkg/dvaction"
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvmodules"
+)
+
+type debugEnvironment struct {
+	Scope map[string]interface{} `json:"scope"`
+	Local map[string]interface{} `json:"local"`
+}
+
+func debugRequest(method string, path string, body interface{}, result interface{}) error {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	request, err := http.NewRequest(method, "http://"+dvaction.GetDebuggerAddress()+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	data, err = ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %d %s", method, path, response.StatusCode, data)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(data, result)
+}
+
+// waitDebugPause waits until the only debugged request is paused
+func waitDebugPause() *dvaction.DebugSessionInfo {
+	for i := 0; i < 500; i++ {
+		var sessions []*dvaction.DebugSessionInfo
+		err := debugRequest("GET", "/sessions", nil, &sessions)
+		if err != nil {
+			fmt.Println(err.Error())
+			return nil
+		}
+		if len(sessions) == 1 && sessions[0].Paused {
+			return sessions[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+func checkDebugPause(name string, kind string, step string, line int) *dvaction.DebugSessionInfo {
+	info := waitDebugPause()
+	ok := info != nil && info.Location.Kind == kind && info.Location.Step == step && info.Location.Line == line
+	if !ok && info != nil {
+		name += fmt.Sprintf(" (paused at %s %s %d)", info.Location.Kind, info.Location.Step, info.Location.Line)
+	}
+	checkCondition(name, ok)
+	return info
+}
+
+func debugCommand(info *dvaction.DebugSessionInfo, command string) {
+	if info == nil {
+		return
+	}
+	err := debugRequest("POST", fmt.Sprintf("/sessions/%d/%s", info.Id, command), nil, nil)
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+}
+
+func proveDebugger() {
+	dir, err := ioutil.TempDir("", "microcore-debugger")
+	if err != nil {
+		checkCondition("temporary folder is created", false)
+		return
+	}
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, "step.js")
+	err = ioutil.WriteFile(script, []byte("let a = 1;\nlet b = a + 1;\nb * 10\n"), 0644)
+	if err != nil {
+		checkCondition("script is written", false)
+		return
+	}
+	err = dvaction.StartDebugger(&dvaction.DebuggerConfig{Listen: "127.0.0.1:0", Breakpoints: []string{"ACTION_dbg_2"}})
+	if err != nil {
+		checkCondition("debugger is started: "+err.Error(), false)
+		return
+	}
+	jsStep := `js:{"file":"` + filepath.ToSlash(script) + `","result":"%s"}`
+	properties := map[string]interface{}{
+		"ACTION_dbg_1":    fmt.Sprintf(jsStep, "R1"),
+		"ACTION_dbg_2":    `call:{"action":"ACTION_dbgsub"}`,
+		"ACTION_dbg_3":    fmt.Sprintf(jsStep, "R3"),
+		"ACTION_dbgsub_1": fmt.Sprintf(jsStep, "S1"),
+	}
+	reader := httptest.NewRequest("GET", "/dbg", nil)
+	reader.Header.Set(dvaction.DefaultDebugHeader, "true")
+	ctx := &dvcontext.RequestContext{
+		Id:                        dvcontext.GetUniqueId(),
+		PrimaryContextEnvironment: dvevaluation.NewObjectWithPrototype(properties, env),
+		Reader:                    reader,
+		Writer:                    httptest.NewRecorder(),
+		Url:                       "/dbg",
+		Server:                    &dvcontext.MicroCoreInfo{},
+	}
+	done := make(chan bool)
+	go func() {
+		dvmodules.FireAction(&dvcontext.DvAction{Name: "dbg"}, ctx)
+		close(done)
+	}()
+
+	info := checkDebugPause("debugger stops at the step breakpoint", "action", "ACTION_dbg_2", 0)
+	environment := &debugEnvironment{}
+	if info != nil {
+		err = debugRequest("GET", fmt.Sprintf("/sessions/%d/env", info.Id), nil, environment)
+	}
+	checkCondition("debugger shows the environment", err == nil && fmt.Sprint(environment.Local["R1"]) == "20")
+	debugCommand(info, "stepin")
+	info = checkDebugPause("debugger steps into the sub-sequence", "action", "ACTION_dbgsub_1", 0)
+	debugCommand(info, "stepin")
+	info = checkDebugPause("debugger steps into the script", "script", "", 1)
+	debugCommand(info, "step")
+	info = checkDebugPause("debugger steps over the script statement", "script", "", 2)
+	result := make(map[string]interface{})
+	if info != nil {
+		err = debugRequest("POST", fmt.Sprintf("/sessions/%d/evaluate", info.Id), &dvaction.DebugEvaluateRequest{Expression: "a + 100"}, &result)
+	}
+	checkCondition("debugger evaluates in the script scope", err == nil && fmt.Sprint(result["result"]) == "101")
+	err = debugRequest("POST", "/breakpoints", &dvaction.DebugBreakpointsRequest{Breakpoints: []string{"step.js:3"}}, nil)
+	checkCondition("script breakpoint is added", err == nil)
+	debugCommand(info, "continue")
+	info = checkDebugPause("debugger stops at the script line breakpoint", "script", "", 3)
+	err = debugRequest("DELETE", "/breakpoints", nil, nil)
+	checkCondition("breakpoints are removed", err == nil)
+	debugCommand(info, "step")
+	info = checkDebugPause("debugger steps over to the next step of the sub-sequence caller", "action", "ACTION_dbg_3", 0)
+	debugCommand(info, "continue")
+	select {
+	case <-done:
+		var sessions []*dvaction.DebugSessionInfo
+		err = debugRequest("GET", "/sessions", nil, &sessions)
+		checkCondition("debugged request is finished", err == nil && len(sessions) == 0 && ctx.StatusCode < 300)
+	case <-time.After(5 * time.Second):
+		checkCondition("debugged request is finished", false)
+	}
+}
@@ -0,0 +1,40 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/Dobryvechir/microcore/pkg/dvdbdata"
+)
+
+func testSqlUpsert(kind string, expected string) {
+	mask := dvdbdata.GetConnectionKindMask(kind)
+	query := dvdbdata.GetSqlUpsert(mask, "props", []string{"id", "name"}, []string{"id"})
+	if query != expected {
+		fmt.Printf("Upsert for %s: %s\n", kind, query)
+	}
+	checkCondition("upsert for "+kind, query == expected)
+}
+
+func proveSqlDialects() {
+	mysql := dvdbdata.GetConnectionKindMask("mariadb")
+	sqlite := dvdbdata.GetConnectionKindMask("sqlite3")
+	checkCondition("mysql and sqlite kinds are detected", mysql == dvdbdata.SqlMysqlLike && sqlite == dvdbdata.SqlSqliteLike)
+	checkCondition("mysql and sqlite placeholders", dvdbdata.GetSqlPlaceholder(mysql, 2) == "?" && dvdbdata.GetSqlPlaceholder(sqlite, 2) == "?")
+	checkCondition("mysql identifiers are quoted by backticks", dvdbdata.QuoteSqlIdentifier(mysql, "order") == "`order`")
+	checkCondition("sqlite identifiers are quoted by double quotes", dvdbdata.QuoteSqlIdentifier(sqlite, `a"b`) == `"a""b"`)
+	checkCondition("mysql and sqlite date functions", dvdbdata.GetTimestampLessDay(mysql) == "NOW() - INTERVAL 1 DAY" && dvdbdata.GetDateNowFunction(sqlite) == "CURRENT_TIMESTAMP")
+	checkCondition("mysql and sqlite batch limits", dvdbdata.GetSqlDialect(mysql).MaxBatch == dvdbdata.CommonMaxBatch && dvdbdata.GetSqlDialect(sqlite).MaxBatch == 500)
+	mysqlDialect := dvdbdata.GetSqlDialect(mysql)
+	checkCondition("mysql column types", mysqlDialect.MapColumnDefinition("id varchar primary") == "id varchar(255) primary" &&
+		mysqlDialect.MapColumnDefinition("applied_at timestamp") == "applied_at datetime(6)")
+	checkCondition("sqlite column types", dvdbdata.GetSqlDialect(sqlite).MapColumnDefinition("data clob") == "data text")
+	testSqlUpsert("mysql", "INSERT INTO props(id,name) VALUES(?,?) ON DUPLICATE KEY UPDATE name=VALUES(name)")
+	testSqlUpsert("sqlite", "INSERT INTO props(id,name) VALUES(?,?) ON CONFLICT(id) DO UPDATE SET name=EXCLUDED.name")
+	testSqlUpsert("postgres", "INSERT INTO props(id,name) VALUES($1,$2) ON CONFLICT(id) DO UPDATE SET name=EXCLUDED.name")
+	testSqlUpsert("oracle", "MERGE INTO props t USING (SELECT :1 id,:2 name FROM dual) s ON (t.id=s.id) WHEN MATCHED THEN UPDATE SET t.name=s.name WHEN NOT MATCHED THEN INSERT (id,name) VALUES (s.id,s.name)")
+}
@@ -337,7 +337,144 @@ func testEvaluation() {
 	testEvaluationSingle("", "V=new RegExp('^ech','y');T='#\\nechir#';V.lastIndex=2;(V.test(T)?1000:0)+V.lastIndex", "0", KindANY)
 	testEvaluationSingle("", "V=new RegExp('^ech','y');T='#vechir#';V.lastIndex=2;(V.test(T)?1000:0)+V.lastIndex", "0", KindANY)
 	testEvaluationSingle("", "V={'a':'?1','b':2};encodeURIObjectKeyValues(V)", "a=%3F1&b=2", KindANY)
+	testEvaluationSingle("", "new Date(0).toISOString()", "1970-01-01T00:00:00.000Z", KindANY)
+	testEvaluationSingle("", "Date.UTC(2020,0,2)", "1577923200000", KindANY)
+	testEvaluationSingle("", "Date.parse('2020-01-02T03:04:05.678Z')", "1577934245678", KindANY)
+	testEvaluationSingle("", "Date.parse('2020-01-02T05:04:05+02:00')", "1577934245000", KindANY)
+	testEvaluationSingle("", "new Date('2020-05-06').getUTCDate()", "6", KindANY)
+	testEvaluationSingle("", "new Date(2020,0,31,10,20).getHours()", "10", KindANY)
+	testEvaluationSingle("", "new Date(2020,0,32).getDate()", "1", KindANY)
+	testEvaluationSingle("", "new Date(Date.UTC(2020,1,29)).getUTCDay()", "6", KindANY)
+	testEvaluationSingle("", "D = new Date(0);D.now === undefined && D.parse === undefined && D.UTC === undefined && Date.now() > 0 && D instanceof Date", "true", KindBoolean)
+	testEvaluationSingle("", "(new Date(86400000) - new Date(0))/1000", "86400", KindInteger)
+	testEvaluationSingle("", "new Date(5) < new Date(6)", "true", KindBoolean)
+	testEvaluationSingle("", "V=new Date(0);V.setUTCFullYear(2001,1,3);V.toISOString()", "2001-02-03T00:00:00.000Z", KindANY)
+	testEvaluationSingle("", "V=new Date(0);V.setUTCHours(25);V.getUTCDate()", "2", KindANY)
+	testEvaluationSingle("", "new Date(new Date(1234)).getTime()", "1234", KindANY)
+	testEvaluationSingle("", "new Date('wrong').toJSON()", "null", KindANY)
+	testEvaluationSingle("", "''+new Date('wrong')", "Invalid Date", KindANY)
+	testEvaluationSingle("", "new Date(0).toUTCString()", "Thu, 01 Jan 1970 00:00:00 GMT", KindANY)
+	testEvaluationSingle("", "JSON.stringify(new Date(0))", "\"1970-01-01T00:00:00.000Z\"", KindANY)
+	testEvaluationSingle("", "JSON.stringify({d: new Date(0), w: new Date('wrong')})", "{\"d\":\"1970-01-01T00:00:00.000Z\",\"w\":null}", KindANY)
+	testEvaluationSingle("", "D={d: new Date(86400000)};D", "{\n  \"d\": \"1970-01-02T00:00:00.000Z\"\n}", KindANY)
+	testEvaluationSingle("", "A=0;while(A<5) A++;A", "5", KindInteger)
+	testEvaluationSingle("", "A=0;S=0;while(A<10){A++;if(A%2==0) continue;if(A>7) break;S+=A};S", "16", KindInteger)
+	testEvaluationSingle("", "A=0;do {A++} while(A<3);A", "3", KindInteger)
+	testEvaluationSingle("", "A=5;do {A++} while(A<3);A", "6", KindInteger)
+	testEvaluationSingle("", "I=5;N=0;for(;I<3;I++){N++};N", "0", KindInteger)
+	testEvaluationSingle("", "X=2;R='';switch(X){case 1: R+='1'; case 2: R+='2'; case 3: R+='3'; break; default: R+='d'};R", "23", KindANY)
+	testEvaluationSingle("", "X=9;R='';switch(X){case 1: R+='1'; default: R+='d'; case 2: R+='2'; break; case 3: R+='3'};R", "d2", KindANY)
+	testEvaluationSingle("", "X='a';R=0;switch(X){case 'b': R=1; break; case 'a': R=2; break};R", "2", KindInteger)
+	testEvaluationSingle("", "X=1;Y=1;R=0;switch(X){case Y?1:2: R=5};R", "5", KindInteger)
+	testEvaluationSingle("", "S=0;outer: for(I=0;I<3;I++){for(J=0;J<3;J++){if(J==1) continue outer;if(I==2) break outer;S+=10*I+J}};S", "10", KindInteger)
+	testEvaluationSingle("", "S=0;outer: while(S<100){switch(S){case 0: S=1; continue outer; default: S=200; break outer}};S", "200", KindInteger)
+	testEvaluationSingle("", "X={default:1,case:2};X.default+X.case", "3", KindInteger)
+	testEvaluationSingle("", "try { throw 5 } catch (e) { R = e + 1 };R", "6", KindInteger)
+	testEvaluationSingle("", "R=0;try { R=1 } finally { R = R + 10 };R", "11", KindInteger)
+	testEvaluationSingle("", "try { throw new TypeError('bad') } catch (e) { R = e.name + '|' + e.message };R", "TypeError|bad", KindANY)
+	testEvaluationSingle("", "try { throw new RangeError('x') } catch (e) { R = e.stack.indexOf('RangeError: x') == 0 && e.stack.indexOf('(0:') > 0 };R", "true", KindBoolean)
+	testEvaluationSingle("", "F = () => { throw {code: 7} };try { F() } catch(e) { R = e.code };R", "7", KindInteger)
+	testEvaluationSingle("", "R='';try { try { throw 1 } finally { R+='f' } } catch (e) { R += e };R", "f1", KindANY)
+	testEvaluationSingle("", "R=0;try { throw 1 } catch { R=2 };R", "2", KindInteger)
+	testEvaluationSingle("", "R='';for(I=0;I<3;I++){ try { if (I==1) continue; R+=I } finally { R+='f' } };R", "0ff2f", KindANY)
+	testEvaluationSingle("", "E=1;try { throw 2 } catch (E) { Z = E };E+Z", "3", KindInteger)
+	testEvaluationSingle("", "''+new Error('m')", "Error: m", KindANY)
+	testEvaluationSingle("", "try { JSON.parse('{') } catch(e) { R = e.name };R", "Error", KindANY)
+	testEvaluationSingle("", "F = () => { try { return 1 } finally { R = 2 } };F()+R", "3", KindInteger)
+	testEvaluationSingle("", "let A = 1, B = 2; const C = A + B; C", "3", KindInteger)
+	testEvaluationSingle("", "let X = 1; if (true) { let X = 5; Y = X };X*10+Y", "15", KindInteger)
+	testEvaluationSingle("", "let X = 1; { let X = 2 };X", "1", KindInteger)
+	testEvaluationSingle("", "S = 0; for (let I = 0; I < 4; I++) { S += I };S", "6", KindInteger)
+	testEvaluationSingle("", "F = []; for (let I = 0; I < 3; I++) { F.push(() => I) };F.map(f => f()).join(',')", "0,1,2", KindANY)
+	testEvaluationSingle("", "function counter() { let n = 0; return { inc: () => ++n, get() { return n } } };C = counter();C.inc();C.inc();C.get()", "2", KindInteger)
+	testEvaluationSingle("", "S = ''; for (const K in {a:1,b:2}) { S += K };S", "ab", KindANY)
+	testEvaluationSingle("", "try { const C = 1; C = 2 } catch (e) { R = e.name };R", "TypeError", KindANY)
+	testEvaluationSingle("", "try { undefinedVar } catch (e) { R = e.name + '|' + e.message + '|' + (e instanceof ReferenceError) };R", "ReferenceError|undefinedVar is not defined|true", KindANY)
+	testEvaluationSingle("", "try { X = 1; X() } catch (e) { R = e.name + '|' + e.message };R", "TypeError|1 is not a function", KindANY)
+	testEvaluationSingle("", "try { null.x } catch (e) { R = e.name + '|' + e.message };R", "TypeError|Cannot read properties of null (reading 'x')", KindANY)
+	testEvaluationSingle("", "try { undefined.foo() } catch (e) { R = e.name + '|' + e.message };R", "TypeError|Cannot read properties of undefined (reading 'foo')", KindANY)
+	testEvaluationSingle("", "try { O = {}; O.f() } catch (e) { R = (e instanceof TypeError) + '|' + e.name };R", "true|TypeError", KindANY)
+	testEvaluationSingle("", "try { O = {a: null}; O.a.b } catch (e) { R = e.name };R", "TypeError", KindANY)
+	testEvaluationSingle("", "try { Z; let Z = 3 } catch (e) { R = e.name };R", "ReferenceError", KindANY)
+	testEvaluationSingle("", "T = 0; switch (2) { case 2: let U = 7; T = U };T", "7", KindInteger)
+	testEvaluationSingle("", "function M(a) { this.a = a };M.prototype.twice = function() { return this.a * 2 };new M(5).twice()", "10", KindInteger)
+	testEvaluationSingle("", "R = sum(2, 3);function sum(a, b) { return a + b };R", "5", KindInteger)
+	testEvaluationSingle("", "class P { x = 1; constructor(y) { this.y = y } sum() { return this.x + this.y } };new P(4).sum()", "5", KindInteger)
+	testEvaluationSingle("", "class T { get d() { return this.v * 2 } set d(n) { this.v = n / 2 } };O = new T();O.d = 8;O.v*10+O.d", "48", KindInteger)
+	testEvaluationSingle("", "class C { static n = 3; static make() { return new C() } };C.make() instanceof C ? C.n : 0", "3", KindInteger)
+	testEvaluationSingle("", "class A { hi() { return 'A' } };class B extends A { hi() { return 'B' + super.hi() } };new B().hi()", "BA", KindANY)
+	testEvaluationSingle("", "class A { constructor(n) { this.n = n } };class B extends A { constructor(n) { super(n + 1); this.k = 2 } };O = new B(1);O.n*10+O.k", "22", KindInteger)
+	testEvaluationSingle("", "class A {};class B extends A {};O = new B();(O instanceof A) && (O instanceof B)", "true", KindBoolean)
+	testEvaluationSingle("", "class A {};X = {};X instanceof A", "false", KindBoolean)
+	testEvaluationSingle("", "class E extends Error {};try { throw new E('bad') } catch (e) { R = (e instanceof Error) + e.message };R", "truebad", KindANY)
+	testEvaluationSingle("", "new TypeError('x') instanceof Error", "true", KindBoolean)
+	testEvaluationSingle("", "function F() {};F.prototype.constructor === F", "true", KindBoolean)
+	testEvaluationSingle("", "class A {};class B extends A {};new B().constructor === B && B.prototype.constructor !== A", "true", KindBoolean)
+	testEvaluationSingle("", "O = {};O.a === 1", "false", KindBoolean)
+	testEvaluationSingle("", "O = {};O.a !== 1", "true", KindBoolean)
+	testEvaluationSingle("S=Good evening", "`${S}, ${1 + 2} times`", "Good evening, 3 times", KindANY)
+	testEvaluationSingle("", "A = {b: [5]};`v=${A.b[0]} ${`in${A.b.length}`}`", "v=5 in1", KindANY)
+	testEvaluationSingle("", "`line1\nline2`", "line1\nline2", KindANY)
+	testEvaluationSingle("", "`a \\` \\${b}`", "a ` ${b}", KindANY)
+	testEvaluationSingle("", "function T(s, a, b) { return s.join('|') + ':' + (a + b) };T`x${1}y${2}z`", "x|y|z:3", KindANY)
+	testEvaluationSingle("", "X = {a: 1, b: [5], c: 3, d: 4};let {a, b: [c, d = 2], ...r} = X;a + '-' + c + '-' + d + '-' + r.c + r.d", "1-5-2-34", KindANY)
+	testEvaluationSingle("", "A = 1;B = 2;[A, B] = [B, A];A*10+B", "21", KindInteger)
+	testEvaluationSingle("", "function f(x, y, z) { return x + y + z };f(...[1, 2], 3)", "6", KindInteger)
+	testEvaluationSingle("", "X = [1, 2];[0, ...X, ...'ab'].join('')", "012ab", KindANY)
+	testEvaluationSingle("", "O = {a: 1, k: 5};P = {...O, k: 2};P.a*10+P.k", "12", KindInteger)
+	testEvaluationSingle("", "function g(a, {b}, [c = 4], ...rest) { return a + b + c + rest.length };g(1, {b: 2}, [], 7, 8)", "9", KindInteger)
+	testEvaluationSingle("", "S = 0;for (const [k, v] of [[1, 2], [3, 4]]) { S += k * v };S", "14", KindInteger)
+	testEvaluationSingle("", "H = (a = 5, {b = 3} = {}) => { return a * b };H()", "15", KindInteger)
+	testEvaluationSingle("", "O = {};[O.x, , O.y] = [1, 2, 3];O.x + O.y", "4", KindInteger)
+	testEvaluationSingle("", "S = '';for (const c of 'héllo') { if (c == 'l') continue; S += c };S", "héo", KindANY)
+	testEvaluationSingle("", "S = '';for (const k in {a: 1, b: 2}) S += k;S", "ab", KindANY)
+	testEvaluationSingle("", "S = 0;for (const [i, v] of [5, 6].entries()) S += i * v;S", "6", KindInteger)
+	testEvaluationSingle("", "S = 0;IT = {i: 0, next() { this.i++; return {value: this.i, done: false} }};for (const v of IT) { if (v > 4) break; S += v };S", "10", KindInteger)
+	testEvaluationSingle("", "K = 'a';O = {[K + 'b']: 1, m(x) { return x * 2 }};O.m(O.ab)", "2", KindInteger)
+	testEvaluationSingle("", "O = {'@@iterator'() { return {i: 0, next() { this.i++; return {value: this.i, done: this.i > 3} }} }};[...O].join(',')", "1,2,3", KindANY)
+	testEvaluationSingle("", "C = 0;IT = {next() { return {value: 1, done: false} }, 'return'() { C++; return {} }};for (const v of IT) { break };C", "1", KindInteger)
+	testEvaluationSingle("", "S = 0;outer: for (const a of [1, 2]) { for (const b of [1, 2]) { if (b == 2) continue outer; S += a * b } };S", "3", KindInteger)
+	testEvaluationSingle("", "M = new Map();M.set('a', 1).set(2, 'b');M.set('a', 3);M.size*100+M.get('a')*10+(M.has(2) ? 1 : 0)", "231", KindInteger)
+	testEvaluationSingle("", "M = new Map([['x', 1], ['y', 2]]);S = '';for (const [k, v] of M) S += k + v;S", "x1y2", KindANY)
+	testEvaluationSingle("", "M = new Map([['x', 1], ['y', 2]]);M.delete('x');JSON.stringify(M)", "{\"y\":2}", KindANY)
+	testEvaluationSingle("", "S = new Set([1, 2, 2, 3, 1]);S.add(4);S.delete(2);[...S].join(',') + ':' + S.size", "1,3,4:3", KindANY)
+	testEvaluationSingle("", "S = new Set([3, 1]);JSON.stringify({s: S})", "{\"s\":[3,1]}", KindANY)
+	testEvaluationSingle("", "M = new Map([[1, 'a'], [2, 'b']]);R = '';M.forEach((v, k) => { R += k + '=' + v });R", "1=a2=b", KindANY)
+	testEvaluationSingle("", "O = {};M = new Map();M.set(O, 5);M.set({}, 6);M.get(O)*10+M.size", "52", KindInteger)
+	testEvaluationSingle("", "W = new WeakMap();K = {};W.set(K, 7);W.has(K) ? W.get(K) : 0", "7", KindInteger)
+	testEvaluationSingle("", "Array.from(new Set(['b', 'a', 'b'])).join('')", "ba", KindANY)
+	testEvaluationSingle("", "Y = Symbol('y');O = {[Y]: 1, [Symbol.iterator]() { return {i: 0, next() { this.i++; return {value: this.i, done: this.i > 2} }} }};O[Y] + [...O].join(',')", "11,2", KindANY)
+	testEvaluationSingle("", "new Map() instanceof Map && !(new Set() instanceof Map)", "true", KindBoolean)
+	testEvaluationSingle("", "L = [];async function f(x) { L.push('f' + x); const v = await x; L.push('a' + v); return v * 2 };P = f(3);L.push('s');P.then(v => L.push('t' + v));setTimeout(() => L.push('timeout'), 0);queueMicrotask(() => L.push('m'));await new Promise(r => setTimeout(r, 5));L.join(',')", "f3,s,a3,m,t6,timeout", KindANY)
+	testEvaluationSingle("", "R = await Promise.all([1, Promise.resolve(2), new Promise(r => setTimeout(() => r(3), 10))]);R.join(',')", "1,2,3", KindANY)
+	testEvaluationSingle("", "R = await Promise.allSettled([Promise.reject(new Error('no')), 5]);R[0].status + ':' + R[0].reason.message + ',' + R[1].status + ':' + R[1].value", "rejected:no,fulfilled:5", KindANY)
+	testEvaluationSingle("", "await Promise.race([new Promise(r => setTimeout(() => r('slow'), 30)), new Promise(r => setTimeout(() => r('fast'), 1))])", "fast", KindANY)
+	testEvaluationSingle("", "E = await Promise.any([Promise.reject(1), Promise.reject(2)]).catch(e => e.name + e.errors.length);E", "AggregateError2", KindANY)
+	testEvaluationSingle("", "const g = async (a) => { try { await Promise.reject(new Error('x' + a)) } catch (e) { return e.message } };await g(1)", "x1", KindANY)
+	testEvaluationSingle("", "S = [];Promise.resolve(1).then(v => v + 1).then(v => { throw v }).catch(v => S.push(v)).finally(() => S.push('f'));await null;await null;await null;await null;S.join('')", "2f", KindANY)
+	testEvaluationSingle("", "N = 0;I = setInterval(() => { N++; if (N == 3) clearInterval(I) }, 1);await new Promise(r => setTimeout(r, 30));N", "3", KindInteger)
+	testEvaluationSingle("", "N = 0;T = setTimeout(() => { N = 5 }, 1);clearTimeout(T);O = {async m() { return 7 }};await O.m() + N", "7", KindInteger)
+	testEvaluationSingle("", "S = 0;for (let I = 0; I < 3; I++) { setTimeout(() => { S += I }, 0) };await new Promise(r => setTimeout(r, 5));S", "3", KindInteger)
+	testEvaluationSingle("", "L = [];setTimeout(() => L.push(2), 2);setTimeout(() => L.push(1), 1);setTimeout(() => L.push(3), 2);await new Promise(r => setTimeout(r, 20));L.join(',')", "1,2,3", KindANY)
+	testEvaluationSingle("", "class A { async get() { return 4 } };X = new A().get();(X instanceof Promise) + ':' + await X", "true:4", KindANY)
+	testEvaluationSingle("", "[1, 2, 3].map(v => v * 2).filter(v => v > 2).length", "2", KindInteger)
+	testEvaluationSingle("", "function f() { return f() };R = '';try { f() } catch (e) { R = e.name + ':' + e.message };R", "RangeError:Maximum call stack size exceeded", KindANY)
+	testEvaluationSingle("", "function f(n) { if (n == 0) return 0; return 1 + f(n - 1) };f(500)", "500", KindInteger)
 
+	proveScriptCache()
+	proveModules()
+	proveDebugger()
+	proveSqlParams()
+	proveTransactions()
+	proveSqlStream()
+	proveMigrations()
+	proveSqlDialects()
+	proveJwt()
+	proveJwks()
+	proveOAuth()
+	proveUsers()
+	proveActionPolicies()
+	proveApiKeys()
 	proveErrors()
 	showResume()
 }
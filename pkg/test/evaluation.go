@@ -8,6 +8,7 @@ package main
 import (
 	"fmt"
 	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
 	"github.com/Dobryvechir/microcore/pkg/dvjson"
 	"github.com/Dobryvechir/microcore/pkg/dvparser"
 	"github.com/Dobryvechir/microcore/pkg/dvtextutils"
@@ -86,6 +87,41 @@ func checkErrorPref(expr string, result string) {
 	checkError(expr, result, 1)
 }
 
+func checkErrorPrefWithLimits(expr string, result string, limits *dvgrammar.ExecutionLimits) {
+	env.Limits = limits
+	checkError(expr, result, 1)
+	env.Limits = nil
+}
+
+func testScriptFile(fileName string, result string) {
+	tested++
+	res, err := env.EvaluateScriptFile(fileName)
+	if err != nil {
+		fmt.Printf("Error file=[%s] exp=[%s] %v\n", fileName, result, err)
+		return
+	}
+	s := dvevaluation.AnyToString(res)
+	if s != result {
+		fmt.Printf("Expected [%s] but [%s] file=[%s]\n", result, s, fileName)
+		return
+	}
+	successful++
+}
+
+func checkScriptFileErrorPref(fileName string, result string) {
+	tested++
+	_, err := env.EvaluateScriptFile(fileName)
+	if err == nil {
+		fmt.Printf("Must be error [%s] but nothing detected in file=[%s]\n", result, fileName)
+		return
+	}
+	if s := err.Error(); !strings.HasPrefix(s, result) {
+		fmt.Printf("Expected error [%s] but [%s] in file=[%s]\n", result, s, fileName)
+		return
+	}
+	successful++
+}
+
 func initEnvironment() {
 	tested = 0
 	successful = 0
@@ -0,0 +1,101 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvsecurity"
+)
+
+func jwkBase64(b []byte) string {
+	return dvsecurity.EncodeJwtBase64(string(b))
+}
+
+func rsaJwk(kid string, key *rsa.PrivateKey) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": kid, "alg": "RS256", "use": "sig",
+		"n": jwkBase64(key.N.Bytes()), "e": jwkBase64(big.NewInt(int64(key.E)).Bytes())}
+}
+
+func ecJwk(kid string, key *ecdsa.PrivateKey) map[string]string {
+	return map[string]string{"kty": "EC", "kid": kid, "crv": "P-256",
+		"x": jwkBase64(key.X.FillBytes(make([]byte, 32))), "y": jwkBase64(key.Y.FillBytes(make([]byte, 32)))}
+}
+
+func proveJwks() {
+	first, _ := rsa.GenerateKey(rand.Reader, 2048)
+	second, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	var mutex sync.Mutex
+	keys := []map[string]string{rsaJwk("first", first), {"kty": "oct", "kid": "skipped", "k": "c2VjcmV0"}}
+	fetches := 0
+	var delay time.Duration
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		wait := delay
+		mutex.Unlock()
+		time.Sleep(wait)
+		mutex.Lock()
+		defer mutex.Unlock()
+		fetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	defer server.Close()
+	verifier, err := dvsecurity.NewJwtVerifier(&dvcontext.JwtVerificationInfo{Jwks: server.URL})
+	checkCondition("jwks verifier is created", err == nil && verifier.Jwks != nil)
+	if err != nil {
+		return
+	}
+	verifier.Jwks.MinRefresh = 0
+	claims := map[string]interface{}{"exp": dvsecurity.GetCurrentSeconds() + 100}
+	checkJwt("token is verified by jwks key", verifier, signTestJwt("RS256", "first", first, claims), true)
+	checkJwt("jwks keys are cached", verifier, signTestJwt("RS256", "first", first, claims), true)
+	checkCondition("jwks is fetched once", fetches == 1)
+
+	mutex.Lock()
+	keys = []map[string]string{rsaJwk("first", first), ecJwk("second", second)}
+	mutex.Unlock()
+	checkJwt("jwks is refreshed for unknown kid", verifier, signTestJwt("ES256", "second", second, claims), true)
+	checkCondition("jwks is fetched again", fetches == 2)
+
+	verifier.Jwks.MinRefresh = time.Hour
+	checkJwt("token with unknown kid is rejected", verifier, signTestJwt("RS256", "third", first, claims), false)
+	checkJwt("token with unknown kid is rejected again", verifier, signTestJwt("RS256", "fourth", first, claims), false)
+	checkCondition("jwks refresh is rate limited", fetches == 2)
+
+	mutex.Lock()
+	delay = 2 * time.Second
+	mutex.Unlock()
+	verifier.Jwks.MinRefresh = 0
+	verifier.Jwks.CacheTime = 0
+	start := time.Now()
+	checkJwt("outdated jwks keys are served while they are reloaded", verifier, signTestJwt("RS256", "first", first, claims), true)
+	checkJwt("outdated jwks keys are served during the load", verifier, signTestJwt("ES256", "second", second, claims), true)
+	checkCondition("outdated jwks keys are served without waiting", time.Since(start) < delay)
+	time.Sleep(delay + time.Second)
+	mutex.Lock()
+	checkCondition("jwks is reloaded once in the background", fetches == 3)
+	mutex.Unlock()
+
+	big := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"keys":[],"padding":"`))
+		w.Write([]byte(strings.Repeat("a", 2<<20)))
+		w.Write([]byte(`"}`))
+	}))
+	defer big.Close()
+	source := dvsecurity.NewJwksKeySource(big.URL, 0, 0)
+	checkCondition("too big jwks is not loaded", len(source.GetKeys("")) == 0 && len(source.GetKeys("first")) == 0)
+}
@@ -0,0 +1,139 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http/httptest"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvmodules"
+	"github.com/Dobryvechir/microcore/pkg/dvsecurity"
+)
+
+func signTestJwt(alg string, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := dvsecurity.EncodeJwtBase64(string(header)) + "." + dvsecurity.EncodeJwtBase64(string(payload))
+	h := crypto.SHA256.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+	var signature []byte
+	switch alg {
+	case "ES256":
+		// jwt keeps r and s of ecdsa signature as fixed size numbers, not in asn.1
+		r, s, _ := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), digest)
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case "PS256":
+		signature, _ = rsa.SignPSS(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	default:
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest)
+	}
+	return signed + "." + dvsecurity.EncodeJwtBase64(string(signature))
+}
+
+func publicKeyPem(key crypto.Signer) string {
+	data, _ := x509.MarshalPKIXPublicKey(key.Public())
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: data}))
+}
+
+func checkJwt(name string, verifier *dvsecurity.JwtVerifier, token string, valid bool) {
+	_, err := verifier.Verify(token)
+	checkCondition(name, (err == nil) == valid)
+}
+
+func proveJwt() {
+	now := dvsecurity.GetCurrentSeconds()
+	claims := map[string]interface{}{"exp": now + 100, "realm_access": map[string]interface{}{"roles": []string{"admin"}}}
+	_, err := dvsecurity.GetJwtVerifier(nil)
+	checkCondition("tokens are not accepted without security settings", err == dvsecurity.ErrJwtNotConfigured)
+	_, err = dvsecurity.GetJwtVerifier(&dvcontext.SecurityServerInfo{RolePrefix: "app"})
+	checkCondition("tokens are not accepted without jwt settings", err == dvsecurity.ErrJwtNotConfigured)
+	forged := dvsecurity.GenerateHs256Jwt(claims, dvsecurity.SecretKey, now+100)
+	ctx := &dvcontext.RequestContext{
+		Id:                        dvcontext.GetUniqueId(),
+		PrimaryContextEnvironment: dvevaluation.NewObjectWithPrototype(map[string]interface{}{}, env),
+		Reader:                    httptest.NewRequest("GET", "/admin", nil),
+		Writer:                    httptest.NewRecorder(),
+		Url:                       "/admin",
+		Server:                    &dvcontext.MicroCoreInfo{},
+	}
+	ctx.Reader.Header.Set("Authorization", "Bearer "+forged)
+	_, err = dvmodules.AnalyzeAuthToken(ctx)
+	checkCondition("token signed by built-in secret is rejected without settings", err != nil)
+
+	secret := "jwt-test-secret"
+	hsVerifier, _ := dvsecurity.NewJwtVerifier(&dvcontext.JwtVerificationInfo{Keys: []*dvcontext.JwtKeyInfo{{Alg: "HS256", Secret: secret}}})
+	own := dvsecurity.GenerateHs256Jwt(claims, secret, now+100)
+	checkJwt("token of configured secret is accepted", hsVerifier, own, true)
+	checkJwt("token with another secret is rejected", hsVerifier, dvsecurity.GenerateHs256Jwt(claims, "forged", now+100), false)
+	checkJwt("expired token is rejected", hsVerifier, dvsecurity.GenerateHs256Jwt(map[string]interface{}{"exp": now - 100}, secret, 0), false)
+	checkJwt("token without expiration is rejected", hsVerifier, dvsecurity.GenerateHs256Jwt(map[string]interface{}{"sub": "a"}, secret, 0), false)
+	checkJwt("expired token within clock skew is accepted", hsVerifier, dvsecurity.GenerateHs256Jwt(map[string]interface{}{"exp": now - 10}, secret, 0), true)
+	checkJwt("token before nbf is rejected", hsVerifier, dvsecurity.GenerateHs256Jwt(map[string]interface{}{"exp": now + 1000, "nbf": now + 500}, secret, 0), false)
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	info := &dvcontext.JwtVerificationInfo{
+		Keys: []*dvcontext.JwtKeyInfo{
+			{Kid: "rsa", PublicKey: publicKeyPem(rsaKey)},
+			{Kid: "ec", Alg: "ES256", PublicKey: publicKeyPem(ecKey)},
+			{Kid: "hs", Alg: "HS512", Secret: "shared"},
+		},
+		Issuers:   []string{"https://issuer"},
+		Audiences: []string{"api"},
+	}
+	verifier, err := dvsecurity.NewJwtVerifier(info)
+	checkCondition("jwt keys are read", err == nil)
+	if err != nil {
+		return
+	}
+	claims = map[string]interface{}{"exp": now + 100, "iss": "https://issuer", "aud": []string{"web", "api"}}
+	checkJwt("RS256 token is accepted", verifier, signTestJwt("RS256", "rsa", rsaKey, claims), true)
+	checkJwt("PS256 token is accepted", verifier, signTestJwt("PS256", "rsa", rsaKey, claims), true)
+	checkJwt("ES256 token is accepted", verifier, signTestJwt("ES256", "ec", ecKey, claims), true)
+	checkJwt("token with wrong kid is rejected", verifier, signTestJwt("RS256", "ec", rsaKey, claims), false)
+	checkJwt("HS256 token with public key as secret is rejected", verifier, dvsecurity.GenerateHs256Jwt(claims, publicKeyPem(rsaKey), now+100), false)
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	checkJwt("token signed by another key is rejected", verifier, signTestJwt("RS256", "rsa", other, claims), false)
+	claims["aud"] = "web"
+	checkJwt("token for another audience is rejected", verifier, signTestJwt("RS256", "rsa", rsaKey, claims), false)
+	claims["aud"] = "api"
+	claims["iss"] = "https://other"
+	checkJwt("token of another issuer is rejected", verifier, signTestJwt("RS256", "rsa", rsaKey, claims), false)
+
+	hsSecurity := &dvcontext.SecurityServerInfo{Jwt: &dvcontext.JwtVerificationInfo{Keys: []*dvcontext.JwtKeyInfo{{Secret: secret}}}}
+	for _, test := range []struct {
+		name     string
+		token    string
+		rejected bool
+	}{
+		{"forged token is rejected by the action", dvsecurity.GenerateHs256Jwt(map[string]interface{}{"exp": now + 100, "realm_access": map[string]interface{}{"roles": []string{"admin"}}}, "forged", now+100), true},
+		{"token of built-in secret is rejected by the action", forged, true},
+		{"valid token is accepted by the action", own, false},
+	} {
+		reader := httptest.NewRequest("GET", "/jwt", nil)
+		reader.Header.Set("Authorization", "Bearer "+test.token)
+		ctx := &dvcontext.RequestContext{
+			Id:                        dvcontext.GetUniqueId(),
+			PrimaryContextEnvironment: dvevaluation.NewObjectWithPrototype(map[string]interface{}{}, env),
+			Reader:                    reader,
+			Writer:                    httptest.NewRecorder(),
+			Url:                       "/jwt",
+			Server:                    &dvcontext.MicroCoreInfo{SecurityInfo: hsSecurity},
+		}
+		dvmodules.FireAction(&dvcontext.DvAction{Name: "jwt", Roles: "ROLES.admin"}, ctx)
+		checkCondition(test.name, (ctx.StatusCode == 401) == test.rejected)
+	}
+}
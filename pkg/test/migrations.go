@@ -0,0 +1,60 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Dobryvechir/microcore/pkg/dvdbdata"
+)
+
+func proveMigrations() {
+	up, down := dvdbdata.ParseMigration([]byte("-- users\nCREATE TABLE users(id int);\n-- DOWN\nDROP TABLE users;\n"))
+	checkCondition("migration is split by down section", string(up) == "CREATE TABLE users(id int);\n" && string(down) == "DROP TABLE users;\n")
+	up, down = dvdbdata.ParseMigration([]byte("CREATE INDEX users_id ON users(id);"))
+	checkCondition("migration without down section", string(up) == "CREATE INDEX users_id ON users(id);" && down == nil)
+
+	dir, err := ioutil.TempDir("", "microcore-migrations")
+	if err != nil {
+		checkCondition("temporary folder is created", false)
+		return
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"002_index.sql":        "CREATE INDEX users_id ON users(id);",
+		"001_users.sql":        "CREATE TABLE users(id int);\n-- down\nDROP TABLE users;",
+		"003_seq.oracle.sql":   "CREATE SEQUENCE users_seq;",
+		"003_seq.postgres.sql": "CREATE SEQUENCE users_seq;",
+		"004_roles.sql":        "CREATE TABLE roles(id int);",
+		"readme.txt":           "not a migration",
+	}
+	for name, content := range files {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+	migrations, err := dvdbdata.ReadMigrationFiles(dir, "postgres")
+	names := make([]string, len(migrations))
+	for i, migration := range migrations {
+		names[i] = migration.Name
+	}
+	checkCondition("migration files are read in order for the sql kind", err == nil && strings.Join(names, ",") == "001_users.sql,002_index.sql,003_seq.postgres.sql,004_roles.sql")
+	if len(migrations) != 4 {
+		return
+	}
+	history := []*dvdbdata.MigrationRecord{
+		{Name: "000_old.sql", Checksum: "x"},
+		{Name: "001_users.sql", Checksum: migrations[0].Checksum},
+		{Name: "002_index.sql", Checksum: dvdbdata.GetMigrationChecksum([]byte("CREATE INDEX users_id ON users(name);"))},
+	}
+	statuses := dvdbdata.CompareMigrations(migrations, history)
+	states := make([]string, len(statuses))
+	for i, status := range statuses {
+		states[i] = status.Name + ":" + status.State
+	}
+	checkCondition("migration states are detected", strings.Join(states, ",") == "000_old.sql:missing,001_users.sql:applied,002_index.sql:changed,003_seq.postgres.sql:pending,004_roles.sql:pending")
+}
@@ -0,0 +1,85 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var moduleFiles = map[string]string{
+	"lib/math.js": `const secret = 10;
+function helper(x) { return x + secret }
+export function addSecret(x) { return helper(x) }
+export let counter = 1;
+export const PI = 3, E = 2;
+export class Point { constructor(x) { this.x = x } get double() { return this.x * 2 + secret } }
+export default function twice(x) { return x * 2 }
+export function inc() { counter++; return counter }
+export const later = async () => { await null; return secret + 1 };`,
+	"lib/index.js": `export * from './math.js';
+export { default as twice, PI as pi } from './math';
+export * as all from './math.js';
+export default [1, 2, 3].map(x => x * 10);`,
+	"main.js": `import twice, { addSecret, PI, Point as P, inc } from './lib/math.js';
+import * as ns from './lib/math.js';
+import list, { pi, all, E } from './lib/index.js';
+[twice(4), addSecret(1), PI, new P(3).double, inc(), ns.E, list[2], pi, all.PI, E].join(',')`,
+	"async.js": `import { later } from './lib/math.js';
+await later()`,
+	"scope.js": `import { addSecret } from './lib/math.js';
+R = '';
+try { R = secret } catch (e) { R = 'hidden' }
+R`,
+	"default.js": `import value from './value.js';
+value`,
+	"value.js":    `export default 5;`,
+	"noexport.js": `import { nothing } from './lib/math.js';`,
+	"missing.js":  `import { x } from './lib/absent.js';`,
+	"cycle1.js":   `import { b } from './cycle2.js'; export const a = 1;`,
+	"cycle2.js":   `import { a } from './cycle1.js'; export const b = 1;`,
+	"twice.js":    `export const a = 1; export { a };`,
+}
+
+func writeModuleFile(dir string, name string, content string) {
+	fileName := filepath.Join(dir, name)
+	err := os.MkdirAll(filepath.Dir(fileName), 0755)
+	if err == nil {
+		err = ioutil.WriteFile(fileName, []byte(content), 0644)
+	}
+	if err != nil {
+		log.Panicf("Cannot write %s: %v", fileName, err)
+	}
+}
+
+func proveModules() {
+	dir, err := ioutil.TempDir("", "modules")
+	if err != nil {
+		log.Panicf("Cannot create temporary folder %v", err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range moduleFiles {
+		writeModuleFile(dir, name, content)
+	}
+	testScriptFile(filepath.Join(dir, "main.js"), "8,11,3,16,2,2,30,3,3,2")
+	testScriptFile(filepath.Join(dir, "async.js"), "11")
+	testScriptFile(filepath.Join(dir, "scope.js"), "hidden")
+	testScriptFile(filepath.Join(dir, "default.js"), "5")
+	writeModuleFile(dir, "value.js", "export default 'changed';")
+	later := time.Now().Add(time.Second)
+	os.Chtimes(filepath.Join(dir, "value.js"), later, later)
+	testScriptFile(filepath.Join(dir, "default.js"), "changed")
+	checkScriptFileErrorPref(filepath.Join(dir, "noexport.js"), "Uncaught SyntaxError: The requested module './lib/math.js' does not provide an export named 'nothing'")
+	checkScriptFileErrorPref(filepath.Join(dir, "missing.js"), "Uncaught Error: Cannot find module './lib/absent.js'")
+	checkScriptFileErrorPref(filepath.Join(dir, "cycle1.js"), "Uncaught Error: Circular import of module './cycle1.js'")
+	checkScriptFileErrorPref(filepath.Join(dir, "twice.js"), "Uncaught SyntaxError: Duplicate export of 'a'")
+	checkErrorPref("export const a = 1", "Uncaught SyntaxError: Cannot use export statement outside a module")
+	checkErrorPref("import { a } from './a.js'", "Uncaught SyntaxError: Cannot use import statement outside a module")
+	checkErrorPref("import { a } './a.js'", "Expected from but found")
+}
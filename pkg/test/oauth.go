@@ -0,0 +1,167 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvdbmanager"
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvsecurity"
+	"golang.org/x/crypto/argon2"
+)
+
+func oauthRequest(security *dvcontext.SecurityServerInfo, handler dvcontext.HandlerFunc, form string, clientId string, secret string) (int, map[string]interface{}) {
+	reader := httptest.NewRequest("POST", "/token", strings.NewReader(form))
+	reader.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if clientId != "" {
+		reader.SetBasicAuth(clientId, secret)
+	}
+	ctx := &dvcontext.RequestContext{
+		Id:                        dvcontext.GetUniqueId(),
+		PrimaryContextEnvironment: dvevaluation.NewObjectWithPrototype(map[string]interface{}{}, env),
+		Reader:                    reader,
+		Writer:                    httptest.NewRecorder(),
+		Url:                       "/token",
+		Server:                    &dvcontext.MicroCoreInfo{SecurityInfo: security},
+	}
+	handler(ctx)
+	res := make(map[string]interface{})
+	json.Unmarshal(ctx.Output, &res)
+	return ctx.StatusCode, res
+}
+
+func oauthToken(security *dvcontext.SecurityServerInfo, form string, clientId string, secret string) (int, map[string]interface{}) {
+	return oauthRequest(security, dvsecurity.LoginByRequestEndPointHandler, form, clientId, secret)
+}
+
+func argon2TestHash(password string) string {
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte(password), salt, 1, 1024, 1, 32)
+	return "$argon2id$v=19$m=1024,t=1,p=1$" + base64.RawStdEncoding.EncodeToString(salt) + "$" + base64.RawStdEncoding.EncodeToString(key)
+}
+
+func prepareOAuthTables(extraTables ...string) string {
+	dir, err := os.MkdirTemp("", "oauth")
+	if err != nil {
+		return ""
+	}
+	aliceHash, _ := dvsecurity.HashPassword("alice-pass")
+	serviceHash, _ := dvsecurity.HashPassword("service-secret")
+	users, _ := json.Marshal([]map[string]interface{}{
+		{"id": "u1", "username": "alice", "password": aliceHash, "email": "alice@example.com", "roles": []string{"admin"}, "tenant_id": "t1"},
+		{"id": "u2", "username": "bob", "password": aliceHash, "disabled": true},
+		{"id": "u3", "username": "carol", "password": argon2TestHash("carol-pass"), "roles": "user,viewer"},
+	})
+	clients, _ := json.Marshal([]map[string]interface{}{
+		{"client_id": "web", "grants": "password,refresh_token", "scope": "profile email"},
+		{"client_id": "service", "secret": serviceHash, "grants": []string{"client_credentials"}, "roles": []string{"service"}},
+	})
+	os.WriteFile(filepath.Join(dir, "oauth_users.json"), users, 0644)
+	os.WriteFile(filepath.Join(dir, "oauth_clients.json"), clients, 0644)
+	tables := []*dvcontext.DatabaseTable{
+		{Name: "oauth_users", Kind: dvdbmanager.KindFile},
+		{Name: "oauth_clients", Kind: dvdbmanager.KindFile},
+		{Name: "oauth_refresh_tokens", Kind: dvdbmanager.KindFile},
+	}
+	for _, name := range extraTables {
+		tables = append(tables, &dvcontext.DatabaseTable{Name: name, Kind: dvdbmanager.KindFile})
+	}
+	dvdbmanager.DbManagerInit([]*dvcontext.DatabaseConfig{{Name: "oauth", Root: dir, Tables: tables}})
+	return dir
+}
+
+func proveOAuth() {
+	dir := prepareOAuthTables()
+	checkCondition("oauth tables are created", dir != "")
+	if dir == "" {
+		return
+	}
+	defer os.RemoveAll(dir)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+	security := &dvcontext.SecurityServerInfo{OAuth: &dvcontext.OAuthServerInfo{
+		Audience:   "account",
+		SigningKey: &dvcontext.JwtKeyInfo{Kid: "oauth", PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))},
+	}}
+	verifier, err := dvsecurity.GetJwtVerifier(security)
+	checkCondition("verifier of oauth tokens is created", err == nil)
+	if err != nil {
+		return
+	}
+
+	status, res := oauthToken(security, "grant_type=password&username=alice&password=alice-pass", "web", "")
+	accessToken, _ := res["access_token"].(string)
+	refreshToken, _ := res["refresh_token"].(string)
+	checkCondition("password grant issues tokens", status == 0 && accessToken != "" && refreshToken != "" && res["scope"] == "profile email")
+	payload, err := verifier.Verify(accessToken)
+	claims := make(map[string]interface{})
+	json.Unmarshal([]byte(payload), &claims)
+	checkCondition("access token has claims of the user", err == nil && claims["sub"] == "u1" && claims["preferred_username"] == "alice" &&
+		claims["tenant-id"] == "t1" && claims["aud"] == "account" && claims["azp"] == "web" &&
+		strings.Contains(dvevaluation.AnyToString(claims["realm_access"]), "admin"))
+	_, err = verifier.Verify(refreshToken)
+	checkCondition("refresh token is not accepted as access token", err != nil)
+
+	status, res = oauthToken(security, "grant_type=password&username=alice&password=wrong", "web", "")
+	checkCondition("wrong password is rejected", status == 400 && res["error"] == "invalid_grant")
+	status, res = oauthToken(security, "grant_type=password&username=nobody&password=alice-pass", "web", "")
+	checkCondition("unknown user is rejected", status == 400 && res["error"] == "invalid_grant")
+	status, res = oauthToken(security, "grant_type=password&username=bob&password=alice-pass", "web", "")
+	checkCondition("disabled user is rejected", status == 400 && res["error"] == "invalid_grant")
+	status, res = oauthToken(security, "grant_type=password&username=carol&password=carol-pass&scope=email", "web", "")
+	checkCondition("argon2 password is accepted", status == 0 && res["scope"] == "email")
+	status, res = oauthToken(security, "grant_type=password&username=carol&password=carol-pass&scope=admin", "web", "")
+	checkCondition("not allowed scope is rejected", status == 400 && res["error"] == "invalid_scope")
+	status, res = oauthToken(security, "grant_type=password&username=alice&password=alice-pass", "mobile", "")
+	checkCondition("unknown client is rejected", status == 401 && res["error"] == "invalid_client")
+	status, res = oauthToken(security, "grant_type=implicit", "web", "")
+	checkCondition("unsupported grant is rejected", status == 400 && res["error"] == "unsupported_grant_type")
+
+	status, res = oauthToken(security, "grant_type=client_credentials", "service", "service-secret")
+	checkCondition("client credentials grant issues access token only", status == 0 && res["access_token"] != nil && res["refresh_token"] == nil)
+	payload, err = verifier.Verify(dvevaluation.AnyToString(res["access_token"]))
+	checkCondition("client token has roles of the client", err == nil && strings.Contains(payload, "\"service\""))
+	status, res = oauthToken(security, "grant_type=client_credentials", "service", "wrong")
+	checkCondition("wrong client secret is rejected", status == 401 && res["error"] == "invalid_client")
+	status, res = oauthToken(security, "grant_type=client_credentials", "web", "")
+	checkCondition("public client cannot use client credentials", status == 400 && res["error"] == "unauthorized_client")
+	status, res = oauthToken(security, "grant_type=password&username=alice&password=alice-pass", "service", "service-secret")
+	checkCondition("client cannot use not allowed grant", status == 400 && res["error"] == "unauthorized_client")
+
+	status, res = oauthToken(security, "grant_type=refresh_token&refresh_token="+refreshToken, "web", "")
+	rotatedToken, _ := res["refresh_token"].(string)
+	checkCondition("refresh token is rotated", status == 0 && rotatedToken != "" && rotatedToken != refreshToken && res["access_token"] != nil)
+	status, res = oauthToken(security, "grant_type=refresh_token&refresh_token="+refreshToken, "web", "")
+	checkCondition("used refresh token is rejected", status == 400 && res["error"] == "invalid_grant")
+	status, _ = oauthToken(security, "grant_type=refresh_token&refresh_token="+rotatedToken, "web", "")
+	checkCondition("reuse of refresh token revokes its session", status == 400)
+
+	_, res = oauthToken(security, "grant_type=password&username=alice&password=alice-pass", "web", "")
+	refreshToken, _ = res["refresh_token"].(string)
+	status, _ = oauthRequest(security, dvsecurity.RevokeByRequestEndPointHandler, "token="+refreshToken, "web", "")
+	checkCondition("refresh token is revoked", status == 200)
+	status, res = oauthToken(security, "grant_type=refresh_token&refresh_token="+refreshToken, "web", "")
+	checkCondition("revoked refresh token is rejected", status == 400 && res["error"] == "invalid_grant")
+	status, _ = oauthRequest(security, dvsecurity.RevokeByRequestEndPointHandler, "token="+refreshToken, "service", "service-secret")
+	checkCondition("client without refresh token grant can call revocation", status == 200)
+	status, res = oauthRequest(security, dvsecurity.RevokeByRequestEndPointHandler, "token="+refreshToken, "service", "wrong")
+	checkCondition("revocation requires client authentication", status == 401 && res["error"] == "invalid_client")
+
+	status, res = oauthToken(nil, "grant_type=password&username=alice&password=alice-pass", "web", "")
+	checkCondition("token endpoint without oauth settings fails", status == 500 && res["error"] == "server_error")
+}
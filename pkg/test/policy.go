@@ -0,0 +1,132 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvmodules"
+	"github.com/Dobryvechir/microcore/pkg/dvsecurity"
+	"github.com/Dobryvechir/microcore/pkg/dvtextutils"
+)
+
+var policyProbeExecuted bool
+
+func policyRequest(server *dvcontext.MicroCoreInfo, policy string, method string, token string, params map[string]string, queries map[string]string) (bool, int) {
+	ctx := &dvcontext.RequestContext{
+		Id:                        dvcontext.GetUniqueId(),
+		PrimaryContextEnvironment: dvevaluation.NewObjectWithPrototype(map[string]interface{}{}, env),
+		Reader:                    httptest.NewRequest(method, "/orders", nil),
+		Writer:                    httptest.NewRecorder(),
+		Url:                       "/orders",
+		Server:                    server,
+		UrlInlineParams:           params,
+		Queries:                   queries,
+	}
+	if token != "" {
+		ctx.Reader.Header.Set("Authorization", "Bearer "+token)
+	}
+	policyProbeExecuted = false
+	dvmodules.FireAction(&dvcontext.DvAction{Name: "orders", Typ: "policy_test_probe", Policy: policy}, ctx)
+	return policyProbeExecuted, ctx.StatusCode
+}
+
+func proveActionPolicies() {
+	dvmodules.RegisterActionProcessor("policy_test_probe", func(ctx *dvcontext.RequestContext) bool {
+		policyProbeExecuted = true
+		return true
+	}, true)
+	security := &dvcontext.SecurityServerInfo{OAuth: &dvcontext.OAuthServerInfo{SigningKey: &dvcontext.JwtKeyInfo{Secret: "policy-test-secret"}}}
+	signer, err := dvsecurity.NewJwtSigner(security.OAuth.SigningKey)
+	checkCondition("policy test signer is created", err == nil)
+	if err != nil {
+		return
+	}
+	token := func(name string, tenant string, department string, roles ...string) string {
+		s, _ := signer.Sign(map[string]interface{}{
+			"iss": "microcore", "exp": time.Now().Unix() + 300, "sub": name, "preferred_username": name,
+			"tenant-id": tenant, "department": department, "realm_access": map[string]interface{}{"roles": roles},
+		})
+		return s
+	}
+	editor := token("ed", "t1", "it", "editor")
+	viewer := token("vi", "t1", "sales", "viewer")
+	guest := token("gu", "t2", "none", "viewer")
+	server := &dvcontext.MicroCoreInfo{SecurityInfo: security, ActionPolicies: map[string]*dvcontext.ActionPolicy{
+		"orders": {Rules: []*dvcontext.PolicyRule{
+			{Effect: "deny", Params: map[string]string{"id": "0,system"}},
+			{Effect: "allow", Roles: []string{"editor", "admin"}, Methods: []string{"get", "post"}},
+			{Effect: "allow", Roles: []string{"viewer"}, Methods: []string{"GET"}, Tenants: []string{"t1"}},
+			{Effect: "allow", Claims: map[string]string{"department": "sales"}, Condition: "DRAFT == 'yes'"},
+			{Effect: "deny", Methods: []string{"DELETE"}},
+			{Effect: "allow", Roles: []string{"editor"}},
+		}},
+		"public":  {Default: "allow", Rules: []*dvcontext.PolicyRule{{Effect: "deny", Methods: []string{"DELETE"}}}},
+		"dry_run": {DryRun: true, Rules: []*dvcontext.PolicyRule{{Effect: "deny"}}},
+		"logging": {LogFirstTimes: 5},
+	}}
+
+	ok, _ := policyRequest(server, "orders", "GET", editor, nil, nil)
+	checkCondition("policy allows role and method", ok)
+	ok, status := policyRequest(server, "orders", "GET", editor, map[string]string{"id": "system"}, nil)
+	checkCondition("deny rule wins over allow rule", !ok && status == 403)
+	ok, _ = policyRequest(server, "orders", "GET", viewer, nil, map[string]string{"id": "5"})
+	checkCondition("policy allows role of tenant", ok)
+	ok, _ = policyRequest(server, "orders", "GET", guest, nil, nil)
+	checkCondition("policy denies role of other tenant", !ok)
+	ok, _ = policyRequest(server, "orders", "POST", viewer, nil, nil)
+	checkCondition("policy denies not allowed method", !ok)
+	ok, _ = policyRequest(server, "orders", "POST", viewer, nil, map[string]string{"draft": "yes"})
+	checkCondition("policy denies claim without condition", !ok)
+	env.Set("DRAFT", "yes")
+	ok, _ = policyRequest(server, "orders", "POST", viewer, nil, nil)
+	checkCondition("policy allows claim with condition", ok)
+	env.Set("DRAFT", "")
+	ok, _ = policyRequest(server, "orders", "DELETE", editor, nil, nil)
+	checkCondition("later deny rule wins over earlier allow rule", !ok)
+	ok, status = policyRequest(server, "orders", "GET", "", nil, nil)
+	checkCondition("policy denies anonymous request with 401", !ok && status == 401)
+	ok, _ = policyRequest(server, "public", "GET", "", nil, nil)
+	checkCondition("default allow policy allows anonymous request", ok)
+	ok, _ = policyRequest(server, "public", "DELETE", editor, nil, nil)
+	checkCondition("default allow policy applies deny rules", !ok)
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	ok, _ = policyRequest(server, "dry_run", "GET", guest, nil, nil)
+	log.SetOutput(os.Stderr)
+	checkCondition("dry run policy does not enforce denial", ok)
+	checkCondition("dry run policy logs its decision", strings.Contains(logged.String(), "Policy dry_run (dry run): GET /orders is denied by rule 1") &&
+		!strings.Contains(logged.String(), "EXTRA"))
+	ok, status = policyRequest(server, "logging", "GET", editor, nil, nil)
+	checkCondition("policy without rules denies by default", !ok && status == 403)
+	ok, status = policyRequest(server, "unknown", "GET", editor, nil, nil)
+	checkCondition("unknown policy denies", !ok && status == 403)
+	ok, status = policyRequest(server, "orders", "GET", editor+"x", nil, nil)
+	checkCondition("invalid token is rejected before policy", !ok && status == 401)
+
+	handler := dvmodules.DynamicRegisterEndPointActions([]*dvcontext.DvAction{{Name: "dynamic", Typ: "policy_test_probe", Url: "/dynamic/orders"}}, true)
+	dvmodules.AddDynamicAction(&dvcontext.DvAction{Name: "dynamic", Typ: "policy_test_probe", Url: "/dynamic/orders", Policy: "orders"}, env)
+	ctx := &dvcontext.RequestContext{
+		Id:                        dvcontext.GetUniqueId(),
+		PrimaryContextEnvironment: dvevaluation.NewObjectWithPrototype(map[string]interface{}{}, env),
+		Reader:                    httptest.NewRequest("GET", "/dynamic/orders", nil),
+		Writer:                    httptest.NewRecorder(),
+		Url:                       "/dynamic/orders",
+		Urls:                      dvtextutils.ConvertURLToList("/dynamic/orders"),
+		Server:                    server,
+	}
+	ctx.Reader.Header.Set("Authorization", "Bearer "+guest)
+	policyProbeExecuted = false
+	handler(ctx)
+	checkCondition("redefined dynamic action keeps its new policy", !policyProbeExecuted && ctx.StatusCode == 403)
+}
@@ -5,8 +5,39 @@ Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@
 
 package main
 
+import (
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
+)
+
 func proveErrors() {
 	checkErrorPref("if x=5 {x=7}","Expected ( but found x")
 	checkErrorPref("for x=5 {x=7}","Expected ( but found x")
 	checkErrorPref("if (x=5) x=7 else x=3","'else' should be used only inside 'if' declaration at else")
+	checkErrorPref("throw new Error('boom')","Uncaught Error: boom")
+	checkErrorPref("try {x=1} x=2","Expected catch or finally but found x")
+	checkErrorPref("do {x=1} x=2","Expected while but found x")
+	checkErrorPref("const c = 1; c = 2","Uncaught TypeError: Assignment to constant variable.")
+	checkErrorPref("c = d; let d = 1","Uncaught ReferenceError: Cannot access 'd' before initialization")
+	checkErrorPref("let a = 1; let a = 2","SyntaxError: Identifier 'a' has already been declared")
+	checkErrorPref("class K {}; K()","TypeError: Class constructor K cannot be invoked without 'new'")
+	checkErrorPref("x = 5; new x()","Uncaught TypeError: 5 is not a constructor")
+	checkErrorPref("let {a} = null","Uncaught TypeError: Cannot destructure 'null' as it is null or undefined.")
+	checkErrorPref("let [a] = 5","Uncaught TypeError: 5 is not iterable")
+	checkErrorPref("const [a]","Uncaught SyntaxError: Missing initializer in destructuring declaration")
+	checkErrorPref("function f(a, ...b, c) {}","Rest parameter must be last formal parameter")
+	checkErrorPref("for (const x of 5) {}","Uncaught TypeError: 5 is not iterable")
+	checkErrorPref("for (const x of {next() { return 1 }}) {}","Uncaught TypeError: Iterator result 1 is not an object")
+	checkErrorPref("new WeakMap().set(1, 2)","TypeError: Invalid value used as weak map key")
+	checkErrorPref("new Map([1])","Uncaught TypeError: Iterator value 1 is not an entry object")
+	checkErrorPref("new Promise(5)","Uncaught TypeError: Promise resolver 5 is not a function")
+	checkErrorPref("await Promise.reject(new Error('late'))","Uncaught Error: late")
+	checkErrorPref("Promise.reject(new Error('lost'))","Uncaught (in promise) Error: lost")
+	checkErrorPref("setTimeout(() => { throw new Error('tick') }, 1)","Uncaught Error: tick")
+	checkErrorPref("function f(n) { return f(n + 1) }; f(0)","Uncaught RangeError: Maximum call stack size exceeded")
+	checkErrorPrefWithLimits("let i = 0; while (true) { i++ }","Uncaught RangeError: Script exceeded the limit of 1000 executed steps", &dvgrammar.ExecutionLimits{MaxSteps: 1000})
+	checkErrorPrefWithLimits("while (true) { try { while (true) {} } catch (e) {} }","Uncaught RangeError: Script exceeded the limit of 1000 executed steps", &dvgrammar.ExecutionLimits{MaxSteps: 1000})
+	checkErrorPrefWithLimits("while (true) {}","Uncaught RangeError: Script execution timed out after 50 ms", &dvgrammar.ExecutionLimits{Timeout: 50})
+	checkErrorPrefWithLimits("setInterval(() => {}, 10)","Uncaught RangeError: Script execution timed out after 50 ms", &dvgrammar.ExecutionLimits{Timeout: 50})
+	checkErrorPrefWithLimits("let a = []; while (true) { a.push(1) }","Uncaught RangeError: Script exceeded the limit of 100 allocated variables", &dvgrammar.ExecutionLimits{MaxVariables: 100})
+	checkErrorPrefWithLimits("function f(n) { if (n > 0) return f(n - 1); return 0 }; f(20)","Uncaught RangeError: Maximum call stack size exceeded", &dvgrammar.ExecutionLimits{MaxDepth: 10})
 }
@@ -0,0 +1,62 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvgrammar"
+	"strconv"
+	"sync"
+)
+
+func checkCondition(name string, ok bool) {
+	tested++
+	if !ok {
+		fmt.Printf("Condition failed: %s\n", name)
+		return
+	}
+	successful++
+}
+
+func proveScriptCache() {
+	cache := dvgrammar.CompiledScripts
+	before := cache.Stats()
+	testEvaluationSingle("i=5", "i<10 ? (i<7? 'a': 'b') : 'c'", "a", KindANY)
+	testEvaluationSingle("i=8", "i<10 ? (i<7? 'a': 'b') : 'c'", "b", KindANY)
+	testEvaluationSingle("i=12", "i<10 ? (i<7? 'a': 'b') : 'c'", "c", KindANY)
+	after := cache.Stats()
+	checkCondition("compiled expression is reused", after.Hits-before.Hits >= 2 && after.Misses-before.Misses <= 1)
+
+	cache.SetCapacity(2)
+	testEvaluationSingle("", "1 + 1", "2", KindInteger)
+	testEvaluationSingle("", "1 + 2", "3", KindInteger)
+	testEvaluationSingle("", "1 + 3", "4", KindInteger)
+	stats := cache.Stats()
+	checkCondition("cache is bounded", stats.Size == 2 && stats.Evictions > after.Evictions)
+	cache.SetCapacity(dvgrammar.DefaultCompiledCacheCapacity)
+
+	results := make([]string, 16)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			local := dvevaluation.NewObjectWithPrototype(map[string]interface{}{"N": n}, env)
+			res, err := local.EvaluateAnyTypeExpression("let s = 0; for (let k = 0; k <= N; k++) { s += k % 2 == 0 ? k : 0 } s")
+			if err != nil {
+				results[n] = err.Error()
+			} else {
+				results[n] = dvevaluation.AnyToString(res)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i, res := range results {
+		expected := (i / 2) * (i/2 + 1)
+		checkCondition("concurrent evaluation "+strconv.Itoa(i)+" gives "+res, res == strconv.Itoa(expected))
+	}
+}
@@ -0,0 +1,33 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package main
+
+import (
+	"fmt"
+	"github.com/Dobryvechir/microcore/pkg/dvdbdata"
+)
+
+func testSqlBinding(query string, sqlType int, params []interface{}, named map[string]interface{}, expected string, expectedArgs string) {
+	res, args, err := dvdbdata.BindSqlParameters(query, sqlType, params, named)
+	if err != nil {
+		res = "error: " + err.Error()
+	}
+	checkCondition(fmt.Sprintf("sql %s is bound as %s %v", query, res, args), res == expected && fmt.Sprint(args) == expectedArgs)
+}
+
+func proveSqlParams() {
+	params := []interface{}{int64(7), "O'Neil"}
+	named := map[string]interface{}{"name": "Ann", "age": int64(30)}
+	testSqlBinding("SELECT * FROM t WHERE id = ? AND name = ?", dvdbdata.SqlPostgresLike, params, nil, "SELECT * FROM t WHERE id = $1 AND name = $2", "[7 O'Neil]")
+	testSqlBinding("SELECT * FROM t WHERE id = ? AND name = ?", dvdbdata.SqlOracleLike, params, nil, "SELECT * FROM t WHERE id = :1 AND name = :2", "[7 O'Neil]")
+	testSqlBinding("SELECT * FROM t WHERE id = ? AND name = ?", 0, params, nil, "SELECT * FROM t WHERE id = ? AND name = ?", "[7 O'Neil]")
+	testSqlBinding("UPDATE t SET name = $2 WHERE id = $1", dvdbdata.SqlOracleLike, params, nil, "UPDATE t SET name = :1 WHERE id = :2", "[O'Neil 7]")
+	testSqlBinding("SELECT id::text FROM t WHERE name = :name AND age > :age", dvdbdata.SqlPostgresLike, nil, named, "SELECT id::text FROM t WHERE name = $1 AND age > $2", "[Ann 30]")
+	testSqlBinding("SELECT ':name?', \"a?\" FROM t -- :age ?\nWHERE x = :age /* ? */", 0, nil, named, "SELECT ':name?', \"a?\" FROM t -- :age ?\nWHERE x = ? /* ? */", "[30]")
+	testSqlBinding("SELECT * FROM t WHERE data ?? 'a' AND data ??| array['b'] AND data ??& array['c'] AND id = ?", dvdbdata.SqlPostgresLike, params, nil, "SELECT * FROM t WHERE data ? 'a' AND data ?| array['b'] AND data ?& array['c'] AND id = $1", "[7]")
+	testSqlBinding("SELECT * FROM t WHERE id = :id", 0, nil, named, "error: Parameter :id is not specified", "[]")
+	testSqlBinding("SELECT * FROM t WHERE id = ? OR id = ? OR id = ?", 0, params, nil, "error: Not enough parameters for the placeholders of SELECT * FROM t WHERE id = ? OR id = ? OR id = ?", "[]")
+}
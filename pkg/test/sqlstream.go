@@ -0,0 +1,34 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package main
+
+import (
+	"net/http/httptest"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvcsv"
+)
+
+func proveSqlStream() {
+	row := string(dvcsv.AppendCsvRow(nil, []string{"id", "", `say "hi"`}, dvcsv.CsvSeparatorComma))
+	checkCondition("csv row is quoted", row == "\"id\",,\"say \"\"hi\"\"\"\r\n")
+	row = string(dvcsv.AppendCsvRow(nil, []string{"a", "b"}, 0))
+	checkCondition("csv row uses the default separator", row == "\"a\";\"b\"\r\n")
+
+	recorder := httptest.NewRecorder()
+	ctx := &dvcontext.RequestContext{
+		Reader: httptest.NewRequest("GET", "/export", nil),
+		Writer: recorder,
+		Url:    "/export",
+		Server: &dvcontext.MicroCoreInfo{},
+	}
+	checkCondition("streaming is started", ctx.StartStreaming("text/csv; charset=utf-8"))
+	recorder.Write([]byte(row))
+	checkCondition("streaming is not started twice", !ctx.StartStreaming("text/csv; charset=utf-8"))
+	ctx.Output = []byte("result")
+	ctx.HandleCommunication()
+	checkCondition("streamed response is not overwritten", recorder.Body.String() == row && recorder.Header().Get("Content-Type") == "text/csv; charset=utf-8")
+}
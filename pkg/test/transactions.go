@@ -0,0 +1,51 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package main
+
+import (
+	"github.com/Dobryvechir/microcore/pkg/dvaction"
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+)
+
+type testTransaction struct {
+	state string
+}
+
+func (tx *testTransaction) Commit() error {
+	tx.state = "committed"
+	return nil
+}
+
+func (tx *testTransaction) Rollback() error {
+	tx.state = "rolled back"
+	return nil
+}
+
+func proveTransactions() {
+	ctx := &dvcontext.RequestContext{Url: "/tx"}
+	first := &testTransaction{}
+	second := &testTransaction{}
+	checkCondition("transaction is begun", ctx.BeginTransaction("order", first) == nil)
+	checkCondition("transaction name is not reused", ctx.BeginTransaction("order", second) != nil)
+	checkCondition("second transaction is begun", ctx.BeginTransaction("lines", second) == nil)
+	checkCondition("transaction is committed", ctx.CommitTransaction("order") == nil && first.state == "committed")
+	checkCondition("committed transaction is unbound", ctx.CommitTransaction("order") != nil)
+	ctx.CleanUpRequest()
+	checkCondition("open transaction is rolled back at the end of the request", second.state == "rolled back" && len(ctx.Transactions) == 0)
+
+	properties := map[string]interface{}{
+		"ACTION_txfail_1": "wrong:step",
+	}
+	ctx = &dvcontext.RequestContext{
+		Id:                        dvcontext.GetUniqueId(),
+		PrimaryContextEnvironment: dvevaluation.NewObjectWithPrototype(properties, env),
+	}
+	tx := &testTransaction{}
+	ctx.BeginTransaction("order", tx)
+	res := dvaction.ExecuteSequence("ACTION_txfail", ctx, nil)
+	checkCondition("transaction is rolled back when the sequence fails", !res && tx.state == "rolled back")
+}
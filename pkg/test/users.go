@@ -0,0 +1,142 @@
+/***********************************************************************
+MicroCore
+Copyright 2020 - 2022 by Danyil Dobryvechir (dobrivecher@yahoo.com ddobryvechir@gmail.com)
+************************************************************************/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"strings"
+
+	"github.com/Dobryvechir/microcore/pkg/dvcontext"
+	"github.com/Dobryvechir/microcore/pkg/dvevaluation"
+	"github.com/Dobryvechir/microcore/pkg/dvmodules"
+	"github.com/Dobryvechir/microcore/pkg/dvuser"
+)
+
+type userTestSession map[string]interface{}
+
+func (s userTestSession) SetItem(key string, value interface{}) { s[key] = value }
+func (s userTestSession) GetItem(key string) interface{}        { return s[key] }
+func (s userTestSession) RemoveItem(key string)                 { delete(s, key) }
+func (s userTestSession) Clear()                                {}
+func (s userTestSession) Keys() []string                        { return nil }
+func (s userTestSession) Values() map[string]interface{}        { return s }
+func (s userTestSession) GetId() string                         { return "test" }
+
+func userRequestContext(security *dvcontext.SecurityServerInfo, body string, user *dvcontext.UserInfo, session dvcontext.RequestSession) *dvcontext.RequestContext {
+	reader := httptest.NewRequest("POST", "/user", strings.NewReader(body))
+	reader.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return &dvcontext.RequestContext{
+		Id:                        dvcontext.GetUniqueId(),
+		PrimaryContextEnvironment: dvevaluation.NewObjectWithPrototype(map[string]interface{}{}, env),
+		Reader:                    reader,
+		Writer:                    httptest.NewRecorder(),
+		Url:                       "/user",
+		Server:                    &dvcontext.MicroCoreInfo{SecurityInfo: security},
+		Session:                   session,
+		User:                      user,
+	}
+}
+
+func userRequest(security *dvcontext.SecurityServerInfo, handler dvcontext.HandlerFunc, body string, user *dvcontext.UserInfo) (bool, int, map[string]interface{}, *dvcontext.RequestContext) {
+	ctx := userRequestContext(security, body, user, nil)
+	ok := handler(ctx)
+	res := make(map[string]interface{})
+	json.Unmarshal(ctx.Output, &res)
+	return ok, ctx.StatusCode, res, ctx
+}
+
+func proveUsers() {
+	dir := prepareOAuthTables("user_reset_tokens")
+	checkCondition("user tables are created", dir != "")
+	if dir == "" {
+		return
+	}
+	defer os.RemoveAll(dir)
+	security := &dvcontext.SecurityServerInfo{
+		OAuth: &dvcontext.OAuthServerInfo{SigningKey: &dvcontext.JwtKeyInfo{Secret: "user-test-secret"}},
+		Users: &dvcontext.UserManagementInfo{DefaultRoles: []string{"user"}, DefaultTenant: "t0"},
+	}
+
+	ok, status, res, _ := userRequest(security, dvuser.RegisterByRequestEndPointHandler, "username=dave&password=dave-pass&email=dave@example.com", nil)
+	checkCondition("user is registered", ok && status == 201 && res["name"] == "dave" && res["tenant_id"] == "t0" &&
+		strings.Contains(dvevaluation.AnyToString(res["roles"]), "user"))
+	ok, status, _, _ = userRequest(security, dvuser.RegisterByRequestEndPointHandler, "username=dave&password=other-pass", nil)
+	checkCondition("duplicate user is rejected", !ok && status == 409)
+	ok, status, _, _ = userRequest(security, dvuser.RegisterByRequestEndPointHandler, "username=alice&password=alice-pass2", nil)
+	checkCondition("existing oauth user is not registered again", !ok && status == 409)
+	ok, status, _, _ = userRequest(security, dvuser.RegisterByRequestEndPointHandler, "username=erin&password=short", nil)
+	checkCondition("short password is rejected", !ok && status == 400)
+	ok, status, _, _ = userRequest(security, dvuser.RegisterByRequestEndPointHandler, "username=e+rin&password=erin-pass", nil)
+	checkCondition("username with space is rejected", !ok && status == 400)
+	ok, status, res, _ = userRequest(security, dvuser.RegisterByRequestEndPointHandler, `{"username":"erin","password":"erin-pass"}`,
+		&dvcontext.UserInfo{Registered: true, Name: "alice", TenantId: "t1"})
+	checkCondition("user is registered in the tenant of the registering user", ok && status == 201 && res["tenant_id"] == "t1")
+
+	status, res = oauthToken(security, "grant_type=password&username=dave&password=dave-pass", "web", "")
+	accessToken, _ := res["access_token"].(string)
+	checkCondition("registered user gets oauth token", status == 0 && accessToken != "")
+
+	var probeUser map[string]interface{}
+	dvmodules.RegisterActionProcessor("user_test_probe", func(ctx *dvcontext.RequestContext) bool {
+		v, _ := ctx.PrimaryContextEnvironment.Get(dvcontext.UserEnvironmentKey)
+		probeUser, _ = v.(map[string]interface{})
+		return true
+	}, true)
+	ctx := userRequestContext(security, "", nil, nil)
+	ctx.Reader.Header.Set("Authorization", "Bearer "+accessToken)
+	dvmodules.FireAction(&dvcontext.DvAction{Name: "probe", Typ: "user_test_probe", Auth: "optional"}, ctx)
+	checkCondition("USER is set by the token", ctx.User != nil && ctx.User.Name == "dave" && ctx.User.Source == dvcontext.UserSourceToken &&
+		probeUser != nil && probeUser["name"] == "dave" && probeUser["tenant_id"] == "t0")
+	ctx = userRequestContext(security, "", nil, nil)
+	dvmodules.FireAction(&dvcontext.DvAction{Name: "probe", Typ: "user_test_probe"}, ctx)
+	checkCondition("USER is anonymous without token", ctx.User != nil && !ctx.User.Registered && probeUser != nil && probeUser["registered"] == false)
+	daveUser := dvuser.GetCurrentUserInfo(userRequestContext(security, "", &dvcontext.UserInfo{Registered: true, Name: "dave"}, nil))
+
+	ok, status, _, _ = userRequest(security, dvuser.PasswordByRequestEndPointHandler, "password=wrong-pass&new_password=dave-pass2", &daveUser)
+	checkCondition("password is not changed with wrong password", !ok && status == 401)
+	ok, status, _, _ = userRequest(security, dvuser.PasswordByRequestEndPointHandler, "password=dave-pass&new_password=dave-pass2", nil)
+	checkCondition("password of anonymous user is not changed", !ok && status == 401)
+	ok, status, _, _ = userRequest(security, dvuser.PasswordByRequestEndPointHandler, "password=dave-pass&new_password=dave-pass2", &daveUser)
+	checkCondition("password is changed", ok && status == 200)
+	status, _ = oauthToken(security, "grant_type=password&username=dave&password=dave-pass", "web", "")
+	checkCondition("old password is rejected after change", status == 400)
+	status, _ = oauthToken(security, "grant_type=password&username=dave&password=dave-pass2", "web", "")
+	checkCondition("new password is accepted after change", status == 0)
+
+	ok, status, _, ctx = userRequest(security, dvuser.ResetRequestByRequestEndPointHandler, "username=dave", nil)
+	token := ctx.PrimaryContextEnvironment.GetString(dvuser.UserResetToken)
+	checkCondition("reset token is created for the steps only", ok && status == 202 && len(token) == 64 &&
+		!strings.Contains(string(ctx.Output), token) && ctx.PrimaryContextEnvironment.GetString(dvuser.UserResetEmail) == "dave@example.com")
+	ok, status, _, _ = userRequest(security, dvuser.ResetRequestByRequestEndPointHandler, "username=nobody", nil)
+	checkCondition("reset request of unknown user looks the same", !ok && status == 202)
+	ok, status, _, _ = userRequest(security, dvuser.ResetByRequestEndPointHandler, "token=wrong&new_password=dave-pass3", nil)
+	checkCondition("wrong reset token is rejected", !ok && status == 400)
+	ok, status, _, _ = userRequest(security, dvuser.ResetByRequestEndPointHandler, "token="+token+"&new_password=dave-pass3", nil)
+	checkCondition("password is reset", ok && status == 200)
+	ok, status, _, _ = userRequest(security, dvuser.ResetByRequestEndPointHandler, "token="+token+"&new_password=dave-pass4", nil)
+	checkCondition("reset token is used only once", !ok && status == 400)
+	status, _ = oauthToken(security, "grant_type=password&username=dave&password=dave-pass3", "web", "")
+	checkCondition("reset password is accepted", status == 0)
+
+	session := userTestSession{}
+	ctx = userRequestContext(security, "username=dave&password=dave-pass3", nil, session)
+	ok = dvuser.LoginByRequestEndPointHandler(ctx)
+	sessionUser := dvcontext.ReadSessionUser(session)
+	checkCondition("user is logged in the session", ok && ctx.StatusCode == 200 && sessionUser != nil && sessionUser.Name == "dave")
+	ctx = userRequestContext(security, "", nil, session)
+	ctx.SetUser(dvcontext.ReadSessionUser(ctx.Session))
+	checkCondition("USER is set by the session", ctx.User.Registered && ctx.User.Source == dvcontext.UserSourceSession &&
+		dvuser.GetSourceInfo(ctx, []string{"name", "tenant_id"}, nil)["tenant_id"] == "t0")
+	dvuser.LogoutByRequestEndPointHandler(ctx)
+	checkCondition("user is logged out of the session", dvcontext.ReadSessionUser(session) == nil && !ctx.User.Registered)
+	ctx = userRequestContext(security, "username=dave&password=dave-pass", nil, session)
+	checkCondition("login with wrong password fails", !dvuser.LoginByRequestEndPointHandler(ctx) && ctx.StatusCode == 401)
+
+	_, err := dvuser.GetUserManager(&dvcontext.SecurityServerInfo{})
+	checkCondition("users without settings are not managed", err != nil)
+}